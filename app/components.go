@@ -1,38 +1,128 @@
 package app
 
 import (
+	"io"
+
 	"github.com/lloydmeta/todddo-openapi/internal/api/controllers"
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/events"
 	"github.com/lloydmeta/todddo-openapi/internal/domain/services"
+	"github.com/lloydmeta/todddo-openapi/internal/infra/eventsourced"
 	"github.com/lloydmeta/todddo-openapi/internal/infra/inmem"
+	"github.com/lloydmeta/todddo-openapi/internal/infra/sqlite"
+)
+
+const (
+	// eventFeedCapacity is how many of the latest events are held on to
+	// for those following them to catch up on
+	eventFeedCapacity = 1000
+	// eventStreamBuffer is how many events those following them can fall
+	// behind by before they get cut off
+	eventStreamBuffer = 100
 )
 
 type Components struct {
 	Controllers Controllers
 	Services    Services
 	Repos       Repos
+	// Events is where the changes made to Todos get published; subscribe
+	// to it to react to them
+	Events events.Bus
+	// trashPurger purges the trash in the background, until Close
+	trashPurger *services.TrashPurger
 }
 
-// MkDefaultComponents returns default components
-func MkDefaultComponents() Components {
-	repoComponents := Repos{TodoRepo: inmem.MkRepo()}
-	serviceComponents := Services{TodoService: services.MkTodoService(repoComponents.TodoRepo)}
-	controllerComponents := Controllers{TodoController: controllers.MkTodosController(serviceComponents.TodoService)}
+// MkDefaultComponents returns default components, using the storage
+// picked in the given Config
+func MkDefaultComponents(config Config) (Components, error) {
+	if err := config.validate(); err != nil {
+		return Components{}, err
+	}
+	todoRepo, todoListRepo, err := mkRepos(&config)
+	if err != nil {
+		return Components{}, err
+	}
+	repoComponents := Repos{TodoRepo: todoRepo, TodoListRepo: todoListRepo}
+	bus := events.MkBus()
+	todoService := services.MkTodoService(repoComponents.TodoRepo, repoComponents.TodoListRepo, config.TrashRetention, bus)
+	serviceComponents := Services{
+		TodoService:     todoService,
+		TodoListService: services.MkTodoListService(repoComponents.TodoListRepo, todoService),
+	}
+	controllerComponents := Controllers{
+		TodoController:      controllers.MkTodosController(serviceComponents.TodoService),
+		TodoListController:  controllers.MkTodoListsController(serviceComponents.TodoListService),
+		TodoEventController: controllers.MkTodoEventController(events.MkFeed(bus, eventFeedCapacity), eventStreamBuffer),
+	}
+	trashPurger := services.MkTrashPurger(repoComponents.TodoRepo, config.TrashRetention)
+	if config.TrashRetention > 0 && config.TrashPurgeInterval > 0 {
+		trashPurger.Start(config.TrashPurgeInterval)
+	}
 	return Components{
 		Controllers: controllerComponents,
 		Services:    serviceComponents,
 		Repos:       repoComponents,
+		Events:      bus,
+		trashPurger: trashPurger,
+	}, nil
+}
+
+// Close stops what the components do in the background, then releases
+// what the repos hold, once nothing is served with them any more
+func (c *Components) Close() error {
+	if c.trashPurger != nil {
+		c.trashPurger.Stop()
+	}
+	c.Events.Close()
+	if closer, ok := c.Repos.TodoRepo.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func mkRepos(config *Config) (domain.TodoRepo, domain.TodoListRepo, error) {
+	switch config.Storage {
+	case SqliteStorage:
+		return sqlite.OpenRepos(config.SqlitePath)
+	case JournaledInMemStorage:
+		return inmem.MkFileRepos(config.JournalDir, config.JournalCompactEvery)
+	case EventSourcedStorage:
+		return mkEventSourcedRepos(config)
+	default:
+		todoRepo, todoListRepo := inmem.MkRepos()
+		return todoRepo, todoListRepo, nil
+	}
+}
+
+func mkEventSourcedRepos(config *Config) (domain.TodoRepo, domain.TodoListRepo, error) {
+	store, err := eventsourced.OpenFileStore(config.EventsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.ReplayUntil == nil {
+		todoRepo, todoListRepo, err := eventsourced.MkRepos(store, config.SnapshotEvery)
+		if err != nil {
+			_ = store.(io.Closer).Close()
+		}
+		return todoRepo, todoListRepo, err
 	}
+	// What gets replayed is kept in memory, so the files are no longer needed
+	defer store.(io.Closer).Close()
+	return eventsourced.Replay(store, *config.ReplayUntil)
 }
 
 type Controllers struct {
-	TodoController controllers.TodoController
+	TodoController      controllers.TodoController
+	TodoListController  controllers.TodoListController
+	TodoEventController controllers.TodoEventController
 }
 
 type Services struct {
-	TodoService services.TodoService
+	TodoService     services.TodoService
+	TodoListService services.TodoListService
 }
 
 type Repos struct {
-	TodoRepo domain.TodoRepo
+	TodoRepo     domain.TodoRepo
+	TodoListRepo domain.TodoListRepo
 }
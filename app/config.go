@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Storage names a domain.TodoRepo implementation
+type Storage string
+
+const (
+	// InMemStorage keeps todos in memory; they are lost on restart
+	InMemStorage Storage = "inmem"
+	// JournaledInMemStorage keeps todos in memory, but also journals
+	// changes to disk so they can be restored on restart
+	JournaledInMemStorage Storage = "inmem-journal"
+	// SqliteStorage keeps todos in a SQLite database file
+	SqliteStorage Storage = "sqlite"
+	// EventSourcedStorage keeps todos as a projection of the events that
+	// changed them, which are appended to files on disk
+	EventSourcedStorage Storage = "eventsourced"
+)
+
+// Config holds the settings used for building Components
+type Config struct {
+	Storage             Storage
+	SqlitePath          string
+	JournalDir          string
+	JournalCompactEvery int
+	EventsDir           string
+	SnapshotEvery       int
+	// ReplayUntil, when set, has the eventsourced storage serve todos as
+	// they were at that time, keeping any changes in memory only
+	ReplayUntil *time.Time
+	// TrashRetention is how long deleted todos are kept in the trash for;
+	// forever when zero
+	TrashRetention time.Duration
+	// TrashPurgeInterval is how often the trash gets rid of the todos it
+	// no longer keeps; only when it is looked at when zero
+	TrashPurgeInterval time.Duration
+}
+
+// DefaultConfig returns a Config that keeps everything in memory
+func DefaultConfig() Config {
+	return Config{
+		Storage:             InMemStorage,
+		SqlitePath:          "todddo.db",
+		JournalDir:          "todddo-journal",
+		JournalCompactEvery: 1000,
+		EventsDir:           "todddo-events",
+		SnapshotEvery:       1000,
+		TrashRetention:      30 * 24 * time.Hour,
+		TrashPurgeInterval:  time.Hour,
+	}
+}
+
+// ConfigFromEnv returns DefaultConfig, overridden by any of the following
+// environment variables that are set:
+//
+//	TODDDO_STORAGE                one of "inmem", "inmem-journal", "sqlite" or "eventsourced"
+//	TODDDO_SQLITE_PATH            path to the SQLite database file
+//	TODDDO_JOURNAL_DIR            directory holding the inmem-journal files
+//	TODDDO_JOURNAL_COMPACT_EVERY  number of changes between journal compactions
+//	TODDDO_EVENTS_DIR             directory holding the eventsourced files
+//	TODDDO_SNAPSHOT_EVERY         number of events between eventsourced snapshots
+//	TODDDO_REPLAY_UNTIL           RFC 3339 time to replay eventsourced todos up to
+//	TODDDO_TRASH_RETENTION        how long deleted todos are kept, e.g. "72h"; "0" keeps them
+//	TODDDO_TRASH_PURGE_INTERVAL   how often expired todos are purged, e.g. "10m"; "0" only when the trash is used
+func ConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	if storage, ok := os.LookupEnv("TODDDO_STORAGE"); ok {
+		config.Storage = Storage(storage)
+	}
+	if path, ok := os.LookupEnv("TODDDO_SQLITE_PATH"); ok {
+		config.SqlitePath = path
+	}
+	if dir, ok := os.LookupEnv("TODDDO_JOURNAL_DIR"); ok {
+		config.JournalDir = dir
+	}
+	if every, ok := os.LookupEnv("TODDDO_JOURNAL_COMPACT_EVERY"); ok {
+		parsed, err := strconv.Atoi(every)
+		if err != nil {
+			return config, fmt.Errorf("invalid TODDDO_JOURNAL_COMPACT_EVERY: [%s]", every)
+		}
+		config.JournalCompactEvery = parsed
+	}
+	if dir, ok := os.LookupEnv("TODDDO_EVENTS_DIR"); ok {
+		config.EventsDir = dir
+	}
+	if every, ok := os.LookupEnv("TODDDO_SNAPSHOT_EVERY"); ok {
+		parsed, err := strconv.Atoi(every)
+		if err != nil {
+			return config, fmt.Errorf("invalid TODDDO_SNAPSHOT_EVERY: [%s]", every)
+		}
+		config.SnapshotEvery = parsed
+	}
+	if until, ok := os.LookupEnv("TODDDO_REPLAY_UNTIL"); ok {
+		parsed, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return config, fmt.Errorf("invalid TODDDO_REPLAY_UNTIL: [%s]", until)
+		}
+		config.ReplayUntil = &parsed
+	}
+	if retention, ok := os.LookupEnv("TODDDO_TRASH_RETENTION"); ok {
+		parsed, err := time.ParseDuration(retention)
+		if err != nil {
+			return config, fmt.Errorf("invalid TODDDO_TRASH_RETENTION: [%s]", retention)
+		}
+		config.TrashRetention = parsed
+	}
+	if interval, ok := os.LookupEnv("TODDDO_TRASH_PURGE_INTERVAL"); ok {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil {
+			return config, fmt.Errorf("invalid TODDDO_TRASH_PURGE_INTERVAL: [%s]", interval)
+		}
+		config.TrashPurgeInterval = parsed
+	}
+	return config, config.validate()
+}
+
+func (c *Config) validate() error {
+	switch c.Storage {
+	case InMemStorage, JournaledInMemStorage, SqliteStorage, EventSourcedStorage:
+	default:
+		return fmt.Errorf("unknown storage: [%s]", c.Storage)
+	}
+	if c.JournalCompactEvery < 0 {
+		return fmt.Errorf("journal compaction interval cannot be negative: [%d]", c.JournalCompactEvery)
+	}
+	if c.SnapshotEvery < 0 {
+		return fmt.Errorf("snapshot interval cannot be negative: [%d]", c.SnapshotEvery)
+	}
+	if c.ReplayUntil != nil && c.Storage != EventSourcedStorage {
+		return fmt.Errorf("only eventsourced storage can be replayed, not: [%s]", c.Storage)
+	}
+	if c.TrashRetention < 0 {
+		return fmt.Errorf("trash retention cannot be negative: [%v]", c.TrashRetention)
+	}
+	if c.TrashPurgeInterval < 0 {
+		return fmt.Errorf("trash purge interval cannot be negative: [%v]", c.TrashPurgeInterval)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package routing
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	actorHeader = "X-Actor"
+	// anonymousActor is who makes the changes of requests without an actor
+	anonymousActor = "anonymous"
+	// maxActorLength is how many characters of an actor are kept
+	maxActorLength = 64
+)
+
+// requestActor returns who the request says it is made by, as given in the
+// X-Actor header, without control characters and cut down to size.
+//
+// There is no authentication to speak of, so it is taken at its word: it is
+// only meant to tell people apart in the history of Todos.
+func requestActor(c *gin.Context) string {
+	actor := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, c.GetHeader(actorHeader)))
+	if runes := []rune(actor); len(runes) > maxActorLength {
+		actor = strings.TrimSpace(string(runes[:maxActorLength]))
+	}
+	if len(actor) == 0 {
+		return anonymousActor
+	}
+	return actor
+}
@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+const (
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+// etagFor returns the (strong) entity tag of a Todo at the given version
+func etagFor(version domain.TodoVersion) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// setEtag sends the entity tag of the given Todo along with the response
+func setEtag(c *gin.Context, todo *models.Todo) {
+	c.Header(etagHeader, etagFor(todo.Version))
+}
+
+// ifMatchVersion returns the version of the Todo that the request's If-Match
+// header requires, where zero means any version will do.
+//
+// Only a single strong entity tag, or `*`, is supported. If the header can't
+// be used, a response is sent and false is returned.
+func ifMatchVersion(c *gin.Context) (domain.TodoVersion, bool) {
+	header := strings.TrimSpace(c.GetHeader(ifMatchHeader))
+	if len(header) == 0 || header == "*" {
+		return 0, true
+	}
+	tags := splitEtags(header)
+	if len(tags) != 1 {
+		detail := fmt.Sprintf("%s must be a single entity tag: [%s]", ifMatchHeader, header)
+		respondWithProblem(c, models.MkError(models.InvalidRequestProblem, http.StatusBadRequest, detail))
+		return 0, false
+	}
+	tag := tags[0]
+	opaqueTag := strings.TrimPrefix(tag, "W/")
+	if len(opaqueTag) < 2 || !strings.HasPrefix(opaqueTag, `"`) || !strings.HasSuffix(opaqueTag, `"`) {
+		detail := fmt.Sprintf("Invalid entity tag in %s: [%s]", ifMatchHeader, header)
+		respondWithProblem(c, models.MkError(models.InvalidRequestProblem, http.StatusBadRequest, detail))
+		return 0, false
+	}
+	// Weak tags never match strongly, and neither do tags we didn't hand out
+	weak := opaqueTag != tag
+	if version, err := strconv.ParseUint(opaqueTag[1:len(opaqueTag)-1], 10, 64); err == nil && version > 0 && !weak {
+		return domain.TodoVersion(version), true
+	} else {
+		detail := fmt.Sprintf("%s does not match: [%s]", ifMatchHeader, header)
+		respondWithProblem(c, models.MkError(models.VersionConflictProblem, http.StatusPreconditionFailed, detail))
+		return 0, false
+	}
+}
+
+// noneMatch returns whether the request's If-None-Match header lets a
+// response with the given entity tag through, comparing tags weakly
+func noneMatch(c *gin.Context, etag string) bool {
+	header := strings.TrimSpace(c.GetHeader(ifNoneMatchHeader))
+	if header == "*" {
+		return false
+	}
+	for _, tag := range splitEtags(header) {
+		if strings.TrimPrefix(tag, "W/") == etag {
+			return false
+		}
+	}
+	return true
+}
+
+// splitEtags splits a comma-separated list of entity tags
+func splitEtags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if trimmed := strings.TrimSpace(tag); len(trimmed) > 0 {
+			tags = append(tags, trimmed)
+		}
+	}
+	return tags
+}
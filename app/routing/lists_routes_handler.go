@@ -0,0 +1,158 @@
+package routing
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lloydmeta/todddo-openapi/internal/api/controllers"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// ListsRoutesHandler serves the lists that Todos can be put on; the Todos on
+// a list are served by TodosRoutesHandler
+type ListsRoutesHandler struct {
+	Controller controllers.TodoListController
+}
+
+// RegisterRoutes takes the given gin.Engine reference and adds the
+// routes that it knows how to take care of
+func (h *ListsRoutesHandler) RegisterRoutes(ginEngine *gin.Engine) {
+	ginEngine.POST("/lists", h.create)
+	ginEngine.GET("/lists", h.list)
+	ginEngine.GET("/lists/:id", h.get)
+	ginEngine.PUT("/lists/:id", h.update)
+	ginEngine.DELETE("/lists/:id", h.delete)
+}
+
+// @Summary Add a new list
+// @ID create-list
+// @Description Creates a new list for Todos to be put on, e.g. for a project
+// @Accept  json
+// @Produce  json
+// @Param   list body models.ListData true "The request body"
+// @Success 201 {object} models.List
+// @Failure 400 {object} models.Error "Name cannot be empty"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /lists [post]
+func (h *ListsRoutesHandler) create(c *gin.Context) {
+	var apiListData models.ListData
+	if err := c.ShouldBindJSON(&apiListData); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	if list, err := h.Controller.Create(&apiListData); err == nil {
+		c.JSON(http.StatusCreated, list)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+// @Summary List existing lists
+// @ID list-existing-lists
+// @Description Retrieves all persisted lists, in order of id
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.Lists
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /lists [get]
+func (h *ListsRoutesHandler) list(c *gin.Context) {
+	if lists, err := h.Controller.List(); err == nil {
+		c.JSON(http.StatusOK, lists)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+// @Summary Get a list by id
+// @ID get-existing-list
+// @Description Retrieves a persisted list
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the list you want to retrieve"
+// @Success 200 {object} models.List
+// @Failure 404 {object} models.Error "List does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /lists/{id} [get]
+func (h *ListsRoutesHandler) get(c *gin.Context) {
+	var idPathParam listIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	id := idPathParam.ID()
+	if list, err := h.Controller.Get(&id); err == nil {
+		c.JSON(http.StatusOK, list)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+// @Summary Rename an existing list
+// @ID update-list
+// @Description Updates the name of an existing list
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the list you want to update"
+// @Param   list body models.ListData true "The request body"
+// @Success 200 {object} models.List
+// @Failure 400 {object} models.Error "Name cannot be empty"
+// @Failure 404 {object} models.Error "List does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /lists/{id} [put]
+func (h *ListsRoutesHandler) update(c *gin.Context) {
+	var idPathParam listIdPathParam
+	var apiListData models.ListData
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else if err := c.ShouldBindJSON(&apiListData); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	id := idPathParam.ID()
+	if list, err := h.Controller.Update(&id, &apiListData); err == nil {
+		c.JSON(http.StatusOK, list)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+// @Summary Delete an existing list
+// @ID delete-list
+// @Description Deletes an existing list. Lists that still have Todos on them are only deleted when asked to delete those too.
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the list you want to delete"
+// @Param   todos query string false "Whether to refuse deleting a list with Todos on it, the default, or delete them too" Enums(refuse, cascade)
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Success
+// @Failure 404 {object} models.Error "List does not exist"
+// @Failure 409 {object} models.Error "List still has Todos on it"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /lists/{id} [delete]
+func (h *ListsRoutesHandler) delete(c *gin.Context) {
+	var idPathParam listIdPathParam
+	var query models.DeleteListQuery
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else if err := c.ShouldBindQuery(&query); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	id := idPathParam.ID()
+	if success, err := h.Controller.AsActor(requestActor(c)).Delete(&id, &query); err == nil {
+		c.JSON(http.StatusOK, success)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+type listIdPathParam struct {
+	UintId uint `uri:"id" binding:"required"`
+}
+
+func (l *listIdPathParam) ID() domain.TodoListID {
+	return domain.TodoListID(l.UintId)
+}
@@ -0,0 +1,140 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lloydmeta/todddo-openapi/internal/api/controllers"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupListsRouter() (*gin.Engine, *mockTodoListController) {
+	engine := gin.Default()
+	mockController := mockTodoListController{}
+	handler := ListsRoutesHandler{Controller: &mockController}
+	handler.RegisterRoutes(engine)
+	return engine, &mockController
+}
+
+func TestPostListsOk(t *testing.T) {
+	router, mockController := setupListsRouter()
+	mockController.create = func(newList *models.ListData) (models.List, models.ApiError) {
+		return models.List{ID: 1, Name: newList.Name}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/lists", models.ListData{Name: "Chores"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.JSONEq(t, `{"id":1,"name":"Chores"}`, resp.Body.String())
+}
+
+func TestPostListsWithoutName(t *testing.T) {
+	router, mockController := setupListsRouter()
+	resp := performRequest(router, http.MethodPost, "/lists", map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.createCalled)
+}
+
+func TestGetListsOk(t *testing.T) {
+	router, mockController := setupListsRouter()
+	mockController.list = func() (models.Lists, models.ApiError) {
+		return models.Lists{Lists: []models.List{{ID: 1, Name: "Chores"}, {ID: 2, Name: "Errands"}}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/lists", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"lists":[{"id":1,"name":"Chores"},{"id":2,"name":"Errands"}]}`, resp.Body.String())
+}
+
+func TestGetListNotFound(t *testing.T) {
+	router, mockController := setupListsRouter()
+	mockController.get = func(id *domain.TodoListID) (models.List, models.ApiError) {
+		return models.List{}, mockApiError{code: http.StatusNotFound, message: "no such list"}
+	}
+	resp := performRequest(router, http.MethodGet, "/lists/3", nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, 1, mockController.getCalled)
+}
+
+func TestPutListOk(t *testing.T) {
+	router, mockController := setupListsRouter()
+	var passedId domain.TodoListID
+	mockController.update = func(id *domain.TodoListID, list *models.ListData) (models.List, models.ApiError) {
+		passedId = *id
+		return models.List{ID: *id, Name: list.Name}, nil
+	}
+	resp := performRequest(router, http.MethodPut, "/lists/3", models.ListData{Name: "Errands"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoListID(3), passedId)
+	var updated models.List
+	_ = json.Unmarshal(resp.Body.Bytes(), &updated)
+	assert.Equal(t, "Errands", updated.Name)
+}
+
+func TestDeleteListCascade(t *testing.T) {
+	router, mockController := setupListsRouter()
+	var passedQuery models.DeleteListQuery
+	mockController.delete = func(id *domain.TodoListID, query *models.DeleteListQuery) (models.Success, models.ApiError) {
+		passedQuery = *query
+		return models.Success{Message: "deleted"}, nil
+	}
+	resp := performRequest(router, http.MethodDelete, "/lists/3?todos=cascade", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, models.CascadeToTodos, passedQuery.Todos)
+	assert.Equal(t, []string{"anonymous"}, mockController.actors)
+}
+
+func TestDeleteListInvalidPolicy(t *testing.T) {
+	router, mockController := setupListsRouter()
+	resp := performRequest(router, http.MethodDelete, "/lists/3?todos=abandon", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.deleteCalled)
+}
+
+// Mocks
+
+type mockTodoListController struct {
+	create       func(newList *models.ListData) (models.List, models.ApiError)
+	createCalled int
+	get          func(id *domain.TodoListID) (models.List, models.ApiError)
+	getCalled    int
+	list         func() (models.Lists, models.ApiError)
+	listCalled   int
+	update       func(id *domain.TodoListID, list *models.ListData) (models.List, models.ApiError)
+	updateCalled int
+	delete       func(id *domain.TodoListID, query *models.DeleteListQuery) (models.Success, models.ApiError)
+	deleteCalled int
+	// actors are those AsActor was called with, in order
+	actors []string
+}
+
+func (m *mockTodoListController) Create(newList *models.ListData) (models.List, models.ApiError) {
+	defer func() { m.createCalled++ }()
+	return m.create(newList)
+}
+
+func (m *mockTodoListController) Get(id *domain.TodoListID) (models.List, models.ApiError) {
+	defer func() { m.getCalled++ }()
+	return m.get(id)
+}
+
+func (m *mockTodoListController) List() (models.Lists, models.ApiError) {
+	defer func() { m.listCalled++ }()
+	return m.list()
+}
+
+func (m *mockTodoListController) Update(id *domain.TodoListID, list *models.ListData) (models.List, models.ApiError) {
+	defer func() { m.updateCalled++ }()
+	return m.update(id, list)
+}
+
+func (m *mockTodoListController) Delete(id *domain.TodoListID, query *models.DeleteListQuery) (models.Success, models.ApiError) {
+	defer func() { m.deleteCalled++ }()
+	return m.delete(id, query)
+}
+
+func (m *mockTodoListController) AsActor(actor string) controllers.TodoListController {
+	m.actors = append(m.actors, actor)
+	return m
+}
@@ -0,0 +1,47 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+)
+
+// CustomRoute is a route gin can't register itself, like /tasks:batch
+// alongside the /tasks/:id wildcard, so it has to be served by the handler
+// for requests that match no route instead
+type CustomRoute struct {
+	Method  string
+	Path    string
+	Handler gin.HandlerFunc
+}
+
+// NoRoute returns the one handler that gets installed with gin's NoRoute.
+// It passes requests on to the given custom routes, and answers the others
+// with problem details: a 405 if only their method is wrong, a 404
+// otherwise.
+func NoRoute(routes ...CustomRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var allowed []string
+		for _, route := range routes {
+			if c.Request.URL.Path != route.Path {
+				continue
+			} else if c.Request.Method == route.Method {
+				route.Handler(c)
+				return
+			}
+			allowed = append(allowed, route.Method)
+		}
+		if len(allowed) > 0 {
+			for _, method := range allowed {
+				c.Writer.Header().Add("Allow", method)
+			}
+			respondWithProblem(c, models.MkError(models.GenericProblem, http.StatusMethodNotAllowed,
+				fmt.Sprintf("%s is not allowed on %s", c.Request.Method, c.Request.URL.Path)))
+		} else {
+			respondWithProblem(c, models.MkError(models.GenericProblem, http.StatusNotFound,
+				fmt.Sprintf("There is nothing at %s", c.Request.URL.Path)))
+		}
+	}
+}
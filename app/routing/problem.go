@@ -0,0 +1,30 @@
+package routing
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+)
+
+// respondWithProblem sends the given models.Error as problem details
+// (RFC 7807) about the current request
+func respondWithProblem(c *gin.Context, problem models.Error) {
+	problem.Instance = c.Request.URL.RequestURI()
+	// Rendering JSON keeps a Content-Type that was already set
+	c.Header("Content-Type", models.ProblemContentType)
+	c.JSON(problem.Status, problem)
+}
+
+// respondWithError sends the given models.ApiError as problem details
+func respondWithError(c *gin.Context, err models.ApiError) {
+	problem := err.AsModel()
+	problem.Status = err.HttpStatusCode()
+	respondWithProblem(c, problem)
+}
+
+// respondWithInvalidRequest sends problem details about a request that
+// could not be bound
+func respondWithInvalidRequest(c *gin.Context, err error) {
+	respondWithProblem(c, models.MkError(models.InvalidRequestProblem, http.StatusBadRequest, err.Error()))
+}
@@ -0,0 +1,285 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+	"github.com/lloydmeta/todddo-openapi/internal/api/controllers"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+const (
+	// defaultPing is how often sockets get pinged, to find out about the
+	// ones that went away without saying so
+	defaultPing = 30 * time.Second
+	// socketWriteTimeout is how long writing a message down a socket may take
+	socketWriteTimeout = 10 * time.Second
+	// maxSocketCommandSize is how big a command sent over a socket may be,
+	// in bytes
+	maxSocketCommandSize = 64 * 1024
+	// socketOutboxSize is how many messages a socket can fall behind by
+	// before it gets closed
+	socketOutboxSize = 64
+)
+
+// upgrader turns requests into sockets. Browsers may only open them from
+// pages served from the same origin.
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// SocketRoutesHandler lets clients follow and make changes to Todos over
+// WebSockets
+type SocketRoutesHandler struct {
+	Controller controllers.TodoController
+	Events     controllers.TodoEventController
+	// Ping is how often sockets get pinged; every 30 seconds when zero
+	Ping time.Duration
+}
+
+// RegisterRoutes takes the given gin.Engine reference and adds the
+// routes that it knows how to take care of
+func (h *SocketRoutesHandler) RegisterRoutes(ginEngine *gin.Engine) {
+	ginEngine.GET("/socket", h.socket)
+}
+
+// @Summary Follow and make changes to Todos over a WebSocket
+// @ID todo-socket
+// @Description Upgrades to a WebSocket down which the changes made to Todos from then on are pushed as "event" messages. Commands sent over it as JSON are answered in turn with a "result" message, or an "error" one when they can't be made sense of, with the same ref. Create, update and delete commands are made just like the operations of a batch (POST /tasks:batch) would be, and get the same results. A subscribe command limits the changes pushed to those of the Todos on the given lists and with any of the given tags (or that were, before being updated); subscribing to neither gets all of them again. Sockets that fall too far behind get closed with status 1013, after which they can reconnect and list the Todos again.
+// @Param   X-Actor header string false "Who is making the changes, as recorded in the history of Todos"
+// @Success 101 {object} models.SocketMessage "The messages sent down the socket"
+// @Failure 400 {object} models.Error "Not a WebSocket handshake"
+// @Router /socket [get]
+func (h *SocketRoutesHandler) socket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already answered
+		return
+	}
+	s := &socket{
+		conn:       conn,
+		controller: h.Controller.AsActor(requestActor(c)),
+		stream:     h.Events.Follow(nil),
+		outbox:     make(chan models.SocketMessage, socketOutboxSize),
+		done:       make(chan struct{}),
+	}
+	s.serve(h.ping())
+}
+
+func (h *SocketRoutesHandler) ping() time.Duration {
+	if h.Ping <= 0 {
+		return defaultPing
+	}
+	return h.Ping
+}
+
+// socket is a connection following and making changes to Todos.
+//
+// It reads and answers commands in turn, while the events it subscribed to
+// get pushed, and messages written, on goroutines of their own. Messages
+// wait to be written in a bounded outbox: when it fills up, or the events
+// come faster than they can be pushed, the socket is closed rather than
+// holding up the others.
+type socket struct {
+	conn       *websocket.Conn
+	controller controllers.TodoController
+	stream     controllers.TodoEventStream
+	// outbox holds the messages yet to be written
+	outbox chan models.SocketMessage
+	// done is closed once the socket gets closed
+	done    chan struct{}
+	closing sync.Once
+
+	mutex  sync.Mutex
+	filter socketFilter
+}
+
+// serve runs the socket until it gets closed, by either end
+func (s *socket) serve(ping time.Duration) {
+	var running sync.WaitGroup
+	running.Add(2)
+	go func() {
+		defer running.Done()
+		s.write(ping)
+	}()
+	go func() {
+		defer running.Done()
+		s.push()
+	}()
+	s.read(ping)
+	s.close(websocket.CloseNormalClosure, "")
+	running.Wait()
+}
+
+// close closes the socket with the given status, unless it already is
+func (s *socket) close(code int, reason string) {
+	s.closing.Do(func() {
+		close(s.done)
+		s.stream.Cancel()
+		message := websocket.FormatCloseMessage(code, reason)
+		_ = s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(socketWriteTimeout))
+		_ = s.conn.Close()
+	})
+}
+
+// read reads and answers the commands sent over the socket until it gets
+// closed, or stops answering pings
+func (s *socket) read(ping time.Duration) {
+	s.conn.SetReadLimit(maxSocketCommandSize)
+	extendDeadline := func() {
+		_ = s.conn.SetReadDeadline(time.Now().Add(2 * ping))
+	}
+	extendDeadline()
+	s.conn.SetPongHandler(func(string) error {
+		extendDeadline()
+		return nil
+	})
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		extendDeadline()
+		s.handle(data)
+	}
+}
+
+func (s *socket) handle(data []byte) {
+	var command models.SocketCommand
+	if err := json.Unmarshal(data, &command); err != nil {
+		s.reject("", err)
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&command); err != nil {
+		s.reject(command.Ref, err)
+		return
+	}
+	if command.Op == models.SubscribeCommand {
+		s.subscribe(&command)
+		s.send(models.SocketMessage{Type: models.ResultMessage, Ref: command.Ref, Result: &models.BatchResult{Status: http.StatusOK}})
+		return
+	}
+	batch := models.BatchData{Operations: []models.BatchOperation{{
+		Op:       command.Op,
+		ID:       command.ID,
+		Version:  command.Version,
+		Todo:     command.Todo,
+		Children: command.Children,
+	}}}
+	if results, err := s.controller.Batch(&batch); err == nil {
+		s.send(models.SocketMessage{Type: models.ResultMessage, Ref: command.Ref, Result: &results.Results[0]})
+	} else {
+		problem := err.AsModel()
+		problem.Status = err.HttpStatusCode()
+		s.send(models.SocketMessage{Type: models.ErrorMessage, Ref: command.Ref, Error: &problem})
+	}
+}
+
+// reject answers a command that could not be made sense of
+func (s *socket) reject(ref string, err error) {
+	problem := models.MkError(models.InvalidRequestProblem, http.StatusBadRequest, err.Error())
+	s.send(models.SocketMessage{Type: models.ErrorMessage, Ref: ref, Error: &problem})
+}
+
+func (s *socket) subscribe(command *models.SocketCommand) {
+	filter := socketFilter{}
+	for _, id := range command.Lists {
+		if filter.lists == nil {
+			filter.lists = make(map[domain.TodoListID]bool)
+		}
+		filter.lists[id] = true
+	}
+	for _, tag := range command.Tags {
+		if filter.tags == nil {
+			filter.tags = make(map[string]bool)
+		}
+		filter.tags[tag] = true
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.filter = filter
+}
+
+// push sends the events the socket subscribed to down it as they come
+func (s *socket) push() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event, ok := <-s.stream.Events:
+			if !ok {
+				s.close(websocket.CloseTryAgainLater, "fell too far behind")
+				return
+			}
+			if s.subscribed(&event) {
+				s.send(models.SocketMessage{Type: models.EventMessage, Event: &event})
+			}
+		}
+	}
+}
+
+func (s *socket) subscribed(event *models.TodoEvent) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.filter.matches(&event.Todo) || (event.Previous != nil && s.filter.matches(event.Previous))
+}
+
+// send queues the given message to be written
+func (s *socket) send(message models.SocketMessage) {
+	select {
+	case s.outbox <- message:
+	default:
+		s.close(websocket.CloseTryAgainLater, "fell too far behind")
+	}
+}
+
+// write writes the queued messages down the socket, pinging it every so
+// often
+func (s *socket) write(ping time.Duration) {
+	pinger := time.NewTicker(ping)
+	defer pinger.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case message := <-s.outbox:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			if err := s.conn.WriteJSON(message); err != nil {
+				s.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-pinger.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout)); err != nil {
+				s.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// socketFilter picks the Todos whose changes a socket subscribed to
+type socketFilter struct {
+	// lists are those the Todos must be on, if any
+	lists map[domain.TodoListID]bool
+	// tags are those the Todos must have one of, if any
+	tags map[string]bool
+}
+
+func (f *socketFilter) matches(todo *models.Todo) bool {
+	if len(f.lists) > 0 && (todo.ListID == nil || !f.lists[*todo.ListID]) {
+		return false
+	}
+	if len(f.tags) == 0 {
+		return true
+	}
+	for _, tag := range todo.Tags {
+		if f.tags[tag] {
+			return true
+		}
+	}
+	return false
+}
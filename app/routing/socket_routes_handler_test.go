@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSocketServer(controller *mockTodoController, events *mockTodoEventController) *httptest.Server {
+	engine := gin.Default()
+	handler := SocketRoutesHandler{Controller: controller, Events: events}
+	handler.RegisterRoutes(engine)
+	return httptest.NewServer(engine)
+}
+
+func dialSocket(t *testing.T, server *httptest.Server, header http.Header) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/socket", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) models.SocketMessage {
+	var message models.SocketMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func todoEvent(id domain.TodoID, listID domain.TodoListID, tags ...string) models.TodoEvent {
+	return models.TodoEvent{ID: "1", Type: "todo.updated", Todo: models.Todo{ID: id, ListID: &listID, Tags: tags}}
+}
+
+func TestSocketPushesEvents(t *testing.T) {
+	events := &mockTodoEventController{feed: make(chan models.TodoEvent)}
+	server := setupSocketServer(&mockTodoController{}, events)
+	defer server.Close()
+	conn := dialSocket(t, server, nil)
+	defer conn.Close()
+
+	events.feed <- todoEvent(1, 1)
+	message := readMessage(t, conn)
+	assert.Equal(t, models.EventMessage, message.Type)
+	if assert.NotNil(t, message.Event) {
+		assert.Equal(t, domain.TodoID(1), message.Event.Todo.ID)
+	}
+
+	_ = conn.WriteJSON(models.SocketCommand{Op: "subscribe", Ref: "a", Lists: []domain.TodoListID{2}})
+	message = readMessage(t, conn)
+	assert.Equal(t, models.SocketMessage{Type: models.ResultMessage, Ref: "a", Result: &models.BatchResult{Status: http.StatusOK}}, message)
+
+	events.feed <- todoEvent(2, 1)
+	events.feed <- todoEvent(3, 2)
+	// moved off a list subscribed to
+	moved, before := todoEvent(4, 3), todoEvent(4, 2)
+	moved.Previous = &before.Todo
+	events.feed <- moved
+	assert.Equal(t, domain.TodoID(3), readMessage(t, conn).Event.Todo.ID)
+	assert.Equal(t, domain.TodoID(4), readMessage(t, conn).Event.Todo.ID)
+}
+
+func TestSocketFiltersByTag(t *testing.T) {
+	events := &mockTodoEventController{feed: make(chan models.TodoEvent)}
+	server := setupSocketServer(&mockTodoController{}, events)
+	defer server.Close()
+	conn := dialSocket(t, server, nil)
+	defer conn.Close()
+
+	_ = conn.WriteJSON(models.SocketCommand{Op: "subscribe", Tags: []string{"home", "garden"}})
+	readMessage(t, conn)
+	events.feed <- todoEvent(1, 1, "errands")
+	events.feed <- todoEvent(2, 1, "errands", "garden")
+	assert.Equal(t, domain.TodoID(2), readMessage(t, conn).Event.Todo.ID)
+}
+
+func TestSocketCommands(t *testing.T) {
+	controller := &mockTodoController{}
+	batches := make(chan *models.BatchData, 1)
+	controller.batch = func(batch *models.BatchData) (models.BatchResults, models.ApiError) {
+		batches <- batch
+		return models.BatchResults{Applied: true, Results: []models.BatchResult{
+			{Status: http.StatusCreated, Todo: &models.Todo{ID: 3, Task: "Buy milk"}},
+		}}, nil
+	}
+	server := setupSocketServer(controller, &mockTodoEventController{open: true})
+	defer server.Close()
+	conn := dialSocket(t, server, http.Header{"X-Actor": []string{"alice"}})
+	defer conn.Close()
+
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"op":"create","ref":"1","todo":{"task":"Buy milk"}}`))
+	message := readMessage(t, conn)
+	assert.Equal(t, models.ResultMessage, message.Type)
+	assert.Equal(t, "1", message.Ref)
+	if assert.NotNil(t, message.Result) {
+		assert.Equal(t, http.StatusCreated, message.Result.Status)
+		assert.Equal(t, domain.TodoID(3), message.Result.Todo.ID)
+	}
+	batch := <-batches
+	assert.Equal(t, []models.BatchOperation{{Op: "create", Todo: &models.TodoData{Task: "Buy milk"}}}, batch.Operations)
+	assert.Equal(t, []string{"alice"}, controller.actors)
+}
+
+func TestSocketRejectsInvalidCommands(t *testing.T) {
+	controller := &mockTodoController{}
+	server := setupSocketServer(controller, &mockTodoEventController{open: true})
+	defer server.Close()
+	conn := dialSocket(t, server, nil)
+	defer conn.Close()
+
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`not json`))
+	message := readMessage(t, conn)
+	assert.Equal(t, models.ErrorMessage, message.Type)
+	if assert.NotNil(t, message.Error) {
+		assert.Equal(t, models.InvalidRequestProblem, message.Error.Type)
+	}
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"op":"explode","ref":"2"}`))
+	message = readMessage(t, conn)
+	assert.Equal(t, models.ErrorMessage, message.Type)
+	assert.Equal(t, "2", message.Ref)
+	// still going
+	_ = conn.WriteJSON(models.SocketCommand{Op: "subscribe", Ref: "3"})
+	assert.Equal(t, "3", readMessage(t, conn).Ref)
+	assert.Equal(t, 0, controller.batchCalled)
+}
+
+func TestSocketClosedWhenFallingBehind(t *testing.T) {
+	server := setupSocketServer(&mockTodoController{}, &mockTodoEventController{})
+	defer server.Close()
+	conn := dialSocket(t, server, nil)
+	defer conn.Close()
+
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), "%v", err)
+}
+
+func TestSocketRequiresHandshake(t *testing.T) {
+	engine := gin.Default()
+	handler := SocketRoutesHandler{Controller: &mockTodoController{}, Events: &mockTodoEventController{}}
+	handler.RegisterRoutes(engine)
+	w := performRequest(engine, "GET", "/socket", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
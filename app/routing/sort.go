@@ -0,0 +1,44 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+)
+
+// parseSort parses a comma-separated list of models.SortFields, each
+// optionally prefixed with - to sort in descending order
+func parseSort(raw string) ([]models.SortKey, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var keys []models.SortKey
+	seen := make(map[string]bool)
+	for _, term := range strings.Split(raw, ",") {
+		key := models.SortKey{Field: strings.TrimSpace(term)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field = key.Field[1:]
+			key.Descending = true
+		}
+		if !isSortField(key.Field) {
+			return nil, fmt.Errorf("Cannot sort on [%s]: sort on %s, prefixed with - for descending order",
+				term, strings.Join(models.SortFields, ", "))
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("Cannot sort on [%s] more than once", key.Field)
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func isSortField(field string) bool {
+	for _, sortField := range models.SortFields {
+		if field == sortField {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	// defaultHeartbeat is how often a comment is sent down event streams
+	// when nothing else is, so that proxies don't take them for dead
+	defaultHeartbeat = 15 * time.Second
+)
+
+// @Summary Follow the changes made to Todos
+// @ID follow-todo-events
+// @Description Streams the changes made to Todos as Server-Sent Events, named after their type and with a TodoEvent as their data. Sending the id of the last event received as the Last-Event-ID header (as EventSource does when reconnecting) resumes from there, as long as the events since are still held on to; when they no longer are, a "reset" event comes first, after which the Todos had better be listed again. Comments are sent every so often as a heartbeat. Clients that fall too far behind get disconnected, and can resume from the last event they received.
+// @Produce text/event-stream
+// @Param   Last-Event-ID header string false "The id of the last event received, to resume from"
+// @Success 200 {object} models.TodoEvent "A stream of events"
+// @Failure 400 {object} models.Error "Invalid Last-Event-ID"
+// @Router /tasks/events [get]
+func (h *TodosRoutesHandler) events(c *gin.Context) {
+	after, err := lastEventID(c)
+	if err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	stream := h.Events.Follow(after)
+	defer stream.Cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// keeps nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if stream.Gap {
+		if _, err := io.WriteString(c.Writer, "event: reset\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+	for i := range stream.Missed {
+		if err := writeEvent(c.Writer, &stream.Missed[i]); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-stream.Events:
+			if !ok {
+				// fallen too far behind, or the server is going away
+				return
+			}
+			if err := writeEvent(c.Writer, &event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func (h *TodosRoutesHandler) heartbeat() time.Duration {
+	if h.Heartbeat <= 0 {
+		return defaultHeartbeat
+	}
+	return h.Heartbeat
+}
+
+// lastEventID returns the id of the last event received, as given in the
+// Last-Event-ID header, if any
+func lastEventID(c *gin.Context) (*uint64, error) {
+	header := c.GetHeader(lastEventIDHeader)
+	if len(header) == 0 {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: [%s]", lastEventIDHeader, header)
+	}
+	return &id, nil
+}
+
+// writeEvent writes the given event as a Server-Sent Event
+func writeEvent(w io.Writer, event *models.TodoEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
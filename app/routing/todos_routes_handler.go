@@ -1,26 +1,63 @@
 package routing
 
 import (
+	"fmt"
 	"github.com/lloydmeta/todddo-openapi/internal/api/controllers"
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lloydmeta/todddo-openapi/internal/api/models"
 )
 
+const mergePatchContentType = "application/merge-patch+json"
+
 type TodosRoutesHandler struct {
 	Controller controllers.TodoController
+	// Events streams the changes made to Todos; GET /tasks/events is only
+	// routed when it is set
+	Events controllers.TodoEventController
+	// Heartbeat is how often event streams get a comment when nothing else
+	// is sent; every 15 seconds when zero
+	Heartbeat time.Duration
 }
 
 // RegisterRoutes takes the given gin.Engine reference and adds the
 // routes that it knows how to take care of
 func (h *TodosRoutesHandler) RegisterRoutes(ginEngine *gin.Engine) {
 	ginEngine.POST("/tasks", h.create)
-	ginEngine.GET("/tasks/:id", h.get)
+	named := map[string]gin.HandlerFunc{"search": h.search, "ready": h.ready}
+	if h.Events != nil {
+		named["events"] = h.events
+	}
+	ginEngine.GET("/tasks/:id", namedOr(named, h.get))
 	ginEngine.GET("/tasks", h.list)
+	ginEngine.GET("/tasks/:id/children", h.listChildren)
 	ginEngine.PUT("/tasks/:id", h.update)
+	ginEngine.PATCH("/tasks/:id", h.patch)
 	ginEngine.DELETE("/tasks/:id", h.delete)
+	ginEngine.POST("/tasks/:id/complete", h.complete)
+	ginEngine.POST("/tasks/:id/reopen", h.reopen)
+	ginEngine.POST("/tasks/:id/tags", h.addTags)
+	ginEngine.DELETE("/tasks/:id/tags/:tag", h.removeTag)
+	ginEngine.POST("/tasks/:id/dependencies", h.addDependencies)
+	ginEngine.DELETE("/tasks/:id/dependencies/:dependency_id", h.removeDependency)
+	ginEngine.POST("/tasks/:id/move", h.move)
+	ginEngine.GET("/tasks/:id/history", h.history)
+	ginEngine.POST("/tasks/:id/revert/:rev", h.revert)
+	ginEngine.GET("/tags", h.listTags)
+	ginEngine.GET("/trash", h.listTrash)
+	ginEngine.POST("/trash/:id/restore", h.restore)
+	ginEngine.DELETE("/trash/:id", h.purge)
+	ginEngine.POST("/lists/:id/tasks", h.createOnList)
+	ginEngine.GET("/lists/:id/tasks", h.listOnList)
+}
+
+// CustomRoutes returns the routes that RegisterRoutes can't add, which have
+// to be passed on to NoRoute
+func (h *TodosRoutesHandler) CustomRoutes() []CustomRoute {
+	return []CustomRoute{{Method: http.MethodPost, Path: "/tasks:batch", Handler: h.batch}}
 }
 
 // @Summary Add a new Todo
@@ -29,59 +66,187 @@ func (h *TodosRoutesHandler) RegisterRoutes(ginEngine *gin.Engine) {
 // @Accept  json
 // @Produce  json
 // @Param   todo body models.TodoData true "The request body"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
 // @Success 200 {object} models.Todo
 // @Failure 400 {object} models.Error "Task cannot be empty"
+// @Failure 500 {object} models.Error "Storage failure"
 // @Router /tasks [post]
 func (h *TodosRoutesHandler) create(c *gin.Context) {
+	h.createOn(c, nil)
+}
+
+// @Summary Add a new Todo to a list
+// @ID create-todo-on-list
+// @Description Creates a new Todo on an existing list, whatever list_id the request body says
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the list to add the Todo to"
+// @Param   todo body models.TodoData true "The request body"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} models.Error "Task cannot be empty, or the list does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /lists/{id}/tasks [post]
+func (h *TodosRoutesHandler) createOnList(c *gin.Context) {
+	var idPathParam listIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	id := idPathParam.ID()
+	h.createOn(c, &id)
+}
+
+// createOn creates a Todo from the body of the request, putting it on the
+// list with the given id if it isn't nil
+func (h *TodosRoutesHandler) createOn(c *gin.Context, listID *domain.TodoListID) {
 	var apiNewTodo models.TodoData
 	if err := c.ShouldBindJSON(&apiNewTodo); err != nil {
-		errResp := models.Error{Message: err.Error()}
-		c.JSON(http.StatusBadRequest, errResp)
+		respondWithInvalidRequest(c, err)
 		return
 	} else {
-		if todo, err := h.Controller.Create(&apiNewTodo); err == nil {
+		if listID != nil {
+			apiNewTodo.ListID = listID
+		}
+		if todo, err := h.Controller.AsActor(requestActor(c)).Create(&apiNewTodo); err == nil {
+			setEtag(c, &todo)
 			c.JSON(http.StatusCreated, todo)
 		} else {
-			c.JSON(err.HttpStatusCode(), err.AsModel())
+			respondWithError(c, err)
 		}
 	}
 }
 
 // @Summary Get a Todo by id
 // @ID get-existing-todo
-// @Description Retrieves a persisted Todo
+// @Description Retrieves a persisted Todo, along with its ETag
 // @Accept  json
 // @Produce  json
 // @Param   id path int true "The id of the todo you want to retrieve"
+// @Param   If-None-Match header string false "ETags of versions of the Todo you already have"
 // @Success 200 {object} models.Todo
+// @Success 304 "The Todo has not changed"
 // @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
 // @Router /tasks/{id} [get]
 func (h *TodosRoutesHandler) get(c *gin.Context) {
 	var idPathParam todoIdPathParam
 	if err := c.ShouldBindUri(&idPathParam); err != nil {
-		errResp := models.Error{Message: err.Error()}
-		c.JSON(http.StatusBadRequest, errResp)
+		respondWithInvalidRequest(c, err)
 		return
 	} else {
 		id := idPathParam.ID()
 		if todo, err := h.Controller.Get(&id); err == nil {
-			c.JSON(http.StatusOK, todo)
+			setEtag(c, &todo)
+			if noneMatch(c, etagFor(todo.Version)) {
+				c.JSON(http.StatusOK, todo)
+			} else {
+				c.Status(http.StatusNotModified)
+			}
 		} else {
-			c.JSON(err.HttpStatusCode(), err.AsModel())
+			respondWithError(c, err)
 		}
 	}
 }
 
-// @Summary List all existing Todos
+// @Summary List existing Todos
 // @ID list-existing-todos
-// @Description Retrieves all persisted Todos
+// @Description Retrieves persisted Todos, a page at a time, in order of id unless sorted otherwise
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} models.Todo
+// @Param   completed query bool false "Only retrieve Todos with this completion status"
+// @Param   due_before query string false "Only retrieve Todos due before this time (RFC 3339)" format(date-time)
+// @Param   due_after query string false "Only retrieve Todos due at or after this time (RFC 3339)" format(date-time)
+// @Param   overdue query bool false "Only retrieve Todos that are (or are not) open and past their due date"
+// @Param   tag query string false "Only retrieve Todos with this tag; repeat to give several"
+// @Param   tag_match query string false "Whether Todos need any of the tags, the default, or all of them" Enums(any, all)
+// @Param   sort query string false "Comma-separated fields to sort on, out of id, priority, due and position; prefix with - for descending order, e.g. -priority,due"
+// @Param   limit query int false "The maximum number of Todos in the page, 100 by default" maximum(1000)
+// @Param   after query string false "The next cursor of the previous page"
+// @Param   tree query bool false "Only retrieve top-level Todos, each with all of its subtasks nested under children"
+// @Success 200 {object} models.TodoPage
+// @Failure 400 {object} models.Error "Invalid query or cursor"
+// @Failure 500 {object} models.Error "Storage failure"
 // @Router /tasks [get]
 func (h *TodosRoutesHandler) list(c *gin.Context) {
-	list := h.Controller.List()
-	c.JSON(http.StatusOK, list)
+	h.listWithin(c, nil, nil)
+}
+
+// @Summary List the subtasks of a Todo
+// @ID list-todo-children
+// @Description Retrieves the Todos that are direct subtasks of an existing Todo, taking the same query parameters as listing all Todos
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo whose subtasks you want to retrieve"
+// @Param   completed query bool false "Only retrieve Todos with this completion status"
+// @Param   tag query string false "Only retrieve Todos with this tag; repeat to give several"
+// @Param   sort query string false "Comma-separated fields to sort on, out of id, priority, due and position; prefix with - for descending order"
+// @Param   limit query int false "The maximum number of Todos in the page, 100 by default" maximum(1000)
+// @Param   after query string false "The next cursor of the previous page"
+// @Param   tree query bool false "Nest all of the subtasks of each Todo under children"
+// @Success 200 {object} models.TodoPage
+// @Failure 400 {object} models.Error "Invalid query or cursor"
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/children [get]
+func (h *TodosRoutesHandler) listChildren(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	id := idPathParam.ID()
+	h.listWithin(c, &id, nil)
+}
+
+// @Summary List the Todos on a list
+// @ID list-todos-on-list
+// @Description Retrieves the Todos on an existing list, taking the same query parameters as listing all Todos
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the list whose Todos you want to retrieve"
+// @Param   completed query bool false "Only retrieve Todos with this completion status"
+// @Param   tag query string false "Only retrieve Todos with this tag; repeat to give several"
+// @Param   sort query string false "Comma-separated fields to sort on, out of id, priority, due and position; prefix with - for descending order"
+// @Param   limit query int false "The maximum number of Todos in the page, 100 by default" maximum(1000)
+// @Param   after query string false "The next cursor of the previous page"
+// @Param   tree query bool false "Only retrieve top-level Todos, each with all of its subtasks nested under children"
+// @Success 200 {object} models.TodoPage
+// @Failure 400 {object} models.Error "Invalid query or cursor"
+// @Failure 404 {object} models.Error "List does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /lists/{id}/tasks [get]
+func (h *TodosRoutesHandler) listOnList(c *gin.Context) {
+	var idPathParam listIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	}
+	id := idPathParam.ID()
+	h.listWithin(c, nil, &id)
+}
+
+// listWithin lists Todos according to the query of the request, only those
+// that are children of the Todo with the given id, or on the list with the
+// given id, if they aren't nil
+func (h *TodosRoutesHandler) listWithin(c *gin.Context, parentID *domain.TodoID, listID *domain.TodoListID) {
+	var query models.TodoQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else if sortKeys, err := parseSort(query.Sort); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		query.SortKeys = sortKeys
+		query.ParentID = parentID
+		query.ListID = listID
+		if list, err := h.Controller.List(&query); err == nil {
+			c.JSON(http.StatusOK, list)
+		} else {
+			respondWithError(c, err)
+		}
+	}
 }
 
 // @Summary Update an existing Todo
@@ -91,57 +256,562 @@ func (h *TodosRoutesHandler) list(c *gin.Context) {
 // @Produce  json
 // @Param   todo body models.TodoData true "The request body"
 // @Param   id path int true "The id of the todo you want to update"
+// @Param   If-Match header string false "Only update if the Todo still has this ETag"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
 // @Success 200 {object} models.Todo
 // @Failure 404 {object} models.Error "Task does not exist"
-// @Failure 400 {object} models.Error "Task cannot be empty"
+// @Failure 400 {object} models.Error "Task cannot be empty, or due date, priority, parent, dependencies, recurrence or list are invalid"
+// @Failure 412 {object} models.Error "Task has changed"
+// @Failure 500 {object} models.Error "Storage failure"
 // @Router /tasks/{id} [put]
 func (h *TodosRoutesHandler) update(c *gin.Context) {
 	var idPathParam todoIdPathParam
 	if err := c.ShouldBindUri(&idPathParam); err != nil {
-		errResp := models.Error{Message: err.Error()}
-		c.JSON(http.StatusBadRequest, errResp)
+		respondWithInvalidRequest(c, err)
 		return
 	} else {
 		var apiTodoData models.TodoData
 		if err := c.ShouldBindJSON(&apiTodoData); err != nil {
-			errResp := models.Error{Message: err.Error()}
-			c.JSON(http.StatusBadRequest, errResp)
+			respondWithInvalidRequest(c, err)
 			return
-		} else {
+		} else if version, ok := ifMatchVersion(c); ok {
 			apiTodo := models.Todo{
-				ID:   idPathParam.ID(),
-				Task: apiTodoData.Task,
+				ID:         idPathParam.ID(),
+				Version:    version,
+				Task:       apiTodoData.Task,
+				Completed:  apiTodoData.Completed,
+				DueAt:      apiTodoData.DueAt,
+				Tags:       apiTodoData.Tags,
+				Priority:   apiTodoData.Priority,
+				ParentID:   apiTodoData.ParentID,
+				DependsOn:  apiTodoData.DependsOn,
+				Recurrence: apiTodoData.Recurrence,
+				ListID:     apiTodoData.ListID,
 			}
-			if todo, err := h.Controller.Update(&apiTodo); err == nil {
+			if todo, err := h.Controller.AsActor(requestActor(c)).Update(&apiTodo); err == nil {
+				setEtag(c, &todo)
 				c.JSON(http.StatusOK, todo)
 			} else {
-				c.JSON(err.HttpStatusCode(), err.AsModel())
+				respondWithError(c, err)
 			}
 		}
 	}
 }
 
+// @Summary Partially update an existing Todo
+// @ID patch-todo
+// @Description Updates only the given fields of an existing Todo, following JSON Merge Patch (RFC 7396)
+// @Accept  application/merge-patch+json
+// @Produce  json
+// @Param   patch body models.TodoData true "The fields to change; null removes a field"
+// @Param   id path int true "The id of the todo you want to update"
+// @Param   If-Match header string false "Only update if the Todo still has this ETag"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 400 {object} models.Error "Invalid patch, or task cannot be empty"
+// @Failure 412 {object} models.Error "Task has changed"
+// @Failure 415 {object} models.Error "Not a merge patch"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id} [patch]
+func (h *TodosRoutesHandler) patch(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else if c.ContentType() != mergePatchContentType {
+		detail := fmt.Sprintf("Content-Type must be [%s]", mergePatchContentType)
+		respondWithProblem(c, models.MkError(models.UnsupportedMediaTypeProblem, http.StatusUnsupportedMediaType, detail))
+		return
+	} else {
+		mergePatch, err := c.GetRawData()
+		if err != nil {
+			respondWithInvalidRequest(c, err)
+			return
+		}
+		version, ok := ifMatchVersion(c)
+		if !ok {
+			return
+		}
+		id := idPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).Patch(&id, version, mergePatch); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
 // @Summary Delete an existing Todo
 // @ID delete-todo
-// @Description Deletes an existing Todo
+// @Description Moves an existing Todo to the trash, where it can be restored from until it gets purged
 // @Accept  json
 // @Produce  json
 // @Param   id path int true "The id of the todo you want to delete"
+// @Param   children query string false "Whether subtasks become top-level Todos, the default, or get deleted too" Enums(orphan, cascade)
+// @Param   If-Match header string false "Only delete if the Todo still has this ETag"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
 // @Success 200 {object} models.Success
 // @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 412 {object} models.Error "Task has changed"
+// @Failure 500 {object} models.Error "Storage failure"
 // @Router /tasks/{id} [delete]
 func (h *TodosRoutesHandler) delete(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	var query models.DeleteQuery
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else if err := c.ShouldBindQuery(&query); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else if version, ok := ifMatchVersion(c); ok {
+		id := idPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).Delete(&id, version, &query); err == nil {
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Complete an existing Todo
+// @ID complete-todo
+// @Description Marks an existing Todo as completed; completing an already-completed Todo does nothing. If it recurs, its next occurrence gets created, skipping any that would already be overdue.
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo you want to complete"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/complete [post]
+func (h *TodosRoutesHandler) complete(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		id := idPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).Complete(&id); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Reopen an existing Todo
+// @ID reopen-todo
+// @Description Marks an existing Todo as not completed; reopening a Todo that is not completed does nothing
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo you want to reopen"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/reopen [post]
+func (h *TodosRoutesHandler) reopen(c *gin.Context) {
 	var idPathParam todoIdPathParam
 	if err := c.ShouldBindUri(&idPathParam); err != nil {
-		errResp := models.Error{Message: err.Error()}
-		c.JSON(http.StatusBadRequest, errResp)
+		respondWithInvalidRequest(c, err)
 		return
 	} else {
 		id := idPathParam.ID()
-		if todo, err := h.Controller.Delete(&id); err == nil {
+		if todo, err := h.Controller.AsActor(requestActor(c)).Reopen(&id); err == nil {
+			setEtag(c, &todo)
 			c.JSON(http.StatusOK, todo)
 		} else {
-			c.JSON(err.HttpStatusCode(), err.AsModel())
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Tag an existing Todo
+// @ID add-todo-tags
+// @Description Adds tags to an existing Todo, keeping the ones it already has
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo you want to tag"
+// @Param   tags body models.TagsData true "The tags to add"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} models.Error "Invalid tags"
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/tags [post]
+func (h *TodosRoutesHandler) addTags(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		var apiTagsData models.TagsData
+		if err := c.ShouldBindJSON(&apiTagsData); err != nil {
+			respondWithInvalidRequest(c, err)
+			return
+		}
+		id := idPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).AddTags(&id, &apiTagsData); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Untag an existing Todo
+// @ID remove-todo-tag
+// @Description Removes a tag from an existing Todo; removing a tag it does not have does nothing
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo you want to untag"
+// @Param   tag path string true "The tag to remove"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/tags/{tag} [delete]
+func (h *TodosRoutesHandler) removeTag(c *gin.Context) {
+	var tagPathParam todoTagPathParam
+	if err := c.ShouldBindUri(&tagPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		id := tagPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).RemoveTag(&id, tagPathParam.Tag); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary List tags
+// @ID list-tags
+// @Description Lists the tags in use, in alphabetical order, along with how many Todos use them
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.TagList
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tags [get]
+func (h *TodosRoutesHandler) listTags(c *gin.Context) {
+	if tags, err := h.Controller.ListTags(); err == nil {
+		c.JSON(http.StatusOK, tags)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+// @Summary Search Todos
+// @ID search-todos
+// @Description Finds the Todos whose tasks have every word searched for, or a word starting with it, ignoring case; the most relevant come first
+// @Accept  json
+// @Produce  json
+// @Param   q query string true "The words to search for"
+// @Param   limit query int false "The maximum number of results, 20 by default" maximum(100)
+// @Success 200 {object} models.SearchResults
+// @Failure 400 {object} models.Error "No words to search for, or too many"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/search [get]
+func (h *TodosRoutesHandler) search(c *gin.Context) {
+	var query models.SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		if results, err := h.Controller.Search(&query); err == nil {
+			c.JSON(http.StatusOK, results)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Make a Todo depend on others
+// @ID add-todo-dependencies
+// @Description Makes an existing Todo depend on other Todos, on top of the ones it already depends on; it is blocked until they are all completed
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo that depends on the others"
+// @Param   dependencies body models.DependenciesData true "The ids of the Todos it depends on"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} models.Error "A dependency does not exist, or would make the Todo depend on itself"
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/dependencies [post]
+func (h *TodosRoutesHandler) addDependencies(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		var apiDependenciesData models.DependenciesData
+		if err := c.ShouldBindJSON(&apiDependenciesData); err != nil {
+			respondWithInvalidRequest(c, err)
+			return
+		}
+		id := idPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).AddDependencies(&id, &apiDependenciesData); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Make a Todo stop depending on another
+// @ID remove-todo-dependency
+// @Description Removes a dependency of an existing Todo; removing one it does not have does nothing
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo that depends on the other"
+// @Param   dependency_id path int true "The id of the todo it should stop depending on"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/dependencies/{dependency_id} [delete]
+func (h *TodosRoutesHandler) removeDependency(c *gin.Context) {
+	var dependencyPathParam todoDependencyPathParam
+	if err := c.ShouldBindUri(&dependencyPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		id, dependencyID := dependencyPathParam.ID(), dependencyPathParam.DependencyID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).RemoveDependency(&id, &dependencyID); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary List Todos that are ready
+// @ID list-ready-todos
+// @Description Lists all open Todos whose dependencies are all completed, in an order that respects dependencies, most urgent first
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.TodoList
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/ready [get]
+func (h *TodosRoutesHandler) ready(c *gin.Context) {
+	if ready, err := h.Controller.Ready(); err == nil {
+		c.JSON(http.StatusOK, ready)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+// @Summary Move a Todo
+// @ID move-todo
+// @Description Moves an existing Todo to just before or just after another, or between two others, in the order Todos are arranged in by hand (see sorting on position); only the moved Todo changes
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo to move"
+// @Param   move body models.MoveData true "Where to move the Todo to"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} models.Error "The Todos to move it next to do not exist, or it can't go between them"
+// @Failure 404 {object} models.Error "Task does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/move [post]
+func (h *TodosRoutesHandler) move(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		var apiMoveData models.MoveData
+		if err := c.ShouldBindJSON(&apiMoveData); err != nil {
+			respondWithInvalidRequest(c, err)
+			return
+		}
+		id := idPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).Move(&id, &apiMoveData); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Get the history of a Todo
+// @ID get-todo-history
+// @Description Lists every change made to a Todo, oldest first, with when it was made, by whom (see the X-Actor header), the fields it changed and what the Todo was like after it. Deleted Todos keep their history, even once purged.
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo whose history you want"
+// @Success 200 {object} models.History
+// @Failure 404 {object} models.Error "Task does not exist, and never did"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/history [get]
+func (h *TodosRoutesHandler) history(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		id := idPathParam.ID()
+		if history, err := h.Controller.History(&id); err == nil {
+			c.JSON(http.StatusOK, history)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Revert a Todo to an earlier revision
+// @ID revert-todo
+// @Description Changes an existing Todo back to what it was as of one of its revisions, which makes a new revision; it keeps its place in the order Todos are arranged in by hand. Its parent, list and dependencies back then must still exist.
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo to revert"
+// @Param   rev path int true "The revision to revert it to"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 400 {object} models.Error "Its parent, list or dependencies back then no longer exist"
+// @Failure 404 {object} models.Error "Task or revision does not exist"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /tasks/{id}/revert/{rev} [post]
+func (h *TodosRoutesHandler) revert(c *gin.Context) {
+	var revisionPathParam todoRevisionPathParam
+	if err := c.ShouldBindUri(&revisionPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		id := revisionPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).Revert(&id, revisionPathParam.Rev); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary List the trash
+// @ID list-trash
+// @Description Lists the deleted Todos that can still be restored, most recently deleted first. They get purged once they have been in the trash for longer than the configured retention.
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} models.Trash
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /trash [get]
+func (h *TodosRoutesHandler) listTrash(c *gin.Context) {
+	if trash, err := h.Controller.ListTrash(); err == nil {
+		c.JSON(http.StatusOK, trash)
+	} else {
+		respondWithError(c, err)
+	}
+}
+
+// @Summary Restore a deleted Todo
+// @ID restore-todo
+// @Description Takes a Todo back out of the trash, as it was when it was deleted, except that it loses its parent, list and dependencies if they have since been deleted too
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo to restore"
+// @Param   X-Actor header string false "Who is making the change, as recorded in the history of Todos"
+// @Success 200 {object} models.Todo
+// @Failure 404 {object} models.Error "Task is not in the trash"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /trash/{id}/restore [post]
+func (h *TodosRoutesHandler) restore(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		id := idPathParam.ID()
+		if todo, err := h.Controller.AsActor(requestActor(c)).Restore(&id); err == nil {
+			setEtag(c, &todo)
+			c.JSON(http.StatusOK, todo)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// @Summary Purge a deleted Todo
+// @ID purge-todo
+// @Description Deletes a Todo in the trash for good
+// @Accept  json
+// @Produce  json
+// @Param   id path int true "The id of the todo to purge"
+// @Success 200 {object} models.Success
+// @Failure 404 {object} models.Error "Task is not in the trash"
+// @Failure 500 {object} models.Error "Storage failure"
+// @Router /trash/{id} [delete]
+func (h *TodosRoutesHandler) purge(c *gin.Context) {
+	var idPathParam todoIdPathParam
+	if err := c.ShouldBindUri(&idPathParam); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		id := idPathParam.ID()
+		if success, err := h.Controller.AsActor(requestActor(c)).Purge(&id); err == nil {
+			c.JSON(http.StatusOK, success)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// batch creates, updates and deletes Todos, up to 1000 at once, in the given
+// order and all together or not at all. Each operation is made exactly as
+// its own request would be, and gets a result in the same order, with the
+// status it would have been answered with. When one fails, none are made: it
+// gets its own error, the others get a batch-aborted one, and the batch as a
+// whole is answered with the status of the one that failed.
+//
+// swag can't parse paths like /tasks:batch, so it is documented by hand in
+// the docs package, which swag init overwrites: put it back after running it
+func (h *TodosRoutesHandler) batch(c *gin.Context) {
+	var apiBatchData models.BatchData
+	if err := c.ShouldBindJSON(&apiBatchData); err != nil {
+		respondWithInvalidRequest(c, err)
+		return
+	} else {
+		if results, err := h.Controller.AsActor(requestActor(c)).Batch(&apiBatchData); err == nil {
+			c.JSON(batchStatus(&results), results)
+		} else {
+			respondWithError(c, err)
+		}
+	}
+}
+
+// batchStatus is the status of the operation that made a batch fail, or OK
+// if it didn't
+func batchStatus(results *models.BatchResults) int {
+	if !results.Applied {
+		for _, result := range results.Results {
+			if result.Error != nil && result.Error.Type != models.BatchAbortedProblem {
+				return result.Status
+			}
+		}
+	}
+	return http.StatusOK
+}
+
+// namedOr returns a handler that passes requests on to the handler named by
+// their :id parameter if there is one, and to byId otherwise; gin can't route
+// static paths like /tasks/search alongside the /tasks/:id wildcard
+func namedOr(named map[string]gin.HandlerFunc, byId gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if handler, ok := named[c.Param("id")]; ok {
+			handler(c)
+		} else {
+			byId(c)
 		}
 	}
 }
@@ -153,3 +823,34 @@ type todoIdPathParam struct {
 func (t *todoIdPathParam) ID() domain.TodoID {
 	return domain.TodoID(t.UintId)
 }
+
+type todoTagPathParam struct {
+	UintId uint   `uri:"id" binding:"required"`
+	Tag    string `uri:"tag" binding:"required"`
+}
+
+func (t *todoTagPathParam) ID() domain.TodoID {
+	return domain.TodoID(t.UintId)
+}
+
+type todoDependencyPathParam struct {
+	UintId           uint `uri:"id" binding:"required"`
+	UintDependencyId uint `uri:"dependency_id" binding:"required"`
+}
+
+func (t *todoDependencyPathParam) ID() domain.TodoID {
+	return domain.TodoID(t.UintId)
+}
+
+func (t *todoDependencyPathParam) DependencyID() domain.TodoID {
+	return domain.TodoID(t.UintDependencyId)
+}
+
+type todoRevisionPathParam struct {
+	UintId uint `uri:"id" binding:"required"`
+	Rev    uint `uri:"rev" binding:"required"`
+}
+
+func (t *todoRevisionPathParam) ID() domain.TodoID {
+	return domain.TodoID(t.UintId)
+}
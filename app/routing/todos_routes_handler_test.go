@@ -2,15 +2,19 @@ package routing
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"github.com/lloydmeta/todddo-openapi/internal/api/controllers"
 	"github.com/lloydmeta/todddo-openapi/internal/api/models"
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupRouter() (*gin.Engine, *mockTodoController) {
@@ -18,6 +22,7 @@ func setupRouter() (*gin.Engine, *mockTodoController) {
 	mockController := mockTodoController{}
 	handler := TodosRoutesHandler{Controller: &mockController}
 	handler.RegisterRoutes(engine)
+	engine.NoRoute(NoRoute(handler.CustomRoutes()...))
 
 	return engine, &mockController
 }
@@ -34,6 +39,22 @@ func performRequest(r http.Handler, method, url string, body interface{}) *httpt
 	return w
 }
 
+func performRequestWithHeader(r http.Handler, method, url, header, value string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, nil)
+	req.Header.Set(header, value)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func performRawRequest(r http.Handler, method, url, contentType, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
 func TestPostTasksOk(t *testing.T) {
 	router, mockController := setupRouter()
 	mockController.create = func(newTodo *models.TodoData) (todo models.Todo, apiError models.ApiError) {
@@ -89,11 +110,53 @@ func TestGetOk(t *testing.T) {
 	}
 }
 
+func TestGetSendsEtag(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.get = func(id *domain.TodoID) (todo models.Todo, apiError models.ApiError) {
+		return models.Todo{ID: *id, Version: 3, Task: "something"}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks/1", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"3"`, resp.Header().Get("ETag"))
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.get = func(id *domain.TodoID) (todo models.Todo, apiError models.ApiError) {
+		return models.Todo{ID: *id, Version: 3, Task: "something"}, nil
+	}
+	for header, expected := range map[string]int{
+		`"3"`:        http.StatusNotModified,
+		`W/"3"`:      http.StatusNotModified,
+		`"1", "3"`:   http.StatusNotModified,
+		`*`:          http.StatusNotModified,
+		`"2"`:        http.StatusOK,
+		`"1", W/"2"`: http.StatusOK,
+	} {
+		resp := performRequestWithHeader(router, http.MethodGet, "/tasks/1", "If-None-Match", header)
+		assert.Equal(t, expected, resp.Code, header)
+		assert.Equal(t, `"3"`, resp.Header().Get("ETag"), header)
+		if expected == http.StatusNotModified {
+			assert.Empty(t, resp.Body.String(), header)
+		}
+	}
+}
+
 func TestGetInvalidId(t *testing.T) {
 	router, mockController := setupRouter()
 	resp := performRequest(router, http.MethodGet, "/tasks/bababoo", nil)
 	assert.Equal(t, http.StatusBadRequest, resp.Code)
 	assert.Equal(t, 0, mockController.getCalled)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+	var problem models.Error
+	if err := json.Unmarshal(resp.Body.Bytes(), &problem); err != nil {
+		assert.Fail(t, err.Error())
+	} else {
+		assert.Equal(t, models.InvalidRequestProblem, problem.Type)
+		assert.Equal(t, http.StatusBadRequest, problem.Status)
+		assert.Equal(t, "/tasks/bababoo", problem.Instance)
+		assert.NotEmpty(t, problem.Detail)
+	}
 }
 
 func TestGetNotFound(t *testing.T) {
@@ -107,11 +170,24 @@ func TestGetNotFound(t *testing.T) {
 	resp := performRequest(router, http.MethodGet, "/tasks/1", nil)
 	assert.Equal(t, http.StatusNotFound, resp.Code)
 	assert.Equal(t, 1, mockController.getCalled)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+	var problem models.Error
+	if err := json.Unmarshal(resp.Body.Bytes(), &problem); err != nil {
+		assert.Fail(t, err.Error())
+	} else {
+		assert.Equal(t, models.Error{
+			Type:     models.GenericProblem,
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   "nope",
+			Instance: "/tasks/1",
+		}, problem)
+	}
 }
 
 func TestDeleteOk(t *testing.T) {
 	router, mockController := setupRouter()
-	mockController.delete = func(id *domain.TodoID) (success models.Success, apiError models.ApiError) {
+	mockController.delete = func(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (success models.Success, apiError models.ApiError) {
 		return models.Success{Message: "oooh yeea"}, nil
 	}
 	resp := performRequest(router, http.MethodDelete, "/tasks/1", nil)
@@ -119,6 +195,67 @@ func TestDeleteOk(t *testing.T) {
 	assert.Equal(t, 1, mockController.deleteCalled)
 }
 
+func TestDeleteIfMatch(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedVersion domain.TodoVersion
+	mockController.delete = func(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (success models.Success, apiError models.ApiError) {
+		passedVersion = version
+		return models.Success{Message: "oooh yeea"}, nil
+	}
+	resp := performRequestWithHeader(router, http.MethodDelete, "/tasks/1", "If-Match", `"4"`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoVersion(4), passedVersion)
+}
+
+func TestDeleteCascading(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery models.DeleteQuery
+	mockController.delete = func(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (success models.Success, apiError models.ApiError) {
+		passedQuery = *query
+		return models.Success{Message: "oooh yeea"}, nil
+	}
+	resp := performRequest(router, http.MethodDelete, "/tasks/1?children=cascade", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, models.CascadeToChildren, passedQuery.Children)
+}
+
+func TestDeleteCascadingIfMatch(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedVersion domain.TodoVersion
+	var passedQuery models.DeleteQuery
+	mockController.delete = func(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (success models.Success, apiError models.ApiError) {
+		passedVersion = version
+		passedQuery = *query
+		return models.Success{Message: "oooh yeea"}, nil
+	}
+	resp := performRequestWithHeader(router, http.MethodDelete, "/tasks/1?children=cascade", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoVersion(2), passedVersion)
+	assert.Equal(t, models.CascadeToChildren, passedQuery.Children)
+}
+
+func TestDeleteInvalidChildrenPolicy(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodDelete, "/tasks/1?children=abandon", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.deleteCalled)
+}
+
+func TestDeleteInvalidIfMatch(t *testing.T) {
+	router, mockController := setupRouter()
+	for header, expected := range map[string]int{
+		`"1", "2"`: http.StatusBadRequest,
+		`4`:        http.StatusBadRequest,
+		`"4`:       http.StatusBadRequest,
+		`W/"4"`:    http.StatusPreconditionFailed,
+		`"abc"`:    http.StatusPreconditionFailed,
+	} {
+		resp := performRequestWithHeader(router, http.MethodDelete, "/tasks/1", "If-Match", header)
+		assert.Equal(t, expected, resp.Code, header)
+	}
+	assert.Equal(t, 0, mockController.deleteCalled)
+}
+
 func TestDeleteInvalidId(t *testing.T) {
 	router, mockController := setupRouter()
 	resp := performRequest(router, http.MethodDelete, "/tasks/bababoo", nil)
@@ -128,7 +265,7 @@ func TestDeleteInvalidId(t *testing.T) {
 
 func TestDeleteNotFound(t *testing.T) {
 	router, mockController := setupRouter()
-	mockController.delete = func(id *domain.TodoID) (success models.Success, apiError models.ApiError) {
+	mockController.delete = func(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (success models.Success, apiError models.ApiError) {
 		return models.Success{}, mockApiError{
 			code:    http.StatusNotFound,
 			message: "nope",
@@ -147,19 +284,143 @@ func TestList(t *testing.T) {
 			Task: "mockity",
 		},
 	}
-	mockController.list = func() []models.Todo {
-		return expected
+	next := "cursor"
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		return models.TodoPage{Todos: expected, Next: &next}, nil
 	}
 	resp := performRequest(router, http.MethodGet, "/tasks", nil)
-	var respTasks []models.Todo
-	if err := json.Unmarshal(resp.Body.Bytes(), &respTasks); err != nil {
+	var respPage models.TodoPage
+	if err := json.Unmarshal(resp.Body.Bytes(), &respPage); err != nil {
 		assert.Fail(t, err.Error())
 	} else {
-		assert.Equal(t, expected, respTasks)
+		assert.Equal(t, expected, respPage.Todos)
+		assert.Equal(t, &next, respPage.Next)
 		assert.Equal(t, 1, mockController.listCalled)
 	}
 }
 
+func TestListFilteredByCompletion(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery *models.TodoQuery
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		passedQuery = query
+		return models.TodoPage{Todos: []models.Todo{}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks?completed=true", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	if assert.NotNil(t, passedQuery.Completed) {
+		assert.True(t, *passedQuery.Completed)
+	}
+}
+
+func TestListInvalidCompletion(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks?completed=maybe", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.listCalled)
+}
+
+func TestListFilteredByDueDate(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery *models.TodoQuery
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		passedQuery = query
+		return models.TodoPage{Todos: []models.Todo{}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks?due_after=2019-08-20T00:00:00Z&due_before=2019-08-21T00:00:00%2B02:00&overdue=false", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	if assert.NotNil(t, passedQuery.DueAfter) && assert.NotNil(t, passedQuery.DueBefore) && assert.NotNil(t, passedQuery.Overdue) {
+		assert.True(t, time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC).Equal(*passedQuery.DueAfter))
+		assert.True(t, time.Date(2019, 8, 20, 22, 0, 0, 0, time.UTC).Equal(*passedQuery.DueBefore))
+		assert.False(t, *passedQuery.Overdue)
+	}
+}
+
+func TestListInvalidDueDate(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks?due_before=tomorrow", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.listCalled)
+}
+
+func TestListFilteredByTags(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery *models.TodoQuery
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		passedQuery = query
+		return models.TodoPage{Todos: []models.Todo{}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks?tag=home&tag=work&tag_match=all", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, []string{"home", "work"}, passedQuery.Tags)
+	assert.Equal(t, "all", passedQuery.TagMatch)
+}
+
+func TestListInvalidTagMatch(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks?tag=home&tag_match=some", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.listCalled)
+}
+
+func TestListSorted(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery *models.TodoQuery
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		passedQuery = query
+		return models.TodoPage{Todos: []models.Todo{}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks?sort=-priority,due,id", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, []models.SortKey{
+		{Field: "priority", Descending: true},
+		{Field: "due"},
+		{Field: "id"},
+	}, passedQuery.SortKeys)
+}
+
+func TestListInvalidSort(t *testing.T) {
+	router, mockController := setupRouter()
+	for _, sort := range []string{"nope", "-priority,,id", "due,-due", "--id"} {
+		resp := performRequest(router, http.MethodGet, "/tasks?sort="+sort, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, sort)
+	}
+	assert.Equal(t, 0, mockController.listCalled)
+}
+
+func TestListPaginated(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery *models.TodoQuery
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		passedQuery = query
+		return models.TodoPage{Todos: []models.Todo{}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks?limit=20&after=abc", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, uint(20), passedQuery.Limit)
+	assert.Equal(t, "abc", passedQuery.After)
+}
+
+func TestListLimitTooLarge(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks?limit=1001", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.listCalled)
+}
+
+func TestListFailure(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		return models.TodoPage{}, mockApiError{
+			code:    http.StatusInternalServerError,
+			message: "disk on fire",
+		}
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks", nil)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, 1, mockController.listCalled)
+}
+
 func TestUpdateTasksOk(t *testing.T) {
 	router, mockController := setupRouter()
 	mockController.update = func(todo *models.Todo) (todo2 models.Todo, apiError models.ApiError) {
@@ -177,6 +438,50 @@ func TestUpdateTasksOk(t *testing.T) {
 	}
 }
 
+func TestUpdateRecurrence(t *testing.T) {
+	router, mockController := setupRouter()
+	var updatedWith models.Todo
+	mockController.update = func(todo *models.Todo) (todo2 models.Todo, apiError models.ApiError) {
+		updatedWith = *todo
+		return *todo, nil
+	}
+	update := models.TodoData{Task: "Water plants", Recurrence: "FREQ=WEEKLY;BYDAY=SA"}
+	resp := performRequest(router, http.MethodPut, "/tasks/1", update)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=SA", updatedWith.Recurrence)
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.update = func(todo *models.Todo) (todo2 models.Todo, apiError models.ApiError) {
+		updated := *todo
+		updated.Version++
+		return updated, nil
+	}
+	req, _ := http.NewRequest(http.MethodPut, "/tasks/1", strings.NewReader(`{"task":"do something"}`))
+	req.Header.Set("If-Match", `"4"`)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"5"`, resp.Header().Get("ETag"))
+}
+
+func TestUpdateVersionConflict(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.update = func(todo *models.Todo) (todo2 models.Todo, apiError models.ApiError) {
+		return *todo, mockApiError{
+			code:    http.StatusPreconditionFailed,
+			message: "changed",
+		}
+	}
+	req, _ := http.NewRequest(http.MethodPut, "/tasks/1", strings.NewReader(`{"task":"do something"}`))
+	req.Header.Set("If-Match", `"4"`)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	assert.Empty(t, resp.Header().Get("ETag"))
+}
+
 func TestUpdateInvalidId(t *testing.T) {
 	router, mockController := setupRouter()
 	resp := performRequest(router, http.MethodPut, "/tasks/bababoo", nil)
@@ -198,19 +503,574 @@ func TestUpdateTasksFailure(t *testing.T) {
 	assert.Equal(t, 1, mockController.updateCalled)
 }
 
+func TestPatchOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedPatch string
+	mockController.patch = func(id *domain.TodoID, version domain.TodoVersion, mergePatch []byte) (models.Todo, models.ApiError) {
+		passedPatch = string(mergePatch)
+		return models.Todo{ID: *id, Task: "something", Completed: true}, nil
+	}
+	resp := performRawRequest(router, http.MethodPatch, "/tasks/1", "application/merge-patch+json", `{"completed":true}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, 1, mockController.patchCalled)
+	assert.Equal(t, `{"completed":true}`, passedPatch)
+}
+
+func TestPatchWrongContentType(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRawRequest(router, http.MethodPatch, "/tasks/1", "application/json", `{"completed":true}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+	assert.Equal(t, 0, mockController.patchCalled)
+}
+
+func TestPatchFailure(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.patch = func(id *domain.TodoID, version domain.TodoVersion, mergePatch []byte) (models.Todo, models.ApiError) {
+		return models.Todo{}, mockApiError{
+			code:    http.StatusNotFound,
+			message: "nope",
+		}
+	}
+	resp := performRawRequest(router, http.MethodPatch, "/tasks/1", "application/merge-patch+json", `{"completed":true}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, 1, mockController.patchCalled)
+}
+
+func TestCompleteOk(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.complete = func(id *domain.TodoID) (models.Todo, models.ApiError) {
+		return models.Todo{ID: *id, Task: "something", Completed: true}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks/1/complete", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var respTask models.Todo
+	if err := json.Unmarshal(resp.Body.Bytes(), &respTask); err != nil {
+		assert.Fail(t, err.Error())
+	} else {
+		assert.True(t, respTask.Completed)
+		assert.Equal(t, 1, mockController.completeCalled)
+	}
+}
+
+func TestCompleteNotFound(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.complete = func(id *domain.TodoID) (models.Todo, models.ApiError) {
+		return models.Todo{}, mockApiError{
+			code:    http.StatusNotFound,
+			message: "nope",
+		}
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks/1/complete", nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, 1, mockController.completeCalled)
+}
+
+func TestReopenOk(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.reopen = func(id *domain.TodoID) (models.Todo, models.ApiError) {
+		return models.Todo{ID: *id, Task: "something"}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks/1/reopen", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, 1, mockController.reopenCalled)
+}
+
+func TestReopenInvalidId(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodPost, "/tasks/bababoo/reopen", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.reopenCalled)
+}
+
+func TestAddTagsOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedTags *models.TagsData
+	mockController.addTags = func(id *domain.TodoID, tags *models.TagsData) (models.Todo, models.ApiError) {
+		passedTags = tags
+		return models.Todo{ID: *id, Version: 2, Task: "something", Tags: tags.Tags}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks/1/tags", models.TagsData{Tags: []string{"home"}})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, []string{"home"}, passedTags.Tags)
+	assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+}
+
+func TestAddNoTags(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodPost, "/tasks/1/tags", models.TagsData{Tags: []string{}})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.addTagsCalled)
+}
+
+func TestRemoveTagOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedId domain.TodoID
+	var passedTag string
+	mockController.removeTag = func(id *domain.TodoID, tag string) (models.Todo, models.ApiError) {
+		passedId, passedTag = *id, tag
+		return models.Todo{ID: *id, Task: "something"}, nil
+	}
+	resp := performRequest(router, http.MethodDelete, "/tasks/1/tags/some%20tag", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoID(1), passedId)
+	assert.Equal(t, "some tag", passedTag)
+}
+
+func TestListTagsOk(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.listTags = func() (models.TagList, models.ApiError) {
+		return models.TagList{Tags: []models.TagCount{{Tag: "home", Count: 2}}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tags", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"tags":[{"tag":"home","count":2}]}`, resp.Body.String())
+}
+
+func TestListChildrenOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery models.TodoQuery
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		passedQuery = *query
+		return models.TodoPage{Todos: []models.Todo{}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks/3/children?tree=true&sort=-priority", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoID(3), *passedQuery.ParentID)
+	assert.True(t, passedQuery.Tree)
+	assert.Equal(t, []models.SortKey{{Field: "priority", Descending: true}}, passedQuery.SortKeys)
+}
+
+func TestListChildrenInvalidId(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks/abc/children", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.listCalled)
+}
+
+func TestListOnListOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedQuery models.TodoQuery
+	mockController.list = func(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+		passedQuery = *query
+		return models.TodoPage{Todos: []models.Todo{}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/lists/2/tasks?completed=false", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoListID(2), *passedQuery.ListID)
+	assert.Nil(t, passedQuery.ParentID)
+	assert.False(t, *passedQuery.Completed)
+}
+
+func TestCreateOnListOk(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.create = func(newTodo *models.TodoData) (models.Todo, models.ApiError) {
+		return models.Todo{ID: 1, Task: newTodo.Task, ListID: newTodo.ListID}, nil
+	}
+	other := domain.TodoListID(5)
+	resp := performRequest(router, http.MethodPost, "/lists/2/tasks", models.TodoData{Task: "Sweep", ListID: &other})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var created models.Todo
+	_ = json.Unmarshal(resp.Body.Bytes(), &created)
+	assert.Equal(t, domain.TodoListID(2), *created.ListID)
+}
+
+func TestCreateOnListInvalidId(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodPost, "/lists/abc/tasks", models.TodoData{Task: "Sweep"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.createCalled)
+}
+
+func TestSearchOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var searchedWith models.SearchQuery
+	mockController.search = func(query *models.SearchQuery) (models.SearchResults, models.ApiError) {
+		searchedWith = *query
+		return models.SearchResults{Results: []models.SearchResult{{Todo: models.Todo{ID: 1, Task: "Buy milk"}, Score: 0.5}}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks/search?q=milk&limit=5", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, models.SearchQuery{Q: "milk", Limit: 5}, searchedWith)
+	assert.Equal(t, 0, mockController.getCalled)
+	var results models.SearchResults
+	_ = json.Unmarshal(resp.Body.Bytes(), &results)
+	assert.Equal(t, 0.5, results.Results[0].Score)
+}
+
+func TestSearchWithoutQuery(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks/search", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.searchCalled)
+}
+
+func TestSearchLimitTooLarge(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks/search?q=milk&limit=101", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.searchCalled)
+}
+
+func TestAddDependenciesOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedDependencies *models.DependenciesData
+	mockController.addDependencies = func(id *domain.TodoID, dependencies *models.DependenciesData) (models.Todo, models.ApiError) {
+		passedDependencies = dependencies
+		return models.Todo{ID: *id, Version: 2, Task: "something", DependsOn: dependencies.DependsOn, Blocked: true}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks/1/dependencies", models.DependenciesData{DependsOn: []domain.TodoID{2}})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, []domain.TodoID{2}, passedDependencies.DependsOn)
+	assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+	var todo models.Todo
+	_ = json.Unmarshal(resp.Body.Bytes(), &todo)
+	assert.True(t, todo.Blocked)
+}
+
+func TestAddNoDependencies(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodPost, "/tasks/1/dependencies", models.DependenciesData{})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.addDependenciesCalled)
+}
+
+func TestRemoveDependencyOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedId, passedDependencyId domain.TodoID
+	mockController.removeDependency = func(id *domain.TodoID, dependencyID *domain.TodoID) (models.Todo, models.ApiError) {
+		passedId, passedDependencyId = *id, *dependencyID
+		return models.Todo{ID: *id, Version: 3, Task: "something"}, nil
+	}
+	resp := performRequest(router, http.MethodDelete, "/tasks/1/dependencies/2", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoID(1), passedId)
+	assert.Equal(t, domain.TodoID(2), passedDependencyId)
+}
+
+func TestRemoveDependencyInvalidId(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodDelete, "/tasks/1/dependencies/lol", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.removeDependencyCalled)
+}
+
+func TestReadyOk(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.ready = func() (models.TodoList, models.ApiError) {
+		return models.TodoList{Todos: []models.Todo{{ID: 2, Task: "Buy paint"}}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks/ready", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, 0, mockController.getCalled)
+	var ready models.TodoList
+	_ = json.Unmarshal(resp.Body.Bytes(), &ready)
+	assert.Equal(t, []models.Todo{{ID: 2, Task: "Buy paint"}}, ready.Todos)
+}
+
+func TestMoveOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedMove *models.MoveData
+	mockController.move = func(id *domain.TodoID, move *models.MoveData) (models.Todo, models.ApiError) {
+		passedMove = move
+		return models.Todo{ID: *id, Version: 4, Task: "something", Position: "a0V"}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks/1/move", map[string]int{"after": 2, "before": 3})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoID(2), *passedMove.After)
+	assert.Equal(t, domain.TodoID(3), *passedMove.Before)
+	assert.Equal(t, `"4"`, resp.Header().Get("ETag"))
+	var todo models.Todo
+	_ = json.Unmarshal(resp.Body.Bytes(), &todo)
+	assert.Equal(t, "a0V", todo.Position)
+}
+
+func TestMoveInvalidId(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodPost, "/tasks/lol/move", models.MoveData{})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.moveCalled)
+}
+
+func TestBatchOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedBatch *models.BatchData
+	mockController.batch = func(batch *models.BatchData) (models.BatchResults, models.ApiError) {
+		passedBatch = batch
+		return models.BatchResults{Applied: true, Results: []models.BatchResult{
+			{Status: http.StatusCreated, Todo: &models.Todo{ID: 3, Task: "Buy milk"}},
+			{Status: http.StatusOK},
+		}}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks:batch", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "todo": map[string]string{"task": "Buy milk"}},
+			{"op": "delete", "id": 1, "version": 2, "children": "cascade"},
+		},
+	})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, []models.BatchOperation{
+		{Op: models.CreateOperation, Todo: &models.TodoData{Task: "Buy milk"}},
+		{Op: models.DeleteOperation, ID: 1, Version: 2, Children: models.CascadeToChildren},
+	}, passedBatch.Operations)
+	var results models.BatchResults
+	_ = json.Unmarshal(resp.Body.Bytes(), &results)
+	assert.True(t, results.Applied)
+	assert.Equal(t, "Buy milk", results.Results[0].Todo.Task)
+}
+
+func TestBatchFailure(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.batch = func(batch *models.BatchData) (models.BatchResults, models.ApiError) {
+		aborted := models.MkError(models.BatchAbortedProblem, http.StatusFailedDependency, "Not made")
+		notFound := models.MkError(models.TodoNotFoundProblem, http.StatusNotFound, "Not found")
+		return models.BatchResults{Results: []models.BatchResult{
+			{Status: http.StatusFailedDependency, Error: &aborted},
+			{Status: http.StatusNotFound, Error: &notFound},
+		}}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks:batch", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "todo": map[string]string{"task": "Buy milk"}},
+			{"op": "delete", "id": 1},
+		},
+	})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	var results models.BatchResults
+	_ = json.Unmarshal(resp.Body.Bytes(), &results)
+	assert.False(t, results.Applied)
+	assert.Equal(t, models.TodoNotFoundProblem, results.Results[1].Error.Type)
+}
+
+func TestBatchInvalid(t *testing.T) {
+	router, mockController := setupRouter()
+	for _, body := range []interface{}{
+		map[string]interface{}{},
+		map[string]interface{}{"operations": []interface{}{}},
+		map[string]interface{}{"operations": []map[string]interface{}{{"op": "rename", "id": 1}}},
+		map[string]interface{}{"operations": []map[string]interface{}{{"op": "delete", "id": 1, "children": "lol"}}},
+		map[string]interface{}{"operations": []map[string]interface{}{{"op": "create", "todo": map[string]string{}}}},
+	} {
+		resp := performRequest(router, http.MethodPost, "/tasks:batch", body)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, models.ProblemContentType, resp.Header().Get("Content-Type"))
+	}
+	assert.Equal(t, 0, mockController.batchCalled)
+}
+
+func TestBatchOnlyPosted(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks:batch", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	assert.Equal(t, http.MethodPost, resp.Header().Get("Allow"))
+	assert.Equal(t, models.ProblemContentType, resp.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodPost, "/tasks:batchy", nil).Code)
+	assert.Equal(t, 0, mockController.batchCalled)
+}
+
+func TestNoRoute(t *testing.T) {
+	router, _ := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/nothing/here?at=all", nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, models.ProblemContentType, resp.Header().Get("Content-Type"))
+	var problem models.Error
+	_ = json.Unmarshal(resp.Body.Bytes(), &problem)
+	assert.Equal(t, models.GenericProblem, problem.Type)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "/nothing/here?at=all", problem.Instance)
+}
+
+func TestListTrashOk(t *testing.T) {
+	router, mockController := setupRouter()
+	deletedAt := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	mockController.listTrash = func() (models.Trash, models.ApiError) {
+		return models.Trash{Todos: []models.TrashedTodo{{Todo: models.Todo{ID: 2, Task: "Buy paint"}, DeletedAt: deletedAt}}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/trash", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var trash models.Trash
+	_ = json.Unmarshal(resp.Body.Bytes(), &trash)
+	assert.Equal(t, []models.TrashedTodo{{Todo: models.Todo{ID: 2, Task: "Buy paint"}, DeletedAt: deletedAt}}, trash.Todos)
+}
+
+func TestRestoreOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedId domain.TodoID
+	mockController.restore = func(id *domain.TodoID) (models.Todo, models.ApiError) {
+		passedId = *id
+		return models.Todo{ID: *id, Version: 2, Task: "Buy paint"}, nil
+	}
+	resp := performRequest(router, http.MethodPost, "/trash/3/restore", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoID(3), passedId)
+	assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+}
+
+func TestRestoreNotInTrash(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.restore = func(id *domain.TodoID) (models.Todo, models.ApiError) {
+		return models.Todo{}, mockApiError{code: http.StatusNotFound, message: "nope"}
+	}
+	resp := performRequest(router, http.MethodPost, "/trash/3/restore", nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Empty(t, resp.Header().Get("ETag"))
+}
+
+func TestRestoreInvalidId(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodPost, "/trash/lol/restore", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.restoreCalled)
+}
+
+func TestPurgeOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedId domain.TodoID
+	mockController.purge = func(id *domain.TodoID) (models.Success, models.ApiError) {
+		passedId = *id
+		return models.Success{Message: "gone"}, nil
+	}
+	resp := performRequest(router, http.MethodDelete, "/trash/3", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoID(3), passedId)
+	assert.Equal(t, 0, mockController.deleteCalled)
+}
+
+func TestHistoryOk(t *testing.T) {
+	router, mockController := setupRouter()
+	at := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	mockController.history = func(id *domain.TodoID) (models.History, models.ApiError) {
+		return models.History{Revisions: []models.Revision{{
+			Rev: 1, Change: "create", At: at, Actor: "alice",
+			Changes: []models.FieldChange{{Field: "task", From: "", To: "Buy paint"}},
+			Todo:    models.Todo{ID: *id, Version: 1, Task: "Buy paint"},
+		}}}, nil
+	}
+	resp := performRequest(router, http.MethodGet, "/tasks/3/history", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var history models.History
+	_ = json.Unmarshal(resp.Body.Bytes(), &history)
+	if assert.Len(t, history.Revisions, 1) {
+		assert.Equal(t, "alice", history.Revisions[0].Actor)
+		assert.Equal(t, domain.TodoID(3), history.Revisions[0].Todo.ID)
+		assert.Equal(t, []models.FieldChange{{Field: "task", From: "", To: "Buy paint"}}, history.Revisions[0].Changes)
+	}
+	assert.Equal(t, 0, mockController.getCalled)
+}
+
+func TestHistoryInvalidId(t *testing.T) {
+	router, mockController := setupRouter()
+	resp := performRequest(router, http.MethodGet, "/tasks/lol/history", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 0, mockController.historyCalled)
+}
+
+func TestRevertOk(t *testing.T) {
+	router, mockController := setupRouter()
+	var passedId domain.TodoID
+	var passedRev uint
+	mockController.revert = func(id *domain.TodoID, rev uint) (models.Todo, models.ApiError) {
+		passedId, passedRev = *id, rev
+		return models.Todo{ID: *id, Version: 4, Task: "Buy paint"}, nil
+	}
+	resp := performRequestWithHeader(router, http.MethodPost, "/tasks/3/revert/2", "X-Actor", "alice")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, domain.TodoID(3), passedId)
+	assert.Equal(t, uint(2), passedRev)
+	assert.Equal(t, `"4"`, resp.Header().Get("ETag"))
+	assert.Equal(t, []string{"alice"}, mockController.actors)
+}
+
+func TestRevertNotFound(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.revert = func(id *domain.TodoID, rev uint) (models.Todo, models.ApiError) {
+		return models.Todo{}, mockApiError{code: http.StatusNotFound, message: "nope"}
+	}
+	resp := performRequest(router, http.MethodPost, "/tasks/3/revert/9", nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Empty(t, resp.Header().Get("ETag"))
+}
+
+func TestRevertInvalidRev(t *testing.T) {
+	router, mockController := setupRouter()
+	for _, rev := range []string{"lol", "0", "-1"} {
+		resp := performRequest(router, http.MethodPost, "/tasks/3/revert/"+rev, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, rev)
+	}
+	assert.Equal(t, 0, mockController.revertCalled)
+}
+
+func TestChangesMadeByActor(t *testing.T) {
+	router, mockController := setupRouter()
+	mockController.complete = func(id *domain.TodoID) (models.Todo, models.ApiError) {
+		return models.Todo{ID: *id, Version: 2, Completed: true}, nil
+	}
+	headers := []struct {
+		value    string
+		expected string
+	}{
+		{"", "anonymous"},
+		{"  alice  ", "alice"},
+		{"bob\tthe\nbuilder", "bobthebuilder"},
+		{" \t ", "anonymous"},
+		{strings.Repeat("é", 70), strings.Repeat("é", 64)},
+	}
+	for _, header := range headers {
+		mockController.actors = nil
+		resp := performRequestWithHeader(router, http.MethodPost, "/tasks/3/complete", "X-Actor", header.value)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, []string{header.expected}, mockController.actors, header.value)
+	}
+}
+
 // Mocks
 
 type mockTodoController struct {
-	create       func(newTodo *models.TodoData) (models.Todo, models.ApiError)
-	createCalled int
-	update       func(todo *models.Todo) (models.Todo, models.ApiError)
-	updateCalled int
-	list         func() []models.Todo
-	listCalled   int
-	get          func(id *domain.TodoID) (models.Todo, models.ApiError)
-	getCalled    int
-	delete       func(id *domain.TodoID) (models.Success, models.ApiError)
-	deleteCalled int
+	create                 func(newTodo *models.TodoData) (models.Todo, models.ApiError)
+	createCalled           int
+	update                 func(todo *models.Todo) (models.Todo, models.ApiError)
+	updateCalled           int
+	list                   func(query *models.TodoQuery) (models.TodoPage, models.ApiError)
+	listCalled             int
+	get                    func(id *domain.TodoID) (models.Todo, models.ApiError)
+	getCalled              int
+	delete                 func(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (models.Success, models.ApiError)
+	deleteCalled           int
+	patch                  func(id *domain.TodoID, version domain.TodoVersion, mergePatch []byte) (models.Todo, models.ApiError)
+	patchCalled            int
+	complete               func(id *domain.TodoID) (models.Todo, models.ApiError)
+	completeCalled         int
+	reopen                 func(id *domain.TodoID) (models.Todo, models.ApiError)
+	reopenCalled           int
+	addTags                func(id *domain.TodoID, tags *models.TagsData) (models.Todo, models.ApiError)
+	addTagsCalled          int
+	removeTag              func(id *domain.TodoID, tag string) (models.Todo, models.ApiError)
+	removeTagCalled        int
+	listTags               func() (models.TagList, models.ApiError)
+	listTagsCalled         int
+	search                 func(query *models.SearchQuery) (models.SearchResults, models.ApiError)
+	searchCalled           int
+	addDependencies        func(id *domain.TodoID, dependencies *models.DependenciesData) (models.Todo, models.ApiError)
+	addDependenciesCalled  int
+	removeDependency       func(id *domain.TodoID, dependencyID *domain.TodoID) (models.Todo, models.ApiError)
+	removeDependencyCalled int
+	ready                  func() (models.TodoList, models.ApiError)
+	readyCalled            int
+	move                   func(id *domain.TodoID, move *models.MoveData) (models.Todo, models.ApiError)
+	moveCalled             int
+	batch                  func(batch *models.BatchData) (models.BatchResults, models.ApiError)
+	batchCalled            int
+	listTrash              func() (models.Trash, models.ApiError)
+	listTrashCalled        int
+	restore                func(id *domain.TodoID) (models.Todo, models.ApiError)
+	restoreCalled          int
+	purge                  func(id *domain.TodoID) (models.Success, models.ApiError)
+	purgeCalled            int
+	history                func(id *domain.TodoID) (models.History, models.ApiError)
+	historyCalled          int
+	revert                 func(id *domain.TodoID, rev uint) (models.Todo, models.ApiError)
+	revertCalled           int
+	// actors are those AsActor was called with, in order
+	actors []string
 }
 
 func (m *mockTodoController) Create(newTodo *models.TodoData) (models.Todo, models.ApiError) {
@@ -223,14 +1083,14 @@ func (m *mockTodoController) Get(id *domain.TodoID) (models.Todo, models.ApiErro
 	return m.get(id)
 }
 
-func (m *mockTodoController) Delete(id *domain.TodoID) (models.Success, models.ApiError) {
+func (m *mockTodoController) Delete(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (models.Success, models.ApiError) {
 	defer func() { m.deleteCalled++ }()
-	return m.delete(id)
+	return m.delete(id, version, query)
 }
 
-func (m *mockTodoController) List() []models.Todo {
+func (m *mockTodoController) List(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
 	defer func() { m.listCalled++ }()
-	return m.list()
+	return m.list(query)
 }
 
 func (m *mockTodoController) Update(todo *models.Todo) (models.Todo, models.ApiError) {
@@ -238,6 +1098,36 @@ func (m *mockTodoController) Update(todo *models.Todo) (models.Todo, models.ApiE
 	return m.update(todo)
 }
 
+func (m *mockTodoController) Patch(id *domain.TodoID, version domain.TodoVersion, mergePatch []byte) (models.Todo, models.ApiError) {
+	defer func() { m.patchCalled++ }()
+	return m.patch(id, version, mergePatch)
+}
+
+func (m *mockTodoController) Complete(id *domain.TodoID) (models.Todo, models.ApiError) {
+	defer func() { m.completeCalled++ }()
+	return m.complete(id)
+}
+
+func (m *mockTodoController) Reopen(id *domain.TodoID) (models.Todo, models.ApiError) {
+	defer func() { m.reopenCalled++ }()
+	return m.reopen(id)
+}
+
+func (m *mockTodoController) AddTags(id *domain.TodoID, tags *models.TagsData) (models.Todo, models.ApiError) {
+	defer func() { m.addTagsCalled++ }()
+	return m.addTags(id, tags)
+}
+
+func (m *mockTodoController) RemoveTag(id *domain.TodoID, tag string) (models.Todo, models.ApiError) {
+	defer func() { m.removeTagCalled++ }()
+	return m.removeTag(id, tag)
+}
+
+func (m *mockTodoController) ListTags() (models.TagList, models.ApiError) {
+	defer func() { m.listTagsCalled++ }()
+	return m.listTags()
+}
+
 type mockApiError struct {
 	code    int
 	message string
@@ -248,9 +1138,178 @@ func (m mockApiError) Error() string {
 }
 
 func (m mockApiError) AsModel() models.Error {
-	return models.Error{Message: m.message}
+	return models.MkError(models.GenericProblem, m.code, m.message)
 }
 
 func (m mockApiError) HttpStatusCode() int {
 	return m.code
 }
+
+func (m *mockTodoController) Search(query *models.SearchQuery) (models.SearchResults, models.ApiError) {
+	defer func() { m.searchCalled++ }()
+	return m.search(query)
+}
+
+func (m *mockTodoController) AddDependencies(id *domain.TodoID, dependencies *models.DependenciesData) (models.Todo, models.ApiError) {
+	defer func() { m.addDependenciesCalled++ }()
+	return m.addDependencies(id, dependencies)
+}
+
+func (m *mockTodoController) RemoveDependency(id *domain.TodoID, dependencyID *domain.TodoID) (models.Todo, models.ApiError) {
+	defer func() { m.removeDependencyCalled++ }()
+	return m.removeDependency(id, dependencyID)
+}
+
+func (m *mockTodoController) Ready() (models.TodoList, models.ApiError) {
+	defer func() { m.readyCalled++ }()
+	return m.ready()
+}
+
+func (m *mockTodoController) Move(id *domain.TodoID, move *models.MoveData) (models.Todo, models.ApiError) {
+	defer func() { m.moveCalled++ }()
+	return m.move(id, move)
+}
+
+func (m *mockTodoController) Batch(batch *models.BatchData) (models.BatchResults, models.ApiError) {
+	defer func() { m.batchCalled++ }()
+	return m.batch(batch)
+}
+
+func (m *mockTodoController) ListTrash() (models.Trash, models.ApiError) {
+	defer func() { m.listTrashCalled++ }()
+	return m.listTrash()
+}
+
+func (m *mockTodoController) Restore(id *domain.TodoID) (models.Todo, models.ApiError) {
+	defer func() { m.restoreCalled++ }()
+	return m.restore(id)
+}
+
+func (m *mockTodoController) Purge(id *domain.TodoID) (models.Success, models.ApiError) {
+	defer func() { m.purgeCalled++ }()
+	return m.purge(id)
+}
+
+func (m *mockTodoController) History(id *domain.TodoID) (models.History, models.ApiError) {
+	defer func() { m.historyCalled++ }()
+	return m.history(id)
+}
+
+func (m *mockTodoController) Revert(id *domain.TodoID, rev uint) (models.Todo, models.ApiError) {
+	defer func() { m.revertCalled++ }()
+	return m.revert(id, rev)
+}
+
+func (m *mockTodoController) AsActor(actor string) controllers.TodoController {
+	m.actors = append(m.actors, actor)
+	return m
+}
+
+// mockTodoEventController hands out streams of the given events, closed
+// unless open is set, or of those sent to feed if it is set, recording what
+// they were followed after
+type mockTodoEventController struct {
+	missed    []models.TodoEvent
+	gap       bool
+	events    []models.TodoEvent
+	open      bool
+	feed      chan models.TodoEvent
+	afters    []*uint64
+	cancelled int
+}
+
+func (m *mockTodoEventController) Follow(after *uint64) controllers.TodoEventStream {
+	m.afters = append(m.afters, after)
+	events := make(chan models.TodoEvent, len(m.events))
+	for _, event := range m.events {
+		events <- event
+	}
+	if m.feed != nil {
+		events = m.feed
+	} else if !m.open {
+		close(events)
+	}
+	return controllers.TodoEventStream{
+		Missed: m.missed,
+		Gap:    m.gap,
+		Events: events,
+		Cancel: func() { m.cancelled++ },
+	}
+}
+
+func setupEventsRouter(events *mockTodoEventController) *gin.Engine {
+	engine := gin.Default()
+	handler := TodosRoutesHandler{Controller: &mockTodoController{}, Events: events, Heartbeat: time.Millisecond}
+	handler.RegisterRoutes(engine)
+	return engine
+}
+
+func TestEvents(t *testing.T) {
+	at := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	events := &mockTodoEventController{
+		missed: []models.TodoEvent{{ID: "1", Type: "todo.created", At: at, Todo: models.Todo{ID: 1, Task: "Water plants"}}},
+		events: []models.TodoEvent{{ID: "2", Type: "todo.deleted", At: at, Actor: "alice", Todo: models.Todo{ID: 1}}},
+	}
+	router := setupEventsRouter(events)
+	w := performRequestWithHeader(router, "GET", "/tasks/events", "Last-Event-ID", "1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	lines := strings.Split(w.Body.String(), "\n")
+	if assert.True(t, len(lines) >= 8) {
+		assert.Equal(t, []string{"id: 1", "event: todo.created"}, lines[0:2])
+		assert.True(t, strings.HasPrefix(lines[2], `data: {"id":"1","type":"todo.created"`))
+		assert.Equal(t, []string{"", "id: 2", "event: todo.deleted"}, lines[3:6])
+		assert.True(t, strings.HasPrefix(lines[6], `data: {"id":"2","type":"todo.deleted"`))
+	}
+	if assert.Len(t, events.afters, 1) && assert.NotNil(t, events.afters[0]) {
+		assert.Equal(t, uint64(1), *events.afters[0])
+	}
+	assert.Equal(t, 1, events.cancelled)
+}
+
+func TestEventsFromNow(t *testing.T) {
+	events := &mockTodoEventController{}
+	router := setupEventsRouter(events)
+	w := performRequest(router, "GET", "/tasks/events", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, []*uint64{nil}, events.afters)
+}
+
+func TestEventsGap(t *testing.T) {
+	events := &mockTodoEventController{
+		gap:    true,
+		events: []models.TodoEvent{{ID: "9", Type: "todo.created"}},
+	}
+	router := setupEventsRouter(events)
+	w := performRequestWithHeader(router, "GET", "/tasks/events", "Last-Event-ID", "1")
+	assert.True(t, strings.HasPrefix(w.Body.String(), "event: reset\ndata: {}\n\nid: 9\n"))
+}
+
+func TestEventsInvalidLastEventID(t *testing.T) {
+	events := &mockTodoEventController{}
+	router := setupEventsRouter(events)
+	w := performRequestWithHeader(router, "GET", "/tasks/events", "Last-Event-ID", "nope")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, models.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Empty(t, events.afters)
+}
+
+func TestEventsHeartbeatUntilDisconnected(t *testing.T) {
+	events := &mockTodoEventController{open: true}
+	router := setupEventsRouter(events)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", "/tasks/events", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req.WithContext(ctx))
+	assert.Contains(t, w.Body.String(), ": heartbeat\n\n")
+	assert.Equal(t, 1, events.cancelled)
+}
+
+func TestEventsNotRoutedWithoutController(t *testing.T) {
+	router, _ := setupRouter()
+	w := performRequest(router, "GET", "/tasks/events", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,6 +1,6 @@
 // GENERATED BY THE COMMAND ABOVE; DO NOT EDIT
 // This file was generated by swaggo/swag at
-// 2019-08-19 17:05:41.022997 +0900 JST m=+0.030905389
+// 2026-10-17 05:36:41.25215204 +0000 UTC m=+0.128829199
 
 package docs
 
@@ -28,61 +28,73 @@ var doc = `{
     "host": "localhost:8080",
     "basePath": "/",
     "paths": {
-        "/tasks": {
+        "/lists": {
             "get": {
-                "description": "Retrieves all persisted Todos",
+                "description": "Retrieves all persisted lists, in order of id",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "List all existing Todos",
-                "operationId": "list-existing-todos",
+                "summary": "List existing lists",
+                "operationId": "list-existing-lists",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Todo"
-                            }
+                            "type": "object",
+                            "$ref": "#/definitions/models.Lists"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Creates a new Todo",
+                "description": "Creates a new list for Todos to be put on, e.g. for a project",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Add a new Todo",
-                "operationId": "create-todo",
+                "summary": "Add a new list",
+                "operationId": "create-list",
                 "parameters": [
                     {
                         "description": "The request body",
-                        "name": "todo",
+                        "name": "list",
                         "in": "body",
                         "required": true,
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/models.TodoData"
+                            "$ref": "#/definitions/models.ListData"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/models.Todo"
+                            "$ref": "#/definitions/models.List"
                         }
                     },
                     "400": {
-                        "description": "Task cannot be empty",
+                        "description": "Name cannot be empty",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/models.Error"
@@ -91,21 +103,21 @@ var doc = `{
                 }
             }
         },
-        "/tasks/{id}": {
+        "/lists/{id}": {
             "get": {
-                "description": "Retrieves a persisted Todo",
+                "description": "Retrieves a persisted list",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get a Todo by id",
-                "operationId": "get-existing-todo",
+                "summary": "Get a list by id",
+                "operationId": "get-existing-list",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "The id of the todo you want to retrieve",
+                        "description": "The id of the list you want to retrieve",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -116,11 +128,18 @@ var doc = `{
                         "description": "OK",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/models.Todo"
+                            "$ref": "#/definitions/models.List"
                         }
                     },
                     "404": {
-                        "description": "Task does not exist",
+                        "description": "List does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/models.Error"
@@ -129,32 +148,98 @@ var doc = `{
                 }
             },
             "put": {
-                "description": "Updates an existing Todo",
+                "description": "Updates the name of an existing list",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update an existing Todo",
-                "operationId": "update-todo",
+                "summary": "Rename an existing list",
+                "operationId": "update-list",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the list you want to update",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
                     {
                         "description": "The request body",
-                        "name": "todo",
+                        "name": "list",
                         "in": "body",
                         "required": true,
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/models.TodoData"
+                            "$ref": "#/definitions/models.ListData"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.List"
+                        }
+                    },
+                    "400": {
+                        "description": "Name cannot be empty",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "List does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
                         }
                     },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes an existing list. Lists that still have Todos on them are only deleted when asked to delete those too.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete an existing list",
+                "operationId": "delete-list",
+                "parameters": [
                     {
                         "type": "integer",
-                        "description": "The id of the todo you want to update",
+                        "description": "The id of the list you want to delete",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "refuse",
+                            "cascade"
+                        ],
+                        "type": "string",
+                        "description": "Whether to refuse deleting a list with Todos on it, the default, or delete them too",
+                        "name": "todos",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -162,18 +247,113 @@ var doc = `{
                         "description": "OK",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/models.Todo"
+                            "$ref": "#/definitions/models.Success"
+                        }
+                    },
+                    "404": {
+                        "description": "List does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "409": {
+                        "description": "List still has Todos on it",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/lists/{id}/tasks": {
+            "get": {
+                "description": "Retrieves the Todos on an existing list, taking the same query parameters as listing all Todos",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the Todos on a list",
+                "operationId": "list-todos-on-list",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the list whose Todos you want to retrieve",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only retrieve Todos with this completion status",
+                        "name": "completed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only retrieve Todos with this tag; repeat to give several",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields to sort on, out of id, priority, due and position; prefix with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The maximum number of Todos in the page, 100 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "The next cursor of the previous page",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only retrieve top-level Todos, each with all of its subtasks nested under children",
+                        "name": "tree",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoPage"
                         }
                     },
                     "400": {
-                        "description": "Task cannot be empty",
+                        "description": "Invalid query or cursor",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/models.Error"
                         }
                     },
                     "404": {
-                        "description": "Task does not exist",
+                        "description": "List does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/models.Error"
@@ -181,23 +361,39 @@ var doc = `{
                     }
                 }
             },
-            "delete": {
-                "description": "Deletes an existing Todo",
+            "post": {
+                "description": "Creates a new Todo on an existing list, whatever list_id the request body says",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete an existing Todo",
-                "operationId": "delete-todo",
+                "summary": "Add a new Todo to a list",
+                "operationId": "create-todo-on-list",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "The id of the todo you want to delete",
+                        "description": "The id of the list to add the Todo to",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "The request body",
+                        "name": "todo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoData"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -205,11 +401,18 @@ var doc = `{
                         "description": "OK",
                         "schema": {
                             "type": "object",
-                            "$ref": "#/definitions/models.Success"
+                            "$ref": "#/definitions/models.Todo"
                         }
                     },
-                    "404": {
-                        "description": "Task does not exist",
+                    "400": {
+                        "description": "Task cannot be empty, or the list does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
                         "schema": {
                             "type": "object",
                             "$ref": "#/definitions/models.Error"
@@ -217,59 +420,2092 @@ var doc = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Error": {
-            "type": "object",
-            "required": [
-                "message"
-            ],
-            "properties": {
-                "message": {
-                    "type": "string",
-                    "example": "Something went wrong :("
-                }
-            }
         },
-        "models.Success": {
-            "type": "object",
-            "required": [
-                "message"
-            ],
-            "properties": {
-                "message": {
-                    "type": "string",
-                    "example": "Something went right!"
+        "/socket": {
+            "get": {
+                "description": "Upgrades to a WebSocket down which the changes made to Todos from then on are pushed as \"event\" messages. Commands sent over it as JSON are answered in turn with a \"result\" message, or an \"error\" one when they can't be made sense of, with the same ref. Create, update and delete commands are made just like the operations of a batch (POST /tasks:batch) would be, and get the same results. A subscribe command limits the changes pushed to those of the Todos on the given lists and with any of the given tags (or that were, before being updated); subscribing to neither gets all of them again. Sockets that fall too far behind get closed with status 1013, after which they can reconnect and list the Todos again.",
+                "summary": "Follow and make changes to Todos over a WebSocket",
+                "operationId": "todo-socket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Who is making the changes, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "The messages sent down the socket",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.SocketMessage"
+                        }
+                    },
+                    "400": {
+                        "description": "Not a WebSocket handshake",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
                 }
             }
         },
-        "models.Todo": {
-            "type": "object",
-            "required": [
-                "id",
-                "task"
-            ],
-            "properties": {
-                "id": {
-                    "type": "integer",
-                    "example": 1
-                },
-                "task": {
-                    "type": "string",
-                    "example": "Buy milk and eggs"
+        "/tags": {
+            "get": {
+                "description": "Lists the tags in use, in alphabetical order, along with how many Todos use them",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List tags",
+                "operationId": "list-tags",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TagList"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
                 }
             }
         },
-        "models.TodoData": {
-            "type": "object",
-            "required": [
-                "task"
-            ],
-            "properties": {
-                "task": {
-                    "type": "string",
-                    "example": "Buy milk and eggs"
+        "/tasks": {
+            "get": {
+                "description": "Retrieves persisted Todos, a page at a time, in order of id unless sorted otherwise",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List existing Todos",
+                "operationId": "list-existing-todos",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only retrieve Todos with this completion status",
+                        "name": "completed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Only retrieve Todos due before this time (RFC 3339)",
+                        "name": "due_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "description": "Only retrieve Todos due at or after this time (RFC 3339)",
+                        "name": "due_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only retrieve Todos that are (or are not) open and past their due date",
+                        "name": "overdue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only retrieve Todos with this tag; repeat to give several",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "any",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "Whether Todos need any of the tags, the default, or all of them",
+                        "name": "tag_match",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields to sort on, out of id, priority, due and position; prefix with - for descending order, e.g. -priority,due",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The maximum number of Todos in the page, 100 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "The next cursor of the previous page",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only retrieve top-level Todos, each with all of its subtasks nested under children",
+                        "name": "tree",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query or cursor",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a new Todo",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add a new Todo",
+                "operationId": "create-todo",
+                "parameters": [
+                    {
+                        "description": "The request body",
+                        "name": "todo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoData"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Task cannot be empty",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/events": {
+            "get": {
+                "description": "Streams the changes made to Todos as Server-Sent Events, named after their type and with a TodoEvent as their data. Sending the id of the last event received as the Last-Event-ID header (as EventSource does when reconnecting) resumes from there, as long as the events since are still held on to; when they no longer are, a \"reset\" event comes first, after which the Todos had better be listed again. Comments are sent every so often as a heartbeat. Clients that fall too far behind get disconnected, and can resume from the last event they received.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Follow the changes made to Todos",
+                "operationId": "follow-todo-events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The id of the last event received, to resume from",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "A stream of events",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid Last-Event-ID",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/ready": {
+            "get": {
+                "description": "Lists all open Todos whose dependencies are all completed, in an order that respects dependencies, most urgent first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List Todos that are ready",
+                "operationId": "list-ready-todos",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoList"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/search": {
+            "get": {
+                "description": "Finds the Todos whose tasks have every word searched for, or a word starting with it, ignoring case; the most relevant come first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Search Todos",
+                "operationId": "search-todos",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The words to search for",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The maximum number of results, 20 by default",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.SearchResults"
+                        }
+                    },
+                    "400": {
+                        "description": "No words to search for, or too many",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}": {
+            "get": {
+                "description": "Retrieves a persisted Todo, along with its ETag",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a Todo by id",
+                "operationId": "get-existing-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to retrieve",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETags of versions of the Todo you already have",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "304": {
+                        "description": "The Todo has not changed"
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Updates an existing Todo",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update an existing Todo",
+                "operationId": "update-todo",
+                "parameters": [
+                    {
+                        "description": "The request body",
+                        "name": "todo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoData"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to update",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only update if the Todo still has this ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Task cannot be empty, or due date, priority, parent, dependencies, recurrence or list are invalid",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "412": {
+                        "description": "Task has changed",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Moves an existing Todo to the trash, where it can be restored from until it gets purged",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete an existing Todo",
+                "operationId": "delete-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to delete",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "orphan",
+                            "cascade"
+                        ],
+                        "type": "string",
+                        "description": "Whether subtasks become top-level Todos, the default, or get deleted too",
+                        "name": "children",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only delete if the Todo still has this ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Success"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "412": {
+                        "description": "Task has changed",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Updates only the given fields of an existing Todo, following JSON Merge Patch (RFC 7396)",
+                "consumes": [
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Partially update an existing Todo",
+                "operationId": "patch-todo",
+                "parameters": [
+                    {
+                        "description": "The fields to change; null removes a field",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoData"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to update",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only update if the Todo still has this ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid patch, or task cannot be empty",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "412": {
+                        "description": "Task has changed",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "415": {
+                        "description": "Not a merge patch",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/children": {
+            "get": {
+                "description": "Retrieves the Todos that are direct subtasks of an existing Todo, taking the same query parameters as listing all Todos",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the subtasks of a Todo",
+                "operationId": "list-todo-children",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo whose subtasks you want to retrieve",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only retrieve Todos with this completion status",
+                        "name": "completed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only retrieve Todos with this tag; repeat to give several",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated fields to sort on, out of id, priority, due and position; prefix with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The maximum number of Todos in the page, 100 by default",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "The next cursor of the previous page",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Nest all of the subtasks of each Todo under children",
+                        "name": "tree",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TodoPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query or cursor",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/complete": {
+            "post": {
+                "description": "Marks an existing Todo as completed; completing an already-completed Todo does nothing. If it recurs, its next occurrence gets created, skipping any that would already be overdue.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Complete an existing Todo",
+                "operationId": "complete-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to complete",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/dependencies": {
+            "post": {
+                "description": "Makes an existing Todo depend on other Todos, on top of the ones it already depends on; it is blocked until they are all completed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Make a Todo depend on others",
+                "operationId": "add-todo-dependencies",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo that depends on the others",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The ids of the Todos it depends on",
+                        "name": "dependencies",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.DependenciesData"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "A dependency does not exist, or would make the Todo depend on itself",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/dependencies/{dependency_id}": {
+            "delete": {
+                "description": "Removes a dependency of an existing Todo; removing one it does not have does nothing",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Make a Todo stop depending on another",
+                "operationId": "remove-todo-dependency",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo that depends on the other",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo it should stop depending on",
+                        "name": "dependency_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/history": {
+            "get": {
+                "description": "Lists every change made to a Todo, oldest first, with when it was made, by whom (see the X-Actor header), the fields it changed and what the Todo was like after it. Deleted Todos keep their history, even once purged.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the history of a Todo",
+                "operationId": "get-todo-history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo whose history you want",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.History"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist, and never did",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/move": {
+            "post": {
+                "description": "Moves an existing Todo to just before or just after another, or between two others, in the order Todos are arranged in by hand (see sorting on position); only the moved Todo changes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Move a Todo",
+                "operationId": "move-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo to move",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Where to move the Todo to",
+                        "name": "move",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.MoveData"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "The Todos to move it next to do not exist, or it can't go between them",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/reopen": {
+            "post": {
+                "description": "Marks an existing Todo as not completed; reopening a Todo that is not completed does nothing",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Reopen an existing Todo",
+                "operationId": "reopen-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to reopen",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/revert/{rev}": {
+            "post": {
+                "description": "Changes an existing Todo back to what it was as of one of its revisions, which makes a new revision; it keeps its place in the order Todos are arranged in by hand. Its parent, list and dependencies back then must still exist.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Revert a Todo to an earlier revision",
+                "operationId": "revert-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo to revert",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The revision to revert it to",
+                        "name": "rev",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Its parent, list or dependencies back then no longer exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Task or revision does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/tags": {
+            "post": {
+                "description": "Adds tags to an existing Todo, keeping the ones it already has",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Tag an existing Todo",
+                "operationId": "add-todo-tags",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to tag",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The tags to add",
+                        "name": "tags",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.TagsData"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tags",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/tags/{tag}": {
+            "delete": {
+                "description": "Removes a tag from an existing Todo; removing a tag it does not have does nothing",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Untag an existing Todo",
+                "operationId": "remove-todo-tag",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo you want to untag",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "The tag to remove",
+                        "name": "tag",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "404": {
+                        "description": "Task does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks:batch": {
+            "post": {
+                "description": "Creates, updates and deletes Todos, up to 1000 at once, in the given order and all together or not at all. Each operation gets a result with the status its own request would have been answered with; when one fails, none are made and the batch is answered with its status.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Make several changes to Todos at once",
+                "operationId": "batch-todos",
+                "parameters": [
+                    {
+                        "description": "The operations to make",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.BatchData"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the changes, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.BatchResults"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid batch",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "An operation is about a Todo that does not exist",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.BatchResults"
+                        }
+                    },
+                    "409": {
+                        "description": "An operation conflicts with the version of its Todo",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.BatchResults"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash": {
+            "get": {
+                "description": "Lists the deleted Todos that can still be restored, most recently deleted first. They get purged once they have been in the trash for longer than the configured retention.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the trash",
+                "operationId": "list-trash",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Trash"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash/{id}": {
+            "delete": {
+                "description": "Deletes a Todo in the trash for good",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Purge a deleted Todo",
+                "operationId": "purge-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo to purge",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Success"
+                        }
+                    },
+                    "404": {
+                        "description": "Task is not in the trash",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash/{id}/restore": {
+            "post": {
+                "description": "Takes a Todo back out of the trash, as it was when it was deleted, except that it loses its parent, list and dependencies if they have since been deleted too",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restore a deleted Todo",
+                "operationId": "restore-todo",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "The id of the todo to restore",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Who is making the change, as recorded in the history of Todos",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "404": {
+                        "description": "Task is not in the trash",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage failure",
+                        "schema": {
+                            "type": "object",
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.BatchData": {
+            "type": "object",
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchOperation"
+                    }
+                }
+            }
+        },
+        "models.BatchOperation": {
+            "type": "object",
+            "required": [
+                "op"
+            ],
+            "properties": {
+                "children": {
+                    "description": "Children says what happens to the subtasks of a deleted Todo, as the\nchildren query parameter would",
+                    "type": "string",
+                    "enum": [
+                        "orphan",
+                        "cascade"
+                    ],
+                    "example": "orphan"
+                },
+                "id": {
+                    "description": "ID is the id of the Todo to update or delete",
+                    "type": "integer",
+                    "example": 1
+                },
+                "op": {
+                    "description": "Op is one of create, update or delete",
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete"
+                    ],
+                    "example": "update"
+                },
+                "todo": {
+                    "description": "Todo is the data of the Todo to create, or to update it with",
+                    "type": "object",
+                    "$ref": "#/definitions/models.TodoData"
+                },
+                "version": {
+                    "description": "Version makes updating or deleting the Todo fail if it is at any other\nversion, as If-Match would",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "models.BatchResult": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "error": {
+                    "description": "Error is why the operation failed, or was not made because another did",
+                    "type": "object",
+                    "$ref": "#/definitions/models.Error"
+                },
+                "status": {
+                    "description": "Status is the HTTP status the operation would have been answered with\non its own",
+                    "type": "integer",
+                    "example": 200
+                },
+                "todo": {
+                    "description": "Todo is the created or updated Todo",
+                    "type": "object",
+                    "$ref": "#/definitions/models.Todo"
+                }
+            }
+        },
+        "models.BatchResults": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Applied is true if all of the operations were made, and false if none were",
+                    "type": "boolean",
+                    "example": true
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchResult"
+                    }
+                }
+            }
+        },
+        "models.DependenciesData": {
+            "type": "object",
+            "required": [
+                "depends_on"
+            ],
+            "properties": {
+                "depends_on": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2,
+                        3
+                    ]
+                }
+            }
+        },
+        "models.Error": {
+            "type": "object",
+            "required": [
+                "status",
+                "title",
+                "type"
+            ],
+            "properties": {
+                "detail": {
+                    "description": "Detail explains this occurrence of the problem, for humans",
+                    "type": "string",
+                    "example": "Could not find [1] in repo"
+                },
+                "instance": {
+                    "description": "Instance is the request path that the problem occurred at",
+                    "type": "string",
+                    "example": "/tasks/1"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "description": "Title is a short summary of the Type that doesn't change from occurrence to occurrence",
+                    "type": "string",
+                    "example": "Todo not found"
+                },
+                "type": {
+                    "description": "Type identifies the kind of problem, and is stable enough to be matched on",
+                    "type": "string",
+                    "example": "urn:todddo:problem:todo-not-found"
+                }
+            }
+        },
+        "models.FieldChange": {
+            "type": "object",
+            "required": [
+                "field"
+            ],
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "task"
+                },
+                "from": {
+                    "type": "object"
+                },
+                "to": {
+                    "type": "object"
+                }
+            }
+        },
+        "models.History": {
+            "type": "object",
+            "properties": {
+                "revisions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Revision"
+                    }
+                }
+            }
+        },
+        "models.List": {
+            "type": "object",
+            "required": [
+                "id",
+                "name"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Chores"
+                }
+            }
+        },
+        "models.ListData": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "Chores"
+                }
+            }
+        },
+        "models.Lists": {
+            "type": "object",
+            "properties": {
+                "lists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.List"
+                    }
+                }
+            }
+        },
+        "models.MoveData": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "description": "After is the id of the Todo to move the Todo to just after",
+                    "type": "integer",
+                    "example": 2
+                },
+                "before": {
+                    "description": "Before is the id of the Todo to move the Todo to just before",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "models.Revision": {
+            "type": "object",
+            "required": [
+                "at",
+                "change",
+                "rev"
+            ],
+            "properties": {
+                "actor": {
+                    "description": "Actor is who made the change, as given in the X-Actor header",
+                    "type": "string",
+                    "example": "alice"
+                },
+                "at": {
+                    "type": "string",
+                    "example": "2019-06-01T12:00:00Z"
+                },
+                "change": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete",
+                        "restore"
+                    ],
+                    "example": "update"
+                },
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "rev": {
+                    "description": "Rev numbers the revisions of a Todo, starting from 1",
+                    "type": "integer",
+                    "example": 2
+                },
+                "todo": {
+                    "type": "object",
+                    "$ref": "#/definitions/models.Todo"
+                }
+            }
+        },
+        "models.SearchResult": {
+            "type": "object",
+            "required": [
+                "score"
+            ],
+            "properties": {
+                "score": {
+                    "description": "Score says how relevant the Todo is; only meaningful compared to\nthe scores of other results of the same search",
+                    "type": "number",
+                    "example": 0.42
+                },
+                "todo": {
+                    "type": "object",
+                    "$ref": "#/definitions/models.Todo"
+                }
+            }
+        },
+        "models.SearchResults": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SearchResult"
+                    }
+                }
+            }
+        },
+        "models.SocketMessage": {
+            "type": "object",
+            "required": [
+                "type"
+            ],
+            "properties": {
+                "error": {
+                    "description": "Error is why a command could not be made sense of",
+                    "type": "object",
+                    "$ref": "#/definitions/models.Error"
+                },
+                "event": {
+                    "type": "object",
+                    "$ref": "#/definitions/models.TodoEvent"
+                },
+                "ref": {
+                    "description": "Ref is that of the command answered",
+                    "type": "string",
+                    "example": "42"
+                },
+                "result": {
+                    "description": "Result is what came of a command, as it would for a BatchOperation",
+                    "type": "object",
+                    "$ref": "#/definitions/models.BatchResult"
+                },
+                "type": {
+                    "description": "Type is one of event, result or error",
+                    "type": "string",
+                    "enum": [
+                        "event",
+                        "result",
+                        "error"
+                    ],
+                    "example": "event"
+                }
+            }
+        },
+        "models.Success": {
+            "type": "object",
+            "required": [
+                "message"
+            ],
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "Something went right!"
+                }
+            }
+        },
+        "models.TagCount": {
+            "type": "object",
+            "required": [
+                "count",
+                "tag"
+            ],
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "tag": {
+                    "type": "string",
+                    "example": "errands"
+                }
+            }
+        },
+        "models.TagList": {
+            "type": "object",
+            "properties": {
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TagCount"
+                    }
+                }
+            }
+        },
+        "models.TagsData": {
+            "type": "object",
+            "required": [
+                "tags"
+            ],
+            "properties": {
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "errands",
+                        "home"
+                    ]
+                }
+            }
+        },
+        "models.Todo": {
+            "type": "object",
+            "required": [
+                "id",
+                "task"
+            ],
+            "properties": {
+                "blocked": {
+                    "description": "Blocked is true while any of the Todos this one depends on is not\ncompleted yet; ignored when updating",
+                    "type": "boolean",
+                    "example": false
+                },
+                "children": {
+                    "description": "Children are the subtasks of the Todo, only given when listing Todos as a tree",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Todo"
+                    }
+                },
+                "completed": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "completed_at": {
+                    "type": "string",
+                    "example": "2019-08-20T13:14:15Z"
+                },
+                "depends_on": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2,
+                        3
+                    ]
+                },
+                "due_at": {
+                    "type": "string",
+                    "example": "2019-08-21T09:00:00Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "list_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "parent_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "position": {
+                    "description": "Position is where the Todo is in the order Todos are arranged in by\nhand, which sorting on position lists them in; ignored when updating,\nsee moving Todos instead",
+                    "type": "string",
+                    "example": "a1"
+                },
+                "priority": {
+                    "type": "string",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high",
+                        "urgent"
+                    ],
+                    "example": "normal"
+                },
+                "recurrence": {
+                    "type": "string",
+                    "example": "FREQ=WEEKLY;BYDAY=SA"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "errands",
+                        "home"
+                    ]
+                },
+                "task": {
+                    "type": "string",
+                    "example": "Buy milk and eggs"
+                },
+                "version": {
+                    "description": "Version increases with every change, and is also sent as the ETag",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "models.TodoData": {
+            "type": "object",
+            "required": [
+                "task"
+            ],
+            "properties": {
+                "completed": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "depends_on": {
+                    "description": "DependsOn are the ids of the Todos that have to be completed before this one",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2,
+                        3
+                    ]
+                },
+                "due_at": {
+                    "type": "string",
+                    "example": "2019-08-21T09:00:00Z"
+                },
+                "list_id": {
+                    "description": "ListID puts the Todo on the list with this id",
+                    "type": "integer",
+                    "example": 1
+                },
+                "parent_id": {
+                    "description": "ParentID makes the Todo a subtask of the one with this id",
+                    "type": "integer",
+                    "example": 1
+                },
+                "priority": {
+                    "description": "Priority is one of low, normal (the default), high or urgent",
+                    "type": "string",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high",
+                        "urgent"
+                    ],
+                    "example": "normal"
+                },
+                "recurrence": {
+                    "description": "Recurrence is an RFC 5545 recurrence rule, limited to FREQ (DAILY,\nWEEKLY or MONTHLY), INTERVAL, BYDAY, COUNT and UNTIL. Completing the\nTodo creates its next occurrence, which the rule moves on to.",
+                    "type": "string",
+                    "example": "FREQ=WEEKLY;BYDAY=SA"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "errands",
+                        "home"
+                    ]
+                },
+                "task": {
+                    "type": "string",
+                    "example": "Buy milk and eggs"
+                }
+            }
+        },
+        "models.TodoEvent": {
+            "type": "object",
+            "required": [
+                "at",
+                "id",
+                "type"
+            ],
+            "properties": {
+                "actor": {
+                    "type": "string",
+                    "example": "alice"
+                },
+                "at": {
+                    "type": "string",
+                    "example": "2019-06-01T12:00:00Z"
+                },
+                "id": {
+                    "description": "ID is what to resume following from this event with, e.g. as the\nLast-Event-ID",
+                    "type": "string",
+                    "example": "1560000000000000001"
+                },
+                "previous": {
+                    "description": "Previous is the Todo as it was right before an update",
+                    "type": "object",
+                    "$ref": "#/definitions/models.Todo"
+                },
+                "todo": {
+                    "description": "Todo is as it was right after the change, or right before it for\ndeletions",
+                    "type": "object",
+                    "$ref": "#/definitions/models.Todo"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "todo.created",
+                        "todo.updated",
+                        "todo.deleted",
+                        "todo.restored"
+                    ],
+                    "example": "todo.updated"
+                }
+            }
+        },
+        "models.TodoList": {
+            "type": "object",
+            "properties": {
+                "todos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Todo"
+                    }
+                }
+            }
+        },
+        "models.TodoPage": {
+            "type": "object",
+            "properties": {
+                "next": {
+                    "description": "Next is the cursor to pass as after to get the next page; absent on the last page",
+                    "type": "string",
+                    "example": "eyJpZCI6MTAwfQ"
+                },
+                "todos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Todo"
+                    }
+                }
+            }
+        },
+        "models.Trash": {
+            "type": "object",
+            "properties": {
+                "todos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TrashedTodo"
+                    }
+                }
+            }
+        },
+        "models.TrashedTodo": {
+            "type": "object",
+            "required": [
+                "deleted_at"
+            ],
+            "properties": {
+                "deleted_at": {
+                    "type": "string",
+                    "example": "2019-06-01T12:00:00Z"
+                },
+                "todo": {
+                    "type": "object",
+                    "$ref": "#/definitions/models.Todo"
                 }
             }
         }
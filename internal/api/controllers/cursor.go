@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// cursorData is what goes inside the opaque cursors handed out to API
+// clients, so that what a domain.TodoCursor holds can change without
+// clients noticing
+type cursorData struct {
+	ID       domain.TodoID   `json:"id"`
+	Priority domain.Priority `json:"p,omitempty"`
+	DueAt    *time.Time      `json:"d,omitempty"`
+	Position string          `json:"o,omitempty"`
+}
+
+func encodeCursor(cursor *domain.TodoCursor) string {
+	// Marshalling a struct of plain values cannot fail
+	bytes, _ := json.Marshal(cursorData{ID: cursor.ID, Priority: cursor.Priority, DueAt: cursor.DueAt, Position: cursor.Position})
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func decodeCursor(encoded string) (*domain.TodoCursor, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var data cursorData
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return nil, err
+	}
+	return &domain.TodoCursor{ID: data.ID, Priority: data.Priority, DueAt: data.DueAt, Position: data.Position}, nil
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// unrevisedFields are the fields of a Todo left out of the changes made by
+// revisions, as they change along with everything else or are not part of
+// the Todo itself
+var unrevisedFields = map[string]bool{"id": true, "version": true, "blocked": true, "children": true}
+
+// toApiHistory converts the revisions of a Todo, working out the fields
+// each one changed from the revision before it
+func toApiHistory(revisions []domain.TodoRevision) (models.History, error) {
+	apiRevisions := make([]models.Revision, len(revisions))
+	previous := toApiTodo(&domain.Todo{})
+	for i, revision := range revisions {
+		apiTodo := toApiTodo(&revision.Todo)
+		changes, err := fieldChanges(&previous, &apiTodo)
+		if err != nil {
+			return models.History{}, err
+		}
+		apiRevisions[i] = models.Revision{
+			Rev:     revision.Rev,
+			Change:  string(revision.Change),
+			At:      revision.At,
+			Actor:   revision.Actor,
+			Changes: changes,
+			Todo:    apiTodo,
+		}
+		previous = apiTodo
+	}
+	return models.History{Revisions: apiRevisions}, nil
+}
+
+// fieldChanges compares the JSON fields of two Todos, returning those that
+// differ in alphabetical order
+func fieldChanges(before, after *models.Todo) ([]models.FieldChange, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, inBefore := beforeFields[name]; !inBefore {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	changes := []models.FieldChange{}
+	for _, name := range names {
+		if unrevisedFields[name] || reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{Field: name, From: beforeFields[name], To: afterFields[name]})
+	}
+	return changes, nil
+}
+
+func jsonFields(todo *models.Todo) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
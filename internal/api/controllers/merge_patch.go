@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// applyMergePatch applies the given JSON Merge Patch (RFC 7396) to the
+// given JSON object, returning the patched object
+func applyMergePatch(target, patch []byte) ([]byte, error) {
+	var targetValue interface{}
+	if err := json.Unmarshal(target, &targetValue); err != nil {
+		return nil, err
+	}
+	var patchValue interface{}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, err
+	}
+	if _, isObject := patchValue.(map[string]interface{}); !isObject {
+		// Valid as far as RFC 7396 goes, but it would replace the whole
+		// resource with something that is not a resource
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+	return json.Marshal(mergePatch(targetValue, patchValue))
+}
+
+// mergePatch is the MergePatch function from section 2 of RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, isObject := patch.(map[string]interface{})
+	if !isObject {
+		return patch
+	}
+	targetObject, isObject := target.(map[string]interface{})
+	if !isObject {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Examples from appendix A of RFC 7396
+func TestApplyMergePatchRfcExamples(t *testing.T) {
+	examples := []struct {
+		target   string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, example := range examples {
+		patched, err := applyMergePatch([]byte(example.target), []byte(example.patch))
+		assert.Nil(t, err)
+		assert.JSONEq(t, example.expected, string(patched), example.patch)
+	}
+}
+
+func TestApplyMergePatchRejectsNonObjects(t *testing.T) {
+	for _, patch := range []string{`["a"]`, `"a"`, `null`, `{`} {
+		_, err := applyMergePatch([]byte(`{"a":"b"}`), []byte(patch))
+		assert.NotNil(t, err, patch)
+	}
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/lloydmeta/todddo-openapi/internal/api/models"
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
@@ -8,12 +10,36 @@ import (
 	"net/http"
 )
 
+// maxPatchAttempts is how many times a patch gets applied before giving up
+// because the Todo keeps getting changed by someone else in between
+const maxPatchAttempts = 5
+
 type TodoController interface {
 	Create(newTodo *models.TodoData) (models.Todo, models.ApiError)
 	Get(id *domain.TodoID) (models.Todo, models.ApiError)
-	Delete(id *domain.TodoID) (models.Success, models.ApiError)
-	List() []models.Todo
+	Delete(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (models.Success, models.ApiError)
+	List(query *models.TodoQuery) (models.TodoPage, models.ApiError)
 	Update(todo *models.Todo) (models.Todo, models.ApiError)
+	Patch(id *domain.TodoID, version domain.TodoVersion, mergePatch []byte) (models.Todo, models.ApiError)
+	Complete(id *domain.TodoID) (models.Todo, models.ApiError)
+	Reopen(id *domain.TodoID) (models.Todo, models.ApiError)
+	AddTags(id *domain.TodoID, tags *models.TagsData) (models.Todo, models.ApiError)
+	RemoveTag(id *domain.TodoID, tag string) (models.Todo, models.ApiError)
+	ListTags() (models.TagList, models.ApiError)
+	Search(query *models.SearchQuery) (models.SearchResults, models.ApiError)
+	AddDependencies(id *domain.TodoID, dependencies *models.DependenciesData) (models.Todo, models.ApiError)
+	RemoveDependency(id *domain.TodoID, dependencyID *domain.TodoID) (models.Todo, models.ApiError)
+	Ready() (models.TodoList, models.ApiError)
+	Move(id *domain.TodoID, move *models.MoveData) (models.Todo, models.ApiError)
+	Batch(batch *models.BatchData) (models.BatchResults, models.ApiError)
+	ListTrash() (models.Trash, models.ApiError)
+	Restore(id *domain.TodoID) (models.Todo, models.ApiError)
+	Purge(id *domain.TodoID) (models.Success, models.ApiError)
+	History(id *domain.TodoID) (models.History, models.ApiError)
+	Revert(id *domain.TodoID, rev uint) (models.Todo, models.ApiError)
+	// AsActor returns a TodoController whose changes are recorded in the
+	// history of Todos as made by the given actor
+	AsActor(actor string) TodoController
 }
 
 // MkTodosController returns a TodoController when given a services.TodoService
@@ -26,16 +52,14 @@ type TodosControllerImpl struct {
 }
 
 func (t *TodosControllerImpl) Create(newTodo *models.TodoData) (models.Todo, models.ApiError) {
-	domainTodo := domain.NewTodo{
-		Task: newTodo.Task,
+	domainTodo, conversionErr := toDomainNewTodo(newTodo)
+	if conversionErr != nil {
+		return models.Todo{}, conversionErr
 	}
 	if persisted, err := t.service.Create(&domainTodo); err == nil {
 		return toApiTodo(&persisted), nil
 	} else {
-		return models.Todo{}, TodosControllerError{
-			httpStatusCode: http.StatusBadRequest,
-			message:        err.Error(),
-		}
+		return models.Todo{}, fromServiceError(err)
 	}
 
 }
@@ -44,67 +68,641 @@ func (t *TodosControllerImpl) Get(id *domain.TodoID) (models.Todo, models.ApiErr
 	if found, err := t.service.Get(id); err == nil {
 		return toApiTodo(&found), nil
 	} else {
-		return models.Todo{}, TodosControllerError{
-			httpStatusCode: http.StatusNotFound,
-			message:        err.Error(),
-		}
+		return models.Todo{}, fromServiceError(err)
 	}
 }
 
-func (t *TodosControllerImpl) Delete(id *domain.TodoID) (models.Success, models.ApiError) {
-	if _, err := t.service.Delete(id); err == nil {
+// Delete moves the Todo with the given id to the trash, which must be at the
+// given version unless that is zero, along with its children if asked to
+func (t *TodosControllerImpl) Delete(id *domain.TodoID, version domain.TodoVersion, query *models.DeleteQuery) (models.Success, models.ApiError) {
+	if _, err := t.service.Delete(id, version, toChildrenPolicy(query.Children)); err == nil {
 		return models.Success{Message: fmt.Sprintf("Successfully deleted Todo with id [%v]", *id)}, nil
 	} else {
-		return models.Success{}, TodosControllerError{
-			httpStatusCode: http.StatusNotFound,
-			message:        err.Error(),
-		}
+		return models.Success{}, fromServiceError(err)
 	}
 }
 
-func (t *TodosControllerImpl) List() []models.Todo {
-	domainTodos := t.service.List()
-	apiTodos := make([]models.Todo, len(domainTodos))
-	for i, domainTodo := range domainTodos {
-		apiTodos[i] = toApiTodo(&domainTodo)
+func (t *TodosControllerImpl) List(query *models.TodoQuery) (models.TodoPage, models.ApiError) {
+	domainQuery := domain.TodoQuery{
+		Completed: query.Completed,
+		DueBefore: query.DueBefore,
+		DueAfter:  query.DueAfter,
+		Overdue:   query.Overdue,
+		Tags:      query.Tags,
+		AllTags:   query.TagMatch == models.AllTagsMatch,
+		ParentID:  query.ParentID,
+		ListID:    query.ListID,
+		TopLevel:  query.Tree && query.ParentID == nil,
+		Sort:      toDomainSort(query.SortKeys),
+		Limit:     query.Limit,
+	}
+	if domainQuery.Limit == 0 {
+		domainQuery.Limit = models.DefaultPageSize
+	}
+	if len(query.After) > 0 {
+		if after, err := decodeCursor(query.After); err == nil {
+			domainQuery.After = after
+		} else {
+			return models.TodoPage{}, TodosControllerError{
+				problemType:    models.InvalidCursorProblem,
+				httpStatusCode: http.StatusBadRequest,
+				message:        fmt.Sprintf("Invalid cursor: [%s]", query.After),
+			}
+		}
+	}
+	if domainPage, err := t.service.List(&domainQuery); err == nil {
+		apiTodos := make([]models.Todo, len(domainPage.Todos))
+		if query.Tree {
+			trees, err := t.service.Subtrees(domainPage.Todos)
+			if err != nil {
+				return models.TodoPage{}, fromServiceError(err)
+			}
+			for i, tree := range trees {
+				apiTodos[i] = toApiTodoTree(&tree)
+			}
+		} else {
+			for i, domainTodo := range domainPage.Todos {
+				apiTodos[i] = toApiTodo(&domainTodo)
+			}
+		}
+		apiPage := models.TodoPage{Todos: apiTodos}
+		if domainPage.Next != nil {
+			next := encodeCursor(domainPage.Next)
+			apiPage.Next = &next
+		}
+		return apiPage, nil
+	} else {
+		return models.TodoPage{}, fromServiceError(err)
 	}
-	return apiTodos
 }
 
+// Update replaces the data of an existing Todo, which must be at the
+// Version of the given one unless that is zero
 func (t *TodosControllerImpl) Update(todo *models.Todo) (models.Todo, models.ApiError) {
-	domainTodo := toDomainTodo(todo)
-	if _, err := t.service.Update(&domainTodo); err == nil {
-		return *todo, nil
-	} else {
-		switch err.(type) {
-		case services.TodoNotFound:
-			return *todo, TodosControllerError{
-				httpStatusCode: http.StatusNotFound,
-				message:        err.Error(),
+	domainTodo, conversionErr := toDomainTodo(todo)
+	if conversionErr != nil {
+		return *todo, conversionErr
+	}
+	if updated, err := t.service.Update(&domainTodo); err == nil {
+		return toApiTodo(&updated), nil
+	} else {
+		return *todo, fromServiceError(err)
+	}
+}
+
+// Patch applies the given JSON Merge Patch (RFC 7396) to the models.TodoData
+// of an existing Todo, then updates it with the result.
+//
+// The Todo must be at the given version unless that is zero, in which case
+// the patch is applied again if the Todo gets changed while patching.
+func (t *TodosControllerImpl) Patch(id *domain.TodoID, version domain.TodoVersion, mergePatch []byte) (models.Todo, models.ApiError) {
+	for attempt := 1; ; attempt++ {
+		existing, err := t.service.Get(id)
+		if err != nil {
+			return models.Todo{}, fromServiceError(err)
+		}
+		// Marshalling a struct of plain values cannot fail
+		existingData, _ := json.Marshal(toApiTodoData(&existing))
+		patchedData, patchErr := applyMergePatch(existingData, mergePatch)
+		if patchErr != nil {
+			return models.Todo{}, TodosControllerError{
+				problemType:    models.InvalidMergePatchProblem,
+				httpStatusCode: http.StatusBadRequest,
+				message:        fmt.Sprintf("Invalid merge patch: %v", patchErr),
 			}
-		default:
-			return *todo, TodosControllerError{
+		}
+		var patched models.TodoData
+		decoder := json.NewDecoder(bytes.NewReader(patchedData))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&patched); err != nil {
+			return models.Todo{}, TodosControllerError{
+				problemType:    models.InvalidMergePatchProblem,
 				httpStatusCode: http.StatusBadRequest,
-				message:        err.Error(),
+				message:        fmt.Sprintf("Invalid merge patch: %v", err),
+			}
+		}
+		priority, priorityErr := toDomainPriority(patched.Priority)
+		if priorityErr != nil {
+			return models.Todo{}, priorityErr
+		}
+		todo := domain.Todo{
+			ID:         *id,
+			Version:    existing.Version,
+			Priority:   priority,
+			Task:       patched.Task,
+			Completed:  patched.Completed,
+			DueAt:      patched.DueAt,
+			Tags:       patched.Tags,
+			ParentID:   patched.ParentID,
+			DependsOn:  patched.DependsOn,
+			Recurrence: patched.Recurrence,
+			ListID:     patched.ListID,
+		}
+		if version != 0 {
+			todo.Version = version
+		}
+		updated, err := t.service.Update(&todo)
+		if err == nil {
+			return toApiTodo(&updated), nil
+		}
+		if _, conflict := err.(services.TodoVersionConflict); !conflict || version != 0 || attempt >= maxPatchAttempts {
+			return models.Todo{}, fromServiceError(err)
+		}
+	}
+}
+
+func (t *TodosControllerImpl) Complete(id *domain.TodoID) (models.Todo, models.ApiError) {
+	if completed, err := t.service.Complete(id); err == nil {
+		return toApiTodo(&completed), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+func (t *TodosControllerImpl) Reopen(id *domain.TodoID) (models.Todo, models.ApiError) {
+	if reopened, err := t.service.Reopen(id); err == nil {
+		return toApiTodo(&reopened), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+// AddTags tags an existing Todo with the given tags, keeping the ones it
+// already has
+func (t *TodosControllerImpl) AddTags(id *domain.TodoID, tags *models.TagsData) (models.Todo, models.ApiError) {
+	if tagged, err := t.service.AddTags(id, tags.Tags); err == nil {
+		return toApiTodo(&tagged), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+func (t *TodosControllerImpl) RemoveTag(id *domain.TodoID, tag string) (models.Todo, models.ApiError) {
+	if untagged, err := t.service.RemoveTags(id, []string{tag}); err == nil {
+		return toApiTodo(&untagged), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+func (t *TodosControllerImpl) ListTags() (models.TagList, models.ApiError) {
+	if tagCounts, err := t.service.ListTags(); err == nil {
+		apiTagCounts := make([]models.TagCount, len(tagCounts))
+		for i, tagCount := range tagCounts {
+			apiTagCounts[i] = models.TagCount{Tag: tagCount.Tag, Count: tagCount.Count}
+		}
+		return models.TagList{Tags: apiTagCounts}, nil
+	} else {
+		return models.TagList{}, fromServiceError(err)
+	}
+}
+
+func (t *TodosControllerImpl) Search(query *models.SearchQuery) (models.SearchResults, models.ApiError) {
+	domainSearch := domain.TodoSearch{Text: query.Q, Limit: query.Limit}
+	if domainSearch.Limit == 0 {
+		domainSearch.Limit = models.DefaultSearchResults
+	}
+	if results, err := t.service.Search(&domainSearch); err == nil {
+		apiResults := make([]models.SearchResult, len(results))
+		for i, result := range results {
+			apiResults[i] = models.SearchResult{Todo: toApiTodo(&result.Todo), Score: result.Score}
+		}
+		return models.SearchResults{Results: apiResults}, nil
+	} else {
+		return models.SearchResults{}, fromServiceError(err)
+	}
+}
+
+// AddDependencies makes an existing Todo depend on the Todos with the given
+// ids, on top of the ones it already depends on
+func (t *TodosControllerImpl) AddDependencies(id *domain.TodoID, dependencies *models.DependenciesData) (models.Todo, models.ApiError) {
+	if updated, err := t.service.AddDependencies(id, dependencies.DependsOn); err == nil {
+		return toApiTodo(&updated), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+func (t *TodosControllerImpl) RemoveDependency(id *domain.TodoID, dependencyID *domain.TodoID) (models.Todo, models.ApiError) {
+	if updated, err := t.service.RemoveDependencies(id, []domain.TodoID{*dependencyID}); err == nil {
+		return toApiTodo(&updated), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+// Ready lists the open Todos that don't have to wait for any others, in the
+// order they are best done in
+func (t *TodosControllerImpl) Ready() (models.TodoList, models.ApiError) {
+	if ready, err := t.service.Ready(); err == nil {
+		apiTodos := make([]models.Todo, len(ready))
+		for i, domainTodo := range ready {
+			apiTodos[i] = toApiTodo(&domainTodo)
+		}
+		return models.TodoList{Todos: apiTodos}, nil
+	} else {
+		return models.TodoList{}, fromServiceError(err)
+	}
+}
+
+// ListTrash lists the deleted Todos that can still be restored, most
+// recently deleted first
+func (t *TodosControllerImpl) ListTrash() (models.Trash, models.ApiError) {
+	if trashed, err := t.service.ListTrash(); err == nil {
+		apiTrashed := make([]models.TrashedTodo, len(trashed))
+		for i, domainTrashed := range trashed {
+			apiTrashed[i] = models.TrashedTodo{Todo: toApiTodo(&domainTrashed.Todo), DeletedAt: domainTrashed.DeletedAt}
+		}
+		return models.Trash{Todos: apiTrashed}, nil
+	} else {
+		return models.Trash{}, fromServiceError(err)
+	}
+}
+
+func (t *TodosControllerImpl) Restore(id *domain.TodoID) (models.Todo, models.ApiError) {
+	if restored, err := t.service.Restore(id); err == nil {
+		return toApiTodo(&restored), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+// Purge deletes the Todo with the given id from the trash, for good
+func (t *TodosControllerImpl) Purge(id *domain.TodoID) (models.Success, models.ApiError) {
+	if _, err := t.service.Purge(id); err == nil {
+		return models.Success{Message: fmt.Sprintf("Successfully purged Todo with id [%v]", *id)}, nil
+	} else {
+		return models.Success{}, fromServiceError(err)
+	}
+}
+
+// History lists the revisions of the Todo with the given id, oldest first,
+// along with the fields each of them changed
+func (t *TodosControllerImpl) History(id *domain.TodoID) (models.History, models.ApiError) {
+	revisions, err := t.service.History(id)
+	if err != nil {
+		return models.History{}, fromServiceError(err)
+	}
+	if history, err := toApiHistory(revisions); err == nil {
+		return history, nil
+	} else {
+		return models.History{}, TodosControllerError{
+			problemType:    models.GenericProblem,
+			httpStatusCode: http.StatusInternalServerError,
+			message:        err.Error(),
+		}
+	}
+}
+
+// Revert changes the Todo with the given id back to what it was as of the
+// given revision
+func (t *TodosControllerImpl) Revert(id *domain.TodoID, rev uint) (models.Todo, models.ApiError) {
+	if reverted, err := t.service.Revert(id, rev); err == nil {
+		return toApiTodo(&reverted), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+func (t *TodosControllerImpl) AsActor(actor string) TodoController {
+	return &TodosControllerImpl{service: t.service.AsActor(actor)}
+}
+
+// Move moves an existing Todo next to others, in the order Todos are
+// arranged in by hand
+func (t *TodosControllerImpl) Move(id *domain.TodoID, move *models.MoveData) (models.Todo, models.ApiError) {
+	if moved, err := t.service.Move(id, move.Before, move.After); err == nil {
+		return toApiTodo(&moved), nil
+	} else {
+		return models.Todo{}, fromServiceError(err)
+	}
+}
+
+// Batch makes all of the operations of the given batch, or none of them if
+// any fails, returning what came of each of them in the same order.
+//
+// A batch that fails because of one of its operations is not an error: the
+// results say which operation it was and why, and that the others were not
+// made.
+func (t *TodosControllerImpl) Batch(batch *models.BatchData) (models.BatchResults, models.ApiError) {
+	ops := make([]services.BatchOp, len(batch.Operations))
+	for i, operation := range batch.Operations {
+		op, err := toBatchOp(&operation)
+		if err != nil {
+			return models.BatchResults{}, TodosControllerError{
+				problemType:    err.AsModel().Type,
+				httpStatusCode: err.HttpStatusCode(),
+				message:        fmt.Sprintf("Operation [%d]: %s", i, err.Error()),
+			}
+		}
+		ops[i] = op
+	}
+	results := make([]models.BatchResult, len(ops))
+	if persisted, err := t.service.Batch(ops); err == nil {
+		for i, op := range ops {
+			switch {
+			case op.Create != nil:
+				apiTodo := toApiTodo(&persisted[i])
+				results[i] = models.BatchResult{Status: http.StatusCreated, Todo: &apiTodo}
+			case op.Update != nil:
+				apiTodo := toApiTodo(&persisted[i])
+				results[i] = models.BatchResult{Status: http.StatusOK, Todo: &apiTodo}
+			default:
+				results[i] = models.BatchResult{Status: http.StatusOK}
 			}
 		}
+		return models.BatchResults{Applied: true, Results: results}, nil
+	} else if batchErr, ok := err.(services.TodoBatchError); ok {
+		for i := range ops {
+			var problem models.Error
+			if i == batchErr.Index {
+				problem = fromServiceError(batchErr.Cause).AsModel()
+			} else {
+				detail := fmt.Sprintf("Not made, as operation [%d] failed", batchErr.Index)
+				problem = models.MkError(models.BatchAbortedProblem, http.StatusFailedDependency, detail)
+			}
+			results[i] = models.BatchResult{Status: problem.Status, Error: &problem}
+		}
+		return models.BatchResults{Applied: false, Results: results}, nil
+	} else {
+		return models.BatchResults{}, fromServiceError(err)
+	}
+}
+
+// toBatchOp converts an operation of a batch, checking that it has what
+// its kind of operation needs
+func toBatchOp(operation *models.BatchOperation) (services.BatchOp, models.ApiError) {
+	invalid := func(message string) (services.BatchOp, models.ApiError) {
+		return services.BatchOp{}, TodosControllerError{
+			problemType:    models.InvalidRequestProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        message,
+		}
+	}
+	if operation.Op != models.CreateOperation && operation.ID == 0 {
+		return invalid(fmt.Sprintf("id is required to %s a Todo", operation.Op))
+	}
+	if operation.Op != models.DeleteOperation && operation.Todo == nil {
+		return invalid(fmt.Sprintf("todo is required to %s a Todo", operation.Op))
+	}
+	var converted services.BatchOp
+	var conversionErr models.ApiError
+	switch operation.Op {
+	case models.CreateOperation:
+		var newTodo domain.NewTodo
+		newTodo, conversionErr = toDomainNewTodo(operation.Todo)
+		converted.Create = &newTodo
+	case models.UpdateOperation:
+		var todo domain.Todo
+		todo, conversionErr = toDomainTodo(&models.Todo{
+			ID:         operation.ID,
+			Version:    operation.Version,
+			Task:       operation.Todo.Task,
+			Completed:  operation.Todo.Completed,
+			DueAt:      operation.Todo.DueAt,
+			Tags:       operation.Todo.Tags,
+			Priority:   operation.Todo.Priority,
+			ParentID:   operation.Todo.ParentID,
+			DependsOn:  operation.Todo.DependsOn,
+			Recurrence: operation.Todo.Recurrence,
+			ListID:     operation.Todo.ListID,
+		})
+		converted.Update = &todo
+	default:
+		converted.Delete = &services.BatchDelete{
+			ID:       operation.ID,
+			Version:  operation.Version,
+			Children: toChildrenPolicy(operation.Children),
+		}
 	}
+	if conversionErr != nil {
+		return services.BatchOp{}, conversionErr
+	}
+	return converted, nil
+}
+
+// toChildrenPolicy converts a validated children parameter, where none at
+// all means orphaning them
+func toChildrenPolicy(children string) services.ChildrenPolicy {
+	if children == models.CascadeToChildren {
+		return services.DeleteChildren
+	}
+	return services.OrphanChildren
 }
 
 func toApiTodo(domainTodo *domain.Todo) models.Todo {
 	return models.Todo{
-		ID:   domainTodo.ID,
-		Task: domainTodo.Task,
+		ID:          domainTodo.ID,
+		Version:     domainTodo.Version,
+		Task:        domainTodo.Task,
+		Completed:   domainTodo.Completed,
+		CompletedAt: domainTodo.CompletedAt,
+		DueAt:       domainTodo.DueAt,
+		Tags:        toApiTags(domainTodo.Tags),
+		Priority:    domainTodo.Priority.String(),
+		ParentID:    domainTodo.ParentID,
+		DependsOn:   domainTodo.DependsOn,
+		Recurrence:  domainTodo.Recurrence,
+		ListID:      domainTodo.ListID,
+		Position:    domainTodo.Position,
+		Blocked:     domainTodo.Blocked,
+	}
+}
+
+// toApiTodoTree converts a Todo along with all of its descendants
+func toApiTodoTree(tree *domain.TodoTree) models.Todo {
+	apiTodo := toApiTodo(&tree.Todo)
+	for _, child := range tree.Children {
+		apiTodo.Children = append(apiTodo.Children, toApiTodoTree(&child))
+	}
+	return apiTodo
+}
+func toApiTodoData(domainTodo *domain.Todo) models.TodoData {
+	return models.TodoData{
+		Task:       domainTodo.Task,
+		Completed:  domainTodo.Completed,
+		DueAt:      domainTodo.DueAt,
+		Tags:       toApiTags(domainTodo.Tags),
+		Priority:   domainTodo.Priority.String(),
+		ParentID:   domainTodo.ParentID,
+		DependsOn:  domainTodo.DependsOn,
+		Recurrence: domainTodo.Recurrence,
+		ListID:     domainTodo.ListID,
 	}
 }
-func toDomainTodo(apiTodo *models.Todo) domain.Todo {
+func toDomainNewTodo(apiTodoData *models.TodoData) (domain.NewTodo, models.ApiError) {
+	priority, err := toDomainPriority(apiTodoData.Priority)
+	if err != nil {
+		return domain.NewTodo{}, err
+	}
+	return domain.NewTodo{
+		Task:       apiTodoData.Task,
+		Completed:  apiTodoData.Completed,
+		DueAt:      apiTodoData.DueAt,
+		Tags:       apiTodoData.Tags,
+		Priority:   priority,
+		ParentID:   apiTodoData.ParentID,
+		DependsOn:  apiTodoData.DependsOn,
+		Recurrence: apiTodoData.Recurrence,
+		ListID:     apiTodoData.ListID,
+	}, nil
+}
+func toDomainTodo(apiTodo *models.Todo) (domain.Todo, models.ApiError) {
+	priority, err := toDomainPriority(apiTodo.Priority)
+	if err != nil {
+		return domain.Todo{}, err
+	}
 	return domain.Todo{
-		ID:   apiTodo.ID,
-		Task: apiTodo.Task,
+		ID:          apiTodo.ID,
+		Version:     apiTodo.Version,
+		Task:        apiTodo.Task,
+		Completed:   apiTodo.Completed,
+		CompletedAt: apiTodo.CompletedAt,
+		DueAt:       apiTodo.DueAt,
+		Tags:        apiTodo.Tags,
+		Priority:    priority,
+		ParentID:    apiTodo.ParentID,
+		DependsOn:   apiTodo.DependsOn,
+		Recurrence:  apiTodo.Recurrence,
+		ListID:      apiTodo.ListID,
+	}, nil
+}
+
+// toDomainPriority parses the name of a priority, where no name at all
+// means normal priority
+func toDomainPriority(name string) (domain.Priority, models.ApiError) {
+	if len(name) == 0 {
+		return domain.NormalPriority, nil
+	}
+	if priority, ok := domain.ParsePriority(name); ok {
+		return priority, nil
+	} else {
+		return domain.NormalPriority, TodosControllerError{
+			problemType:    models.InvalidTodoProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        fmt.Sprintf("Priority must be one of low, normal, high or urgent: [%s]", name),
+		}
+	}
+}
+
+// toDomainSort converts sort keys that have already been validated
+func toDomainSort(apiSortKeys []models.SortKey) []domain.SortKey {
+	var domainSortKeys []domain.SortKey
+	for _, apiSortKey := range apiSortKeys {
+		domainSortKeys = append(domainSortKeys, domain.SortKey{
+			Field:      sortFields[apiSortKey.Field],
+			Descending: apiSortKey.Descending,
+		})
+	}
+	return domainSortKeys
+}
+
+// sortFields maps models.SortFields to what they sort on
+var sortFields = map[string]domain.SortField{
+	"id":       domain.SortByID,
+	"priority": domain.SortByPriority,
+	"due":      domain.SortByDueAt,
+	"position": domain.SortByPosition,
+}
+
+// toApiTags makes sure Todos without tags get an empty list of them
+// rather than null
+func toApiTags(domainTags []string) []string {
+	if domainTags == nil {
+		return []string{}
+	}
+	return domainTags
+}
+
+// fromServiceError maps errors coming out of services.TodoService and
+// services.TodoListService onto
+// the HTTP status codes they should be reported with
+func fromServiceError(err services.TodoServiceError) TodosControllerError {
+	switch err.(type) {
+	case services.TodoNotFound, services.TrashedTodoNotFound:
+		return TodosControllerError{
+			problemType:    models.TodoNotFoundProblem,
+			httpStatusCode: http.StatusNotFound,
+			message:        err.Error(),
+		}
+	case services.TodoRevisionNotFound:
+		return TodosControllerError{
+			problemType:    models.RevisionNotFoundProblem,
+			httpStatusCode: http.StatusNotFound,
+			message:        err.Error(),
+		}
+	case services.TodoVersionConflict:
+		return TodosControllerError{
+			problemType:    models.VersionConflictProblem,
+			httpStatusCode: http.StatusPreconditionFailed,
+			message:        err.Error(),
+		}
+	case services.TodoStorageError:
+		return TodosControllerError{
+			problemType:    models.StorageFailureProblem,
+			httpStatusCode: http.StatusInternalServerError,
+			message:        err.Error(),
+		}
+	case *services.TodoDataError, services.TodoDataError, services.TodoDueAtError, services.TodoTagError,
+		services.TodoRecurrenceError:
+		return TodosControllerError{
+			problemType:    models.InvalidTodoProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        err.Error(),
+		}
+	case services.TodoParentNotFound, services.TodoParentCycle:
+		return TodosControllerError{
+			problemType:    models.InvalidParentProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        err.Error(),
+		}
+	case services.TodoDependencyNotFound, services.TodoDependencyCycle:
+		return TodosControllerError{
+			problemType:    models.InvalidDependencyProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        err.Error(),
+		}
+	case services.ListNotFound:
+		return TodosControllerError{
+			problemType:    models.ListNotFoundProblem,
+			httpStatusCode: http.StatusNotFound,
+			message:        err.Error(),
+		}
+	case services.ListNotEmpty:
+		return TodosControllerError{
+			problemType:    models.ListNotEmptyProblem,
+			httpStatusCode: http.StatusConflict,
+			message:        err.Error(),
+		}
+	case services.ListNameError, services.TodoListNotFound:
+		return TodosControllerError{
+			problemType:    models.InvalidListProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        err.Error(),
+		}
+	case services.TodoMoveError, services.TodoMoveTargetNotFound:
+		return TodosControllerError{
+			problemType:    models.InvalidMoveProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        err.Error(),
+		}
+	case services.TodoSearchError:
+		return TodosControllerError{
+			problemType:    models.InvalidSearchProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        err.Error(),
+		}
+	default:
+		return TodosControllerError{
+			problemType:    models.GenericProblem,
+			httpStatusCode: http.StatusBadRequest,
+			message:        err.Error(),
+		}
 	}
 }
 
 type TodosControllerError struct {
+	problemType    models.ProblemType
 	httpStatusCode int
 	message        string
 }
@@ -114,7 +712,7 @@ func (t TodosControllerError) Error() string {
 }
 
 func (t TodosControllerError) AsModel() models.Error {
-	return models.Error{Message: t.message}
+	return models.MkError(t.problemType, t.httpStatusCode, t.message)
 }
 
 func (t TodosControllerError) HttpStatusCode() int {
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	apiModels "github.com/lloydmeta/todddo-openapi/internal/api/models"
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
 	"github.com/lloydmeta/todddo-openapi/internal/domain/services"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
+	"time"
 )
 
 func TestCreateOk(t *testing.T) {
@@ -76,23 +78,37 @@ func TestGetNotFound(t *testing.T) {
 func TestDeleteOk(t *testing.T) {
 	mockService := mockTodoService{}
 	todoId := domain.TodoID(1234)
-	mockService.delete = func(todoId *domain.TodoID) (b bool, serviceError services.TodoServiceError) {
+	mockService.delete = func(todoId *domain.TodoID, version domain.TodoVersion, children services.ChildrenPolicy) (b bool, serviceError services.TodoServiceError) {
 		return true, nil
 	}
 	controller := MkTodosController(&mockService)
-	_, err := controller.Delete(&todoId)
+	_, err := controller.Delete(&todoId, 0, &apiModels.DeleteQuery{})
 	assert.Equal(t, 1, mockService.deleteCalled)
 	assert.Nil(t, err)
 }
 
+func TestDeleteCascading(t *testing.T) {
+	mockService := mockTodoService{}
+	todoId := domain.TodoID(1234)
+	var passedChildren services.ChildrenPolicy
+	mockService.delete = func(todoId *domain.TodoID, version domain.TodoVersion, children services.ChildrenPolicy) (b bool, serviceError services.TodoServiceError) {
+		passedChildren = children
+		return true, nil
+	}
+	controller := MkTodosController(&mockService)
+	_, err := controller.Delete(&todoId, 0, &apiModels.DeleteQuery{Children: apiModels.CascadeToChildren})
+	assert.Nil(t, err)
+	assert.Equal(t, services.DeleteChildren, passedChildren)
+}
+
 func TestDeleteFound(t *testing.T) {
 	mockService := mockTodoService{}
 	todoId := domain.TodoID(1234)
-	mockService.delete = func(todoId *domain.TodoID) (b bool, serviceError services.TodoServiceError) {
+	mockService.delete = func(todoId *domain.TodoID, version domain.TodoVersion, children services.ChildrenPolicy) (b bool, serviceError services.TodoServiceError) {
 		return false, services.TodoNotFound{ID: *todoId}
 	}
 	controller := MkTodosController(&mockService)
-	_, err := controller.Delete(&todoId)
+	_, err := controller.Delete(&todoId, 0, &apiModels.DeleteQuery{})
 	if err != nil {
 		assert.Equal(t, 1, mockService.deleteCalled)
 		assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
@@ -109,17 +125,106 @@ func TestList(t *testing.T) {
 		Task: "lol",
 	}
 	domainModels := []domain.Todo{domainModel}
-	mockService.list = func() []domain.Todo {
-		return domainModels
+	var passedQuery *domain.TodoQuery
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		passedQuery = query
+		return domain.TodoPage{Todos: domainModels}, nil
 	}
 	controller := MkTodosController(&mockService)
-	results := controller.List()
+	completed := true
+	results, _ := controller.List(&apiModels.TodoQuery{Completed: &completed})
 	assert.Equal(t, 1, mockService.listCalled)
+	assert.Equal(t, &completed, passedQuery.Completed)
+	assert.Equal(t, apiModels.DefaultPageSize, passedQuery.Limit)
 	expected := make([]apiModels.Todo, len(domainModels))
 	for i, v := range domainModels {
 		expected[i] = toApiTodo(&v)
 	}
-	assert.Equal(t, expected, results)
+	assert.Equal(t, expected, results.Todos)
+	assert.Nil(t, results.Next)
+}
+
+func TestListCursorsRoundTrip(t *testing.T) {
+	mockService := mockTodoService{}
+	var passedQuery *domain.TodoQuery
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		passedQuery = query
+		return domain.TodoPage{Todos: []domain.Todo{}, Next: &domain.TodoCursor{ID: domain.TodoID(42)}}, nil
+	}
+	controller := MkTodosController(&mockService)
+	first, _ := controller.List(&apiModels.TodoQuery{Limit: 5})
+	if assert.NotNil(t, first.Next) {
+		_, _ = controller.List(&apiModels.TodoQuery{Limit: 5, After: *first.Next})
+		assert.Equal(t, uint(5), passedQuery.Limit)
+		assert.Equal(t, &domain.TodoCursor{ID: domain.TodoID(42)}, passedQuery.After)
+	}
+}
+
+func TestListSortedCursorsRoundTrip(t *testing.T) {
+	mockService := mockTodoService{}
+	dueAt := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	cursor := domain.TodoCursor{ID: domain.TodoID(42), Priority: domain.HighPriority, DueAt: &dueAt}
+	var passedQuery *domain.TodoQuery
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		passedQuery = query
+		return domain.TodoPage{Todos: []domain.Todo{}, Next: &cursor}, nil
+	}
+	controller := MkTodosController(&mockService)
+	sortKeys := []apiModels.SortKey{{Field: "priority", Descending: true}, {Field: "due"}}
+	first, _ := controller.List(&apiModels.TodoQuery{Limit: 5, SortKeys: sortKeys})
+	assert.Equal(t, []domain.SortKey{
+		{Field: domain.SortByPriority, Descending: true},
+		{Field: domain.SortByDueAt},
+	}, passedQuery.Sort)
+	if assert.NotNil(t, first.Next) {
+		_, _ = controller.List(&apiModels.TodoQuery{Limit: 5, SortKeys: sortKeys, After: *first.Next})
+		assert.Equal(t, &cursor, passedQuery.After)
+	}
+}
+
+func TestListPositionCursorsRoundTrip(t *testing.T) {
+	mockService := mockTodoService{}
+	cursor := domain.TodoCursor{ID: domain.TodoID(42), Position: "a1V"}
+	var passedQuery *domain.TodoQuery
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		passedQuery = query
+		return domain.TodoPage{Todos: []domain.Todo{}, Next: &cursor}, nil
+	}
+	controller := MkTodosController(&mockService)
+	sortKeys := []apiModels.SortKey{{Field: "position"}}
+	first, _ := controller.List(&apiModels.TodoQuery{Limit: 5, SortKeys: sortKeys})
+	assert.Equal(t, []domain.SortKey{{Field: domain.SortByPosition}}, passedQuery.Sort)
+	if assert.NotNil(t, first.Next) {
+		_, _ = controller.List(&apiModels.TodoQuery{Limit: 5, SortKeys: sortKeys, After: *first.Next})
+		assert.Equal(t, &cursor, passedQuery.After)
+	}
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	mockService := mockTodoService{}
+	controller := MkTodosController(&mockService)
+	_, err := controller.List(&apiModels.TodoQuery{After: "not a cursor!"})
+	if err != nil {
+		assert.Equal(t, 0, mockService.listCalled)
+		assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	} else {
+		assert.Fail(t, "Expected an error")
+	}
+}
+
+func TestListStorageFailure(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		return domain.TodoPage{}, services.TodoStorageError{Cause: errors.New("disk on fire")}
+	}
+	controller := MkTodosController(&mockService)
+	_, err := controller.List(&apiModels.TodoQuery{})
+	if err != nil {
+		assert.Equal(t, 1, mockService.listCalled)
+		assert.Equal(t, http.StatusInternalServerError, err.HttpStatusCode())
+	} else {
+		assert.Fail(t, "Expected an error")
+	}
 }
 
 func TestUpdateOk(t *testing.T) {
@@ -176,19 +281,761 @@ func TestUpdateInvaliddata(t *testing.T) {
 	}
 }
 
+func TestErrorsAsProblems(t *testing.T) {
+	for serviceErr, expected := range map[services.TodoServiceError]apiModels.Error{
+		services.TodoNotFound{ID: 1}: {
+			Type:   apiModels.TodoNotFoundProblem,
+			Title:  "Todo not found",
+			Status: http.StatusNotFound,
+			Detail: services.TodoNotFound{ID: 1}.Error(),
+		},
+		&services.TodoDataError{}: {
+			Type:   apiModels.InvalidTodoProblem,
+			Title:  "Invalid todo",
+			Status: http.StatusBadRequest,
+			Detail: services.TodoDataError{}.Error(),
+		},
+		services.TodoVersionConflict{ID: 1, Expected: 1, Actual: 2}: {
+			Type:   apiModels.VersionConflictProblem,
+			Title:  "Todo has changed",
+			Status: http.StatusPreconditionFailed,
+			Detail: services.TodoVersionConflict{ID: 1, Expected: 1, Actual: 2}.Error(),
+		},
+	} {
+		err := fromServiceError(serviceErr)
+		assert.Equal(t, expected, err.AsModel())
+		assert.Equal(t, expected.Status, err.HttpStatusCode())
+	}
+}
+
+func TestGetWithoutTags(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Task: "lol"}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	found, err := controller.Get(&todoId)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, found.Tags)
+}
+
+func TestListByAllTags(t *testing.T) {
+	mockService := mockTodoService{}
+	var listedWith *domain.TodoQuery
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		listedWith = query
+		return domain.TodoPage{}, nil
+	}
+	controller := MkTodosController(&mockService)
+	_, err := controller.List(&apiModels.TodoQuery{Tags: []string{"home", "work"}, TagMatch: apiModels.AllTagsMatch})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"home", "work"}, listedWith.Tags)
+	assert.True(t, listedWith.AllTags)
+}
+
+func TestRemoveTag(t *testing.T) {
+	mockService := mockTodoService{}
+	var removed []string
+	mockService.removeTags = func(todoId *domain.TodoID, tags []string) (domain.Todo, services.TodoServiceError) {
+		removed = tags
+		return domain.Todo{ID: *todoId, Task: "lol"}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	_, err := controller.RemoveTag(&todoId, "home")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"home"}, removed)
+}
+
+func TestListTree(t *testing.T) {
+	mockService := mockTodoService{}
+	one := domain.TodoID(1)
+	parent := domain.Todo{ID: one, Task: "Move house"}
+	child := domain.Todo{ID: 2, Task: "Pack books", ParentID: &one}
+	var listedWith domain.TodoQuery
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		listedWith = *query
+		return domain.TodoPage{Todos: []domain.Todo{parent}}, nil
+	}
+	mockService.subtrees = func(todos []domain.Todo) ([]domain.TodoTree, services.TodoServiceError) {
+		return []domain.TodoTree{{Todo: parent, Children: []domain.TodoTree{{Todo: child}}}}, nil
+	}
+	controller := MkTodosController(&mockService)
+	page, err := controller.List(&apiModels.TodoQuery{Tree: true})
+	assert.Nil(t, err)
+	assert.True(t, listedWith.TopLevel)
+	expected := []apiModels.Todo{{
+		ID: one, Task: "Move house", Tags: []string{}, Priority: "normal",
+		Children: []apiModels.Todo{{ID: 2, Task: "Pack books", Tags: []string{}, Priority: "normal", ParentID: &one}},
+	}}
+	assert.Equal(t, expected, page.Todos)
+}
+
+func TestListChildren(t *testing.T) {
+	mockService := mockTodoService{}
+	one := domain.TodoID(1)
+	var listedWith domain.TodoQuery
+	mockService.list = func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
+		listedWith = *query
+		return domain.TodoPage{}, nil
+	}
+	mockService.subtrees = func(todos []domain.Todo) ([]domain.TodoTree, services.TodoServiceError) {
+		return nil, nil
+	}
+	controller := MkTodosController(&mockService)
+	_, err := controller.List(&apiModels.TodoQuery{ParentID: &one, Tree: true})
+	assert.Nil(t, err)
+	assert.Equal(t, &one, listedWith.ParentID)
+	assert.False(t, listedWith.TopLevel)
+}
+
+func TestCreateWithCyclicParent(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.create = func(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TodoParentNotFound{ParentID: *newTodo.ParentID}
+	}
+	controller := MkTodosController(&mockService)
+	parentID := domain.TodoID(9)
+	_, err := controller.Create(&apiModels.TodoData{Task: "child", ParentID: &parentID})
+	assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	assert.Equal(t, apiModels.InvalidParentProblem, err.AsModel().Type)
+}
+
+func TestCreateWithRecurrence(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.create = func(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: 1, Task: newTodo.Task, Recurrence: newTodo.Recurrence}, nil
+	}
+	controller := MkTodosController(&mockService)
+	created, err := controller.Create(&apiModels.TodoData{Task: "Water plants", Recurrence: "FREQ=WEEKLY;BYDAY=SA"})
+	assert.Nil(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=SA", created.Recurrence)
+}
+
+func TestCreateWithInvalidRecurrence(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.create = func(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TodoRecurrenceError{Recurrence: newTodo.Recurrence}
+	}
+	controller := MkTodosController(&mockService)
+	_, err := controller.Create(&apiModels.TodoData{Task: "Water plants", Recurrence: "FREQ=YEARLY"})
+	assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	assert.Equal(t, apiModels.InvalidTodoProblem, err.AsModel().Type)
+}
+
+func TestSearch(t *testing.T) {
+	mockService := mockTodoService{}
+	var searchedWith domain.TodoSearch
+	mockService.search = func(search *domain.TodoSearch) ([]domain.TodoSearchResult, services.TodoServiceError) {
+		searchedWith = *search
+		return []domain.TodoSearchResult{{Todo: domain.Todo{ID: 1, Version: 1, Task: "Buy milk"}, Score: 0.5}}, nil
+	}
+	controller := MkTodosController(&mockService)
+	found, err := controller.Search(&apiModels.SearchQuery{Q: "milk"})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.TodoSearch{Text: "milk", Limit: apiModels.DefaultSearchResults}, searchedWith)
+	expected := apiModels.SearchResults{Results: []apiModels.SearchResult{{
+		Todo:  apiModels.Todo{ID: 1, Version: 1, Task: "Buy milk", Tags: []string{}, Priority: "normal"},
+		Score: 0.5,
+	}}}
+	assert.Equal(t, expected, found)
+}
+
+func TestSearchWithoutTerms(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.search = func(search *domain.TodoSearch) ([]domain.TodoSearchResult, services.TodoServiceError) {
+		return nil, services.TodoSearchError{Text: search.Text}
+	}
+	controller := MkTodosController(&mockService)
+	_, err := controller.Search(&apiModels.SearchQuery{Q: "?!", Limit: 5})
+	assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	assert.Equal(t, apiModels.InvalidSearchProblem, err.AsModel().Type)
+}
+
+func TestListTags(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.listTags = func() ([]domain.TagCount, services.TodoServiceError) {
+		return []domain.TagCount{{Tag: "home", Count: 2}, {Tag: "work", Count: 1}}, nil
+	}
+	controller := MkTodosController(&mockService)
+	listed, err := controller.ListTags()
+	assert.Nil(t, err)
+	assert.Equal(t, apiModels.TagList{Tags: []apiModels.TagCount{{Tag: "home", Count: 2}, {Tag: "work", Count: 1}}}, listed)
+}
+
+func TestCreateWithPriority(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.create = func(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: domain.TodoID(1), Task: newTodo.Task, Priority: newTodo.Priority}, nil
+	}
+	controller := MkTodosController(&mockService)
+	created, err := controller.Create(&apiModels.TodoData{Task: "lol", Priority: "urgent"})
+	assert.Nil(t, err)
+	assert.Equal(t, "urgent", created.Priority)
+}
+
+func TestCreateWithUnknownPriority(t *testing.T) {
+	mockService := mockTodoService{}
+	controller := MkTodosController(&mockService)
+	_, err := controller.Create(&apiModels.TodoData{Task: "lol", Priority: "meh"})
+	if err != nil {
+		assert.Equal(t, 0, mockService.createCalled)
+		assert.Equal(t, apiModels.InvalidTodoProblem, err.AsModel().Type)
+	} else {
+		assert.Fail(t, "Expected an error")
+	}
+}
+
+func TestPatchOk(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Task: "lol"}, nil
+	}
+	var updatedWith *domain.Todo
+	mockService.update = func(todo *domain.Todo) (domain.Todo, services.TodoServiceError) {
+		updatedWith = todo
+		return *todo, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	patched, err := controller.Patch(&todoId, 0, []byte(`{"completed":true}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, mockService.updateCalled)
+	assert.Equal(t, &domain.Todo{ID: todoId, Task: "lol", Completed: true, Tags: []string{}}, updatedWith)
+	assert.Equal(t, "lol", patched.Task)
+	assert.True(t, patched.Completed)
+}
+
+func TestPatchNotFound(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TodoNotFound{ID: *todoId}
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	_, err := controller.Patch(&todoId, 0, []byte(`{"completed":true}`))
+	if err != nil {
+		assert.Equal(t, 0, mockService.updateCalled)
+		assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
+	} else {
+		assert.Fail(t, "Expected an error")
+	}
+}
+
+func TestPatchInvalid(t *testing.T) {
+	for _, patch := range []string{`[]`, `{"task":3}`, `{"id":3}`, `{"task":`} {
+		mockService := mockTodoService{}
+		mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+			return domain.Todo{ID: *todoId, Task: "lol"}, nil
+		}
+		controller := MkTodosController(&mockService)
+		todoId := domain.TodoID(1234)
+		_, err := controller.Patch(&todoId, 0, []byte(patch))
+		if err != nil {
+			assert.Equal(t, 0, mockService.updateCalled, patch)
+			assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode(), patch)
+		} else {
+			assert.Fail(t, "Expected an error", patch)
+		}
+	}
+}
+
+func TestPatchWithVersion(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Version: 3, Task: "lol"}, nil
+	}
+	mockService.update = func(todo *domain.Todo) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TodoVersionConflict{ID: todo.ID, Expected: todo.Version, Actual: 3}
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	_, err := controller.Patch(&todoId, 2, []byte(`{"completed":true}`))
+	if err != nil {
+		assert.Equal(t, 1, mockService.updateCalled)
+		assert.Equal(t, http.StatusPreconditionFailed, err.HttpStatusCode())
+	} else {
+		assert.Fail(t, "Expected an error")
+	}
+}
+
+func TestPatchRetriesConcurrentChanges(t *testing.T) {
+	mockService := mockTodoService{}
+	var version domain.TodoVersion = 1
+	mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Version: version, Task: "lol"}, nil
+	}
+	mockService.update = func(todo *domain.Todo) (domain.Todo, services.TodoServiceError) {
+		if mockService.updateCalled == 0 {
+			version++
+			return domain.Todo{}, services.TodoVersionConflict{ID: todo.ID, Expected: todo.Version, Actual: version}
+		}
+		updated := *todo
+		updated.Version++
+		return updated, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	patched, err := controller.Patch(&todoId, 0, []byte(`{"completed":true}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, mockService.updateCalled)
+	assert.Equal(t, domain.TodoVersion(3), patched.Version)
+}
+
+func TestPatchRemovingDueDate(t *testing.T) {
+	mockService := mockTodoService{}
+	dueAt := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Version: 1, Task: "lol", DueAt: &dueAt}, nil
+	}
+	var updatedWith *domain.Todo
+	mockService.update = func(todo *domain.Todo) (domain.Todo, services.TodoServiceError) {
+		updatedWith = todo
+		return *todo, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	patched, err := controller.Patch(&todoId, 0, []byte(`{"due_at":null}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &domain.Todo{ID: todoId, Version: 1, Task: "lol", Tags: []string{}}, updatedWith)
+	assert.Nil(t, patched.DueAt)
+}
+
+func TestPatchRemovingTask(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.get = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Task: "lol"}, nil
+	}
+	mockService.update = func(todo *domain.Todo) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TodoDataError{Task: todo.Task}
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	_, err := controller.Patch(&todoId, 0, []byte(`{"task":null}`))
+	if err != nil {
+		assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	} else {
+		assert.Fail(t, "Expected an error")
+	}
+}
+
+func TestCompleteOk(t *testing.T) {
+	mockService := mockTodoService{}
+	completedAt := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	mockService.complete = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Task: "lol", Completed: true, CompletedAt: &completedAt}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	completed, err := controller.Complete(&todoId)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, mockService.completeCalled)
+	assert.True(t, completed.Completed)
+	assert.Equal(t, &completedAt, completed.CompletedAt)
+}
+
+func TestCompleteNotFound(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.complete = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TodoNotFound{ID: *todoId}
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	_, err := controller.Complete(&todoId)
+	if err != nil {
+		assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
+	} else {
+		assert.Fail(t, "Expected an error")
+	}
+}
+
+func TestReopenOk(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.reopen = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Task: "lol"}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	reopened, err := controller.Reopen(&todoId)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, mockService.reopenCalled)
+	assert.False(t, reopened.Completed)
+}
+
+func TestAddDependenciesOk(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.addDependencies = func(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Task: "lol", DependsOn: dependsOn, Blocked: true}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	updated, err := controller.AddDependencies(&todoId, &apiModels.DependenciesData{DependsOn: []domain.TodoID{1, 2}})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{1, 2}, updated.DependsOn)
+	assert.True(t, updated.Blocked)
+}
+
+func TestAddDependenciesCycle(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.addDependencies = func(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TodoDependencyCycle{ID: *todoId, DependencyID: dependsOn[0]}
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	_, err := controller.AddDependencies(&todoId, &apiModels.DependenciesData{DependsOn: []domain.TodoID{1}})
+	assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	assert.Equal(t, apiModels.InvalidDependencyProblem, err.AsModel().Type)
+}
+
+func TestRemoveDependencyOk(t *testing.T) {
+	mockService := mockTodoService{}
+	var removed []domain.TodoID
+	mockService.removeDependencies = func(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		removed = dependsOn
+		return domain.Todo{ID: *todoId, Task: "lol"}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId, dependencyId := domain.TodoID(1234), domain.TodoID(1)
+	updated, err := controller.RemoveDependency(&todoId, &dependencyId)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{1}, removed)
+	assert.Nil(t, updated.DependsOn)
+}
+
+func TestReady(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.ready = func() ([]domain.Todo, services.TodoServiceError) {
+		return []domain.Todo{{ID: 2, Task: "Buy paint"}, {ID: 1, Task: "Paint fence", DependsOn: []domain.TodoID{3}}}, nil
+	}
+	controller := MkTodosController(&mockService)
+	ready, err := controller.Ready()
+	assert.Nil(t, err)
+	expected := apiModels.TodoList{Todos: []apiModels.Todo{
+		{ID: 2, Task: "Buy paint", Tags: []string{}, Priority: "normal"},
+		{ID: 1, Task: "Paint fence", Tags: []string{}, Priority: "normal", DependsOn: []domain.TodoID{3}},
+	}}
+	assert.Equal(t, expected, ready)
+}
+
+func TestMoveOk(t *testing.T) {
+	mockService := mockTodoService{}
+	var passedBefore, passedAfter *domain.TodoID
+	mockService.move = func(todoId *domain.TodoID, before *domain.TodoID, after *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		passedBefore, passedAfter = before, after
+		return domain.Todo{ID: *todoId, Task: "lol", Position: "a0V"}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId, before := domain.TodoID(1234), domain.TodoID(2)
+	moved, err := controller.Move(&todoId, &apiModels.MoveData{Before: &before})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, mockService.moveCalled)
+	assert.Equal(t, &before, passedBefore)
+	assert.Nil(t, passedAfter)
+	assert.Equal(t, "a0V", moved.Position)
+}
+
+func TestMoveInvalid(t *testing.T) {
+	controller := MkTodosController(&mockTodoService{
+		move: func(todoId *domain.TodoID, before *domain.TodoID, after *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+			return domain.Todo{}, services.TodoMoveTargetNotFound{TargetID: *before}
+		},
+	})
+	todoId, before := domain.TodoID(1234), domain.TodoID(2)
+	_, err := controller.Move(&todoId, &apiModels.MoveData{Before: &before})
+	assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	assert.Equal(t, apiModels.InvalidMoveProblem, err.AsModel().Type)
+}
+
+func TestBatchOk(t *testing.T) {
+	mockService := mockTodoService{}
+	var passed []services.BatchOp
+	mockService.batch = func(ops []services.BatchOp) ([]domain.Todo, services.TodoServiceError) {
+		passed = ops
+		return []domain.Todo{
+			{ID: 3, Version: 1, Task: "Buy milk", Priority: domain.HighPriority},
+			{ID: 1, Version: 2, Task: "Buy eggs"},
+			{},
+		}, nil
+	}
+	controller := MkTodosController(&mockService)
+	results, err := controller.Batch(&apiModels.BatchData{Operations: []apiModels.BatchOperation{
+		{Op: apiModels.CreateOperation, Todo: &apiModels.TodoData{Task: "Buy milk", Priority: "high"}},
+		{Op: apiModels.UpdateOperation, ID: 1, Version: 1, Todo: &apiModels.TodoData{Task: "Buy eggs"}},
+		{Op: apiModels.DeleteOperation, ID: 2, Children: apiModels.CascadeToChildren},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, mockService.batchCalled)
+	assert.Equal(t, []services.BatchOp{
+		{Create: &domain.NewTodo{Task: "Buy milk", Priority: domain.HighPriority}},
+		{Update: &domain.Todo{ID: 1, Version: 1, Task: "Buy eggs", Priority: domain.NormalPriority}},
+		{Delete: &services.BatchDelete{ID: 2, Children: services.DeleteChildren}},
+	}, passed)
+	assert.True(t, results.Applied)
+	assert.Equal(t, 3, len(results.Results))
+	assert.Equal(t, http.StatusCreated, results.Results[0].Status)
+	assert.Equal(t, domain.TodoID(3), results.Results[0].Todo.ID)
+	assert.Equal(t, "high", results.Results[0].Todo.Priority)
+	assert.Equal(t, http.StatusOK, results.Results[1].Status)
+	assert.Equal(t, domain.TodoVersion(2), results.Results[1].Todo.Version)
+	assert.Equal(t, apiModels.BatchResult{Status: http.StatusOK}, results.Results[2])
+}
+
+func TestBatchFailure(t *testing.T) {
+	controller := MkTodosController(&mockTodoService{
+		batch: func(ops []services.BatchOp) ([]domain.Todo, services.TodoServiceError) {
+			return nil, services.TodoBatchError{Index: 1, Cause: services.TodoNotFound{ID: 2}}
+		},
+	})
+	results, err := controller.Batch(&apiModels.BatchData{Operations: []apiModels.BatchOperation{
+		{Op: apiModels.CreateOperation, Todo: &apiModels.TodoData{Task: "Buy milk"}},
+		{Op: apiModels.DeleteOperation, ID: 2},
+		{Op: apiModels.DeleteOperation, ID: 3},
+	}})
+	assert.Nil(t, err)
+	assert.False(t, results.Applied)
+	assert.Equal(t, 3, len(results.Results))
+	for i, result := range results.Results {
+		assert.Nil(t, result.Todo)
+		if i == 1 {
+			assert.Equal(t, http.StatusNotFound, result.Status)
+			assert.Equal(t, apiModels.TodoNotFoundProblem, result.Error.Type)
+		} else {
+			assert.Equal(t, http.StatusFailedDependency, result.Status)
+			assert.Equal(t, apiModels.BatchAbortedProblem, result.Error.Type)
+			assert.Equal(t, "Not made, as operation [1] failed", result.Error.Detail)
+		}
+	}
+}
+
+func TestBatchInvalidOperation(t *testing.T) {
+	mockService := mockTodoService{}
+	controller := MkTodosController(&mockService)
+	for _, operation := range []apiModels.BatchOperation{
+		{Op: apiModels.CreateOperation},
+		{Op: apiModels.UpdateOperation, Todo: &apiModels.TodoData{Task: "Buy eggs"}},
+		{Op: apiModels.UpdateOperation, ID: 1},
+		{Op: apiModels.DeleteOperation},
+		{Op: apiModels.CreateOperation, Todo: &apiModels.TodoData{Task: "Buy eggs", Priority: "meh"}},
+	} {
+		_, err := controller.Batch(&apiModels.BatchData{Operations: []apiModels.BatchOperation{
+			{Op: apiModels.DeleteOperation, ID: 1},
+			operation,
+		}})
+		assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+		assert.Contains(t, err.Error(), "Operation [1]")
+	}
+	assert.Equal(t, 0, mockService.batchCalled)
+}
+
+func TestBatchStorageFailure(t *testing.T) {
+	controller := MkTodosController(&mockTodoService{
+		batch: func(ops []services.BatchOp) ([]domain.Todo, services.TodoServiceError) {
+			return nil, services.TodoStorageError{Cause: errors.New("disk on fire")}
+		},
+	})
+	_, err := controller.Batch(&apiModels.BatchData{Operations: []apiModels.BatchOperation{
+		{Op: apiModels.DeleteOperation, ID: 1},
+	}})
+	assert.Equal(t, http.StatusInternalServerError, err.HttpStatusCode())
+	assert.Equal(t, apiModels.StorageFailureProblem, err.AsModel().Type)
+}
+
 // Mocks
 
+func TestListTrash(t *testing.T) {
+	mockService := mockTodoService{}
+	deletedAt := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	mockService.listTrash = func() ([]domain.TrashedTodo, services.TodoServiceError) {
+		return []domain.TrashedTodo{{Todo: domain.Todo{ID: 1, Task: "lol"}, DeletedAt: deletedAt}}, nil
+	}
+	controller := MkTodosController(&mockService)
+	trash, err := controller.ListTrash()
+	assert.Nil(t, err)
+	if assert.Len(t, trash.Todos, 1) {
+		assert.Equal(t, "lol", trash.Todos[0].Todo.Task)
+		assert.Equal(t, []string{}, trash.Todos[0].Todo.Tags)
+		assert.Equal(t, deletedAt, trash.Todos[0].DeletedAt)
+	}
+}
+
+func TestRestoreOk(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.restore = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: *todoId, Version: 2, Task: "lol"}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	restored, err := controller.Restore(&todoId)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, mockService.restoreCalled)
+	assert.Equal(t, todoId, restored.ID)
+	assert.Equal(t, domain.TodoVersion(2), restored.Version)
+}
+
+func TestRestoreNotInTrash(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.restore = func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{}, services.TrashedTodoNotFound{ID: *todoId}
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1234)
+	_, err := controller.Restore(&todoId)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
+		assert.Equal(t, apiModels.TodoNotFoundProblem, err.AsModel().Type)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.purge = func(todoId *domain.TodoID) (bool, services.TodoServiceError) {
+		if *todoId == 1 {
+			return true, nil
+		}
+		return false, services.TrashedTodoNotFound{ID: *todoId}
+	}
+	controller := MkTodosController(&mockService)
+	one, two := domain.TodoID(1), domain.TodoID(2)
+	_, err := controller.Purge(&one)
+	assert.Nil(t, err)
+	_, err = controller.Purge(&two)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
+	}
+	assert.Equal(t, 2, mockService.purgeCalled)
+}
+
+func TestHistory(t *testing.T) {
+	at := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	dueAt := at.Add(time.Hour)
+	created := domain.Todo{ID: 1, Version: 1, Task: "Buy milk", Position: "a0"}
+	updated := domain.Todo{ID: 1, Version: 2, Task: "Buy milk and eggs", DueAt: &dueAt, Tags: []string{"errands"}, Position: "a0"}
+	mockService := mockTodoService{}
+	mockService.history = func(todoId *domain.TodoID) ([]domain.TodoRevision, services.TodoServiceError) {
+		if *todoId != 1 {
+			return nil, services.TodoNotFound{ID: *todoId}
+		}
+		return []domain.TodoRevision{
+			{Rev: 1, Change: domain.CreatedRevision, At: at, Actor: "alice", Todo: created},
+			{Rev: 2, Change: domain.UpdatedRevision, At: at, Actor: "bob", Todo: updated},
+			{Rev: 3, Change: domain.DeletedRevision, At: at, Todo: updated},
+		}, nil
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1)
+	history, err := controller.History(&todoId)
+	assert.Nil(t, err)
+	if assert.Len(t, history.Revisions, 3) {
+		assert.Equal(t, "create", history.Revisions[0].Change)
+		assert.Equal(t, "alice", history.Revisions[0].Actor)
+		assert.Equal(t, []apiModels.FieldChange{
+			{Field: "position", From: "", To: "a0"},
+			{Field: "task", From: "", To: "Buy milk"},
+		}, history.Revisions[0].Changes)
+		assert.Equal(t, []apiModels.FieldChange{
+			{Field: "due_at", From: nil, To: "2019-06-01T13:00:00Z"},
+			{Field: "tags", From: []interface{}{}, To: []interface{}{"errands"}},
+			{Field: "task", From: "Buy milk", To: "Buy milk and eggs"},
+		}, history.Revisions[1].Changes)
+		assert.Equal(t, "bob", history.Revisions[1].Actor)
+		assert.Equal(t, "delete", history.Revisions[2].Change)
+		assert.Empty(t, history.Revisions[2].Changes)
+		assert.Equal(t, toApiTodo(&updated), history.Revisions[2].Todo)
+	}
+	todoId = 2
+	_, err = controller.History(&todoId)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
+	}
+}
+
+func TestRevert(t *testing.T) {
+	mockService := mockTodoService{}
+	mockService.revert = func(todoId *domain.TodoID, rev uint) (domain.Todo, services.TodoServiceError) {
+		if rev == 1 {
+			return domain.Todo{ID: *todoId, Version: 3, Task: "Buy milk"}, nil
+		}
+		return domain.Todo{}, services.TodoRevisionNotFound{ID: *todoId, Rev: rev}
+	}
+	controller := MkTodosController(&mockService)
+	todoId := domain.TodoID(1)
+	reverted, err := controller.Revert(&todoId, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Buy milk", reverted.Task)
+	_, err = controller.Revert(&todoId, 7)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
+		assert.Equal(t, apiModels.RevisionNotFoundProblem, err.AsModel().Type)
+	}
+}
+
+func TestAsActor(t *testing.T) {
+	actorService := mockTodoService{}
+	actorService.create = func(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError) {
+		return domain.Todo{ID: 1, Version: 1, Task: newTodo.Task}, nil
+	}
+	mockService := mockTodoService{}
+	mockService.asActor = func(actor string) services.TodoService {
+		assert.Equal(t, "alice", actor)
+		return &actorService
+	}
+	controller := MkTodosController(&mockService)
+	_, err := controller.AsActor("alice").Create(&apiModels.TodoData{Task: "Buy milk"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, actorService.createCalled)
+	assert.Equal(t, 0, mockService.createCalled)
+}
+
 type mockTodoService struct {
-	create       func(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError)
-	createCalled int
-	update       func(todo *domain.Todo) (domain.Todo, services.TodoServiceError)
-	updateCalled int
-	list         func() []domain.Todo
-	listCalled   int
-	get          func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError)
-	getCalled    int
-	delete       func(todoId *domain.TodoID) (bool, services.TodoServiceError)
-	deleteCalled int
+	create                   func(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError)
+	createCalled             int
+	update                   func(todo *domain.Todo) (domain.Todo, services.TodoServiceError)
+	updateCalled             int
+	list                     func(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError)
+	listCalled               int
+	get                      func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError)
+	getCalled                int
+	delete                   func(todoId *domain.TodoID, version domain.TodoVersion, children services.ChildrenPolicy) (bool, services.TodoServiceError)
+	deleteCalled             int
+	complete                 func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError)
+	completeCalled           int
+	reopen                   func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError)
+	reopenCalled             int
+	addTags                  func(todoId *domain.TodoID, tags []string) (domain.Todo, services.TodoServiceError)
+	addTagsCalled            int
+	removeTags               func(todoId *domain.TodoID, tags []string) (domain.Todo, services.TodoServiceError)
+	removeTagsCalled         int
+	listTags                 func() ([]domain.TagCount, services.TodoServiceError)
+	listTagsCalled           int
+	search                   func(search *domain.TodoSearch) ([]domain.TodoSearchResult, services.TodoServiceError)
+	searchCalled             int
+	subtrees                 func(todos []domain.Todo) ([]domain.TodoTree, services.TodoServiceError)
+	subtreesCalled           int
+	addDependencies          func(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, services.TodoServiceError)
+	addDependenciesCalled    int
+	removeDependencies       func(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, services.TodoServiceError)
+	removeDependenciesCalled int
+	ready                    func() ([]domain.Todo, services.TodoServiceError)
+	readyCalled              int
+	move                     func(todoId *domain.TodoID, before *domain.TodoID, after *domain.TodoID) (domain.Todo, services.TodoServiceError)
+	moveCalled               int
+	batch                    func(ops []services.BatchOp) ([]domain.Todo, services.TodoServiceError)
+	batchCalled              int
+	withinTx                 func(f func(todos services.TodoService, lists domain.TodoListRepo) services.TodoServiceError) services.TodoServiceError
+	withinTxCalled           int
+	listTrash                func() ([]domain.TrashedTodo, services.TodoServiceError)
+	listTrashCalled          int
+	restore                  func(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError)
+	restoreCalled            int
+	purge                    func(todoId *domain.TodoID) (bool, services.TodoServiceError)
+	purgeCalled              int
+	history                  func(todoId *domain.TodoID) ([]domain.TodoRevision, services.TodoServiceError)
+	historyCalled            int
+	revert                   func(todoId *domain.TodoID, rev uint) (domain.Todo, services.TodoServiceError)
+	revertCalled             int
+	asActor                  func(actor string) services.TodoService
+	asActorCalled            int
 }
 
 func (m *mockTodoService) Create(newTodo *domain.NewTodo) (domain.Todo, services.TodoServiceError) {
@@ -201,9 +1048,9 @@ func (m *mockTodoService) Update(todo *domain.Todo) (domain.Todo, services.TodoS
 	return m.update(todo)
 }
 
-func (m *mockTodoService) List() []domain.Todo {
+func (m *mockTodoService) List(query *domain.TodoQuery) (domain.TodoPage, services.TodoServiceError) {
 	defer func() { m.listCalled++ }()
-	return m.list()
+	return m.list(query)
 }
 
 func (m *mockTodoService) Get(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
@@ -211,7 +1058,105 @@ func (m *mockTodoService) Get(todoId *domain.TodoID) (domain.Todo, services.Todo
 	return m.get(todoId)
 }
 
-func (m *mockTodoService) Delete(todoId *domain.TodoID) (bool, services.TodoServiceError) {
+func (m *mockTodoService) Delete(todoId *domain.TodoID, version domain.TodoVersion, children services.ChildrenPolicy) (bool, services.TodoServiceError) {
 	defer func() { m.deleteCalled++ }()
-	return m.delete(todoId)
+	return m.delete(todoId, version, children)
+}
+
+func (m *mockTodoService) Complete(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.completeCalled++ }()
+	return m.complete(todoId)
+}
+
+func (m *mockTodoService) Reopen(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.reopenCalled++ }()
+	return m.reopen(todoId)
+}
+
+func (m *mockTodoService) AddTags(todoId *domain.TodoID, tags []string) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.addTagsCalled++ }()
+	return m.addTags(todoId, tags)
+}
+
+func (m *mockTodoService) RemoveTags(todoId *domain.TodoID, tags []string) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.removeTagsCalled++ }()
+	return m.removeTags(todoId, tags)
+}
+
+func (m *mockTodoService) ListTags() ([]domain.TagCount, services.TodoServiceError) {
+	defer func() { m.listTagsCalled++ }()
+	return m.listTags()
+}
+
+func (m *mockTodoService) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, services.TodoServiceError) {
+	defer func() { m.searchCalled++ }()
+	return m.search(search)
+}
+
+func (m *mockTodoService) Subtrees(todos []domain.Todo) ([]domain.TodoTree, services.TodoServiceError) {
+	defer func() { m.subtreesCalled++ }()
+	return m.subtrees(todos)
+}
+
+func (m *mockTodoService) AddDependencies(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.addDependenciesCalled++ }()
+	return m.addDependencies(todoId, dependsOn)
+}
+
+func (m *mockTodoService) RemoveDependencies(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.removeDependenciesCalled++ }()
+	return m.removeDependencies(todoId, dependsOn)
+}
+
+func (m *mockTodoService) Ready() ([]domain.Todo, services.TodoServiceError) {
+	defer func() { m.readyCalled++ }()
+	return m.ready()
+}
+
+func (m *mockTodoService) Move(todoId *domain.TodoID, before *domain.TodoID, after *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.moveCalled++ }()
+	return m.move(todoId, before, after)
+}
+
+func (m *mockTodoService) Batch(ops []services.BatchOp) ([]domain.Todo, services.TodoServiceError) {
+	defer func() { m.batchCalled++ }()
+	return m.batch(ops)
+}
+
+func (m *mockTodoService) WithinTx(f func(todos services.TodoService, lists domain.TodoListRepo) services.TodoServiceError) services.TodoServiceError {
+	defer func() { m.withinTxCalled++ }()
+	return m.withinTx(f)
+}
+
+func (m *mockTodoService) ListTrash() ([]domain.TrashedTodo, services.TodoServiceError) {
+	defer func() { m.listTrashCalled++ }()
+	return m.listTrash()
+}
+
+func (m *mockTodoService) Restore(todoId *domain.TodoID) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.restoreCalled++ }()
+	return m.restore(todoId)
+}
+
+func (m *mockTodoService) Purge(todoId *domain.TodoID) (bool, services.TodoServiceError) {
+	defer func() { m.purgeCalled++ }()
+	return m.purge(todoId)
+}
+
+func (m *mockTodoService) History(todoId *domain.TodoID) ([]domain.TodoRevision, services.TodoServiceError) {
+	defer func() { m.historyCalled++ }()
+	return m.history(todoId)
+}
+
+func (m *mockTodoService) Revert(todoId *domain.TodoID, rev uint) (domain.Todo, services.TodoServiceError) {
+	defer func() { m.revertCalled++ }()
+	return m.revert(todoId, rev)
+}
+
+func (m *mockTodoService) AsActor(actor string) services.TodoService {
+	defer func() { m.asActorCalled++ }()
+	if m.asActor == nil {
+		return m
+	}
+	return m.asActor(actor)
 }
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/events"
+)
+
+type TodoEventController interface {
+	// Follow returns a stream of the changes made to Todos from now on,
+	// along with those made after the event with the given id, if any
+	Follow(after *uint64) TodoEventStream
+}
+
+// TodoEventStream streams models.TodoEvents
+type TodoEventStream struct {
+	// Missed are the events that came after the one following resumed from
+	Missed []models.TodoEvent
+	// Gap is true when following could not be resumed, as some of the
+	// events since are no longer held on to; whoever follows had better
+	// start over, e.g. by listing the Todos again
+	Gap bool
+	// Events gets the events as they come. It is closed once the stream is
+	// cancelled, or when it falls too far behind, in which case following
+	// can be resumed from the last event received.
+	Events <-chan models.TodoEvent
+	// Cancel stops the stream; it must be called once done with it
+	Cancel func()
+}
+
+// MkTodoEventController returns a TodoEventController following the given
+// events.Feed, with streams holding up to buffer events yet to be received
+func MkTodoEventController(feed events.Feed, buffer int) TodoEventController {
+	return &TodoEventControllerImpl{feed: feed, buffer: buffer}
+}
+
+type TodoEventControllerImpl struct {
+	feed   events.Feed
+	buffer int
+}
+
+func (t *TodoEventControllerImpl) Follow(after *uint64) TodoEventStream {
+	missed, complete, subscription := t.feed.Follow(after, t.buffer)
+	apiMissed := make([]models.TodoEvent, len(missed))
+	for i, event := range missed {
+		apiMissed[i] = toApiTodoEvent(&event)
+	}
+	apiEvents := make(chan models.TodoEvent)
+	done := make(chan struct{})
+	go func() {
+		defer close(apiEvents)
+		for event := range subscription.Events() {
+			select {
+			case apiEvents <- toApiTodoEvent(&event):
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return TodoEventStream{
+		Missed: apiMissed,
+		Gap:    !complete,
+		Events: apiEvents,
+		Cancel: func() {
+			once.Do(func() {
+				close(done)
+				subscription.Cancel()
+			})
+		},
+	}
+}
+
+func toApiTodoEvent(event *events.Sequenced) models.TodoEvent {
+	change := event.Event.Details()
+	apiEvent := models.TodoEvent{
+		ID:    strconv.FormatUint(event.ID, 10),
+		Type:  event.Event.Name(),
+		At:    change.At,
+		Actor: change.Actor,
+		Todo:  toApiTodo(&change.Todo),
+	}
+	if change.Previous != nil {
+		previous := toApiTodo(change.Previous)
+		apiEvent.Previous = &previous
+	}
+	return apiEvent
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollow(t *testing.T) {
+	bus := events.MkBus()
+	controller := MkTodoEventController(events.MkFeed(bus, 10), 10)
+	stream := controller.Follow(nil)
+	defer stream.Cancel()
+	at := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	bus.Publish(events.TodoCreated{Change: events.Change{Todo: domain.Todo{ID: 1, Task: "Water plants"}, Actor: "alice", At: at}})
+
+	event := <-stream.Events
+	assert.Equal(t, "todo.created", event.Type)
+	assert.Equal(t, "alice", event.Actor)
+	assert.Equal(t, at, event.At)
+	assert.Equal(t, domain.TodoID(1), event.Todo.ID)
+	assert.Equal(t, "Water plants", event.Todo.Task)
+	assert.False(t, stream.Gap)
+	assert.Empty(t, stream.Missed)
+
+	bus.Publish(events.TodoUpdated{Change: events.Change{Todo: domain.Todo{ID: 1, Version: 2}, Previous: &domain.Todo{ID: 1, Version: 1}}})
+	updated := <-stream.Events
+	if assert.NotNil(t, updated.Previous) {
+		assert.Equal(t, domain.TodoVersion(1), updated.Previous.Version)
+	}
+	assert.Nil(t, event.Previous)
+	bus.Publish(events.TodoDeleted{Change: events.Change{Todo: domain.Todo{ID: 1}}})
+	after, _ := strconv.ParseUint(event.ID, 10, 64)
+	resumed := controller.Follow(&after)
+	defer resumed.Cancel()
+	assert.False(t, resumed.Gap)
+	if assert.Len(t, resumed.Missed, 2) {
+		assert.Equal(t, "todo.updated", resumed.Missed[0].Type)
+		assert.Equal(t, "todo.deleted", resumed.Missed[1].Type)
+	}
+	// from before the feed began
+	after -= 2
+	gap := controller.Follow(&after)
+	gap.Cancel()
+	assert.True(t, gap.Gap)
+}
+
+func TestFollowCancel(t *testing.T) {
+	bus := events.MkBus()
+	stream := MkTodoEventController(events.MkFeed(bus, 10), 10).Follow(nil)
+	bus.Publish(events.TodoCreated{Change: events.Change{Todo: domain.Todo{ID: 1}}})
+	stream.Cancel()
+	stream.Cancel()
+	// whatever was on its way, the events end
+	for range stream.Events {
+	}
+}
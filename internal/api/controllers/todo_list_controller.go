@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/services"
+)
+
+type TodoListController interface {
+	Create(newList *models.ListData) (models.List, models.ApiError)
+	Get(id *domain.TodoListID) (models.List, models.ApiError)
+	List() (models.Lists, models.ApiError)
+	Update(id *domain.TodoListID, list *models.ListData) (models.List, models.ApiError)
+	Delete(id *domain.TodoListID, query *models.DeleteListQuery) (models.Success, models.ApiError)
+	// AsActor returns a TodoListController whose changes to Todos are
+	// recorded in their history as made by the given actor
+	AsActor(actor string) TodoListController
+}
+
+// MkTodoListsController returns a TodoListController when given a
+// services.TodoListService
+func MkTodoListsController(service services.TodoListService) TodoListController {
+	return &TodoListsControllerImpl{service: service}
+}
+
+type TodoListsControllerImpl struct {
+	service services.TodoListService
+}
+
+func (l *TodoListsControllerImpl) AsActor(actor string) TodoListController {
+	return &TodoListsControllerImpl{service: l.service.AsActor(actor)}
+}
+
+func (l *TodoListsControllerImpl) Create(newList *models.ListData) (models.List, models.ApiError) {
+	if created, err := l.service.Create(&domain.NewTodoList{Name: newList.Name}); err == nil {
+		return toApiList(&created), nil
+	} else {
+		return models.List{}, fromServiceError(err)
+	}
+}
+
+func (l *TodoListsControllerImpl) Get(id *domain.TodoListID) (models.List, models.ApiError) {
+	if found, err := l.service.Get(id); err == nil {
+		return toApiList(&found), nil
+	} else {
+		return models.List{}, fromServiceError(err)
+	}
+}
+
+func (l *TodoListsControllerImpl) List() (models.Lists, models.ApiError) {
+	if lists, err := l.service.List(); err == nil {
+		apiLists := make([]models.List, len(lists))
+		for i, list := range lists {
+			apiLists[i] = toApiList(&list)
+		}
+		return models.Lists{Lists: apiLists}, nil
+	} else {
+		return models.Lists{}, fromServiceError(err)
+	}
+}
+
+// Update renames the list with the given id
+func (l *TodoListsControllerImpl) Update(id *domain.TodoListID, list *models.ListData) (models.List, models.ApiError) {
+	if updated, err := l.service.Update(&domain.TodoList{ID: *id, Name: list.Name}); err == nil {
+		return toApiList(&updated), nil
+	} else {
+		return models.List{}, fromServiceError(err)
+	}
+}
+
+// Delete deletes the list with the given id, along with the Todos on it if
+// asked to; otherwise it has to be empty
+func (l *TodoListsControllerImpl) Delete(id *domain.TodoListID, query *models.DeleteListQuery) (models.Success, models.ApiError) {
+	todos := services.RefuseTodos
+	if query.Todos == models.CascadeToTodos {
+		todos = services.DeleteTodos
+	}
+	if _, err := l.service.Delete(id, todos); err == nil {
+		return models.Success{Message: fmt.Sprintf("Successfully deleted list with id [%v]", *id)}, nil
+	} else {
+		return models.Success{}, fromServiceError(err)
+	}
+}
+
+func toApiList(domainList *domain.TodoList) models.List {
+	return models.List{ID: domainList.ID, Name: domainList.Name}
+}
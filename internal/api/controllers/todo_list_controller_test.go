@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	apiModels "github.com/lloydmeta/todddo-openapi/internal/api/models"
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateListOk(t *testing.T) {
+	mockService := mockTodoListService{}
+	mockService.create = func(newList *domain.NewTodoList) (domain.TodoList, services.TodoServiceError) {
+		return domain.TodoList{ID: 1, Name: newList.Name}, nil
+	}
+	controller := MkTodoListsController(&mockService)
+	created, err := controller.Create(&apiModels.ListData{Name: "Chores"})
+	assert.Nil(t, err)
+	assert.Equal(t, apiModels.List{ID: 1, Name: "Chores"}, created)
+}
+
+func TestCreateListInvalidName(t *testing.T) {
+	mockService := mockTodoListService{}
+	mockService.create = func(newList *domain.NewTodoList) (domain.TodoList, services.TodoServiceError) {
+		return domain.TodoList{}, services.ListNameError{Name: newList.Name}
+	}
+	controller := MkTodoListsController(&mockService)
+	_, err := controller.Create(&apiModels.ListData{Name: " "})
+	assert.Equal(t, http.StatusBadRequest, err.HttpStatusCode())
+	assert.Equal(t, apiModels.InvalidListProblem, err.AsModel().Type)
+}
+
+func TestGetListNotFound(t *testing.T) {
+	mockService := mockTodoListService{}
+	mockService.get = func(listId *domain.TodoListID) (domain.TodoList, services.TodoServiceError) {
+		return domain.TodoList{}, services.ListNotFound{ID: *listId}
+	}
+	controller := MkTodoListsController(&mockService)
+	id := domain.TodoListID(3)
+	_, err := controller.Get(&id)
+	assert.Equal(t, http.StatusNotFound, err.HttpStatusCode())
+	assert.Equal(t, apiModels.ListNotFoundProblem, err.AsModel().Type)
+}
+
+func TestListListsOk(t *testing.T) {
+	mockService := mockTodoListService{}
+	mockService.list = func() ([]domain.TodoList, services.TodoServiceError) {
+		return []domain.TodoList{{ID: 1, Name: "Chores"}, {ID: 2, Name: "Errands"}}, nil
+	}
+	controller := MkTodoListsController(&mockService)
+	lists, err := controller.List()
+	assert.Nil(t, err)
+	assert.Equal(t, apiModels.Lists{Lists: []apiModels.List{{ID: 1, Name: "Chores"}, {ID: 2, Name: "Errands"}}}, lists)
+}
+
+func TestUpdateListOk(t *testing.T) {
+	mockService := mockTodoListService{}
+	mockService.update = func(list *domain.TodoList) (domain.TodoList, services.TodoServiceError) {
+		return *list, nil
+	}
+	controller := MkTodoListsController(&mockService)
+	id := domain.TodoListID(2)
+	updated, err := controller.Update(&id, &apiModels.ListData{Name: "Errands"})
+	assert.Nil(t, err)
+	assert.Equal(t, apiModels.List{ID: 2, Name: "Errands"}, updated)
+}
+
+func TestDeleteListPolicies(t *testing.T) {
+	for query, expected := range map[string]services.TodosPolicy{
+		"":                       services.RefuseTodos,
+		apiModels.RefuseTodos:    services.RefuseTodos,
+		apiModels.CascadeToTodos: services.DeleteTodos,
+	} {
+		mockService := mockTodoListService{}
+		var passedPolicy services.TodosPolicy
+		mockService.delete = func(listId *domain.TodoListID, todos services.TodosPolicy) (bool, services.TodoServiceError) {
+			passedPolicy = todos
+			return true, nil
+		}
+		controller := MkTodoListsController(&mockService)
+		id := domain.TodoListID(2)
+		_, err := controller.Delete(&id, &apiModels.DeleteListQuery{Todos: query})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, passedPolicy)
+	}
+}
+
+func TestDeleteListNotEmpty(t *testing.T) {
+	mockService := mockTodoListService{}
+	mockService.delete = func(listId *domain.TodoListID, todos services.TodosPolicy) (bool, services.TodoServiceError) {
+		return false, services.ListNotEmpty{ID: *listId, Todos: 2}
+	}
+	controller := MkTodoListsController(&mockService)
+	id := domain.TodoListID(2)
+	_, err := controller.Delete(&id, &apiModels.DeleteListQuery{})
+	assert.Equal(t, http.StatusConflict, err.HttpStatusCode())
+	assert.Equal(t, apiModels.ListNotEmptyProblem, err.AsModel().Type)
+}
+
+// Mocks
+
+type mockTodoListService struct {
+	create        func(newList *domain.NewTodoList) (domain.TodoList, services.TodoServiceError)
+	createCalled  int
+	get           func(listId *domain.TodoListID) (domain.TodoList, services.TodoServiceError)
+	getCalled     int
+	list          func() ([]domain.TodoList, services.TodoServiceError)
+	listCalled    int
+	update        func(list *domain.TodoList) (domain.TodoList, services.TodoServiceError)
+	updateCalled  int
+	delete        func(listId *domain.TodoListID, todos services.TodosPolicy) (bool, services.TodoServiceError)
+	deleteCalled  int
+	asActor       func(actor string) services.TodoListService
+	asActorCalled int
+}
+
+func (m *mockTodoListService) Create(newList *domain.NewTodoList) (domain.TodoList, services.TodoServiceError) {
+	defer func() { m.createCalled++ }()
+	return m.create(newList)
+}
+
+func (m *mockTodoListService) Get(listId *domain.TodoListID) (domain.TodoList, services.TodoServiceError) {
+	defer func() { m.getCalled++ }()
+	return m.get(listId)
+}
+
+func (m *mockTodoListService) List() ([]domain.TodoList, services.TodoServiceError) {
+	defer func() { m.listCalled++ }()
+	return m.list()
+}
+
+func (m *mockTodoListService) Update(list *domain.TodoList) (domain.TodoList, services.TodoServiceError) {
+	defer func() { m.updateCalled++ }()
+	return m.update(list)
+}
+
+func (m *mockTodoListService) Delete(listId *domain.TodoListID, todos services.TodosPolicy) (bool, services.TodoServiceError) {
+	defer func() { m.deleteCalled++ }()
+	return m.delete(listId, todos)
+}
+
+func (m *mockTodoListService) AsActor(actor string) services.TodoListService {
+	defer func() { m.asActorCalled++ }()
+	if m.asActor == nil {
+		return m
+	}
+	return m.asActor(actor)
+}
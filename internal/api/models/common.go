@@ -1,8 +1,109 @@
 package models
 
-// Error models errors as JSON in the API
+import "net/http"
+
+// Error models errors as JSON in the API, following the Problem Details
+// format (RFC 7807)
 type Error struct {
-	Message string `json:"message" binding:"required" example:"Something went wrong :("`
+	// Type identifies the kind of problem, and is stable enough to be matched on
+	Type ProblemType `json:"type" binding:"required" example:"urn:todddo:problem:todo-not-found"`
+	// Title is a short summary of the Type that doesn't change from occurrence to occurrence
+	Title  string `json:"title" binding:"required" example:"Todo not found"`
+	Status int    `json:"status" binding:"required" example:"404"`
+	// Detail explains this occurrence of the problem, for humans
+	Detail string `json:"detail" example:"Could not find [1] in repo"`
+	// Instance is the request path that the problem occurred at
+	Instance string `json:"instance,omitempty" example:"/tasks/1"`
+}
+
+// ProblemContentType is the media type that Errors are sent as
+const ProblemContentType = "application/problem+json"
+
+// ProblemType is a URI identifying a kind of problem
+type ProblemType string
+
+const (
+	// GenericProblem is used when there is nothing more to say than the status
+	GenericProblem ProblemType = "about:blank"
+	// InvalidRequestProblem is used when a request can't be bound, e.g. because
+	// the path, query, headers or body are malformed or missing
+	InvalidRequestProblem ProblemType = "urn:todddo:problem:invalid-request"
+	// InvalidTodoProblem is used when the data of a Todo is not valid
+	InvalidTodoProblem ProblemType = "urn:todddo:problem:invalid-todo"
+	// TodoNotFoundProblem is used when a Todo does not exist
+	TodoNotFoundProblem ProblemType = "urn:todddo:problem:todo-not-found"
+	// VersionConflictProblem is used when a Todo is not at the expected version
+	VersionConflictProblem ProblemType = "urn:todddo:problem:version-conflict"
+	// InvalidCursorProblem is used when a pagination cursor can't be decoded
+	InvalidCursorProblem ProblemType = "urn:todddo:problem:invalid-cursor"
+	// InvalidMergePatchProblem is used when a merge patch can't be applied
+	InvalidMergePatchProblem ProblemType = "urn:todddo:problem:invalid-merge-patch"
+	// UnsupportedMediaTypeProblem is used when a body has the wrong Content-Type
+	UnsupportedMediaTypeProblem ProblemType = "urn:todddo:problem:unsupported-media-type"
+	// InvalidParentProblem is used when the parent of a Todo does not exist,
+	// or would make the Todo a subtask of itself
+	InvalidParentProblem ProblemType = "urn:todddo:problem:invalid-parent"
+	// InvalidDependencyProblem is used when a Todo is made to depend on one
+	// that does not exist, or on itself, directly or not
+	InvalidDependencyProblem ProblemType = "urn:todddo:problem:invalid-dependency"
+	// InvalidListProblem is used when the name of a list is not valid, or
+	// when a Todo is put on a list that does not exist
+	InvalidListProblem ProblemType = "urn:todddo:problem:invalid-list"
+	// ListNotFoundProblem is used when a list does not exist
+	ListNotFoundProblem ProblemType = "urn:todddo:problem:list-not-found"
+	// ListNotEmptyProblem is used when deleting a list that still has Todos on
+	// it, without asking for them to be deleted too
+	ListNotEmptyProblem ProblemType = "urn:todddo:problem:list-not-empty"
+	// RevisionNotFoundProblem is used when reverting a Todo to a revision it
+	// does not have
+	RevisionNotFoundProblem ProblemType = "urn:todddo:problem:revision-not-found"
+	// InvalidMoveProblem is used when a Todo can't be moved where it was
+	// asked to go, or next to a Todo that does not exist
+	InvalidMoveProblem ProblemType = "urn:todddo:problem:invalid-move"
+	// BatchAbortedProblem is used for the operations of a batch that were
+	// not made because another one failed
+	BatchAbortedProblem ProblemType = "urn:todddo:problem:batch-aborted"
+	// InvalidSearchProblem is used when a search has no words to look for, or too many
+	InvalidSearchProblem ProblemType = "urn:todddo:problem:invalid-search"
+	// StorageFailureProblem is used when Todos could not be stored or retrieved
+	StorageFailureProblem ProblemType = "urn:todddo:problem:storage-failure"
+)
+
+var problemTitles = map[ProblemType]string{
+	InvalidRequestProblem:       "Invalid request",
+	InvalidTodoProblem:          "Invalid todo",
+	TodoNotFoundProblem:         "Todo not found",
+	VersionConflictProblem:      "Todo has changed",
+	InvalidCursorProblem:        "Invalid cursor",
+	InvalidMergePatchProblem:    "Invalid merge patch",
+	UnsupportedMediaTypeProblem: "Unsupported media type",
+	InvalidParentProblem:        "Invalid parent",
+	InvalidDependencyProblem:    "Invalid dependency",
+	InvalidListProblem:          "Invalid list",
+	ListNotFoundProblem:         "List not found",
+	ListNotEmptyProblem:         "List not empty",
+	RevisionNotFoundProblem:     "Revision not found",
+	InvalidMoveProblem:          "Invalid move",
+	BatchAbortedProblem:         "Batch aborted",
+	InvalidSearchProblem:        "Invalid search",
+	StorageFailureProblem:       "Storage failure",
+}
+
+// MkError returns an Error of the given type, occurring with the given status.
+//
+// Types without a title of their own, like GenericProblem, are titled with
+// the standard text of the status.
+func MkError(problemType ProblemType, status int, detail string) Error {
+	title, ok := problemTitles[problemType]
+	if !ok {
+		title = http.StatusText(status)
+	}
+	return Error{
+		Type:   problemType,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
 }
 
 type Success struct {
@@ -0,0 +1,31 @@
+package models
+
+import "github.com/lloydmeta/todddo-openapi/internal/domain"
+
+// ListData models the payload for creating or renaming a list of Todos
+type ListData struct {
+	Name string `json:"name" binding:"required" example:"Chores"`
+}
+
+// List models an existing list of Todos, e.g. one per project
+type List struct {
+	ID   domain.TodoListID `json:"id" binding:"required" example:"1"`
+	Name string            `json:"name" binding:"required" example:"Chores"`
+}
+
+// Lists models all the lists of Todos, in order of id
+type Lists struct {
+	Lists []List `json:"lists" binding:"required"`
+}
+
+const (
+	// RefuseTodos refuses to delete a list that still has Todos on it; the default
+	RefuseTodos = "refuse"
+	// CascadeToTodos deletes the Todos on a list along with it
+	CascadeToTodos = "cascade"
+)
+
+// DeleteListQuery models the query parameters for deleting a list
+type DeleteListQuery struct {
+	Todos string `form:"todos" binding:"omitempty,eq=refuse|eq=cascade"`
+}
@@ -1,16 +1,335 @@
 package models
 
 import (
+	"time"
+
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
 )
 
 // TodoData models the payload for creating a new Todo
 type TodoData struct {
-	Task string `json:"task" binding:"required" example:"Buy milk and eggs"`
+	Task      string     `json:"task" binding:"required" example:"Buy milk and eggs"`
+	Completed bool       `json:"completed" example:"false"`
+	DueAt     *time.Time `json:"due_at,omitempty" example:"2019-08-21T09:00:00Z"`
+	Tags      []string   `json:"tags" example:"errands,home"`
+	// Priority is one of low, normal (the default), high or urgent
+	Priority string `json:"priority,omitempty" enums:"low,normal,high,urgent" example:"normal"`
+	// ParentID makes the Todo a subtask of the one with this id
+	ParentID *domain.TodoID `json:"parent_id,omitempty" example:"1"`
+	// DependsOn are the ids of the Todos that have to be completed before this one
+	DependsOn []domain.TodoID `json:"depends_on,omitempty" swaggertype:"array,integer" example:"2,3"`
+	// Recurrence is an RFC 5545 recurrence rule, limited to FREQ (DAILY,
+	// WEEKLY or MONTHLY), INTERVAL, BYDAY, COUNT and UNTIL. Completing the
+	// Todo creates its next occurrence, which the rule moves on to.
+	Recurrence string `json:"recurrence,omitempty" example:"FREQ=WEEKLY;BYDAY=SA"`
+	// ListID puts the Todo on the list with this id
+	ListID *domain.TodoListID `json:"list_id,omitempty" example:"1"`
 }
 
 // Todo models the payload for an existing Todo
 type Todo struct {
-	ID   domain.TodoID `json:"id" binding:"required" example:"1"`
-	Task string        `json:"task" binding:"required" example:"Buy milk and eggs"`
+	ID domain.TodoID `json:"id" binding:"required" example:"1"`
+	// Version increases with every change, and is also sent as the ETag
+	Version     domain.TodoVersion `json:"version" example:"3"`
+	Task        string             `json:"task" binding:"required" example:"Buy milk and eggs"`
+	Completed   bool               `json:"completed" example:"true"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty" example:"2019-08-20T13:14:15Z"`
+	DueAt       *time.Time         `json:"due_at,omitempty" example:"2019-08-21T09:00:00Z"`
+	Tags        []string           `json:"tags" example:"errands,home"`
+	Priority    string             `json:"priority" enums:"low,normal,high,urgent" example:"normal"`
+	ParentID    *domain.TodoID     `json:"parent_id,omitempty" example:"1"`
+	DependsOn   []domain.TodoID    `json:"depends_on,omitempty" swaggertype:"array,integer" example:"2,3"`
+	Recurrence  string             `json:"recurrence,omitempty" example:"FREQ=WEEKLY;BYDAY=SA"`
+	ListID      *domain.TodoListID `json:"list_id,omitempty" example:"1"`
+	// Position is where the Todo is in the order Todos are arranged in by
+	// hand, which sorting on position lists them in; ignored when updating,
+	// see moving Todos instead
+	Position string `json:"position" example:"a1"`
+	// Blocked is true while any of the Todos this one depends on is not
+	// completed yet; ignored when updating
+	Blocked bool `json:"blocked" example:"false"`
+	// Children are the subtasks of the Todo, only given when listing Todos as a tree
+	Children []Todo `json:"children,omitempty"`
+}
+
+const (
+	// DefaultPageSize is the number of Todos in a page when no limit is given
+	DefaultPageSize uint = 100
+	// MaxPageSize is the largest limit that can be asked for
+	MaxPageSize uint = 1000
+)
+
+// TodoQuery models the query parameters for listing Todos
+type TodoQuery struct {
+	Completed *bool      `form:"completed"`
+	DueBefore *time.Time `form:"due_before"`
+	DueAfter  *time.Time `form:"due_after"`
+	Overdue   *bool      `form:"overdue"`
+	Tags      []string   `form:"tag"`
+	TagMatch  string     `form:"tag_match" binding:"omitempty,eq=any|eq=all"`
+	Sort      string     `form:"sort"`
+	Limit     uint       `form:"limit" binding:"max=1000"`
+	After     string     `form:"after"`
+	Tree      bool       `form:"tree"`
+	// ParentID is the id of the Todo whose children are being listed, if any
+	ParentID *domain.TodoID `form:"-"`
+	// ListID is the id of the list whose Todos are being listed, if any
+	ListID *domain.TodoListID `form:"-"`
+	// SortKeys is Sort, once it has been parsed
+	SortKeys []SortKey `form:"-"`
+}
+
+// SortKey models one of the comma-separated keys in TodoQuery.Sort: one of
+// the SortFields, prefixed with - to sort in descending order
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// SortFields are the fields that Todos can be sorted on
+var SortFields = []string{"id", "priority", "due", "position"}
+
+const (
+	// AnyTagMatch lists Todos with any of the tags asked for; the default
+	AnyTagMatch = "any"
+	// AllTagsMatch lists Todos with all of the tags asked for
+	AllTagsMatch = "all"
+)
+
+// TodoPage models a page of Todos
+type TodoPage struct {
+	Todos []Todo `json:"todos" binding:"required"`
+	// Next is the cursor to pass as `after` to get the next page; absent on the last page
+	Next *string `json:"next,omitempty" example:"eyJpZCI6MTAwfQ"`
+}
+
+const (
+	// OrphanChildren makes the children of a deleted Todo top-level Todos; the default
+	OrphanChildren = "orphan"
+	// CascadeToChildren deletes the children of a deleted Todo along with it
+	CascadeToChildren = "cascade"
+)
+
+// DeleteQuery models the query parameters for deleting a Todo
+type DeleteQuery struct {
+	Children string `form:"children" binding:"omitempty,eq=orphan|eq=cascade"`
+}
+
+// TagsData models the payload for tagging a Todo
+type TagsData struct {
+	Tags []string `json:"tags" binding:"required,min=1" example:"errands,home"`
+}
+
+// DependenciesData models the payload for making a Todo depend on others
+type DependenciesData struct {
+	DependsOn []domain.TodoID `json:"depends_on" binding:"required,min=1" swaggertype:"array,integer" example:"2,3"`
+}
+
+// MoveData models the payload for moving a Todo next to others, in the
+// order Todos are arranged in by hand. At least one of Before and After
+// has to be given; given both, After has to come before Before.
+type MoveData struct {
+	// Before is the id of the Todo to move the Todo to just before
+	Before *domain.TodoID `json:"before,omitempty" example:"3"`
+	// After is the id of the Todo to move the Todo to just after
+	After *domain.TodoID `json:"after,omitempty" example:"2"`
+}
+
+const (
+	// CreateOperation creates a new Todo out of the data of a BatchOperation
+	CreateOperation = "create"
+	// UpdateOperation replaces the data of the Todo with the id of a BatchOperation
+	UpdateOperation = "update"
+	// DeleteOperation deletes the Todo with the id of a BatchOperation
+	DeleteOperation = "delete"
+)
+
+// BatchOperation models one of the operations of a batch, which is made
+// just like the request it stands for would be
+type BatchOperation struct {
+	// Op is one of create, update or delete
+	Op string `json:"op" binding:"required,eq=create|eq=update|eq=delete" enums:"create,update,delete" example:"update"`
+	// ID is the id of the Todo to update or delete
+	ID domain.TodoID `json:"id,omitempty" example:"1"`
+	// Version makes updating or deleting the Todo fail if it is at any other
+	// version, as If-Match would
+	Version domain.TodoVersion `json:"version,omitempty" example:"3"`
+	// Todo is the data of the Todo to create, or to update it with
+	Todo *TodoData `json:"todo,omitempty"`
+	// Children says what happens to the subtasks of a deleted Todo, as the
+	// children query parameter would
+	Children string `json:"children,omitempty" binding:"omitempty,eq=orphan|eq=cascade" enums:"orphan,cascade" example:"orphan"`
+}
+
+// BatchData models the payload for making several changes at once, up to
+// 1000 of them
+type BatchData struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=1000,dive"`
+}
+
+// BatchResult models what came of one of the operations of a batch
+type BatchResult struct {
+	// Status is the HTTP status the operation would have been answered with
+	// on its own
+	Status int `json:"status" binding:"required" example:"200"`
+	// Todo is the created or updated Todo
+	Todo *Todo `json:"todo,omitempty"`
+	// Error is why the operation failed, or was not made because another did
+	Error *Error `json:"error,omitempty"`
+}
+
+// BatchResults models the results of a batch, in the same order as its
+// operations
+type BatchResults struct {
+	// Applied is true if all of the operations were made, and false if none were
+	Applied bool          `json:"applied" example:"true"`
+	Results []BatchResult `json:"results" binding:"required"`
+}
+
+// TodoList models Todos that are all given at once
+type TodoList struct {
+	Todos []Todo `json:"todos" binding:"required"`
+}
+
+// TagCount models a tag along with how many Todos use it
+type TagCount struct {
+	Tag   string `json:"tag" binding:"required" example:"errands"`
+	Count uint   `json:"count" binding:"required" example:"3"`
+}
+
+// TagList models the tags in use
+type TagList struct {
+	Tags []TagCount `json:"tags" binding:"required"`
+}
+
+const (
+	// DefaultSearchResults is the number of results of a search when no limit is given
+	DefaultSearchResults uint = 20
+	// MaxSearchResults is the largest limit that can be asked for when searching
+	MaxSearchResults uint = 100
+)
+
+// SearchQuery models the query parameters for searching Todos
+type SearchQuery struct {
+	// Q is the text to search for; every word in it has to be in a Todo's
+	// task, or be the start of a word there
+	Q     string `form:"q" binding:"required"`
+	Limit uint   `form:"limit" binding:"max=100"`
+}
+
+// SearchResult models a Todo found by a search
+type SearchResult struct {
+	Todo Todo `json:"todo" binding:"required"`
+	// Score says how relevant the Todo is; only meaningful compared to
+	// the scores of other results of the same search
+	Score float64 `json:"score" binding:"required" example:"0.42"`
+}
+
+// SearchResults models the results of a search, most relevant first
+type SearchResults struct {
+	Results []SearchResult `json:"results" binding:"required"`
+}
+
+// TrashedTodo models a deleted Todo, as it was when it was deleted
+type TrashedTodo struct {
+	Todo      Todo      `json:"todo" binding:"required"`
+	DeletedAt time.Time `json:"deleted_at" binding:"required" example:"2019-06-01T12:00:00Z"`
+}
+
+// Trash models the deleted Todos that can still be restored, most recently
+// deleted first
+type Trash struct {
+	Todos []TrashedTodo `json:"todos" binding:"required"`
+}
+
+// FieldChange models the change made to a field of a Todo by a revision,
+// as the JSON values of the field before and after it. From is null for
+// fields that were not set before, and To for fields that got unset; the
+// first revision of a Todo changes it from a blank one.
+type FieldChange struct {
+	Field string      `json:"field" binding:"required" example:"task"`
+	From  interface{} `json:"from" swaggertype:"object"`
+	To    interface{} `json:"to" swaggertype:"object"`
+}
+
+// Revision models a change made to a Todo, along with what the Todo was
+// like right after it, or right before it for deletions
+type Revision struct {
+	// Rev numbers the revisions of a Todo, starting from 1
+	Rev    uint      `json:"rev" binding:"required" example:"2"`
+	Change string    `json:"change" binding:"required" enums:"create,update,delete,restore" example:"update"`
+	At     time.Time `json:"at" binding:"required" example:"2019-06-01T12:00:00Z"`
+	// Actor is who made the change, as given in the X-Actor header
+	Actor   string        `json:"actor" example:"alice"`
+	Changes []FieldChange `json:"changes" binding:"required"`
+	Todo    Todo          `json:"todo" binding:"required"`
+}
+
+// History models the revisions of a Todo, oldest first
+type History struct {
+	Revisions []Revision `json:"revisions" binding:"required"`
+}
+
+// TodoEvent models a change made to a Todo, as streamed to those following
+// the changes
+type TodoEvent struct {
+	// ID is what to resume following from this event with, e.g. as the
+	// Last-Event-ID
+	ID    string    `json:"id" binding:"required" example:"1560000000000000001"`
+	Type  string    `json:"type" binding:"required" enums:"todo.created,todo.updated,todo.deleted,todo.restored" example:"todo.updated"`
+	At    time.Time `json:"at" binding:"required" example:"2019-06-01T12:00:00Z"`
+	Actor string    `json:"actor" example:"alice"`
+	// Todo is as it was right after the change, or right before it for
+	// deletions
+	Todo Todo `json:"todo" binding:"required"`
+	// Previous is the Todo as it was right before an update
+	Previous *Todo `json:"previous,omitempty"`
+}
+
+const (
+	// SubscribeCommand changes which changes to Todos a socket gets
+	SubscribeCommand = "subscribe"
+
+	// EventMessage pushes a TodoEvent down a socket
+	EventMessage = "event"
+	// ResultMessage answers a command sent over a socket
+	ResultMessage = "result"
+	// ErrorMessage answers a command sent over a socket that could not be
+	// made sense of
+	ErrorMessage = "error"
+)
+
+// SocketCommand models a message sent over a socket: either a change to
+// make to a Todo, made just like the BatchOperation it stands for would be,
+// or a subscription to the changes made to the Todos on some lists or with
+// some tags
+type SocketCommand struct {
+	// Op is one of subscribe, create, update or delete
+	Op string `json:"op" binding:"required,eq=subscribe|eq=create|eq=update|eq=delete" enums:"subscribe,create,update,delete" example:"update"`
+	// Ref is sent back in the answer to the command, to tell it apart
+	Ref      string             `json:"ref,omitempty" example:"42"`
+	ID       domain.TodoID      `json:"id,omitempty" example:"1"`
+	Version  domain.TodoVersion `json:"version,omitempty" example:"3"`
+	Todo     *TodoData          `json:"todo,omitempty"`
+	Children string             `json:"children,omitempty" binding:"omitempty,eq=orphan|eq=cascade" enums:"orphan,cascade" example:"orphan"`
+	// Lists limits the changes subscribed to to those of the Todos on these
+	// lists (or that were before being updated); all of them when empty
+	Lists []domain.TodoListID `json:"lists,omitempty" example:"1"`
+	// Tags limits the changes subscribed to to those of the Todos with any
+	// of these tags (or that had before being updated); all of them when
+	// empty
+	Tags []string `json:"tags,omitempty" example:"errands"`
+}
+
+// SocketMessage models a message sent down a socket
+type SocketMessage struct {
+	// Type is one of event, result or error
+	Type string `json:"type" binding:"required" enums:"event,result,error" example:"event"`
+	// Ref is that of the command answered
+	Ref   string     `json:"ref,omitempty" example:"42"`
+	Event *TodoEvent `json:"event,omitempty"`
+	// Result is what came of a command, as it would for a BatchOperation
+	Result *BatchResult `json:"result,omitempty"`
+	// Error is why a command could not be made sense of
+	Error *Error `json:"error,omitempty"`
 }
@@ -0,0 +1,60 @@
+package domain
+
+import "container/heap"
+
+// urgency is the order in which Todos that don't depend on each other get
+// put by TopologicalOrder: most important first, then soonest due
+var urgency = TodoQuery{Sort: []SortKey{{Field: SortByPriority, Descending: true}, {Field: SortByDueAt}}}
+
+// TopologicalOrder orders the given Todos so that each of them comes after
+// the ones it depends on, as far as those are among the given Todos; Todos
+// that are free to go in any order are ordered by urgency, then by id.
+//
+// Todos that are part of a dependency cycle, or depend on one, are left out.
+func TopologicalOrder(todos []Todo) []Todo {
+	given := make(map[TodoID]bool, len(todos))
+	for _, todo := range todos {
+		given[todo.ID] = true
+	}
+	waitingOn := make(map[TodoID]int, len(todos))
+	dependents := make(map[TodoID][]*Todo)
+	available := &todoHeap{}
+	for i := range todos {
+		todo := &todos[i]
+		for _, dependency := range todo.DependsOn {
+			if given[dependency] {
+				waitingOn[todo.ID]++
+				dependents[dependency] = append(dependents[dependency], todo)
+			}
+		}
+		if waitingOn[todo.ID] == 0 {
+			heap.Push(available, todo)
+		}
+	}
+	ordered := make([]Todo, 0, len(todos))
+	for available.Len() > 0 {
+		next := heap.Pop(available).(*Todo)
+		ordered = append(ordered, *next)
+		for _, dependent := range dependents[next.ID] {
+			waitingOn[dependent.ID]--
+			if waitingOn[dependent.ID] == 0 {
+				heap.Push(available, dependent)
+			}
+		}
+	}
+	return ordered
+}
+
+// todoHeap is a heap.Interface of Todos, with the most urgent on top
+type todoHeap []*Todo
+
+func (h todoHeap) Len() int            { return len(h) }
+func (h todoHeap) Less(i, j int) bool  { return urgency.Compare(h[i], h[j]) < 0 }
+func (h todoHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *todoHeap) Push(x interface{}) { *h = append(*h, x.(*Todo)) }
+func (h *todoHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,197 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// Publisher publishes Events to whoever subscribed to them
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Handler deals with an Event it subscribed to
+type Handler func(event Event)
+
+// Unsubscribe stops a Handler from getting any more Events
+type Unsubscribe func()
+
+// Bus is a Publisher that Handlers can subscribe to, in process. A Handler
+// that panics is only logged, so as not to get in the way of the others.
+type Bus interface {
+	Publisher
+	// Subscribe registers a Handler that gets every Event as it is
+	// published, before Publish returns, so it had better be quick. No
+	// other change gets made until it returns, so it must not make one.
+	Subscribe(handler Handler) Unsubscribe
+	// SubscribeAsync registers a Handler that gets every Event in the order
+	// it was published in, on a goroutine of its own, so that Publish never
+	// waits for it. Events already published when it unsubscribes are
+	// still handled.
+	SubscribeAsync(handler Handler) Unsubscribe
+	// Close unsubscribes all the Handlers, waiting for the asynchronous ones
+	// to deal with the Events already published to them
+	Close()
+}
+
+// MkBus returns a new Bus without any subscribers
+func MkBus() Bus {
+	return &bus{}
+}
+
+type bus struct {
+	mutex       sync.RWMutex
+	subscribers []*subscriber
+	// running are the goroutines of asynchronous subscribers
+	running sync.WaitGroup
+}
+
+type subscriber struct {
+	handler Handler
+	// queue holds the Events yet to be handled; nil for synchronous
+	// subscribers
+	queue *queue
+}
+
+func (b *bus) Publish(event Event) {
+	b.mutex.RLock()
+	subscribers := b.subscribers
+	b.mutex.RUnlock()
+	for _, s := range subscribers {
+		if s.queue == nil {
+			deliver(s.handler, event)
+		} else {
+			s.queue.push(event)
+		}
+	}
+}
+
+func (b *bus) Subscribe(handler Handler) Unsubscribe {
+	return b.add(&subscriber{handler: handler})
+}
+
+func (b *bus) SubscribeAsync(handler Handler) Unsubscribe {
+	s := &subscriber{handler: handler, queue: mkQueue()}
+	b.running.Add(1)
+	go func() {
+		defer b.running.Done()
+		s.queue.drain(handler)
+	}()
+	return b.add(s)
+}
+
+func (b *bus) Close() {
+	b.mutex.Lock()
+	subscribers := b.subscribers
+	b.subscribers = nil
+	b.mutex.Unlock()
+	for _, s := range subscribers {
+		s.close()
+	}
+	b.running.Wait()
+}
+
+// add registers the given subscriber, returning what unsubscribes it
+func (b *bus) add(s *subscriber) Unsubscribe {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	// Publish goes through the subscribers without holding the mutex, so
+	// they are always replaced rather than changed in place
+	b.subscribers = append(b.subscribers[:len(b.subscribers):len(b.subscribers)], s)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.remove(s)
+			s.close()
+		})
+	}
+}
+
+func (b *bus) remove(s *subscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	remaining := make([]*subscriber, 0, len(b.subscribers))
+	for _, other := range b.subscribers {
+		if other != s {
+			remaining = append(remaining, other)
+		}
+	}
+	b.subscribers = remaining
+}
+
+func (s *subscriber) close() {
+	if s.queue != nil {
+		s.queue.close()
+	}
+}
+
+// queue holds the Events published to an asynchronous subscriber until
+// they get handled, however many there are, so that publishing never
+// blocks
+type queue struct {
+	mutex  sync.Mutex
+	events []Event
+	closed bool
+	// ready is signalled when there are Events to handle, or the queue is
+	// closed
+	ready chan struct{}
+}
+
+func mkQueue() *queue {
+	return &queue{ready: make(chan struct{}, 1)}
+}
+
+func (q *queue) push(event Event) {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return
+	}
+	q.events = append(q.events, event)
+	q.mutex.Unlock()
+	q.signal()
+}
+
+func (q *queue) close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+	q.signal()
+}
+
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+		// already signalled
+	}
+}
+
+// drain hands the Events in the queue to the given Handler as they come,
+// until it is closed and there are none left
+func (q *queue) drain(handler Handler) {
+	for {
+		q.mutex.Lock()
+		events, closed := q.events, q.closed
+		q.events = nil
+		q.mutex.Unlock()
+		for _, event := range events {
+			deliver(handler, event)
+		}
+		if len(events) == 0 {
+			if closed {
+				return
+			}
+			<-q.ready
+		}
+	}
+}
+
+func deliver(handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("handler for event [%s] panicked: %v", event.Name(), r)
+		}
+	}()
+	handler(event)
+}
@@ -0,0 +1,97 @@
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func created(id domain.TodoID) Event {
+	return TodoCreated{Change{Todo: domain.Todo{ID: id}}}
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := MkBus()
+	var handled []string
+	unsubscribe := bus.Subscribe(func(event Event) {
+		handled = append(handled, event.Name())
+	})
+	bus.Publish(created(1))
+	bus.Publish(TodoDeleted{Change{Todo: domain.Todo{ID: 1}}})
+	assert.Equal(t, []string{"todo.created", "todo.deleted"}, handled)
+
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(created(2))
+	assert.Len(t, handled, 2)
+}
+
+func TestSubscribeAsync(t *testing.T) {
+	bus := MkBus()
+	var mutex sync.Mutex
+	var handled []domain.TodoID
+	bus.SubscribeAsync(func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		handled = append(handled, event.Details().Todo.ID)
+	})
+	for id := domain.TodoID(1); id <= 100; id++ {
+		bus.Publish(created(id))
+	}
+	// Close waits for the Events already published to be handled
+	bus.Close()
+	assert.Len(t, handled, 100)
+	for i, id := range handled {
+		assert.Equal(t, domain.TodoID(i+1), id)
+	}
+	bus.Publish(created(101))
+	assert.Len(t, handled, 100)
+}
+
+func TestSubscribeAsyncDoesNotBlockPublish(t *testing.T) {
+	bus := MkBus()
+	release := make(chan struct{})
+	var handled int
+	bus.SubscribeAsync(func(event Event) {
+		<-release
+		handled++
+	})
+	for id := domain.TodoID(1); id <= 10; id++ {
+		bus.Publish(created(id))
+	}
+	close(release)
+	bus.Close()
+	assert.Equal(t, 10, handled)
+}
+
+func TestUnsubscribeAsync(t *testing.T) {
+	bus := MkBus()
+	var handled int
+	unsubscribe := bus.SubscribeAsync(func(event Event) {
+		handled++
+	})
+	bus.Publish(created(1))
+	unsubscribe()
+	bus.Publish(created(2))
+	bus.Close()
+	assert.Equal(t, 1, handled)
+}
+
+func TestPanickingHandlers(t *testing.T) {
+	bus := MkBus()
+	var handled int
+	bus.Subscribe(func(event Event) {
+		panic("oops")
+	})
+	bus.SubscribeAsync(func(event Event) {
+		panic("oops")
+	})
+	bus.Subscribe(func(event Event) {
+		handled++
+	})
+	bus.Publish(created(1))
+	bus.Close()
+	assert.Equal(t, 1, handled)
+}
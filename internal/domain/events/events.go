@@ -0,0 +1,47 @@
+package events
+
+import (
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// Event is something that happened to a Todo, published once the change
+// has been made for good
+type Event interface {
+	// Name says what happened, e.g. "todo.created"
+	Name() string
+	Details() Change
+}
+
+// Change is what all the Events say about the change they are about
+type Change struct {
+	// Todo is as it was right after the change (or, for deletions, right
+	// before)
+	Todo domain.Todo
+	// Previous is the Todo as it was right before an update; nil for the
+	// other changes
+	Previous *domain.Todo
+	// Actor is who made the change
+	Actor string
+	At    time.Time
+}
+
+// TodoCreated says a Todo was created
+type TodoCreated struct{ Change }
+
+// TodoUpdated says a Todo was changed, however it was
+type TodoUpdated struct{ Change }
+
+// TodoDeleted says a Todo was moved to the trash
+type TodoDeleted struct{ Change }
+
+// TodoRestored says a Todo was taken back out of the trash
+type TodoRestored struct{ Change }
+
+func (e TodoCreated) Name() string  { return "todo.created" }
+func (e TodoUpdated) Name() string  { return "todo.updated" }
+func (e TodoDeleted) Name() string  { return "todo.deleted" }
+func (e TodoRestored) Name() string { return "todo.restored" }
+
+func (c Change) Details() Change { return c }
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sequenced is an Event along with its id in the Feed it went through
+type Sequenced struct {
+	ID    uint64
+	Event Event
+}
+
+// Feed numbers the Events published to a Bus, holding on to the latest
+// ones so that whoever follows it can catch up on what they missed, e.g.
+// after reconnecting
+type Feed interface {
+	// Follow returns a Subscription to the Events from now on, holding at
+	// most buffer (at least 1) of them yet to be received. When after is
+	// not nil, the Events held that came after the one with that id are
+	// returned too, unless some of those are no longer held, in which case
+	// complete is false.
+	Follow(after *uint64, buffer int) (missed []Sequenced, complete bool, subscription Subscription)
+}
+
+// Subscription gets the Events going through a Feed
+type Subscription interface {
+	// Events gets each Event in turn. It is closed once the Subscription is
+	// cancelled, or when it falls more than its buffer behind, so that a
+	// slow follower never holds up the others.
+	Events() <-chan Sequenced
+	Cancel()
+}
+
+// MkFeed returns a Feed of the Events published to the given Bus from now
+// on, holding on to the latest capacity of them.
+//
+// Ids carry on from the time the Feed is made at, so that those from
+// another Feed, such as the one from before a restart, are never taken for
+// ones of its own.
+func MkFeed(bus Bus, capacity int) Feed {
+	f := &feed{
+		lastId:        uint64(time.Now().UnixNano()),
+		capacity:      capacity,
+		subscriptions: make(map[*subscription]bool),
+	}
+	bus.Subscribe(f.publish)
+	return f
+}
+
+type feed struct {
+	mutex  sync.Mutex
+	lastId uint64
+	// held are the latest Events, oldest first
+	held          []Sequenced
+	capacity      int
+	subscriptions map[*subscription]bool
+}
+
+func (f *feed) publish(event Event) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.lastId++
+	sequenced := Sequenced{ID: f.lastId, Event: event}
+	if f.capacity > 0 {
+		if len(f.held) >= f.capacity {
+			f.held = f.held[len(f.held)-f.capacity+1:]
+		}
+		f.held = append(f.held, sequenced)
+	}
+	for s := range f.subscriptions {
+		select {
+		case s.events <- sequenced:
+		default:
+			// fallen behind
+			f.drop(s)
+		}
+	}
+}
+
+func (f *feed) Follow(after *uint64, buffer int) ([]Sequenced, bool, Subscription) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	missed, complete := []Sequenced(nil), true
+	if after != nil {
+		missed, complete = f.since(*after)
+	}
+	s := &subscription{feed: f, events: make(chan Sequenced, buffer)}
+	f.subscriptions[s] = true
+	return missed, complete, s
+}
+
+// since returns the Events held that came after the one with the given id,
+// if all of them are
+func (f *feed) since(after uint64) ([]Sequenced, bool) {
+	if after == f.lastId {
+		return nil, true
+	}
+	if after > f.lastId || len(f.held) == 0 || f.held[0].ID > after+1 {
+		return nil, false
+	}
+	start := sort.Search(len(f.held), func(i int) bool { return f.held[i].ID > after })
+	return append([]Sequenced(nil), f.held[start:]...), true
+}
+
+// drop stops the given subscription from getting any more Events. Must be
+// called with the mutex held.
+func (f *feed) drop(s *subscription) {
+	if f.subscriptions[s] {
+		delete(f.subscriptions, s)
+		close(s.events)
+	}
+}
+
+type subscription struct {
+	feed   *feed
+	events chan Sequenced
+}
+
+func (s *subscription) Events() <-chan Sequenced {
+	return s.events
+}
+
+func (s *subscription) Cancel() {
+	s.feed.mutex.Lock()
+	defer s.feed.mutex.Unlock()
+	s.feed.drop(s)
+}
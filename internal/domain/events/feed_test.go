@@ -0,0 +1,87 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(events []Sequenced) []domain.TodoID {
+	var todoIds []domain.TodoID
+	for _, event := range events {
+		todoIds = append(todoIds, event.Event.Details().Todo.ID)
+	}
+	return todoIds
+}
+
+func TestFollow(t *testing.T) {
+	bus := MkBus()
+	feed := MkFeed(bus, 10)
+	missed, complete, subscription := feed.Follow(nil, 10)
+	assert.Empty(t, missed)
+	assert.True(t, complete)
+	bus.Publish(created(1))
+	bus.Publish(created(2))
+	first, second := <-subscription.Events(), <-subscription.Events()
+	assert.Equal(t, domain.TodoID(1), first.Event.Details().Todo.ID)
+	assert.Equal(t, first.ID+1, second.ID)
+
+	subscription.Cancel()
+	subscription.Cancel()
+	_, open := <-subscription.Events()
+	assert.False(t, open)
+	bus.Publish(created(3))
+}
+
+func TestFollowAfter(t *testing.T) {
+	bus := MkBus()
+	feed := MkFeed(bus, 3)
+	_, _, subscription := feed.Follow(nil, 10)
+	for id := domain.TodoID(1); id <= 5; id++ {
+		bus.Publish(created(id))
+	}
+	var seen []Sequenced
+	for i := 0; i < 5; i++ {
+		seen = append(seen, <-subscription.Events())
+	}
+
+	missed, complete, _ := feed.Follow(&seen[2].ID, 10)
+	assert.True(t, complete)
+	assert.Equal(t, []domain.TodoID{4, 5}, ids(missed))
+	missed, complete, _ = feed.Follow(&seen[1].ID, 10)
+	assert.True(t, complete)
+	assert.Equal(t, []domain.TodoID{3, 4, 5}, ids(missed))
+	missed, complete, _ = feed.Follow(&seen[4].ID, 10)
+	assert.True(t, complete)
+	assert.Empty(t, missed)
+	// no longer held
+	missed, complete, _ = feed.Follow(&seen[0].ID, 10)
+	assert.False(t, complete)
+	assert.Empty(t, missed)
+	// not of this feed
+	unknown := seen[4].ID + 1
+	missed, complete, _ = feed.Follow(&unknown, 10)
+	assert.False(t, complete)
+	assert.Empty(t, missed)
+}
+
+func TestFollowersFallingBehind(t *testing.T) {
+	bus := MkBus()
+	feed := MkFeed(bus, 10)
+	_, _, slow := feed.Follow(nil, 2)
+	_, _, fast := feed.Follow(nil, 1)
+	bus.Publish(created(1))
+	assert.Equal(t, domain.TodoID(1), (<-fast.Events()).Event.Details().Todo.ID)
+	bus.Publish(created(2))
+	bus.Publish(created(3))
+
+	var received []Sequenced
+	for event := range slow.Events() {
+		received = append(received, event)
+	}
+	assert.Equal(t, []domain.TodoID{1, 2}, ids(received))
+	assert.Equal(t, domain.TodoID(2), (<-fast.Events()).Event.Details().Todo.ID)
+	_, open := <-fast.Events()
+	assert.False(t, open)
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// RevisionChange says what kind of change a TodoRevision records
+type RevisionChange string
+
+const (
+	CreatedRevision  RevisionChange = "create"
+	UpdatedRevision  RevisionChange = "update"
+	DeletedRevision  RevisionChange = "delete"
+	RestoredRevision RevisionChange = "restore"
+)
+
+// TodoRevision records a change made to a Todo, as kept in its history by
+// the TodoRepo. Revisions are never changed once recorded, and outlive the
+// Todo they are about.
+type TodoRevision struct {
+	// Rev numbers the revisions of a Todo, from 1 for the oldest
+	Rev    uint
+	Change RevisionChange
+	At     time.Time
+	// Actor is who made the change, as given to TodoRepo.AsActor; empty
+	// when not known
+	Actor string
+	// Todo is as it was right after the change, or right before it for
+	// deletions. It is never Blocked.
+	Todo Todo
+}
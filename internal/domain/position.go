@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Positions order Todos by hand. They are fractional indexes: strings that
+// sort in the order Todos are listed in, with a new one always to be found
+// between any two others, so that moving a Todo only ever changes the Todo
+// itself.
+//
+// A position is an integer part followed by a fractional part, both in
+// base 62 (0-9, A-Z, then a-z, so that they sort as plain strings). The
+// first character of the integer part says how many digits it has: a-z
+// for 1 to 26 digits counting up, A-Z for 1 to 26 digits counting down,
+// so that positions added to either end stay short. The fractional part
+// never ends in 0, so there is always room before it.
+
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// firstPosition is where the first Todo goes
+const firstPosition = "a0"
+
+// smallestInteger is the integer part that can't be decremented, so
+// positions before it have to be made up of fractional parts
+var smallestInteger = "A" + strings.Repeat("0", 26)
+
+// PositionBetween returns a position that sorts after lower and before
+// upper, where an empty lower or upper means there is no bound on that
+// side. It fails if either is not a valid position, or if lower does not
+// sort before upper.
+func PositionBetween(lower string, upper string) (string, error) {
+	if len(lower) > 0 {
+		if err := checkPosition(lower); err != nil {
+			return "", err
+		}
+	}
+	if len(upper) > 0 {
+		if err := checkPosition(upper); err != nil {
+			return "", err
+		}
+	}
+	switch {
+	case len(lower) > 0 && len(upper) > 0 && lower >= upper:
+		return "", fmt.Errorf("position %q does not come before %q", lower, upper)
+	case len(lower) == 0 && len(upper) == 0:
+		return firstPosition, nil
+	case len(lower) == 0:
+		integer, fraction := splitPosition(upper)
+		if integer == smallestInteger {
+			return integer + midpoint("", fraction), nil
+		}
+		if integer < upper {
+			return integer, nil
+		}
+		return decrementInteger(integer), nil
+	case len(upper) == 0:
+		integer, fraction := splitPosition(lower)
+		if incremented, ok := incrementInteger(integer); ok {
+			return incremented, nil
+		}
+		return integer + midpoint(fraction, ""), nil
+	default:
+		lowerInteger, lowerFraction := splitPosition(lower)
+		upperInteger, upperFraction := splitPosition(upper)
+		if lowerInteger == upperInteger {
+			return lowerInteger + midpoint(lowerFraction, upperFraction), nil
+		}
+		if incremented, ok := incrementInteger(lowerInteger); ok && incremented < upper {
+			return incremented, nil
+		}
+		return lowerInteger + midpoint(lowerFraction, ""), nil
+	}
+}
+
+// checkPosition makes sure the given position is one PositionBetween
+// could have returned
+func checkPosition(position string) error {
+	length, ok := integerLength(position[0])
+	if !ok || length > len(position) || position == smallestInteger {
+		return fmt.Errorf("%q is not a valid position", position)
+	}
+	for _, c := range position[1:] {
+		if !strings.ContainsRune(positionDigits, c) {
+			return fmt.Errorf("%q is not a valid position", position)
+		}
+	}
+	if _, fraction := splitPosition(position); strings.HasSuffix(fraction, "0") {
+		return fmt.Errorf("%q is not a valid position", position)
+	}
+	return nil
+}
+
+// integerLength returns how long the integer part starting with the given
+// head is, head included
+func integerLength(head byte) (int, bool) {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2, true
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2, true
+	default:
+		return 0, false
+	}
+}
+
+// splitPosition splits a valid position into its integer and fractional parts
+func splitPosition(position string) (string, string) {
+	length, _ := integerLength(position[0])
+	return position[:length], position[length:]
+}
+
+// midpoint returns a fractional part between the given ones, where an empty
+// upper one means there is no upper bound
+func midpoint(lower string, upper string) string {
+	if len(upper) > 0 {
+		// skip the prefix they share, padding lower with zeros
+		n := 0
+		for n < len(upper) && digitAt(lower, n, 0) == digitIndex(upper[n]) {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(lower) {
+				rest = lower[n:]
+			}
+			return upper[:n] + midpoint(rest, upper[n:])
+		}
+	}
+	lowerDigit := digitAt(lower, 0, 0)
+	upperDigit := len(positionDigits)
+	if len(upper) > 0 {
+		upperDigit = digitIndex(upper[0])
+	}
+	if upperDigit-lowerDigit > 1 {
+		return string(positionDigits[(lowerDigit+upperDigit+1)/2])
+	}
+	// the digits are adjacent, so the answer needs another digit
+	if len(upper) > 1 {
+		return upper[:1]
+	}
+	rest := ""
+	if len(lower) > 1 {
+		rest = lower[1:]
+	}
+	return string(positionDigits[lowerDigit]) + midpoint(rest, "")
+}
+
+// digitAt returns the value of the digit at index i of the given
+// fractional part, or the given default past its end
+func digitAt(fraction string, i int, otherwise int) int {
+	if i < len(fraction) {
+		return digitIndex(fraction[i])
+	}
+	return otherwise
+}
+
+func digitIndex(digit byte) int {
+	return strings.IndexByte(positionDigits, digit)
+}
+
+// incrementInteger returns the integer part after the given one, or false
+// if it is the largest one there is
+func incrementInteger(integer string) (string, bool) {
+	head, digits := integer[0], []byte(integer[1:])
+	for i := len(digits) - 1; i >= 0; i-- {
+		if d := digitIndex(digits[i]) + 1; d < len(positionDigits) {
+			digits[i] = positionDigits[d]
+			return string(head) + string(digits), true
+		}
+		digits[i] = positionDigits[0]
+	}
+	// carried all the way, so the number of digits changes
+	switch head {
+	case 'Z':
+		return "a0", true
+	case 'z':
+		return "", false
+	}
+	head++
+	if head > 'a' {
+		digits = append(digits, positionDigits[0])
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits), true
+}
+
+// decrementInteger returns the integer part before the given one, which
+// must not be smallestInteger
+func decrementInteger(integer string) string {
+	head, digits := integer[0], []byte(integer[1:])
+	largest := positionDigits[len(positionDigits)-1]
+	for i := len(digits) - 1; i >= 0; i-- {
+		if d := digitIndex(digits[i]) - 1; d >= 0 {
+			digits[i] = positionDigits[d]
+			return string(head) + string(digits)
+		}
+		digits[i] = largest
+	}
+	// borrowed all the way, so the number of digits changes
+	if head == 'a' {
+		return "Z" + string(largest)
+	}
+	head--
+	if head < 'Z' {
+		digits = append(digits, largest)
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits)
+}
@@ -0,0 +1,309 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a Recurrence repeats, before its Interval is
+// taken into account
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Recurrence says when a recurring Todo comes round again. It is a subset
+// of the recurrence rules of RFC 5545 (iCalendar): FREQ is one of DAILY,
+// WEEKLY or MONTHLY, along with INTERVAL, BYDAY, COUNT or UNTIL.
+//
+// Each occurrence is a Todo of its own, so rules are relative to the
+// occurrence at hand rather than to where the series started, and COUNT
+// is how many occurrences are left, including the one at hand.
+type Recurrence struct {
+	Frequency Frequency
+	// Interval is how many days, weeks or months apart occurrences are; at least 1
+	Interval int
+	// ByDay restricts occurrences to the given days of the week, which can
+	// only be numbered for Monthly Recurrences; sorted, without duplicates
+	ByDay []WeekdayNum
+	// Count is how many occurrences are left, or 0 if that isn't limited
+	Count uint
+	// Until is when the last occurrence can be due at the latest, if at all
+	Until *time.Time
+}
+
+// WeekdayNum is a day of the week, optionally numbered within a month
+// the way RFC 5545 does in BYDAY: 1MO is the first Monday of the month,
+// and -1FR is the last Friday
+type WeekdayNum struct {
+	// Ordinal is between -5 and 5, where 0 means every such day
+	Ordinal int
+	Weekday time.Weekday
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// untilLayout is how UNTIL is written when it is a date-time, always in UTC
+const untilLayout = "20060102T150405Z"
+
+// ParseRecurrence parses a recurrence rule such as FREQ=WEEKLY;BYDAY=SA,SU,
+// with or without an RRULE: prefix
+func ParseRecurrence(rule string) (Recurrence, error) {
+	recurrence := Recurrence{Interval: 1}
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(rule, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 || len(keyValue[1]) == 0 {
+			return Recurrence{}, fmt.Errorf("%q is not of the form NAME=VALUE", part)
+		}
+		key, value := keyValue[0], keyValue[1]
+		if seen[key] {
+			return Recurrence{}, fmt.Errorf("%s is given more than once", key)
+		}
+		seen[key] = true
+		var err error
+		switch key {
+		case "FREQ":
+			recurrence.Frequency, err = parseFrequency(value)
+		case "INTERVAL":
+			recurrence.Interval, err = parsePositive(key, value)
+		case "COUNT":
+			var count int
+			count, err = parsePositive(key, value)
+			recurrence.Count = uint(count)
+		case "UNTIL":
+			recurrence.Until, err = parseUntil(value)
+		case "BYDAY":
+			recurrence.ByDay, err = parseByDay(value)
+		default:
+			err = fmt.Errorf("%s is not supported", key)
+		}
+		if err != nil {
+			return Recurrence{}, err
+		}
+	}
+	if len(recurrence.Frequency) == 0 {
+		return Recurrence{}, fmt.Errorf("FREQ is missing")
+	}
+	if recurrence.Count > 0 && recurrence.Until != nil {
+		return Recurrence{}, fmt.Errorf("COUNT and UNTIL can't both be given")
+	}
+	if recurrence.Frequency != Monthly {
+		for _, day := range recurrence.ByDay {
+			if day.Ordinal != 0 {
+				return Recurrence{}, fmt.Errorf("BYDAY can only be numbered for MONTHLY rules")
+			}
+		}
+	}
+	return recurrence, nil
+}
+
+func parseFrequency(value string) (Frequency, error) {
+	switch frequency := Frequency(value); frequency {
+	case Daily, Weekly, Monthly:
+		return frequency, nil
+	default:
+		return "", fmt.Errorf("FREQ must be one of DAILY, WEEKLY or MONTHLY, not %s", value)
+	}
+}
+
+func parsePositive(key string, value string) (int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return 0, fmt.Errorf("%s must be a positive number, not %s", key, value)
+	}
+	return parsed, nil
+}
+
+// parseUntil parses either a UTC date-time or a date, which is taken to
+// last until the end of that day in UTC
+func parseUntil(value string) (*time.Time, error) {
+	if until, err := time.Parse(untilLayout, value); err == nil {
+		return &until, nil
+	}
+	if date, err := time.Parse("20060102", value); err == nil {
+		until := date.AddDate(0, 0, 1).Add(-time.Second)
+		return &until, nil
+	}
+	return nil, fmt.Errorf("UNTIL must be a date (YYYYMMDD) or a UTC date-time (YYYYMMDDTHHMMSSZ), not %s", value)
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, day := range strings.Split(value, ",") {
+		if len(day) < 2 {
+			return nil, fmt.Errorf("%q is not a day of the week", day)
+		}
+		weekday, ordinal := day[len(day)-2:], day[:len(day)-2]
+		parsed := WeekdayNum{Weekday: -1}
+		for i, name := range weekdayNames {
+			if name == weekday {
+				parsed.Weekday = time.Weekday(i)
+			}
+		}
+		if parsed.Weekday < 0 {
+			return nil, fmt.Errorf("%q is not a day of the week", day)
+		}
+		if len(ordinal) > 0 {
+			number, err := strconv.Atoi(ordinal)
+			if err != nil || number == 0 || number < -5 || number > 5 {
+				return nil, fmt.Errorf("%q is not a day of the week numbered between -5 and 5", day)
+			}
+			parsed.Ordinal = number
+		}
+		days = append(days, parsed)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].less(days[j]) })
+	unique := days[:1]
+	for _, day := range days[1:] {
+		if day != unique[len(unique)-1] {
+			unique = append(unique, day)
+		}
+	}
+	return unique, nil
+}
+
+// less orders WeekdayNums from Monday to Sunday, then by Ordinal
+func (d WeekdayNum) less(other WeekdayNum) bool {
+	if d.Weekday != other.Weekday {
+		return mondayFirst(d.Weekday) < mondayFirst(other.Weekday)
+	}
+	return d.Ordinal < other.Ordinal
+}
+
+func (d WeekdayNum) String() string {
+	if d.Ordinal == 0 {
+		return weekdayNames[d.Weekday]
+	}
+	return strconv.Itoa(d.Ordinal) + weekdayNames[d.Weekday]
+}
+
+// String returns the Recurrence as a recurrence rule that ParseRecurrence
+// parses back into the same Recurrence
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Frequency)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.FormatUint(uint64(r.Count), 10))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// maxRecurrenceSteps bounds how far ahead Next looks for an occurrence, as
+// some rules, like the 5th Friday of every 12th month, can go a long time
+// without one
+const maxRecurrenceSteps = 1000
+
+// Next returns when the occurrence after the one at the given time is, along
+// with the Recurrence that the occurrence follows from there on. It returns
+// false if there are no more occurrences.
+func (r Recurrence) Next(after time.Time) (time.Time, Recurrence, bool) {
+	if r.Count == 1 {
+		return time.Time{}, Recurrence{}, false
+	}
+	next, ok := r.next(after)
+	if !ok || (r.Until != nil && next.After(*r.Until)) {
+		return time.Time{}, Recurrence{}, false
+	}
+	rest := r
+	if r.Count > 1 {
+		rest.Count--
+	}
+	return next, rest, true
+}
+
+func (r Recurrence) next(after time.Time) (time.Time, bool) {
+	switch r.Frequency {
+	case Daily:
+		for step := 1; step <= maxRecurrenceSteps; step++ {
+			if next := after.AddDate(0, 0, step*r.Interval); r.onDay(next) {
+				return next, true
+			}
+		}
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			return after.AddDate(0, 0, 7*r.Interval), true
+		}
+		// the rest of the week at hand first, then the next week due
+		weekStart := after.AddDate(0, 0, -mondayFirst(after.Weekday()))
+		for week := 0; week <= maxRecurrenceSteps; week += r.Interval {
+			for day := 0; day < 7; day++ {
+				if next := weekStart.AddDate(0, 0, week*7+day); next.After(after) && r.onDay(next) {
+					return next, true
+				}
+			}
+		}
+	case Monthly:
+		// the rest of the month at hand first, then the next month due
+		monthStart := time.Date(after.Year(), after.Month(), 1, after.Hour(), after.Minute(), after.Second(), after.Nanosecond(), after.Location())
+		for month := 0; month <= maxRecurrenceSteps; month += r.Interval {
+			if month == 0 && len(r.ByDay) == 0 {
+				continue
+			}
+			start := monthStart.AddDate(0, month, 0)
+			for day := 0; start.AddDate(0, 0, day).Month() == start.Month(); day++ {
+				if next := start.AddDate(0, 0, day); next.After(after) && r.onMonthDay(next, after.Day()) {
+					return next, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// onDay returns true if the given time is on one of the days in ByDay,
+// ignoring ordinals, or if there are none
+func (r Recurrence) onDay(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if day.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// onMonthDay returns true if the given time is on one of the days in ByDay,
+// counting ordinals within its month, or on the given day of the month if
+// there are none
+func (r Recurrence) onMonthDay(t time.Time, dayOfMonth int) bool {
+	if len(r.ByDay) == 0 {
+		return t.Day() == dayOfMonth
+	}
+	fromStart := (t.Day()-1)/7 + 1
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	fromEnd := -((daysInMonth-t.Day())/7 + 1)
+	for _, day := range r.ByDay {
+		if day.Weekday == t.Weekday() && (day.Ordinal == 0 || day.Ordinal == fromStart || day.Ordinal == fromEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+// mondayFirst returns how many days into a week starting on Monday the
+// given weekday is, which is how RFC 5545 counts weeks by default
+func mondayFirst(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
@@ -0,0 +1,116 @@
+package repotest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// ListFactory returns a new, empty domain.TodoListRepo along with a function
+// that releases whatever it holds once a test is done with it
+type ListFactory func(t *testing.T) (repo domain.TodoListRepo, teardown func())
+
+// RunLists runs every contract test for domain.TodoListRepo against repos
+// returned by the given ListFactory, each as a subtest with a fresh repo
+func RunLists(t *testing.T, factory ListFactory) {
+	for _, contract := range listContracts {
+		c := contract
+		t.Run(c.name, func(t *testing.T) {
+			repo, teardown := factory(t)
+			defer teardown()
+			c.test(t, repo)
+		})
+	}
+}
+
+type listContract struct {
+	name string
+	test func(t *testing.T, repo domain.TodoListRepo)
+}
+
+var listContracts = []listContract{
+	{"CreateThenGet", testListCreateThenGet},
+	{"GetAbsent", testListGetAbsent},
+	{"ListOrderedById", testListsOrderedById},
+	{"UpdatePresent", testListUpdatePresent},
+	{"UpdateAbsent", testListUpdateAbsent},
+	{"DeletePresent", testListDeletePresent},
+	{"DeleteAbsent", testListDeleteAbsent},
+}
+
+func mustCreateList(t *testing.T, repo domain.TodoListRepo, name string) domain.TodoList {
+	created, err := repo.Create(&domain.NewTodoList{Name: name})
+	if err != nil {
+		t.Fatalf("Could not create list [%s]: %v", name, err)
+	}
+	return created
+}
+
+func testListCreateThenGet(t *testing.T, repo domain.TodoListRepo) {
+	created := mustCreateList(t, repo, "Chores")
+	assert.NotZero(t, created.ID)
+	assert.Equal(t, "Chores", created.Name)
+	retrieved, err := repo.Get(&created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, created, retrieved)
+}
+
+func testListGetAbsent(t *testing.T, repo domain.TodoListRepo) {
+	id := domain.TodoListID(42)
+	_, err := repo.Get(&id)
+	assert.Equal(t, domain.TodoListNotFound{ID: id}, err)
+}
+
+func testListsOrderedById(t *testing.T, repo domain.TodoListRepo) {
+	empty, err := repo.List()
+	assert.Nil(t, err)
+	assert.Empty(t, empty)
+
+	first := mustCreateList(t, repo, "Work")
+	second := mustCreateList(t, repo, "Home")
+	third := mustCreateList(t, repo, "Garden")
+	assert.True(t, first.ID < second.ID && second.ID < third.ID)
+	listed, err := repo.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoList{first, second, third}, listed)
+}
+
+func testListUpdatePresent(t *testing.T, repo domain.TodoListRepo) {
+	created := mustCreateList(t, repo, "Chores")
+	created.Name = "Errands"
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Equal(t, created, updated)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testListUpdateAbsent(t *testing.T, repo domain.TodoListRepo) {
+	absent := domain.TodoList{ID: 42, Name: "Nowhere"}
+	_, err := repo.Update(&absent)
+	assert.Equal(t, domain.TodoListNotFound{ID: absent.ID}, err)
+}
+
+func testListDeletePresent(t *testing.T, repo domain.TodoListRepo) {
+	deleted := mustCreateList(t, repo, "Chores")
+	kept := mustCreateList(t, repo, "Errands")
+	ok, err := repo.Delete(&deleted.ID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = repo.Get(&deleted.ID)
+	assert.Equal(t, domain.TodoListNotFound{ID: deleted.ID}, err)
+	listed, _ := repo.List()
+	assert.Equal(t, []domain.TodoList{kept}, listed)
+	// ids of deleted lists are not handed out again
+	next := mustCreateList(t, repo, "Garden")
+	assert.True(t, next.ID > kept.ID)
+}
+
+func testListDeleteAbsent(t *testing.T, repo domain.TodoListRepo) {
+	id := domain.TodoListID(42)
+	ok, err := repo.Delete(&id)
+	assert.False(t, ok)
+	assert.Equal(t, domain.TodoListNotFound{ID: id}, err)
+}
@@ -0,0 +1,1246 @@
+// Package repotest holds a conformance suite that every domain.TodoRepo
+// implementation should pass, so that they can be used interchangeably.
+//
+// Implementations hook into it from their own tests:
+//
+//	func TestTodoRepoContract(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) (domain.TodoRepo, func()) {
+//			return MkRepo(), func() {}
+//		})
+//	}
+package repotest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// Factory returns a new, empty domain.TodoRepo along with a function
+// that releases whatever it holds once a test is done with it
+type Factory func(t *testing.T) (repo domain.TodoRepo, teardown func())
+
+// Run runs every contract test against repos returned by the given Factory,
+// each as a subtest with a fresh repo
+func Run(t *testing.T, factory Factory) {
+	for _, contract := range contracts {
+		c := contract
+		t.Run(c.name, func(t *testing.T) {
+			repo, teardown := factory(t)
+			defer teardown()
+			c.test(t, repo)
+		})
+	}
+}
+
+type contract struct {
+	name string
+	test func(t *testing.T, repo domain.TodoRepo)
+}
+
+var contracts = []contract{
+	{"CreateReturnsPersisted", testCreateReturnsPersisted},
+	{"IdsAreMonotonic", testIdsAreMonotonic},
+	{"GetPresent", testGetPresent},
+	{"GetAbsent", testGetAbsent},
+	{"ListEmpty", testListEmpty},
+	{"ListOrderedById", testListOrderedById},
+	{"UpdatePresent", testUpdatePresent},
+	{"UpdateAbsent", testUpdateAbsent},
+	{"DeletePresent", testDeletePresent},
+	{"DeleteAbsent", testDeleteAbsent},
+	{"ConcurrentCreates", testConcurrentCreates},
+	{"CompletionRoundTrips", testCompletionRoundTrips},
+	{"ListFilteredByCompletion", testListFilteredByCompletion},
+	{"ListPaginated", testListPaginated},
+	{"ListPaginatedExactMultiple", testListPaginatedExactMultiple},
+	{"ListPaginatedWithFilter", testListPaginatedWithFilter},
+	{"ListAfterDeletedCursor", testListAfterDeletedCursor},
+	{"VersionsIncrease", testVersionsIncrease},
+	{"UpdateWithStaleVersion", testUpdateWithStaleVersion},
+	{"UpdateWithAnyVersion", testUpdateWithAnyVersion},
+	{"DeleteWithStaleVersion", testDeleteWithStaleVersion},
+	{"DeleteWithCurrentVersion", testDeleteWithCurrentVersion},
+	{"DueDateRoundTrips", testDueDateRoundTrips},
+	{"ListFilteredByDueDate", testListFilteredByDueDate},
+	{"ListFilteredByOverdue", testListFilteredByOverdue},
+	{"TagsRoundTrip", testTagsRoundTrip},
+	{"ListFilteredByAnyTag", testListFilteredByAnyTag},
+	{"ListFilteredByAllTags", testListFilteredByAllTags},
+	{"ListTagsEmpty", testListTagsEmpty},
+	{"ListTagsCounted", testListTagsCounted},
+	{"PriorityRoundTrips", testPriorityRoundTrips},
+	{"ListSortedByIdDescending", testListSortedByIdDescending},
+	{"ListSortedByPriority", testListSortedByPriority},
+	{"ListSortedByDueDate", testListSortedByDueDate},
+	{"ListSortedPaginated", testListSortedPaginated},
+	{"SearchEmpty", testSearchEmpty},
+	{"SearchMatchesEveryToken", testSearchMatchesEveryToken},
+	{"SearchIgnoresCase", testSearchIgnoresCase},
+	{"SearchByPrefix", testSearchByPrefix},
+	{"SearchRanked", testSearchRanked},
+	{"SearchLimited", testSearchLimited},
+	{"SearchFollowsChanges", testSearchFollowsChanges},
+	{"ParentRoundTrips", testParentRoundTrips},
+	{"ListFilteredByParent", testListFilteredByParent},
+	{"ListTopLevel", testListTopLevel},
+	{"DependenciesRoundTrip", testDependenciesRoundTrip},
+	{"BlockedByOpenDependencies", testBlockedByOpenDependencies},
+	{"ListFilteredByDependencyOf", testListFilteredByDependencyOf},
+	{"RecurrenceRoundTrips", testRecurrenceRoundTrips},
+	{"ListIdRoundTrips", testListIdRoundTrips},
+	{"ListFilteredByListId", testListFilteredByListId},
+	{"CreatedAtTheEnd", testCreatedAtTheEnd},
+	{"PositionRoundTrips", testPositionRoundTrips},
+	{"ListSortedByPositionPaginated", testListSortedByPositionPaginated},
+	{"TxCommits", testTxCommits},
+	{"TxRollsBack", testTxRollsBack},
+	{"TxRollsBackLists", testTxRollsBackLists},
+	{"TxNested", testTxNested},
+	{"DeleteMovesToTrash", testDeleteMovesToTrash},
+	{"TrashNotListed", testTrashNotListed},
+	{"TrashMostRecentFirst", testTrashMostRecentFirst},
+	{"RestorePresent", testRestorePresent},
+	{"RestoreAbsent", testRestoreAbsent},
+	{"PurgePresent", testPurgePresent},
+	{"PurgeAbsent", testPurgeAbsent},
+	{"PurgeDeletedBefore", testPurgeDeletedBefore},
+	{"TxRollsBackTrash", testTxRollsBackTrash},
+	{"HistoryRecordsChanges", testHistoryRecordsChanges},
+	{"HistoryRecordsActors", testHistoryRecordsActors},
+	{"HistoryAbsent", testHistoryAbsent},
+	{"HistoryOutlivesPurge", testHistoryOutlivesPurge},
+	{"TxRollsBackHistory", testTxRollsBackHistory},
+}
+
+// mustCreate creates a Todo for the given task, failing the test right away
+// if that does not work
+func mustCreate(t *testing.T, repo domain.TodoRepo, task string) domain.Todo {
+	created, err := repo.Create(&domain.NewTodo{Task: task})
+	if err != nil {
+		t.Fatalf("Could not create [%s]: %v", task, err)
+	}
+	return created
+}
+
+// mustList lists the Todos matching the given query, failing the test right
+// away if that does not work or if it returns more than one page
+func mustList(t *testing.T, repo domain.TodoRepo, query *domain.TodoQuery) []domain.Todo {
+	page, err := repo.List(query)
+	if err != nil {
+		t.Fatalf("Could not list: %v", err)
+	}
+	assert.Nil(t, page.Next)
+	return page.Todos
+}
+
+// listAllPages follows Next cursors until the last page, returning each page
+func listAllPages(t *testing.T, repo domain.TodoRepo, query domain.TodoQuery) [][]domain.Todo {
+	var pages [][]domain.Todo
+	for {
+		page, err := repo.List(&query)
+		if err != nil {
+			t.Fatalf("Could not list: %v", err)
+		}
+		pages = append(pages, page.Todos)
+		if page.Next == nil {
+			return pages
+		}
+		if len(pages) > 100 {
+			t.Fatal("Too many pages, cursors are probably not advancing")
+		}
+		query.After = page.Next
+	}
+}
+
+// mustSearch returns the Todos found by the given search, most relevant first
+func mustSearch(t *testing.T, repo domain.TodoRepo, search *domain.TodoSearch) []domain.Todo {
+	results, err := repo.Search(search)
+	if err != nil {
+		t.Fatalf("Could not search: %v", err)
+	}
+	todos := make([]domain.Todo, 0, len(results))
+	for _, result := range results {
+		todos = append(todos, result.Todo)
+	}
+	return todos
+}
+
+func testCreateReturnsPersisted(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	assert.Equal(t, "clean up after yourself", created.Task)
+	assert.NotEqual(t, domain.TodoID(0), created.ID)
+}
+
+func testIdsAreMonotonic(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, fake.Sentence())
+	second := mustCreate(t, repo, fake.Sentence())
+	assert.True(t, second.ID > first.ID)
+	// ids of deleted todos are never handed out again
+	_, _ = repo.Delete(&second.ID, 0)
+	third := mustCreate(t, repo, fake.Sentence())
+	assert.True(t, third.ID > second.ID)
+}
+
+func testGetPresent(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	retrieved, err := repo.Get(&created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, created, retrieved)
+}
+
+func testGetAbsent(t *testing.T, repo domain.TodoRepo) {
+	id := domain.TodoID(999999)
+	_, err := repo.Get(&id)
+	assert.Equal(t, domain.TodoNotFound{ID: id}, err)
+}
+
+func testListEmpty(t *testing.T, repo domain.TodoRepo) {
+	assert.Empty(t, mustList(t, repo, &domain.TodoQuery{}))
+}
+
+func testListOrderedById(t *testing.T, repo domain.TodoRepo) {
+	toMake := 10
+	var createds []domain.Todo
+	for i := 0; i < toMake; i++ {
+		createds = append(createds, mustCreate(t, repo, fake.Sentence()))
+	}
+	// leave a gap to make sure ordering does not depend on ids being dense
+	_, _ = repo.Delete(&createds[3].ID, 0)
+	createds = append(createds[:3], createds[4:]...)
+
+	listed := mustList(t, repo, &domain.TodoQuery{})
+	assert.Equal(t, createds, listed, fmt.Sprintf("createds: [%v], listed: [%v]", createds, listed))
+}
+
+func testUpdatePresent(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	update := created
+	update.Task = "do the dishes"
+	updated, err := repo.Update(&update)
+	assert.Nil(t, err)
+	assert.Equal(t, "do the dishes", updated.Task)
+	assert.True(t, updated.Version > created.Version)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testUpdateAbsent(t *testing.T, repo domain.TodoRepo) {
+	update := domain.Todo{
+		ID:   domain.TodoID(1235135151),
+		Task: "something something",
+	}
+	_, err := repo.Update(&update)
+	assert.Equal(t, domain.TodoNotFound{ID: update.ID}, err)
+	assert.Empty(t, mustList(t, repo, &domain.TodoQuery{}))
+}
+
+func testDeletePresent(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	deleted, err := repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	_, getErr := repo.Get(&created.ID)
+	assert.Equal(t, domain.TodoNotFound{ID: created.ID}, getErr)
+}
+
+func testDeleteAbsent(t *testing.T, repo domain.TodoRepo) {
+	id := domain.TodoID(99999999)
+	deleted, err := repo.Delete(&id, 0)
+	assert.False(t, deleted)
+	assert.Equal(t, domain.TodoNotFound{ID: id}, err)
+}
+
+func testConcurrentCreates(t *testing.T, repo domain.TodoRepo) {
+	workers, perWorker := 8, 25
+	results := make(chan domain.Todo, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if created, err := repo.Create(&domain.NewTodo{Task: fmt.Sprintf("%d-%d", w, i)}); err == nil {
+					results <- created
+				} else {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[domain.TodoID]bool)
+	for created := range results {
+		assert.False(t, seen[created.ID], fmt.Sprintf("id handed out twice: [%v]", created.ID))
+		seen[created.ID] = true
+	}
+	assert.Equal(t, workers*perWorker, len(mustList(t, repo, &domain.TodoQuery{})))
+}
+
+func testCompletionRoundTrips(t *testing.T, repo domain.TodoRepo) {
+	completedAt := time.Date(2019, 8, 20, 13, 14, 15, 16, time.UTC)
+	created, err := repo.Create(&domain.NewTodo{Task: "already done", Completed: true, CompletedAt: &completedAt})
+	assert.Nil(t, err)
+	assert.True(t, created.Completed)
+	assert.Equal(t, &completedAt, created.CompletedAt)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, created, retrieved)
+
+	created.Completed = false
+	created.CompletedAt = nil
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.False(t, updated.Completed)
+	assert.Nil(t, updated.CompletedAt)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testListFilteredByCompletion(t *testing.T, repo domain.TodoRepo) {
+	completedAt := time.Date(2019, 8, 20, 13, 14, 15, 16, time.UTC)
+	open := mustCreate(t, repo, "not yet")
+	done, _ := repo.Create(&domain.NewTodo{Task: "already done", Completed: true, CompletedAt: &completedAt})
+
+	yes, no := true, false
+	assert.Equal(t, []domain.Todo{done}, mustList(t, repo, &domain.TodoQuery{Completed: &yes}))
+	assert.Equal(t, []domain.Todo{open}, mustList(t, repo, &domain.TodoQuery{Completed: &no}))
+}
+
+func testListPaginated(t *testing.T, repo domain.TodoRepo) {
+	var createds []domain.Todo
+	for i := 0; i < 7; i++ {
+		createds = append(createds, mustCreate(t, repo, fake.Sentence()))
+	}
+	pages := listAllPages(t, repo, domain.TodoQuery{Limit: 3})
+	assert.Equal(t, [][]domain.Todo{createds[0:3], createds[3:6], createds[6:7]}, pages)
+}
+
+func testListPaginatedExactMultiple(t *testing.T, repo domain.TodoRepo) {
+	var createds []domain.Todo
+	for i := 0; i < 6; i++ {
+		createds = append(createds, mustCreate(t, repo, fake.Sentence()))
+	}
+	// the second page is full, but there is nothing after it
+	pages := listAllPages(t, repo, domain.TodoQuery{Limit: 3})
+	assert.Equal(t, [][]domain.Todo{createds[0:3], createds[3:6]}, pages)
+}
+
+func testListPaginatedWithFilter(t *testing.T, repo domain.TodoRepo) {
+	completedAt := time.Date(2019, 8, 20, 13, 14, 15, 16, time.UTC)
+	var dones []domain.Todo
+	for i := 0; i < 5; i++ {
+		mustCreate(t, repo, fake.Sentence())
+		done, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Completed: true, CompletedAt: &completedAt})
+		dones = append(dones, done)
+	}
+	yes := true
+	pages := listAllPages(t, repo, domain.TodoQuery{Completed: &yes, Limit: 2})
+	assert.Equal(t, [][]domain.Todo{dones[0:2], dones[2:4], dones[4:5]}, pages)
+}
+
+func testListAfterDeletedCursor(t *testing.T, repo domain.TodoRepo) {
+	var createds []domain.Todo
+	for i := 0; i < 4; i++ {
+		createds = append(createds, mustCreate(t, repo, fake.Sentence()))
+	}
+	page, _ := repo.List(&domain.TodoQuery{Limit: 2})
+	// the todo the cursor points at going away should not matter
+	_, _ = repo.Delete(&createds[1].ID, 0)
+	next, err := repo.List(&domain.TodoQuery{Limit: 2, After: page.Next})
+	assert.Nil(t, err)
+	assert.Equal(t, createds[2:4], next.Todos)
+}
+
+func testVersionsIncrease(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	assert.Equal(t, domain.TodoVersion(1), created.Version)
+	first, err := repo.Update(&created)
+	assert.Nil(t, err)
+	second, err := repo.Update(&first)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.TodoVersion(2), first.Version)
+	assert.Equal(t, domain.TodoVersion(3), second.Version)
+}
+
+func testUpdateWithStaleVersion(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	first := created
+	first.Task = "first writer"
+	updated, _ := repo.Update(&first)
+
+	second := created
+	second.Task = "second writer"
+	_, err := repo.Update(&second)
+	assert.Equal(t, domain.TodoVersionConflict{ID: created.ID, Expected: created.Version, Actual: updated.Version}, err)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testUpdateWithAnyVersion(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	_, _ = repo.Update(&created)
+	update := created
+	update.Version = 0
+	update.Task = "last write wins"
+	updated, err := repo.Update(&update)
+	assert.Nil(t, err)
+	assert.Equal(t, created.Version+2, updated.Version)
+}
+
+func testDeleteWithStaleVersion(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	updated, _ := repo.Update(&created)
+	deleted, err := repo.Delete(&created.ID, created.Version)
+	assert.False(t, deleted)
+	assert.Equal(t, domain.TodoVersionConflict{ID: created.ID, Expected: created.Version, Actual: updated.Version}, err)
+	_, getErr := repo.Get(&created.ID)
+	assert.Nil(t, getErr)
+}
+
+func testDeleteWithCurrentVersion(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "clean up after yourself")
+	deleted, err := repo.Delete(&created.ID, created.Version)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+}
+
+func testDueDateRoundTrips(t *testing.T, repo domain.TodoRepo) {
+	dueAt := time.Date(2019, 8, 20, 13, 14, 15, 16, time.UTC)
+	created, err := repo.Create(&domain.NewTodo{Task: "by tuesday", DueAt: &dueAt})
+	assert.Nil(t, err)
+	assert.Equal(t, &dueAt, created.DueAt)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, created, retrieved)
+
+	created.DueAt = nil
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Nil(t, updated.DueAt)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testListFilteredByDueDate(t *testing.T, repo domain.TodoRepo) {
+	monday := time.Date(2019, 8, 19, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	wednesday := monday.AddDate(0, 0, 2)
+	mustCreate(t, repo, "whenever")
+	dueMonday, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &monday})
+	dueTuesday, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &tuesday})
+	dueWednesday, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &wednesday})
+
+	assert.Equal(t, []domain.Todo{dueMonday}, mustList(t, repo, &domain.TodoQuery{DueBefore: &tuesday}))
+	assert.Equal(t, []domain.Todo{dueTuesday, dueWednesday}, mustList(t, repo, &domain.TodoQuery{DueAfter: &tuesday}))
+	// due dates are kept in [DueAfter, DueBefore)
+	assert.Equal(t, []domain.Todo{dueTuesday}, mustList(t, repo, &domain.TodoQuery{DueAfter: &tuesday, DueBefore: &wednesday}))
+}
+
+func testListFilteredByOverdue(t *testing.T, repo domain.TodoRepo) {
+	now := time.Date(2019, 8, 20, 13, 14, 15, 16, time.UTC)
+	yesterday := now.AddDate(0, 0, -1)
+	tomorrow := now.AddDate(0, 0, 1)
+	whenever := mustCreate(t, repo, "whenever")
+	late, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &yesterday})
+	lateButDone, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &yesterday, Completed: true, CompletedAt: &now})
+	onTime, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &tomorrow})
+
+	yes, no := true, false
+	assert.Equal(t, []domain.Todo{late}, mustList(t, repo, &domain.TodoQuery{Overdue: &yes, OverdueAsOf: now}))
+	assert.Equal(t, []domain.Todo{whenever, lateButDone, onTime}, mustList(t, repo, &domain.TodoQuery{Overdue: &no, OverdueAsOf: now}))
+	// it will all be late eventually
+	assert.Equal(t, []domain.Todo{late, onTime}, mustList(t, repo, &domain.TodoQuery{Overdue: &yes, OverdueAsOf: tomorrow.Add(time.Second)}))
+}
+
+func testTagsRoundTrip(t *testing.T, repo domain.TodoRepo) {
+	created, err := repo.Create(&domain.NewTodo{Task: "tagged", Tags: []string{"work", "home", "work"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"home", "work"}, created.Tags)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, created, retrieved)
+
+	created.Tags = []string{"errands", "home"}
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"errands", "home"}, updated.Tags)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+
+	updated.Tags = []string{}
+	untagged, err := repo.Update(&updated)
+	assert.Nil(t, err)
+	assert.Nil(t, untagged.Tags)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, untagged, retrieved)
+}
+
+func testListFilteredByAnyTag(t *testing.T, repo domain.TodoRepo) {
+	mustCreate(t, repo, "untagged")
+	work, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"work"}})
+	home, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"home"}})
+	both, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"home", "work"}})
+	repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"errands"}})
+
+	assert.Equal(t, []domain.Todo{work, both}, mustList(t, repo, &domain.TodoQuery{Tags: []string{"work"}}))
+	assert.Equal(t, []domain.Todo{work, home, both}, mustList(t, repo, &domain.TodoQuery{Tags: []string{"work", "home"}}))
+	assert.Equal(t, []domain.Todo{}, mustList(t, repo, &domain.TodoQuery{Tags: []string{"nope"}}))
+}
+
+func testListFilteredByAllTags(t *testing.T, repo domain.TodoRepo) {
+	mustCreate(t, repo, "untagged")
+	work, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"work"}})
+	repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"home"}})
+	both, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"home", "work"}})
+	all, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"errands", "home", "work"}})
+
+	assert.Equal(t, []domain.Todo{work, both, all}, mustList(t, repo, &domain.TodoQuery{Tags: []string{"work"}, AllTags: true}))
+	assert.Equal(t, []domain.Todo{both, all}, mustList(t, repo, &domain.TodoQuery{Tags: []string{"work", "home", "work"}, AllTags: true}))
+	assert.Equal(t, []domain.Todo{}, mustList(t, repo, &domain.TodoQuery{Tags: []string{"work", "nope"}, AllTags: true}))
+}
+
+func testListTagsEmpty(t *testing.T, repo domain.TodoRepo) {
+	mustCreate(t, repo, "untagged")
+	tagCounts, err := repo.ListTags()
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TagCount{}, tagCounts)
+}
+
+func testListTagsCounted(t *testing.T, repo domain.TodoRepo) {
+	work, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"work"}})
+	repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"home", "work"}})
+	errands, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Tags: []string{"errands"}})
+	tagCounts, err := repo.ListTags()
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TagCount{{Tag: "errands", Count: 1}, {Tag: "home", Count: 1}, {Tag: "work", Count: 2}}, tagCounts)
+
+	// counts follow updates and deletes
+	work.Tags = []string{"home"}
+	_, _ = repo.Update(&work)
+	_, _ = repo.Delete(&errands.ID, 0)
+	tagCounts, err = repo.ListTags()
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TagCount{{Tag: "home", Count: 2}, {Tag: "work", Count: 1}}, tagCounts)
+}
+
+func testPriorityRoundTrips(t *testing.T, repo domain.TodoRepo) {
+	created, err := repo.Create(&domain.NewTodo{Task: "asap", Priority: domain.UrgentPriority})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.UrgentPriority, created.Priority)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, created, retrieved)
+
+	created.Priority = domain.LowPriority
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.LowPriority, updated.Priority)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testListSortedByIdDescending(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, fake.Sentence())
+	second := mustCreate(t, repo, fake.Sentence())
+	third := mustCreate(t, repo, fake.Sentence())
+	query := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByID, Descending: true}}}
+	assert.Equal(t, []domain.Todo{third, second, first}, mustList(t, repo, &query))
+}
+
+func testListSortedByPriority(t *testing.T, repo domain.TodoRepo) {
+	normal := mustCreate(t, repo, fake.Sentence())
+	urgent, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Priority: domain.UrgentPriority})
+	low, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Priority: domain.LowPriority})
+	alsoUrgent, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Priority: domain.UrgentPriority})
+
+	ascending := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPriority}}}
+	assert.Equal(t, []domain.Todo{low, normal, urgent, alsoUrgent}, mustList(t, repo, &ascending))
+	// ties are broken by ascending ids unless told otherwise
+	descending := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPriority, Descending: true}}}
+	assert.Equal(t, []domain.Todo{urgent, alsoUrgent, normal, low}, mustList(t, repo, &descending))
+	newestFirst := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPriority, Descending: true}, {Field: domain.SortByID, Descending: true}}}
+	assert.Equal(t, []domain.Todo{alsoUrgent, urgent, normal, low}, mustList(t, repo, &newestFirst))
+}
+
+func testListSortedByDueDate(t *testing.T, repo domain.TodoRepo) {
+	monday := time.Date(2019, 8, 19, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	whenever := mustCreate(t, repo, "whenever")
+	dueTuesday, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &tuesday})
+	dueMonday, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), DueAt: &monday})
+
+	// no due date comes after every due date
+	ascending := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByDueAt}}}
+	assert.Equal(t, []domain.Todo{dueMonday, dueTuesday, whenever}, mustList(t, repo, &ascending))
+	descending := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByDueAt, Descending: true}}}
+	assert.Equal(t, []domain.Todo{whenever, dueTuesday, dueMonday}, mustList(t, repo, &descending))
+}
+
+func testListSortedPaginated(t *testing.T, repo domain.TodoRepo) {
+	monday := time.Date(2019, 8, 19, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	priorities := []domain.Priority{domain.HighPriority, domain.NormalPriority, domain.LowPriority}
+	dueAts := []*time.Time{&tuesday, nil, &monday}
+	var createds []domain.Todo
+	for i := 0; i < 9; i++ {
+		created, _ := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Priority: priorities[i%3], DueAt: dueAts[(i/3)%3]})
+		createds = append(createds, created)
+	}
+	query := domain.TodoQuery{
+		Sort:  []domain.SortKey{{Field: domain.SortByPriority, Descending: true}, {Field: domain.SortByDueAt}},
+		Limit: 2,
+	}
+	var listed []domain.Todo
+	for _, page := range listAllPages(t, repo, query) {
+		assert.True(t, len(page) <= 2)
+		listed = append(listed, page...)
+	}
+	expected := []domain.Todo{
+		createds[6], createds[0], createds[3], // high: monday, tuesday, whenever
+		createds[7], createds[1], createds[4], // normal
+		createds[8], createds[2], createds[5], // low
+	}
+	assert.Equal(t, expected, listed)
+}
+
+func testSearchEmpty(t *testing.T, repo domain.TodoRepo) {
+	assert.Empty(t, mustSearch(t, repo, &domain.TodoSearch{Text: "anything"}))
+}
+
+func testSearchMatchesEveryToken(t *testing.T, repo domain.TodoRepo) {
+	buyMilk := mustCreate(t, repo, "Buy milk")
+	mustCreate(t, repo, "Buy eggs")
+	mustCreate(t, repo, "Sell milk")
+	assert.Equal(t, []domain.Todo{buyMilk}, mustSearch(t, repo, &domain.TodoSearch{Text: "milk, buy!"}))
+	assert.Empty(t, mustSearch(t, repo, &domain.TodoSearch{Text: "buy bread"}))
+}
+
+func testSearchIgnoresCase(t *testing.T, repo domain.TodoRepo) {
+	shout := mustCreate(t, repo, "CALL ΟΔΟΣ Straße")
+	assert.Equal(t, []domain.Todo{shout}, mustSearch(t, repo, &domain.TodoSearch{Text: "Call οδος STRAßE"}))
+}
+
+func testSearchByPrefix(t *testing.T, repo domain.TodoRepo) {
+	paint := mustCreate(t, repo, "Paint the fence")
+	painting := mustCreate(t, repo, "Hang the painting")
+	mustCreate(t, repo, "Plant a tree")
+	found := mustSearch(t, repo, &domain.TodoSearch{Text: "pain"})
+	assert.ElementsMatch(t, []domain.Todo{paint, painting}, found)
+	assert.Empty(t, mustSearch(t, repo, &domain.TodoSearch{Text: "paintings"}))
+}
+
+func testSearchRanked(t *testing.T, repo domain.TodoRepo) {
+	long := mustCreate(t, repo, "Call the plumber about the leak in the kitchen")
+	short := mustCreate(t, repo, "Call mum")
+	// shorter tasks are more about their terms
+	assert.Equal(t, []domain.Todo{short, long}, mustSearch(t, repo, &domain.TodoSearch{Text: "call"}))
+
+	paintingFence := mustCreate(t, repo, "Painting fence")
+	paintFence := mustCreate(t, repo, "Paint fence")
+	// exact matches count for more than prefixes
+	assert.Equal(t, []domain.Todo{paintFence, paintingFence}, mustSearch(t, repo, &domain.TodoSearch{Text: "paint"}))
+
+	washCar := mustCreate(t, repo, "Wash car")
+	washCat := mustCreate(t, repo, "Wash cat")
+	mustCreate(t, repo, "Car keys")
+	mustCreate(t, repo, "Car insurance")
+	// rarer terms count for more
+	assert.Equal(t, []domain.Todo{washCat, washCar}, mustSearch(t, repo, &domain.TodoSearch{Text: "wash ca"}))
+}
+
+func testSearchLimited(t *testing.T, repo domain.TodoRepo) {
+	for i := 0; i < 3; i++ {
+		mustCreate(t, repo, "Buy milk")
+	}
+	assert.Len(t, mustSearch(t, repo, &domain.TodoSearch{Text: "milk", Limit: 2}), 2)
+}
+
+func testSearchFollowsChanges(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "Walk the dog")
+	assert.Equal(t, []domain.Todo{created}, mustSearch(t, repo, &domain.TodoSearch{Text: "dog"}))
+
+	created.Task = "Walk the cat"
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Empty(t, mustSearch(t, repo, &domain.TodoSearch{Text: "dog"}))
+	assert.Equal(t, []domain.Todo{updated}, mustSearch(t, repo, &domain.TodoSearch{Text: "cat"}))
+
+	_, err = repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, mustSearch(t, repo, &domain.TodoSearch{Text: "walk"}))
+}
+
+func testParentRoundTrips(t *testing.T, repo domain.TodoRepo) {
+	parent := mustCreate(t, repo, "Move house")
+	child, err := repo.Create(&domain.NewTodo{Task: "Pack books", ParentID: &parent.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, &parent.ID, child.ParentID)
+	retrieved, _ := repo.Get(&child.ID)
+	assert.Equal(t, child, retrieved)
+
+	child.ParentID = nil
+	updated, err := repo.Update(&child)
+	assert.Nil(t, err)
+	assert.Nil(t, updated.ParentID)
+	retrieved, _ = repo.Get(&child.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testListFilteredByParent(t *testing.T, repo domain.TodoRepo) {
+	parent := mustCreate(t, repo, "Move house")
+	other := mustCreate(t, repo, "Plan party")
+	first, _ := repo.Create(&domain.NewTodo{Task: "Pack books", ParentID: &parent.ID})
+	repo.Create(&domain.NewTodo{Task: "Send invites", ParentID: &other.ID})
+	second, _ := repo.Create(&domain.NewTodo{Task: "Hire van", ParentID: &parent.ID})
+	repo.Create(&domain.NewTodo{Task: "Pack fragile books", ParentID: &first.ID})
+	assert.Equal(t, []domain.Todo{first, second}, mustList(t, repo, &domain.TodoQuery{ParentID: &parent.ID}))
+	assert.Empty(t, mustList(t, repo, &domain.TodoQuery{ParentID: &second.ID}))
+}
+
+func testListTopLevel(t *testing.T, repo domain.TodoRepo) {
+	parent := mustCreate(t, repo, "Move house")
+	repo.Create(&domain.NewTodo{Task: "Pack books", ParentID: &parent.ID})
+	other := mustCreate(t, repo, "Plan party")
+	assert.Equal(t, []domain.Todo{parent, other}, mustList(t, repo, &domain.TodoQuery{TopLevel: true}))
+}
+
+func testDependenciesRoundTrip(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, "Buy paint")
+	second := mustCreate(t, repo, "Buy brushes")
+	dependent, err := repo.Create(&domain.NewTodo{
+		Task:      "Paint fence",
+		DependsOn: []domain.TodoID{second.ID, first.ID, second.ID},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{first.ID, second.ID}, dependent.DependsOn)
+	retrieved, _ := repo.Get(&dependent.ID)
+	assert.Equal(t, dependent, retrieved)
+
+	dependent.DependsOn = []domain.TodoID{second.ID}
+	updated, err := repo.Update(&dependent)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{second.ID}, updated.DependsOn)
+	retrieved, _ = repo.Get(&dependent.ID)
+	assert.Equal(t, updated, retrieved)
+
+	updated.DependsOn = nil
+	updated, _ = repo.Update(&updated)
+	assert.Nil(t, updated.DependsOn)
+	retrieved, _ = repo.Get(&dependent.ID)
+	assert.Nil(t, retrieved.DependsOn)
+}
+
+func testBlockedByOpenDependencies(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, "Buy paint")
+	second, _ := repo.Create(&domain.NewTodo{Task: "Buy brushes", Completed: true})
+	dependent, _ := repo.Create(&domain.NewTodo{Task: "Paint fence", DependsOn: []domain.TodoID{first.ID, second.ID}})
+	assert.True(t, dependent.Blocked)
+	free := mustCreate(t, repo, "Mow lawn")
+	assert.False(t, free.Blocked)
+
+	first.Completed = true
+	repo.Update(&first)
+	retrieved, _ := repo.Get(&dependent.ID)
+	assert.False(t, retrieved.Blocked)
+	listed := mustList(t, repo, &domain.TodoQuery{})
+	assert.Equal(t, retrieved, listed[2])
+
+	// Todos that no longer exist don't block anything
+	second.Completed = false
+	second, _ = repo.Update(&second)
+	retrieved, _ = repo.Get(&dependent.ID)
+	assert.True(t, retrieved.Blocked)
+
+	// Todos that no longer exist don't block anything
+	repo.Delete(&second.ID, second.Version)
+	retrieved, _ = repo.Get(&dependent.ID)
+	assert.False(t, retrieved.Blocked)
+}
+
+func testListFilteredByDependencyOf(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, "Buy paint")
+	second := mustCreate(t, repo, "Buy brushes")
+	both, _ := repo.Create(&domain.NewTodo{Task: "Paint fence", DependsOn: []domain.TodoID{first.ID, second.ID}})
+	repo.Create(&domain.NewTodo{Task: "Clean brushes", DependsOn: []domain.TodoID{second.ID}})
+	mustCreate(t, repo, "Mow lawn")
+	assert.Equal(t, []domain.Todo{both}, mustList(t, repo, &domain.TodoQuery{DependencyOf: &first.ID}))
+	assert.Len(t, mustList(t, repo, &domain.TodoQuery{DependencyOf: &second.ID}), 2)
+	assert.Empty(t, mustList(t, repo, &domain.TodoQuery{DependencyOf: &both.ID}))
+}
+
+func testRecurrenceRoundTrips(t *testing.T, repo domain.TodoRepo) {
+	created, err := repo.Create(&domain.NewTodo{Task: "Water plants", Recurrence: "FREQ=WEEKLY;BYDAY=SA"})
+	assert.Nil(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=SA", created.Recurrence)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, created, retrieved)
+
+	created.Recurrence = ""
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Empty(t, updated.Recurrence)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testListIdRoundTrips(t *testing.T, repo domain.TodoRepo) {
+	listID := domain.TodoListID(3)
+	created, err := repo.Create(&domain.NewTodo{Task: "Mow the lawn", ListID: &listID})
+	assert.Nil(t, err)
+	assert.Equal(t, &listID, created.ListID)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, created, retrieved)
+
+	created.ListID = nil
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Nil(t, updated.ListID)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+}
+
+func testListFilteredByListId(t *testing.T, repo domain.TodoRepo) {
+	home, work := domain.TodoListID(1), domain.TodoListID(2)
+	first, _ := repo.Create(&domain.NewTodo{Task: "Mow the lawn", ListID: &home})
+	_, _ = repo.Create(&domain.NewTodo{Task: "File the report", ListID: &work})
+	_ = mustCreate(t, repo, "Call mum")
+	third, _ := repo.Create(&domain.NewTodo{Task: "Fix the gate", ListID: &home})
+
+	assert.Equal(t, []domain.Todo{first, third}, mustList(t, repo, &domain.TodoQuery{ListID: &home}))
+	absent := domain.TodoListID(42)
+	assert.Empty(t, mustList(t, repo, &domain.TodoQuery{ListID: &absent}))
+}
+
+func testCreatedAtTheEnd(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, fake.Sentence())
+	second := mustCreate(t, repo, fake.Sentence())
+	third := mustCreate(t, repo, fake.Sentence())
+	assert.True(t, first.Position < second.Position && second.Position < third.Position)
+	// even when the last Todo has been moved towards the front
+	before, _ := domain.PositionBetween("", first.Position)
+	third.Position = before
+	third, _ = repo.Update(&third)
+	fourth := mustCreate(t, repo, fake.Sentence())
+	byPosition := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPosition}}}
+	assert.Equal(t, []domain.Todo{third, first, second, fourth}, mustList(t, repo, &byPosition))
+}
+
+func testPositionRoundTrips(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, fake.Sentence())
+	second := mustCreate(t, repo, fake.Sentence())
+	between, _ := domain.PositionBetween(first.Position, second.Position)
+	created, err := repo.Create(&domain.NewTodo{Task: fake.Sentence(), Position: between})
+	assert.Nil(t, err)
+	assert.Equal(t, between, created.Position)
+	retrieved, _ := repo.Get(&created.ID)
+	assert.Equal(t, created, retrieved)
+
+	// no Position keeps the current one
+	created.Position = ""
+	created.Task = "changed"
+	updated, err := repo.Update(&created)
+	assert.Nil(t, err)
+	assert.Equal(t, between, updated.Position)
+	retrieved, _ = repo.Get(&created.ID)
+	assert.Equal(t, updated, retrieved)
+
+	byPosition := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPosition}}}
+	assert.Equal(t, []domain.Todo{first, updated, second}, mustList(t, repo, &byPosition))
+}
+
+func testListSortedByPositionPaginated(t *testing.T, repo domain.TodoRepo) {
+	var createds []domain.Todo
+	for i := 0; i < 7; i++ {
+		createds = append(createds, mustCreate(t, repo, fake.Sentence()))
+	}
+	// move the last one to the front
+	last := createds[len(createds)-1]
+	last.Position, _ = domain.PositionBetween("", createds[0].Position)
+	moved, _ := repo.Update(&last)
+	expected := append([]domain.Todo{moved}, createds[:len(createds)-1]...)
+
+	query := domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPosition}}, Limit: 3}
+	pages := listAllPages(t, repo, query)
+	assert.Len(t, pages, 3)
+	var listed []domain.Todo
+	for _, page := range pages {
+		listed = append(listed, page...)
+	}
+	assert.Equal(t, expected, listed)
+
+	query.Sort[0].Descending = true
+	pages = listAllPages(t, repo, query)
+	listed = nil
+	for _, page := range pages {
+		listed = append(listed, page...)
+	}
+	assert.Equal(t, expected[len(expected)-1], listed[0])
+	assert.Equal(t, moved, listed[len(listed)-1])
+}
+
+func testTxCommits(t *testing.T, repo domain.TodoRepo) {
+	kept := mustCreate(t, repo, "Water the plants")
+	gone := mustCreate(t, repo, "Feed the cat")
+	var created domain.Todo
+	err := repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		var err domain.TodoRepoError
+		if created, err = todos.Create(&domain.NewTodo{Task: "Walk the dog"}); err != nil {
+			return err
+		}
+		// changes are seen within the transaction
+		retrieved, err := todos.Get(&created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, created, retrieved)
+		kept.Task = "Water the plants twice"
+		if kept, err = todos.Update(&kept); err != nil {
+			return err
+		}
+		_, err = todos.Delete(&gone.ID, 0)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.Todo{kept, created}, mustList(t, repo, &domain.TodoQuery{}))
+	assert.Equal(t, []domain.Todo{created}, mustSearch(t, repo, &domain.TodoSearch{Text: "dog", Limit: 10}))
+}
+
+func testTxRollsBack(t *testing.T, repo domain.TodoRepo) {
+	kept := mustCreate(t, repo, "Water the plants")
+	deleted := mustCreate(t, repo, "Feed the cat")
+	failure := fmt.Errorf("changed my mind")
+	err := repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		if _, err := todos.Create(&domain.NewTodo{Task: "Walk the dog"}); err != nil {
+			return err
+		}
+		changed := kept
+		changed.Task = "Water the plants twice"
+		if _, err := todos.Update(&changed); err != nil {
+			return err
+		}
+		if _, err := todos.Delete(&deleted.ID, 0); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	assert.Equal(t, []domain.Todo{kept, deleted}, mustList(t, repo, &domain.TodoQuery{}))
+	assert.Empty(t, mustSearch(t, repo, &domain.TodoSearch{Text: "dog", Limit: 10}))
+	assert.Equal(t, []domain.Todo{kept}, mustSearch(t, repo, &domain.TodoSearch{Text: "plants", Limit: 10}))
+	// and the repo carries on as if nothing happened
+	next := mustCreate(t, repo, "Walk the dog")
+	assert.True(t, next.ID > deleted.ID)
+	assert.True(t, next.Position > deleted.Position)
+	assert.Equal(t, []domain.Todo{next}, mustSearch(t, repo, &domain.TodoSearch{Text: "dog", Limit: 10}))
+}
+
+func testTxRollsBackLists(t *testing.T, repo domain.TodoRepo) {
+	var created domain.TodoList
+	_ = repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		var err domain.TodoListRepoError
+		created, err = lists.Create(&domain.NewTodoList{Name: "Chores"})
+		assert.Nil(t, err)
+		retrieved, err := lists.Get(&created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, created, retrieved)
+		return fmt.Errorf("changed my mind")
+	})
+	_ = repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		_, err := lists.Get(&created.ID)
+		assert.Equal(t, domain.TodoListNotFound{ID: created.ID}, err)
+		return nil
+	})
+}
+
+func testTxNested(t *testing.T, repo domain.TodoRepo) {
+	failure := fmt.Errorf("changed my mind")
+	err := repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		err := todos.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+			_, err := todos.Create(&domain.NewTodo{Task: "Walk the dog"})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// the inner function's changes are part of the outer transaction
+		assert.Len(t, mustList(t, todos, &domain.TodoQuery{}), 1)
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	assert.Empty(t, mustList(t, repo, &domain.TodoQuery{}))
+}
+
+// mustListTrash returns the Todos in the trash, failing the test right away
+// if that does not work
+func mustListTrash(t *testing.T, repo domain.TodoRepo) []domain.TrashedTodo {
+	trashed, err := repo.ListTrash()
+	if err != nil {
+		t.Fatalf("Could not list the trash: %v", err)
+	}
+	return trashed
+}
+
+func testDeleteMovesToTrash(t *testing.T, repo domain.TodoRepo) {
+	assert.Empty(t, mustListTrash(t, repo))
+	parent := mustCreate(t, repo, "Move house")
+	dependency := mustCreate(t, repo, "Find a van")
+	due := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	created, err := repo.Create(&domain.NewTodo{
+		Task:      "Pack the books",
+		DueAt:     &due,
+		Tags:      []string{"home", "boxes"},
+		Priority:  domain.HighPriority,
+		ParentID:  &parent.ID,
+		DependsOn: []domain.TodoID{dependency.ID},
+	})
+	assert.Nil(t, err)
+	before := time.Now()
+	deleted, err := repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	trashed := mustListTrash(t, repo)
+	assert.Len(t, trashed, 1)
+	// nothing in the trash is blocked, as it can't be worked on anyway
+	expected := created
+	expected.Blocked = false
+	assert.Equal(t, expected, trashed[0].Todo)
+	assert.False(t, trashed[0].DeletedAt.Before(before.Add(-time.Second)))
+	assert.False(t, trashed[0].DeletedAt.After(time.Now().Add(time.Second)))
+	// it can't be deleted twice
+	_, err = repo.Delete(&created.ID, 0)
+	assert.Equal(t, domain.TodoNotFound{ID: created.ID}, err)
+}
+
+func testTrashNotListed(t *testing.T, repo domain.TodoRepo) {
+	kept := mustCreate(t, repo, "Water the plants")
+	created, err := repo.Create(&domain.NewTodo{Task: "Water the lawn", Tags: []string{"garden"}})
+	assert.Nil(t, err)
+	_, err = repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	_, err = repo.Get(&created.ID)
+	assert.Equal(t, domain.TodoNotFound{ID: created.ID}, err)
+	assert.Equal(t, []domain.Todo{kept}, mustList(t, repo, &domain.TodoQuery{}))
+	assert.Equal(t, []domain.Todo{kept}, mustSearch(t, repo, &domain.TodoSearch{Text: "water", Limit: 10}))
+	tags, err := repo.ListTags()
+	assert.Nil(t, err)
+	assert.Empty(t, tags)
+}
+
+func testTrashMostRecentFirst(t *testing.T, repo domain.TodoRepo) {
+	first := mustCreate(t, repo, "Water the plants")
+	second := mustCreate(t, repo, "Feed the cat")
+	_, err := repo.Delete(&second.ID, 0)
+	assert.Nil(t, err)
+	_, err = repo.Delete(&first.ID, 0)
+	assert.Nil(t, err)
+	trashed := mustListTrash(t, repo)
+	assert.Len(t, trashed, 2)
+	assert.Equal(t, first, trashed[0].Todo)
+	assert.Equal(t, second, trashed[1].Todo)
+}
+
+func testRestorePresent(t *testing.T, repo domain.TodoRepo) {
+	dependency := mustCreate(t, repo, "Find a van")
+	created, err := repo.Create(&domain.NewTodo{
+		Task:      "Pack the books",
+		Tags:      []string{"home"},
+		DependsOn: []domain.TodoID{dependency.ID},
+	})
+	assert.Nil(t, err)
+	_, err = repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	restored, err := repo.Restore(&created.ID)
+	assert.Nil(t, err)
+	expected := created
+	expected.Version++
+	assert.Equal(t, expected, restored)
+	retrieved, err := repo.Get(&created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, retrieved)
+	assert.Empty(t, mustListTrash(t, repo))
+	assert.Equal(t, []domain.Todo{expected}, mustSearch(t, repo, &domain.TodoSearch{Text: "books", Limit: 10}))
+	assert.Equal(t, []domain.Todo{expected}, mustList(t, repo, &domain.TodoQuery{Tags: []string{"home"}}))
+}
+
+func testRestoreAbsent(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "Water the plants")
+	// only what is in the trash can be restored
+	_, err := repo.Restore(&created.ID)
+	assert.Equal(t, domain.TodoNotFound{ID: created.ID}, err)
+	id := domain.TodoID(99999999)
+	_, err = repo.Restore(&id)
+	assert.Equal(t, domain.TodoNotFound{ID: id}, err)
+}
+
+func testPurgePresent(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "Water the plants")
+	_, err := repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	purged, err := repo.Purge(&created.ID)
+	assert.Nil(t, err)
+	assert.True(t, purged)
+	assert.Empty(t, mustListTrash(t, repo))
+	_, err = repo.Restore(&created.ID)
+	assert.Equal(t, domain.TodoNotFound{ID: created.ID}, err)
+	// purged ids are not reused either
+	next := mustCreate(t, repo, "Feed the cat")
+	assert.True(t, next.ID > created.ID)
+}
+
+func testPurgeAbsent(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "Water the plants")
+	purged, err := repo.Purge(&created.ID)
+	assert.False(t, purged)
+	assert.Equal(t, domain.TodoNotFound{ID: created.ID}, err)
+	assert.Equal(t, []domain.Todo{created}, mustList(t, repo, &domain.TodoQuery{}))
+}
+
+func testPurgeDeletedBefore(t *testing.T, repo domain.TodoRepo) {
+	old := mustCreate(t, repo, "Water the plants")
+	_, err := repo.Delete(&old.ID, 0)
+	assert.Nil(t, err)
+	purged, err := repo.PurgeDeletedBefore(time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, purged)
+	assert.Len(t, mustListTrash(t, repo), 1)
+	cutoff := time.Now().Add(time.Second)
+	purged, err = repo.PurgeDeletedBefore(cutoff)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Empty(t, mustListTrash(t, repo))
+}
+
+func testTxRollsBackTrash(t *testing.T, repo domain.TodoRepo) {
+	deleted := mustCreate(t, repo, "Water the plants")
+	trashed := mustCreate(t, repo, "Feed the cat")
+	_, err := repo.Delete(&trashed.ID, 0)
+	assert.Nil(t, err)
+	before := mustListTrash(t, repo)
+	failure := fmt.Errorf("changed my mind")
+	txErr := repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		if _, err := todos.Delete(&deleted.ID, 0); err != nil {
+			return err
+		}
+		if _, err := todos.Restore(&trashed.ID); err != nil {
+			return err
+		}
+		assert.Len(t, mustListTrash(t, todos), 1)
+		return failure
+	})
+	assert.Equal(t, failure, txErr)
+	assert.Equal(t, []domain.Todo{deleted}, mustList(t, repo, &domain.TodoQuery{}))
+	assert.Equal(t, before, mustListTrash(t, repo))
+}
+
+// mustHistory returns the history of the Todo with the given id, failing the
+// test right away if that does not work
+func mustHistory(t *testing.T, repo domain.TodoRepo, id domain.TodoID) []domain.TodoRevision {
+	revisions, err := repo.History(&id)
+	if err != nil {
+		t.Fatalf("Could not get the history of [%v]: %v", id, err)
+	}
+	return revisions
+}
+
+func testHistoryRecordsChanges(t *testing.T, repo domain.TodoRepo) {
+	before := time.Now().Add(-time.Second)
+	created, err := repo.Create(&domain.NewTodo{Task: "Water the plants", Tags: []string{"garden"}})
+	assert.Nil(t, err)
+	changed := created
+	changed.Task = "Water the plants twice"
+	updated, err := repo.Update(&changed)
+	assert.Nil(t, err)
+	_, err = repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	restored, err := repo.Restore(&created.ID)
+	assert.Nil(t, err)
+
+	revisions := mustHistory(t, repo, created.ID)
+	if assert.Len(t, revisions, 4) {
+		expected := []struct {
+			change domain.RevisionChange
+			todo   domain.Todo
+		}{
+			{domain.CreatedRevision, created},
+			{domain.UpdatedRevision, updated},
+			{domain.DeletedRevision, updated},
+			{domain.RestoredRevision, restored},
+		}
+		for i, revision := range revisions {
+			assert.Equal(t, uint(i+1), revision.Rev)
+			assert.Equal(t, expected[i].change, revision.Change)
+			assert.Equal(t, expected[i].todo, revision.Todo)
+			assert.Empty(t, revision.Actor)
+			assert.False(t, revision.At.Before(before))
+			assert.False(t, revision.At.After(time.Now().Add(time.Second)))
+		}
+	}
+	// each Todo has a history of its own
+	other := mustCreate(t, repo, "Feed the cat")
+	assert.Len(t, mustHistory(t, repo, other.ID), 1)
+}
+
+func testHistoryRecordsActors(t *testing.T, repo domain.TodoRepo) {
+	created, err := repo.AsActor("alice").Create(&domain.NewTodo{Task: "Water the plants"})
+	assert.Nil(t, err)
+	// the repo of an actor works on the same Todos
+	retrieved, err := repo.Get(&created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, created, retrieved)
+	txErr := repo.AsActor("bob").WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		changed := created
+		changed.Task = "Water the plants twice"
+		_, err := todos.Update(&changed)
+		return err
+	})
+	assert.Nil(t, txErr)
+	_, err = repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	var actors []string
+	for _, revision := range mustHistory(t, repo, created.ID) {
+		actors = append(actors, revision.Actor)
+	}
+	assert.Equal(t, []string{"alice", "bob", ""}, actors)
+}
+
+func testHistoryAbsent(t *testing.T, repo domain.TodoRepo) {
+	id := domain.TodoID(99999999)
+	_, err := repo.History(&id)
+	assert.Equal(t, domain.TodoNotFound{ID: id}, err)
+}
+
+func testHistoryOutlivesPurge(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "Water the plants")
+	_, err := repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	_, err = repo.Purge(&created.ID)
+	assert.Nil(t, err)
+	assert.Len(t, mustHistory(t, repo, created.ID), 2)
+}
+
+func testTxRollsBackHistory(t *testing.T, repo domain.TodoRepo) {
+	created := mustCreate(t, repo, "Water the plants")
+	before := mustHistory(t, repo, created.ID)
+	failure := fmt.Errorf("changed my mind")
+	txErr := repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		changed := created
+		changed.Task = "Water the plants twice"
+		if _, err := todos.Update(&changed); err != nil {
+			return err
+		}
+		assert.Len(t, mustHistory(t, todos, created.ID), 2)
+		return failure
+	})
+	assert.Equal(t, failure, txErr)
+	assert.Equal(t, before, mustHistory(t, repo, created.ID))
+	// and the next revision takes the place of the one rolled back
+	_, err := repo.Delete(&created.ID, 0)
+	assert.Nil(t, err)
+	revisions := mustHistory(t, repo, created.ID)
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, uint(2), revisions[1].Rev)
+	}
+}
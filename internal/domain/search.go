@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TodoSearch is a full-text search over the tasks of Todos
+type TodoSearch struct {
+	// Text is split up with Tokenise; Todos match if, for every one of its
+	// tokens, their task has a term that is equal to or starts with it
+	Text string
+	// Limit caps the number of results; 0 means no limit
+	Limit uint
+}
+
+// TodoSearchResult is a Todo matching a TodoSearch, along with how
+// relevant it is; higher scores are more relevant
+type TodoSearchResult struct {
+	Todo  Todo
+	Score float64
+}
+
+// Tokenise splits text into terms, i.e. runs of letters and digits, folding
+// their case so that searches are case-insensitive. Terms are returned in
+// the order they appear in, duplicates included.
+func Tokenise(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = strings.Map(foldCase, word)
+	}
+	return words
+}
+
+// foldCase maps runes that only differ in case onto the same rune; going
+// through upper case first also catches the odd ones out like ſ and ς
+func foldCase(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+// TermCounts counts how many times each of the given terms occurs
+func TermCounts(terms []string) map[string]uint {
+	counts := make(map[string]uint, len(terms))
+	for _, term := range terms {
+		counts[term]++
+	}
+	return counts
+}
+
+// PrefixRange returns the range [lower, upper) that holds exactly the
+// strings starting with the given non-empty prefix, when compared byte by byte
+func PrefixRange(prefix string) (lower string, upper string) {
+	// 0xff never occurs in UTF-8, so bumping the last byte can't overflow
+	last := len(prefix) - 1
+	return prefix, prefix[:last] + string([]byte{prefix[last] + 1})
+}
+
+// Posting records how many times a term occurs in the task of a Todo,
+// which has Length terms in all
+type Posting struct {
+	ID          TodoID
+	Term        string
+	Occurrences uint
+	Length      uint
+}
+
+// SearchHit is the id of a Todo matching a TodoSearch, along with its score
+type SearchHit struct {
+	ID    TodoID
+	Score float64
+}
+
+// prefixMatchWeight is how much a term that merely starts with a token
+// counts for, compared to one that is equal to it
+const prefixMatchWeight = 0.5
+
+// RankSearch scores the Todos that match every one of the given tokens,
+// given the postings of all terms that start with any of them and the
+// total number of Todos, returning up to limit (0 for no limit) of them,
+// most relevant first and by id between equals.
+//
+// Each matching term adds its TF-IDF to the score of a Todo: terms that
+// make up more of a task, or that are rarer, count for more. Terms that
+// only match a token by prefix count for less than exact matches.
+func RankSearch(tokens []string, postings []Posting, todos uint, limit uint) []SearchHit {
+	tokens = uniqueSorted(tokens)
+	postings = uniquePostings(postings)
+	documentFrequencies := make(map[string]uint)
+	for _, posting := range postings {
+		documentFrequencies[posting.Term]++
+	}
+	scores := make(map[TodoID]float64)
+	matchedTokens := make(map[TodoID]int)
+	for _, token := range tokens {
+		matched := make(map[TodoID]bool)
+		for _, posting := range postings {
+			if !strings.HasPrefix(posting.Term, token) || posting.Length == 0 {
+				continue
+			}
+			weight := prefixMatchWeight
+			if posting.Term == token {
+				weight = 1
+			}
+			termFrequency := float64(posting.Occurrences) / float64(posting.Length)
+			inverseDocumentFrequency := math.Log(1 + float64(todos)/float64(documentFrequencies[posting.Term]))
+			scores[posting.ID] += weight * termFrequency * inverseDocumentFrequency
+			if !matched[posting.ID] {
+				matched[posting.ID] = true
+				matchedTokens[posting.ID]++
+			}
+		}
+	}
+	hits := make([]SearchHit, 0)
+	for id, score := range scores {
+		if matchedTokens[id] == len(tokens) {
+			hits = append(hits, SearchHit{ID: id, Score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && uint(len(hits)) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+func uniqueSorted(strs []string) []string {
+	sorted := make([]string, len(strs))
+	copy(sorted, strs)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for _, s := range sorted {
+		if len(unique) == 0 || s != unique[len(unique)-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// uniquePostings sorts postings by term then id, dropping repeats, so that
+// overlapping prefixes don't count twice and scores add up the same way
+// whatever order the postings came in
+func uniquePostings(postings []Posting) []Posting {
+	sorted := make([]Posting, len(postings))
+	copy(sorted, postings)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Term != sorted[j].Term {
+			return sorted[i].Term < sorted[j].Term
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	unique := sorted[:0]
+	for _, posting := range sorted {
+		if last := len(unique) - 1; last < 0 || posting.Term != unique[last].Term || posting.ID != unique[last].ID {
+			unique = append(unique, posting)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+type TodoListService interface {
+	Create(newList *domain.NewTodoList) (domain.TodoList, TodoServiceError)
+	Get(listId *domain.TodoListID) (domain.TodoList, TodoServiceError)
+	List() ([]domain.TodoList, TodoServiceError)
+	Update(list *domain.TodoList) (domain.TodoList, TodoServiceError)
+	Delete(listId *domain.TodoListID, todos TodosPolicy) (bool, TodoServiceError)
+	// AsActor returns a TodoListService whose changes to Todos are recorded
+	// in their history as made by the given actor
+	AsActor(actor string) TodoListService
+}
+
+// TodosPolicy says what happens to the Todos on a list when it gets deleted
+type TodosPolicy int
+
+const (
+	// RefuseTodos refuses to delete lists that still have Todos on them
+	RefuseTodos TodosPolicy = iota
+	// DeleteTodos deletes the Todos on a list along with it; their children
+	// on other lists are kept, as top-level Todos
+	DeleteTodos
+)
+
+// MkTodoListService returns a default implementation of TodoListService
+// given a domain.TodoListRepo, along with the TodoService that deals with
+// the Todos on its lists
+func MkTodoListService(lists domain.TodoListRepo, todos TodoService) TodoListService {
+	return &todoListServiceImpl{Lists: lists, Todos: todos}
+}
+
+// todoListServiceImpl encapsulates business logic around domain.TodoList
+type todoListServiceImpl struct {
+	Lists domain.TodoListRepo
+	Todos TodoService
+}
+
+func (service *todoListServiceImpl) Create(newList *domain.NewTodoList) (domain.TodoList, TodoServiceError) {
+	name, err := normaliseListName(newList.Name)
+	if err != nil {
+		return domain.TodoList{}, err
+	}
+	if created, err := service.Lists.Create(&domain.NewTodoList{Name: name}); err == nil {
+		return created, nil
+	} else {
+		return domain.TodoList{}, fromListRepoError(err)
+	}
+}
+
+func (service *todoListServiceImpl) Get(listId *domain.TodoListID) (domain.TodoList, TodoServiceError) {
+	if found, err := service.Lists.Get(listId); err == nil {
+		return found, nil
+	} else {
+		return domain.TodoList{}, fromListRepoError(err)
+	}
+}
+
+func (service *todoListServiceImpl) List() ([]domain.TodoList, TodoServiceError) {
+	if lists, err := service.Lists.List(); err == nil {
+		return lists, nil
+	} else {
+		return nil, fromListRepoError(err)
+	}
+}
+
+func (service *todoListServiceImpl) AsActor(actor string) TodoListService {
+	return &todoListServiceImpl{Lists: service.Lists, Todos: service.Todos.AsActor(actor)}
+}
+
+// Update renames an existing list
+func (service *todoListServiceImpl) Update(list *domain.TodoList) (domain.TodoList, TodoServiceError) {
+	name, err := normaliseListName(list.Name)
+	if err != nil {
+		return domain.TodoList{}, err
+	}
+	if updated, err := service.Lists.Update(&domain.TodoList{ID: list.ID, Name: name}); err == nil {
+		return updated, nil
+	} else {
+		return domain.TodoList{}, fromListRepoError(err)
+	}
+}
+
+// Delete deletes an existing list, dealing with the Todos on it according
+// to the given policy. With DeleteTodos, each of them is deleted the way
+// TodoService.Delete does, along with the list itself, all together or not
+// at all.
+func (service *todoListServiceImpl) Delete(listId *domain.TodoListID, todos TodosPolicy) (bool, TodoServiceError) {
+	var result bool
+	err := service.Todos.WithinTx(func(todoService TodoService, lists domain.TodoListRepo) TodoServiceError {
+		page, err := todoService.List(&domain.TodoQuery{ListID: listId})
+		if err != nil {
+			return err
+		}
+		if todos == RefuseTodos && len(page.Todos) > 0 {
+			return ListNotEmpty{ID: *listId, Todos: len(page.Todos)}
+		}
+		for _, todo := range page.Todos {
+			if _, err := todoService.Delete(&todo.ID, 0, OrphanChildren); err != nil {
+				return err
+			}
+		}
+		deleted, listErr := lists.Delete(listId)
+		if listErr != nil {
+			return fromListRepoError(listErr)
+		}
+		result = deleted
+		return nil
+	})
+	return result, err
+}
+
+// maxListNameLength is the longest a list name can be, in characters
+const maxListNameLength = 128
+
+// normaliseListName trims the given list name, returning a ListNameError if
+// that leaves it empty or too long
+func normaliseListName(name string) (string, TodoServiceError) {
+	trimmed := strings.TrimSpace(name)
+	if len(trimmed) == 0 || utf8.RuneCountInString(trimmed) > maxListNameLength {
+		return "", ListNameError{Name: name}
+	}
+	return trimmed, nil
+}
+
+// fromListRepoError translates errors coming out of a domain.TodoListRepo
+// into TodoServiceErrors
+func fromListRepoError(err domain.TodoListRepoError) TodoServiceError {
+	switch e := err.(type) {
+	case domain.TodoListNotFound:
+		return ListNotFound{ID: e.ID}
+	default:
+		return TodoStorageError{Cause: err}
+	}
+}
+
+// <-- errors
+
+// ListNameError is returned when a list name is empty or too long
+type ListNameError struct {
+	Name string
+}
+
+type ListNotFound struct {
+	ID domain.TodoListID
+}
+
+// ListNotEmpty is returned when deleting a list that still has Todos on it
+// without deleting them too
+type ListNotEmpty struct {
+	ID    domain.TodoListID
+	Todos int
+}
+
+func (err ListNameError) Error() string {
+	return fmt.Sprintf("List names must be between 1 and %d characters: %q", maxListNameLength, err.Name)
+}
+
+func (err ListNotFound) Error() string {
+	return fmt.Sprintf("This list does not exist: [%v]", err.ID)
+}
+
+func (err ListNotEmpty) Error() string {
+	return fmt.Sprintf("This list still has %d todo(s) on it: [%v]", err.Todos, err.ID)
+}
+
+//     errors  -->
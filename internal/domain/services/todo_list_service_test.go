@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateListTrimsName(t *testing.T) {
+	lists := mockListRepoWith()
+	service := todoListServiceImpl{Lists: lists}
+	created, err := service.Create(&domain.NewTodoList{Name: "  Chores "})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.TodoList{ID: 1, Name: "Chores"}, created)
+}
+
+func TestCreateListWithInvalidName(t *testing.T) {
+	for _, name := range []string{"", "   ", string(make([]rune, maxListNameLength+1))} {
+		lists := mockListRepoWith()
+		service := todoListServiceImpl{Lists: lists}
+		_, err := service.Create(&domain.NewTodoList{Name: name})
+		assert.Equal(t, ListNameError{Name: name}, err)
+		assert.Empty(t, lists.lists)
+	}
+}
+
+func TestUpdateAbsentList(t *testing.T) {
+	service := todoListServiceImpl{Lists: mockListRepoWith()}
+	_, err := service.Update(&domain.TodoList{ID: 4, Name: "Errands"})
+	assert.Equal(t, ListNotFound{ID: 4}, err)
+}
+
+func TestDeleteEmptyList(t *testing.T) {
+	lists := mockListRepoWith(domain.TodoList{ID: 1, Name: "Chores"})
+	todos := mockRepoWith()
+	service := todoListServiceImpl{Lists: lists, Todos: serviceWithLists(todos, lists)}
+	one := domain.TodoListID(1)
+	deleted, err := service.Delete(&one, RefuseTodos)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Empty(t, lists.lists)
+}
+
+func TestDeleteNonEmptyListRefused(t *testing.T) {
+	one := domain.TodoListID(1)
+	lists := mockListRepoWith(domain.TodoList{ID: 1, Name: "Chores"})
+	todos := mockRepoWith(domain.Todo{ID: 1, Task: "Sweep", ListID: &one})
+	service := todoListServiceImpl{Lists: lists, Todos: serviceWithLists(todos, lists)}
+	_, err := service.Delete(&one, RefuseTodos)
+	assert.Equal(t, ListNotEmpty{ID: 1, Todos: 1}, err)
+	assert.Len(t, lists.lists, 1)
+	assert.Equal(t, []domain.TodoID{1}, todos.ids())
+}
+
+func TestDeleteListCascades(t *testing.T) {
+	one, two := domain.TodoListID(1), domain.TodoListID(2)
+	parent := domain.TodoID(1)
+	lists := mockListRepoWith(domain.TodoList{ID: 1, Name: "Chores"}, domain.TodoList{ID: 2, Name: "Errands"})
+	todos := mockRepoWith(
+		domain.Todo{ID: 1, Task: "Clean the house", ListID: &one},
+		domain.Todo{ID: 2, Task: "Sweep", ParentID: &parent, ListID: &one},
+		domain.Todo{ID: 3, Task: "Buy a broom", ParentID: &parent, ListID: &two},
+		domain.Todo{ID: 4, Task: "Post a letter", DependsOn: []domain.TodoID{2}},
+	)
+	service := todoListServiceImpl{Lists: lists, Todos: serviceWithLists(todos, lists)}
+	deleted, err := service.Delete(&one, DeleteTodos)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []domain.TodoID{3, 4}, todos.ids())
+	// what was left behind no longer points at what was deleted
+	assert.Nil(t, todos.todos[3].ParentID)
+	assert.Empty(t, todos.todos[4].DependsOn)
+	_, stillThere := lists.lists[2]
+	assert.True(t, stillThere)
+}
+
+func TestDeleteAbsentList(t *testing.T) {
+	lists := mockListRepoWith()
+	service := todoListServiceImpl{Lists: lists, Todos: serviceWithLists(mockRepoWith(), lists)}
+	four := domain.TodoListID(4)
+	_, err := service.Delete(&four, DeleteTodos)
+	assert.Equal(t, ListNotFound{ID: 4}, err)
+}
+
+func TestDeleteListCascadeIsAtomic(t *testing.T) {
+	one := domain.TodoListID(1)
+	failure := domain.TodoListRepoFailure{ID: one, Cause: errors.New("disk on fire")}
+	lists := &failingListRepo{mockListRepo: mockListRepoWith(domain.TodoList{ID: 1, Name: "Chores"}), failure: failure}
+	todos := mockRepoWith(domain.Todo{ID: 1, Task: "Sweep", ListID: &one})
+	service := todoListServiceImpl{Lists: lists, Todos: serviceWithLists(todos, lists)}
+	_, err := service.Delete(&one, DeleteTodos)
+	assert.Equal(t, TodoStorageError{Cause: failure}, err)
+	// the Todos on it are not deleted without it
+	assert.Equal(t, []domain.TodoID{1}, todos.ids())
+}
+
+func TestDeleteListAsActor(t *testing.T) {
+	one := domain.TodoListID(1)
+	lists := mockListRepoWith(domain.TodoList{ID: 1, Name: "Chores"})
+	todos := mockRepoWith(domain.Todo{ID: 1, Task: "Sweep", ListID: &one})
+	var actors []string
+	todos.asActor = func(actor string) domain.TodoRepo {
+		actors = append(actors, actor)
+		return todos
+	}
+	service := todoListServiceImpl{Lists: lists, Todos: serviceWithLists(todos, lists)}
+	_, err := service.AsActor("alice").Delete(&one, DeleteTodos)
+	assert.Nil(t, err)
+	// the Todos deleted along with the list are deleted by the actor
+	assert.Equal(t, []string{"alice"}, actors)
+	assert.Empty(t, todos.ids())
+}
+
+// serviceWithLists returns a todoServiceImpl whose Todos can be on the
+// given lists, which functions run by WithinTx get too
+func serviceWithLists(todos *storingMockRepo, lists domain.TodoListRepo) *todoServiceImpl {
+	todos.lists = lists
+	return &todoServiceImpl{Repo: todos, Lists: lists}
+}
+
+// mockListRepo keeps lists in a map
+type mockListRepo struct {
+	lists map[domain.TodoListID]string
+}
+
+func mockListRepoWith(lists ...domain.TodoList) *mockListRepo {
+	r := &mockListRepo{lists: make(map[domain.TodoListID]string)}
+	for _, list := range lists {
+		r.lists[list.ID] = list.Name
+	}
+	return r
+}
+
+func (r *mockListRepo) Create(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	id := domain.TodoListID(len(r.lists) + 1)
+	r.lists[id] = newList.Name
+	return domain.TodoList{ID: id, Name: newList.Name}, nil
+}
+
+func (r *mockListRepo) Get(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	if name, ok := r.lists[*id]; ok {
+		return domain.TodoList{ID: *id, Name: name}, nil
+	}
+	return domain.TodoList{}, domain.TodoListNotFound{ID: *id}
+}
+
+func (r *mockListRepo) List() ([]domain.TodoList, domain.TodoListRepoError) {
+	var lists []domain.TodoList
+	for id, name := range r.lists {
+		lists = append(lists, domain.TodoList{ID: id, Name: name})
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+	return lists, nil
+}
+
+func (r *mockListRepo) Update(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	if _, ok := r.lists[list.ID]; !ok {
+		return domain.TodoList{}, domain.TodoListNotFound{ID: list.ID}
+	}
+	r.lists[list.ID] = list.Name
+	return *list, nil
+}
+
+func (r *mockListRepo) Delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	if _, ok := r.lists[*id]; !ok {
+		return false, domain.TodoListNotFound{ID: *id}
+	}
+	delete(r.lists, *id)
+	return true, nil
+}
+
+// failingListRepo is a mockListRepo that fails to delete lists
+type failingListRepo struct {
+	*mockListRepo
+	failure domain.TodoListRepoError
+}
+
+func (r *failingListRepo) Delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	return false, r.failure
+}
@@ -2,74 +2,1166 @@ package services
 
 import (
 	"fmt"
+	"math"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/events"
 )
 
 type TodoService interface {
 	Create(newTodo *domain.NewTodo) (domain.Todo, TodoServiceError)
 	Update(todo *domain.Todo) (domain.Todo, TodoServiceError)
-	List() []domain.Todo
+	List(query *domain.TodoQuery) (domain.TodoPage, TodoServiceError)
 	Get(todoId *domain.TodoID) (domain.Todo, TodoServiceError)
-	Delete(todoId *domain.TodoID) (bool, TodoServiceError)
+	Delete(todoId *domain.TodoID, version domain.TodoVersion, children ChildrenPolicy) (bool, TodoServiceError)
+	ListTrash() ([]domain.TrashedTodo, TodoServiceError)
+	Restore(todoId *domain.TodoID) (domain.Todo, TodoServiceError)
+	Purge(todoId *domain.TodoID) (bool, TodoServiceError)
+	History(todoId *domain.TodoID) ([]domain.TodoRevision, TodoServiceError)
+	Revert(todoId *domain.TodoID, rev uint) (domain.Todo, TodoServiceError)
+	Complete(todoId *domain.TodoID) (domain.Todo, TodoServiceError)
+	Reopen(todoId *domain.TodoID) (domain.Todo, TodoServiceError)
+	AddTags(todoId *domain.TodoID, tags []string) (domain.Todo, TodoServiceError)
+	RemoveTags(todoId *domain.TodoID, tags []string) (domain.Todo, TodoServiceError)
+	ListTags() ([]domain.TagCount, TodoServiceError)
+	Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, TodoServiceError)
+	Subtrees(todos []domain.Todo) ([]domain.TodoTree, TodoServiceError)
+	AddDependencies(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, TodoServiceError)
+	RemoveDependencies(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, TodoServiceError)
+	Ready() ([]domain.Todo, TodoServiceError)
+	Move(todoId *domain.TodoID, before *domain.TodoID, after *domain.TodoID) (domain.Todo, TodoServiceError)
+	Batch(ops []BatchOp) ([]domain.Todo, TodoServiceError)
+	// WithinTx runs the given function with a TodoService, and the
+	// domain.TodoListRepo kept alongside its Todos, whose changes are made
+	// all together if it returns nil, or not at all if it returns an error,
+	// which WithinTx then returns
+	WithinTx(f func(todos TodoService, lists domain.TodoListRepo) TodoServiceError) TodoServiceError
+	// AsActor returns a TodoService whose changes are recorded in the
+	// history of Todos as made by the given actor
+	AsActor(actor string) TodoService
 }
 
+// ChildrenPolicy says what happens to the children of a Todo when it
+// gets deleted
+type ChildrenPolicy int
+
+const (
+	// OrphanChildren turns the children of a deleted Todo into top-level
+	// Todos, keeping their own children
+	OrphanChildren ChildrenPolicy = iota
+	// DeleteChildren deletes the children of a deleted Todo along with it,
+	// all the way down
+	DeleteChildren
+)
+
+// BatchOp is one of the operations making up a batch: exactly one of
+// Create, Update and Delete is set
+type BatchOp struct {
+	Create *domain.NewTodo
+	Update *domain.Todo
+	Delete *BatchDelete
+}
+
+// BatchDelete is what Delete is given, as a BatchOp
+type BatchDelete struct {
+	ID       domain.TodoID
+	Version  domain.TodoVersion
+	Children ChildrenPolicy
+}
+
+// maxModifyAttempts is how many times a change to a Todo gets tried before
+// giving up because it keeps getting changed by someone else in between
+const maxModifyAttempts = 5
+
 // MkTodoService returns a default implementation of TodoService given
-// a domain.TodoRepo
-func MkTodoService(repo domain.TodoRepo) TodoService {
-	return &todoServiceImpl{Repo: repo}
+// a domain.TodoRepo, along with the domain.TodoListRepo for the lists its
+// Todos are on, how long deleted Todos are kept in the trash for (zero
+// to keep them until they are purged), and the events.Publisher the
+// changes it makes to Todos get published to
+func MkTodoService(repo domain.TodoRepo, lists domain.TodoListRepo, trashRetention time.Duration, publisher events.Publisher) TodoService {
+	return &todoServiceImpl{Repo: repo, Lists: lists, Clock: time.Now, TrashRetention: trashRetention, Events: publisher, commits: &sync.Mutex{}}
 }
 
 // todoServiceImpl encapsulates business logic around domain.Todo
 //
 // At the moment it mostly does simple validation on Create and Update
-// cases, but maybe it can do more interesting things in the future
+// cases, and keeps track of when Todos get completed, but maybe it can
+// do more interesting things in the future
 type todoServiceImpl struct {
-	Repo domain.TodoRepo
+	Repo  domain.TodoRepo
+	Lists domain.TodoListRepo
+	// Clock tells the time; defaults to time.Now when nil
+	Clock func() time.Time
+	// TrashRetention is how long deleted Todos are kept in the trash for;
+	// forever when zero
+	TrashRetention time.Duration
+	// Events is where the changes made to Todos get published, once they
+	// have been made for good; nowhere when nil
+	Events events.Publisher
+	// commits is held from making changes until their events are published,
+	// so that events get published in the order the changes were made in;
+	// shared by the copies AsActor makes. Changes are not ordered when nil.
+	commits *sync.Mutex
+	// Actor is who the changes are made by
+	Actor string
+	// inTx is true when Repo and Lists are those of a transaction
+	inTx bool
+	// pending are the events to publish once the transaction is committed
+	pending *[]events.Event
 }
 
+// Create creates a new Todo, once its parent, list and dependencies have
+// been checked
 func (service *todoServiceImpl) Create(newTodo *domain.NewTodo) (domain.Todo, TodoServiceError) {
+	var created domain.Todo
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		created, err = within.create(newTodo)
+		return err
+	})
+	return created, err
+}
+
+func (service *todoServiceImpl) create(newTodo *domain.NewTodo) (domain.Todo, TodoServiceError) {
 	if len(newTodo.Task) == 0 {
 		err := &TodoDataError{Task: newTodo.Task}
 		return domain.Todo{}, err
+	} else if err := checkDueAt(newTodo.DueAt); err != nil {
+		return domain.Todo{}, err
+	} else if err := checkTags(newTodo.Tags); err != nil {
+		return domain.Todo{}, err
+	} else if !newTodo.Priority.IsValid() {
+		return domain.Todo{}, TodoPriorityError{Priority: newTodo.Priority}
+	} else if err := service.checkParent(0, newTodo.ParentID); err != nil {
+		return domain.Todo{}, err
+	} else if err := service.checkList(newTodo.ListID); err != nil {
+		return domain.Todo{}, err
+	} else if err := service.checkDependencies(0, newTodo.DependsOn); err != nil {
+		return domain.Todo{}, err
+	} else if recurrence, err := normaliseRecurrence(newTodo.Recurrence); err != nil {
+		return domain.Todo{}, err
 	} else {
-		return service.Repo.Create(newTodo), nil
+		toCreate := *newTodo
+		toCreate.CompletedAt = service.completionTime(newTodo.Completed, nil)
+		toCreate.Recurrence = recurrence
+		if created, err := service.Repo.Create(&toCreate); err == nil {
+			service.publish(events.TodoCreated{Change: service.change(created)})
+			return created, nil
+		} else {
+			return domain.Todo{}, fromRepoError(err)
+		}
 	}
 }
 
+// Update replaces the Task, completion status, due date, tags, priority,
+// parent, dependencies, recurrence and list of an existing Todo, which must be at
+// the given Version unless that is zero.
+//
+// The given CompletedAt is ignored: it is kept as-is for Todos that were
+// already completed, and set to the current time for newly completed ones.
+// Newly completed Todos that recur come round again, as with Complete.
 func (service *todoServiceImpl) Update(todo *domain.Todo) (domain.Todo, TodoServiceError) {
+	var updated domain.Todo
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		updated, err = within.update(todo)
+		return err
+	})
+	return updated, err
+}
+
+func (service *todoServiceImpl) update(todo *domain.Todo) (domain.Todo, TodoServiceError) {
 	if len(todo.Task) == 0 {
 		err := &TodoDataError{Task: todo.Task}
 		return domain.Todo{}, err
+	} else if err := checkDueAt(todo.DueAt); err != nil {
+		return domain.Todo{}, err
+	} else if err := checkTags(todo.Tags); err != nil {
+		return domain.Todo{}, err
+	} else if !todo.Priority.IsValid() {
+		return domain.Todo{}, TodoPriorityError{Priority: todo.Priority}
+	} else if err := service.checkParent(todo.ID, todo.ParentID); err != nil {
+		return domain.Todo{}, err
+	} else if err := service.checkList(todo.ListID); err != nil {
+		return domain.Todo{}, err
+	} else if err := service.checkDependencies(todo.ID, todo.DependsOn); err != nil {
+		return domain.Todo{}, err
+	} else if recurrence, err := normaliseRecurrence(todo.Recurrence); err != nil {
+		return domain.Todo{}, err
 	} else {
-		if updated, err := service.Repo.Update(todo); err == nil {
-			return updated, nil
-		} else {
-			return domain.Todo{}, TodoNotFound{ID: err.Id()}
+		var recurring bool
+		updated, err := service.modify(todo.ID, todo.Version, func(existing *domain.Todo) {
+			recurring = todo.Completed && !existing.Completed && len(recurrence) > 0
+			existing.CompletedAt = service.completionTime(todo.Completed, existing)
+			existing.Task = todo.Task
+			existing.Completed = todo.Completed
+			existing.DueAt = todo.DueAt
+			existing.Tags = todo.Tags
+			existing.Priority = todo.Priority
+			existing.ParentID = todo.ParentID
+			existing.DependsOn = todo.DependsOn
+			existing.Recurrence = recurrence
+			existing.ListID = todo.ListID
+			if recurring {
+				existing.Recurrence = ""
+			}
+		})
+		if err != nil || !recurring {
+			return updated, err
 		}
+		return updated, service.recur(&updated, recurrence)
 	}
 }
 
-func (service *todoServiceImpl) List() []domain.Todo {
-	return service.Repo.List()
+// List lists a page of Todos matching the given query; whether Todos are
+// overdue is judged as of now. Listing the children of a Todo that does
+// not exist fails with TodoNotFound, and listing the Todos on a list that
+// does not exist fails with ListNotFound.
+func (service *todoServiceImpl) List(query *domain.TodoQuery) (domain.TodoPage, TodoServiceError) {
+	if query.ParentID != nil {
+		if _, err := service.Repo.Get(query.ParentID); err != nil {
+			return domain.TodoPage{}, fromRepoError(err)
+		}
+	}
+	if query.ListID != nil {
+		if _, err := service.Lists.Get(query.ListID); err != nil {
+			return domain.TodoPage{}, fromListRepoError(err)
+		}
+	}
+	for _, dueAt := range []*time.Time{query.DueBefore, query.DueAfter} {
+		if err := checkDueAt(dueAt); err != nil {
+			return domain.TodoPage{}, err
+		}
+	}
+	if err := checkTags(query.Tags); err != nil {
+		return domain.TodoPage{}, err
+	}
+	toList := *query
+	toList.OverdueAsOf = service.now()
+	if listed, err := service.Repo.List(&toList); err == nil {
+		return listed, nil
+	} else {
+		return domain.TodoPage{}, fromRepoError(err)
+	}
 }
 
 func (service *todoServiceImpl) Get(todoId *domain.TodoID) (domain.Todo, TodoServiceError) {
 	if found, err := service.Repo.Get(todoId); err == nil {
 		return found, nil
 	} else {
-		return domain.Todo{}, TodoNotFound{ID: err.Id()}
+		return domain.Todo{}, fromRepoError(err)
 	}
 }
 
-func (service *todoServiceImpl) Delete(todoId *domain.TodoID) (bool, TodoServiceError) {
-	if result, err := service.Repo.Delete(todoId); err == nil {
+// Delete moves an existing Todo to the trash, which must be at the given
+// version unless that is zero, dealing with its children according to the
+// given policy and dropping it from the dependencies of other Todos, all
+// together or not at all. Deleted children go to the trash too.
+func (service *todoServiceImpl) Delete(todoId *domain.TodoID, version domain.TodoVersion, children ChildrenPolicy) (bool, TodoServiceError) {
+	var deleted bool
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		deleted, err = within.delete(todoId, version, children)
+		return err
+	})
+	return deleted, err
+}
+
+func (service *todoServiceImpl) delete(todoId *domain.TodoID, version domain.TodoVersion, children ChildrenPolicy) (bool, TodoServiceError) {
+	if err := service.purgeExpired(); err != nil {
+		return false, err
+	}
+	existing, err := service.Repo.Get(todoId)
+	if err != nil {
+		return false, fromRepoError(err)
+	}
+	if err := domain.CheckVersion(*todoId, version, existing.Version); err != nil {
+		return false, fromRepoError(err)
+	}
+	if children == DeleteChildren {
+		if err := service.deleteDescendants(*todoId); err != nil {
+			return false, err
+		}
+	} else if err := service.orphanChildren(*todoId); err != nil {
+		return false, err
+	}
+	if err := service.removeFromDependents(*todoId); err != nil {
+		return false, err
+	}
+	// Cleaning up may have changed the Todo itself, when it depends on one
+	// of its descendants, so it is deleted as it is now; the version it was
+	// asked to be deleted at has already been checked
+	if existing, err = service.Repo.Get(todoId); err != nil {
+		return false, fromRepoError(err)
+	}
+	if result, err := service.Repo.Delete(todoId, existing.Version); err == nil {
+		service.publish(events.TodoDeleted{Change: service.change(existing)})
 		return result, nil
 	} else {
-		return false, TodoNotFound{ID: err.Id()}
+		return false, fromRepoError(err)
+	}
+}
+
+// deleteDescendants deletes all the descendants of the Todo with the given
+// id, deepest first so that none of them is ever left without a parent
+func (service *todoServiceImpl) deleteDescendants(todoId domain.TodoID) TodoServiceError {
+	trees, err := service.Subtrees([]domain.Todo{{ID: todoId}})
+	if err != nil {
+		return err
+	}
+	var deleteAll func(trees []domain.TodoTree) TodoServiceError
+	deleteAll = func(trees []domain.TodoTree) TodoServiceError {
+		for _, tree := range trees {
+			if err := deleteAll(tree.Children); err != nil {
+				return err
+			}
+			if err := service.removeFromDependents(tree.Todo.ID); err != nil {
+				return err
+			}
+			if _, err := service.Repo.Delete(&tree.Todo.ID, 0); err == nil {
+				service.publish(events.TodoDeleted{Change: service.change(tree.Todo)})
+			} else if _, notFound := err.(domain.TodoNotFound); !notFound {
+				// unless someone else got there first
+				return fromRepoError(err)
+			}
+		}
+		return nil
+	}
+	return deleteAll(trees[0].Children)
+}
+
+// orphanChildren turns the children of the Todo with the given id into
+// top-level Todos
+func (service *todoServiceImpl) orphanChildren(todoId domain.TodoID) TodoServiceError {
+	children, err := service.children(todoId)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		_, err := service.modify(child.ID, 0, func(existing *domain.Todo) {
+			// unless it was moved elsewhere in the meantime
+			if existing.ParentID != nil && *existing.ParentID == todoId {
+				existing.ParentID = nil
+			}
+		})
+		if _, notFound := err.(TodoNotFound); err != nil && !notFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFromDependents makes the Todos that depend on the one with the given
+// id stop doing so
+func (service *todoServiceImpl) removeFromDependents(todoId domain.TodoID) TodoServiceError {
+	page, err := service.Repo.List(&domain.TodoQuery{DependencyOf: &todoId})
+	if err != nil {
+		return fromRepoError(err)
+	}
+	for _, dependent := range page.Todos {
+		_, err := service.RemoveDependencies(&dependent.ID, []domain.TodoID{todoId})
+		if _, notFound := err.(TodoNotFound); err != nil && !notFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTrash lists the deleted Todos that are still in the trash, most
+// recently deleted first
+func (service *todoServiceImpl) ListTrash() ([]domain.TrashedTodo, TodoServiceError) {
+	if err := service.purgeExpired(); err != nil {
+		return nil, err
+	}
+	if trashed, err := service.Repo.ListTrash(); err == nil {
+		return trashed, nil
+	} else {
+		return nil, fromRepoError(err)
+	}
+}
+
+// Restore takes a Todo back out of the trash, as it was when it was
+// deleted, except that it loses its parent, list and dependencies if they
+// have since gone away themselves
+func (service *todoServiceImpl) Restore(todoId *domain.TodoID) (domain.Todo, TodoServiceError) {
+	var restored domain.Todo
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		restored, err = within.restore(todoId)
+		return err
+	})
+	return restored, err
+}
+
+func (service *todoServiceImpl) restore(todoId *domain.TodoID) (domain.Todo, TodoServiceError) {
+	if err := service.purgeExpired(); err != nil {
+		return domain.Todo{}, err
+	}
+	restored, err := service.Repo.Restore(todoId)
+	if _, notFound := err.(domain.TodoNotFound); notFound {
+		return domain.Todo{}, TrashedTodoNotFound{ID: *todoId}
+	} else if err != nil {
+		return domain.Todo{}, fromRepoError(err)
+	}
+	toUpdate := restored
+	changed := false
+	if err := service.checkParent(restored.ID, restored.ParentID); err != nil {
+		if _, notFound := err.(TodoParentNotFound); !notFound {
+			return domain.Todo{}, err
+		}
+		toUpdate.ParentID, changed = nil, true
+	}
+	if err := service.checkList(restored.ListID); err != nil {
+		if _, notFound := err.(TodoListNotFound); !notFound {
+			return domain.Todo{}, err
+		}
+		toUpdate.ListID, changed = nil, true
+	}
+	toUpdate.DependsOn = nil
+	for _, dependencyId := range restored.DependsOn {
+		if err := service.checkDependencies(restored.ID, []domain.TodoID{dependencyId}); err != nil {
+			if _, notFound := err.(TodoDependencyNotFound); !notFound {
+				return domain.Todo{}, err
+			}
+			changed = true
+			continue
+		}
+		toUpdate.DependsOn = append(toUpdate.DependsOn, dependencyId)
+	}
+	if !changed {
+		service.publish(events.TodoRestored{Change: service.change(restored)})
+		return restored, nil
+	}
+	if updated, err := service.Repo.Update(&toUpdate); err == nil {
+		service.publish(events.TodoRestored{Change: service.change(updated)})
+		return updated, nil
+	} else {
+		return domain.Todo{}, fromRepoError(err)
+	}
+}
+
+// Purge deletes a Todo in the trash for good
+func (service *todoServiceImpl) Purge(todoId *domain.TodoID) (bool, TodoServiceError) {
+	if err := service.purgeExpired(); err != nil {
+		return false, err
+	}
+	purged, err := service.Repo.Purge(todoId)
+	if _, notFound := err.(domain.TodoNotFound); notFound {
+		return false, TrashedTodoNotFound{ID: *todoId}
+	} else if err != nil {
+		return false, fromRepoError(err)
+	}
+	return purged, nil
+}
+
+// History lists the revisions of a Todo, oldest first, including those made
+// before it was deleted
+func (service *todoServiceImpl) History(todoId *domain.TodoID) ([]domain.TodoRevision, TodoServiceError) {
+	if revisions, err := service.Repo.History(todoId); err == nil {
+		return revisions, nil
+	} else {
+		return nil, fromRepoError(err)
+	}
+}
+
+// Revert changes an existing Todo back to what it was as of the given
+// revision, as a new revision, keeping its place in the order. Its parent,
+// list and dependencies back then have to be valid still.
+func (service *todoServiceImpl) Revert(todoId *domain.TodoID, rev uint) (domain.Todo, TodoServiceError) {
+	var reverted domain.Todo
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		reverted, err = within.revert(todoId, rev)
+		return err
+	})
+	return reverted, err
+}
+
+func (service *todoServiceImpl) revert(todoId *domain.TodoID, rev uint) (domain.Todo, TodoServiceError) {
+	revisions, err := service.History(todoId)
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	var target *domain.Todo
+	for i := range revisions {
+		if revisions[i].Rev == rev {
+			target = &revisions[i].Todo
+			break
+		}
+	}
+	if target == nil {
+		return domain.Todo{}, TodoRevisionNotFound{ID: *todoId, Rev: rev}
+	}
+	if err := service.checkParent(*todoId, target.ParentID); err != nil {
+		return domain.Todo{}, err
+	}
+	if err := service.checkList(target.ListID); err != nil {
+		return domain.Todo{}, err
+	}
+	if err := service.checkDependencies(*todoId, target.DependsOn); err != nil {
+		return domain.Todo{}, err
+	}
+	return service.modify(*todoId, 0, func(existing *domain.Todo) {
+		existing.Task = target.Task
+		existing.Completed = target.Completed
+		existing.CompletedAt = target.CompletedAt
+		existing.DueAt = target.DueAt
+		existing.Tags = target.Tags
+		existing.Priority = target.Priority
+		existing.ParentID = target.ParentID
+		existing.DependsOn = target.DependsOn
+		existing.Recurrence = target.Recurrence
+		existing.ListID = target.ListID
+	})
+}
+
+// purgeExpired purges the Todos that have been in the trash for longer
+// than TrashRetention. A TrashPurger does that regularly, so this only
+// makes sure none are left in between.
+func (service *todoServiceImpl) purgeExpired() TodoServiceError {
+	_, err := purgeTrash(service.Repo, service.now(), service.TrashRetention)
+	return err
+}
+
+// Complete marks an existing Todo as completed. If it recurs, its next
+// occurrence gets created, due as its recurrence says, and the recurrence
+// moves on to that occurrence.
+func (service *todoServiceImpl) Complete(todoId *domain.TodoID) (domain.Todo, TodoServiceError) {
+	return service.setCompleted(todoId, true)
+}
+
+func (service *todoServiceImpl) Reopen(todoId *domain.TodoID) (domain.Todo, TodoServiceError) {
+	return service.setCompleted(todoId, false)
+}
+
+// setCompleted marks an existing Todo as completed or not, creating its
+// next occurrence along with it when it recurs
+func (service *todoServiceImpl) setCompleted(todoId *domain.TodoID, completed bool) (domain.Todo, TodoServiceError) {
+	var updated domain.Todo
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		updated, err = within.setCompletedWithin(todoId, completed)
+		return err
+	})
+	return updated, err
+}
+
+func (service *todoServiceImpl) setCompletedWithin(todoId *domain.TodoID, completed bool) (domain.Todo, TodoServiceError) {
+	existing, err := service.Repo.Get(todoId)
+	if err != nil {
+		return domain.Todo{}, fromRepoError(err)
+	}
+	if existing.Completed == completed {
+		return existing, nil
+	}
+	var recurrence string
+	updated, modifyErr := service.modify(*todoId, 0, func(existing *domain.Todo) {
+		recurrence = ""
+		if completed && !existing.Completed {
+			recurrence, existing.Recurrence = existing.Recurrence, ""
+		}
+		existing.CompletedAt = service.completionTime(completed, existing)
+		existing.Completed = completed
+	})
+	if modifyErr != nil || len(recurrence) == 0 {
+		return updated, modifyErr
+	}
+	return updated, service.recur(&updated, recurrence)
+}
+
+// recur creates the occurrence that comes after the given one, which has
+// just been completed, according to the given recurrence rule. Occurrences
+// without a due date recur from when they were completed.
+//
+// Occurrences that would already be overdue are skipped, and count
+// towards the COUNT of the rule as if they had happened, so completing
+// an overdue Todo catches its series up rather than creating another
+// overdue one. If that uses up the series, nothing gets created.
+func (service *todoServiceImpl) recur(completed *domain.Todo, rule string) TodoServiceError {
+	recurrence, err := domain.ParseRecurrence(rule)
+	if err != nil {
+		return TodoRecurrenceError{Recurrence: rule, Cause: err}
+	}
+	now := service.now()
+	after := now
+	if completed.DueAt != nil {
+		after = *completed.DueAt
+	}
+	nextDueAt, rest, ok := recurrence.Next(after)
+	for ok && nextDueAt.Before(now) {
+		nextDueAt, rest, ok = rest.Next(nextDueAt)
+	}
+	if !ok {
+		return nil
+	}
+	next := domain.NewTodo{
+		Task:       completed.Task,
+		DueAt:      &nextDueAt,
+		Tags:       completed.Tags,
+		Priority:   completed.Priority,
+		ParentID:   completed.ParentID,
+		Recurrence: rest.String(),
+		ListID:     completed.ListID,
+	}
+	if created, err := service.Repo.Create(&next); err == nil {
+		service.publish(events.TodoCreated{Change: service.change(created)})
+		return nil
+	} else {
+		return fromRepoError(err)
 	}
 }
 
+// AddTags tags an existing Todo with the given tags, on top of the
+// ones it already has
+func (service *todoServiceImpl) AddTags(todoId *domain.TodoID, tags []string) (domain.Todo, TodoServiceError) {
+	if err := checkTags(tags); err != nil {
+		return domain.Todo{}, err
+	}
+	return service.modify(*todoId, 0, func(existing *domain.Todo) {
+		existing.Tags = domain.NormaliseTags(append(append([]string{}, existing.Tags...), tags...))
+	})
+}
+
+// RemoveTags removes the given tags from an existing Todo; tags it
+// does not have are ignored
+func (service *todoServiceImpl) RemoveTags(todoId *domain.TodoID, tags []string) (domain.Todo, TodoServiceError) {
+	removed := domain.Todo{Tags: domain.NormaliseTags(tags)}
+	return service.modify(*todoId, 0, func(existing *domain.Todo) {
+		var kept []string
+		for _, tag := range existing.Tags {
+			if !removed.HasTag(tag) {
+				kept = append(kept, tag)
+			}
+		}
+		existing.Tags = kept
+	})
+}
+
+func (service *todoServiceImpl) ListTags() ([]domain.TagCount, TodoServiceError) {
+	if tagCounts, err := service.Repo.ListTags(); err == nil {
+		return tagCounts, nil
+	} else {
+		return nil, fromRepoError(err)
+	}
+}
+
+// Search finds the Todos whose tasks match the given search, most
+// relevant first
+func (service *todoServiceImpl) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, TodoServiceError) {
+	if terms := len(domain.Tokenise(search.Text)); terms == 0 || terms > maxSearchTerms {
+		return nil, TodoSearchError{Text: search.Text}
+	}
+	if results, err := service.Repo.Search(search); err == nil {
+		return results, nil
+	} else {
+		return nil, fromRepoError(err)
+	}
+}
+
+// AddDependencies makes an existing Todo depend on the Todos with the given
+// ids, on top of the ones it already depends on
+func (service *todoServiceImpl) AddDependencies(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, TodoServiceError) {
+	var updated domain.Todo
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		updated, err = within.addDependencies(todoId, dependsOn)
+		return err
+	})
+	return updated, err
+}
+
+func (service *todoServiceImpl) addDependencies(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, TodoServiceError) {
+	if err := service.checkDependencies(*todoId, dependsOn); err != nil {
+		return domain.Todo{}, err
+	}
+	return service.modify(*todoId, 0, func(existing *domain.Todo) {
+		existing.DependsOn = domain.NormaliseIDs(append(append([]domain.TodoID{}, existing.DependsOn...), dependsOn...))
+	})
+}
+
+// RemoveDependencies makes an existing Todo stop depending on the Todos with
+// the given ids; ones it does not depend on are ignored
+func (service *todoServiceImpl) RemoveDependencies(todoId *domain.TodoID, dependsOn []domain.TodoID) (domain.Todo, TodoServiceError) {
+	removed := domain.Todo{DependsOn: domain.NormaliseIDs(dependsOn)}
+	return service.modify(*todoId, 0, func(existing *domain.Todo) {
+		var kept []domain.TodoID
+		for _, dependency := range existing.DependsOn {
+			if !removed.DependsOnTodo(dependency) {
+				kept = append(kept, dependency)
+			}
+		}
+		existing.DependsOn = kept
+	})
+}
+
+// Ready returns the open Todos whose dependencies have all been completed,
+// in the order they are best done in
+func (service *todoServiceImpl) Ready() ([]domain.Todo, TodoServiceError) {
+	open := false
+	page, err := service.Repo.List(&domain.TodoQuery{Completed: &open})
+	if err != nil {
+		return nil, fromRepoError(err)
+	}
+	ready := make([]domain.Todo, 0)
+	for _, todo := range domain.TopologicalOrder(page.Todos) {
+		if !todo.Blocked {
+			ready = append(ready, todo)
+		}
+	}
+	return ready, nil
+}
+
+// Move moves an existing Todo in the order Todos are arranged in by hand, to
+// just before the Todo with the id before, or just after the one with the
+// id after. Given both, it goes between them, which means after has to come
+// before before. Only the moved Todo changes.
+func (service *todoServiceImpl) Move(todoId *domain.TodoID, before *domain.TodoID, after *domain.TodoID) (domain.Todo, TodoServiceError) {
+	var moved domain.Todo
+	err := service.withinTx(func(within *todoServiceImpl) (err TodoServiceError) {
+		moved, err = within.move(todoId, before, after)
+		return err
+	})
+	return moved, err
+}
+
+func (service *todoServiceImpl) move(todoId *domain.TodoID, before *domain.TodoID, after *domain.TodoID) (domain.Todo, TodoServiceError) {
+	switch {
+	case before == nil && after == nil:
+		return domain.Todo{}, TodoMoveError{ID: *todoId, Reason: "it has to be moved before or after another Todo"}
+	case (before != nil && *before == *todoId) || (after != nil && *after == *todoId):
+		return domain.Todo{}, TodoMoveError{ID: *todoId, Reason: "it can't be moved next to itself"}
+	}
+	var lower, upper string
+	if after != nil {
+		target, err := service.moveTarget(*after)
+		if err != nil {
+			return domain.Todo{}, err
+		}
+		lower = target.Position
+		if before == nil {
+			if upper, err = service.adjacentPosition(&target, *todoId, false); err != nil {
+				return domain.Todo{}, err
+			}
+		}
+	}
+	if before != nil {
+		target, err := service.moveTarget(*before)
+		if err != nil {
+			return domain.Todo{}, err
+		}
+		upper = target.Position
+		if after == nil {
+			if lower, err = service.adjacentPosition(&target, *todoId, true); err != nil {
+				return domain.Todo{}, err
+			}
+		}
+	}
+	position, err := domain.PositionBetween(lower, upper)
+	if err != nil && before != nil && after != nil {
+		return domain.Todo{}, TodoMoveError{ID: *todoId, Reason: fmt.Sprintf("[%v] does not come before [%v]", *after, *before)}
+	} else if err != nil {
+		// two Todos that got the same Position at the same time
+		return domain.Todo{}, TodoMoveError{ID: *todoId, Reason: "the Todos on either side are at the same position"}
+	}
+	return service.modify(*todoId, 0, func(existing *domain.Todo) {
+		existing.Position = position
+	})
+}
+
+// Batch runs the given operations in order, exactly as Create, Update and
+// Delete would, all together or not at all. It returns the created and
+// updated Todos in the same order, with zero Todos for the deleted ones.
+// When an operation fails, none of them are made, and the error is a
+// TodoBatchError saying which one it was.
+func (service *todoServiceImpl) Batch(ops []BatchOp) ([]domain.Todo, TodoServiceError) {
+	results := make([]domain.Todo, len(ops))
+	err := service.withinTx(func(within *todoServiceImpl) TodoServiceError {
+		for i, op := range ops {
+			var err TodoServiceError
+			switch {
+			case op.Create != nil:
+				results[i], err = within.Create(op.Create)
+			case op.Update != nil:
+				results[i], err = within.Update(op.Update)
+			case op.Delete != nil:
+				_, err = within.Delete(&op.Delete.ID, op.Delete.Version, op.Delete.Children)
+			}
+			if err != nil {
+				return TodoBatchError{Index: i, Cause: err}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (service *todoServiceImpl) WithinTx(f func(todos TodoService, lists domain.TodoListRepo) TodoServiceError) TodoServiceError {
+	return service.withinTx(func(within *todoServiceImpl) TodoServiceError {
+		return f(within, within.Lists)
+	})
+}
+
+func (service *todoServiceImpl) AsActor(actor string) TodoService {
+	asActor := *service
+	asActor.Repo = service.Repo.AsActor(actor)
+	asActor.Actor = actor
+	return &asActor
+}
+
+// withinTx runs the given function with a todoServiceImpl working on the
+// repos of a transaction, which is committed if it returns nil and rolled
+// back otherwise. Already in one, it just runs the function in it.
+func (service *todoServiceImpl) withinTx(f func(within *todoServiceImpl) TodoServiceError) TodoServiceError {
+	if service.inTx {
+		return f(service)
+	}
+	defer service.inCommitOrder()()
+	var failed TodoServiceError
+	var pending []events.Event
+	err := service.Repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		// a transaction may be retried, so only the events of the last
+		// attempt count
+		pending = nil
+		within := &todoServiceImpl{
+			Repo:           todos,
+			Lists:          lists,
+			Clock:          service.Clock,
+			TrashRetention: service.TrashRetention,
+			Events:         service.Events,
+			Actor:          service.Actor,
+			inTx:           true,
+			pending:        &pending,
+		}
+		if failed = f(within); failed != nil {
+			return failed
+		}
+		return nil
+	})
+	if failed != nil {
+		return failed
+	} else if err != nil {
+		// the transaction could not be begun or committed
+		return TodoStorageError{Cause: err}
+	}
+	for _, event := range pending {
+		service.Events.Publish(event)
+	}
+	return nil
+}
+
+// inCommitOrder waits for the changes under way to be made and published,
+// returning what to call once those about to be made have been too. Within
+// a transaction, there is nothing to wait for: the transaction already is.
+func (service *todoServiceImpl) inCommitOrder() func() {
+	if service.inTx || service.commits == nil {
+		return func() {}
+	}
+	service.commits.Lock()
+	return service.commits.Unlock
+}
+
+// change returns what the events about a change made to the given Todo say
+// about it
+func (service *todoServiceImpl) change(todo domain.Todo) events.Change {
+	return events.Change{Todo: todo, Actor: service.Actor, At: service.now()}
+}
+
+// publish publishes the given event once the transaction it happened in is
+// committed, or right away outside of one
+func (service *todoServiceImpl) publish(event events.Event) {
+	if service.Events == nil {
+		return
+	}
+	if service.pending != nil {
+		*service.pending = append(*service.pending, event)
+		return
+	}
+	service.Events.Publish(event)
+}
+
+// moveTarget returns the Todo with the given id, which a Todo is being
+// moved next to
+func (service *todoServiceImpl) moveTarget(todoId domain.TodoID) (domain.Todo, TodoServiceError) {
+	target, err := service.Repo.Get(&todoId)
+	if _, notFound := err.(domain.TodoNotFound); notFound {
+		return domain.Todo{}, TodoMoveTargetNotFound{TargetID: todoId}
+	} else if err != nil {
+		return domain.Todo{}, fromRepoError(err)
+	}
+	return target, nil
+}
+
+// adjacentPosition returns the Position of the Todo that comes right after
+// the given one in the order Todos are arranged in by hand, or right before
+// it if backwards, skipping the Todo with the given id. It returns an empty
+// Position if there is no such Todo.
+func (service *todoServiceImpl) adjacentPosition(todo *domain.Todo, skipped domain.TodoID, backwards bool) (string, TodoServiceError) {
+	query := domain.TodoQuery{
+		Sort:  []domain.SortKey{{Field: domain.SortByPosition, Descending: backwards}, {Field: domain.SortByID, Descending: backwards}},
+		After: domain.CursorFor(todo),
+		Limit: 2,
+	}
+	page, err := service.Repo.List(&query)
+	if err != nil {
+		return "", fromRepoError(err)
+	}
+	for _, adjacent := range page.Todos {
+		if adjacent.ID != skipped {
+			return adjacent.Position, nil
+		}
+	}
+	return "", nil
+}
+
+// Subtrees returns the given Todos along with all of their descendants
+func (service *todoServiceImpl) Subtrees(todos []domain.Todo) ([]domain.TodoTree, TodoServiceError) {
+	return service.subtrees(todos, make(map[domain.TodoID]bool))
+}
+
+// subtrees does the work of Subtrees, skipping Todos that have already been
+// visited so that it can't go round in circles
+func (service *todoServiceImpl) subtrees(todos []domain.Todo, visited map[domain.TodoID]bool) ([]domain.TodoTree, TodoServiceError) {
+	var trees []domain.TodoTree
+	for _, todo := range todos {
+		if visited[todo.ID] {
+			continue
+		}
+		visited[todo.ID] = true
+		children, err := service.children(todo.ID)
+		if err != nil {
+			return nil, err
+		}
+		childTrees, err := service.subtrees(children, visited)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, domain.TodoTree{Todo: todo, Children: childTrees})
+	}
+	return trees, nil
+}
+
+// children lists all the children of the Todo with the given id
+func (service *todoServiceImpl) children(todoId domain.TodoID) ([]domain.Todo, TodoServiceError) {
+	if page, err := service.Repo.List(&domain.TodoQuery{ParentID: &todoId}); err == nil {
+		return page.Todos, nil
+	} else {
+		return nil, fromRepoError(err)
+	}
+}
+
+// checkParent makes sure that the Todo with the given parent id, if any,
+// exists, and that it is not the Todo with the given id (zero for new
+// Todos) or one of its descendants
+func (service *todoServiceImpl) checkParent(todoId domain.TodoID, parentId *domain.TodoID) TodoServiceError {
+	if parentId == nil {
+		return nil
+	}
+	visited := make(map[domain.TodoID]bool)
+	for ancestorId := *parentId; !visited[ancestorId]; {
+		if ancestorId == todoId {
+			return TodoParentCycle{ID: todoId, ParentID: *parentId}
+		}
+		visited[ancestorId] = true
+		ancestor, err := service.Repo.Get(&ancestorId)
+		if _, notFound := err.(domain.TodoNotFound); notFound && ancestorId == *parentId {
+			return TodoParentNotFound{ParentID: *parentId}
+		} else if notFound {
+			// an ancestor further up went away; that is not our problem
+			return nil
+		} else if err != nil {
+			return fromRepoError(err)
+		}
+		if ancestor.ParentID == nil {
+			return nil
+		}
+		ancestorId = *ancestor.ParentID
+	}
+	return nil
+}
+
+// checkList makes sure that the list with the given id, if any, exists
+func (service *todoServiceImpl) checkList(listId *domain.TodoListID) TodoServiceError {
+	if listId == nil {
+		return nil
+	}
+	if _, err := service.Lists.Get(listId); err != nil {
+		if _, notFound := err.(domain.TodoListNotFound); notFound {
+			return TodoListNotFound{ListID: *listId}
+		}
+		return fromListRepoError(err)
+	}
+	return nil
+}
+
+// checkDependencies makes sure that the Todos with the given ids all exist,
+// and that none of them is the Todo with the given id (zero for new Todos)
+// or depends on it, directly or not
+func (service *todoServiceImpl) checkDependencies(todoId domain.TodoID, dependsOn []domain.TodoID) TodoServiceError {
+	visited := make(map[domain.TodoID]bool)
+	for _, dependencyId := range domain.NormaliseIDs(dependsOn) {
+		if dependencyId == todoId {
+			return TodoDependencyCycle{ID: todoId, DependencyID: dependencyId}
+		}
+		dependency, err := service.Repo.Get(&dependencyId)
+		if _, notFound := err.(domain.TodoNotFound); notFound {
+			return TodoDependencyNotFound{DependencyID: dependencyId}
+		} else if err != nil {
+			return fromRepoError(err)
+		}
+		// new Todos can't be depended on yet, so there is nothing more to check
+		if todoId == 0 {
+			continue
+		}
+		if reaches, err := service.reaches(dependency, todoId, visited); err != nil {
+			return err
+		} else if reaches {
+			return TodoDependencyCycle{ID: todoId, DependencyID: dependencyId}
+		}
+	}
+	return nil
+}
+
+// reaches returns true if the given Todo depends on the one with the given
+// id, directly or not, skipping Todos that have already been visited
+func (service *todoServiceImpl) reaches(todo domain.Todo, todoId domain.TodoID, visited map[domain.TodoID]bool) (bool, TodoServiceError) {
+	if visited[todo.ID] {
+		return false, nil
+	}
+	visited[todo.ID] = true
+	for _, dependencyId := range todo.DependsOn {
+		if dependencyId == todoId {
+			return true, nil
+		}
+		dependency, err := service.Repo.Get(&dependencyId)
+		if _, notFound := err.(domain.TodoNotFound); notFound {
+			// a dependency further down went away; that is not our problem
+			continue
+		} else if err != nil {
+			return false, fromRepoError(err)
+		}
+		if reaches, err := service.reaches(dependency, todoId, visited); err != nil || reaches {
+			return reaches, err
+		}
+	}
+	return false, nil
+}
+
+// modify applies the given change to the currently persisted version of a
+// Todo, which must be at the given version unless that is zero. In that
+// case, the change is retried on top of newer versions if the Todo happens
+// to get changed by someone else in the meantime.
+func (service *todoServiceImpl) modify(todoId domain.TodoID, version domain.TodoVersion, change func(existing *domain.Todo)) (domain.Todo, TodoServiceError) {
+	defer service.inCommitOrder()()
+	for attempt := 1; ; attempt++ {
+		existing, err := service.Repo.Get(&todoId)
+		if err != nil {
+			return domain.Todo{}, fromRepoError(err)
+		}
+		if err := domain.CheckVersion(todoId, version, existing.Version); err != nil {
+			return domain.Todo{}, fromRepoError(err)
+		}
+		toUpdate := existing
+		change(&toUpdate)
+		toUpdate.ID = existing.ID
+		toUpdate.Version = existing.Version
+		updated, err := service.Repo.Update(&toUpdate)
+		if err == nil {
+			change := service.change(updated)
+			change.Previous = &existing
+			service.publish(events.TodoUpdated{Change: change})
+			return updated, nil
+		}
+		if _, conflict := err.(domain.TodoVersionConflict); !conflict || version != 0 || attempt >= maxModifyAttempts {
+			return domain.Todo{}, fromRepoError(err)
+		}
+	}
+}
+
+// completionTime works out when a Todo with the given completion status was
+// completed, keeping the timestamp of the existing Todo (if any) when it was
+// already completed
+func (service *todoServiceImpl) completionTime(completed bool, existing *domain.Todo) *time.Time {
+	if !completed {
+		return nil
+	}
+	if existing != nil && existing.Completed && existing.CompletedAt != nil {
+		return existing.CompletedAt
+	}
+	now := service.now()
+	return &now
+}
+
+func (service *todoServiceImpl) now() time.Time {
+	if service.Clock == nil {
+		return time.Now().UTC()
+	}
+	return service.Clock().UTC()
+}
+
+// fromRepoError translates errors coming out of a domain.TodoRepo into
+// TodoServiceErrors
+func fromRepoError(err domain.TodoRepoError) TodoServiceError {
+	switch e := err.(type) {
+	case domain.TodoNotFound:
+		return TodoNotFound{ID: e.ID}
+	case domain.TodoVersionConflict:
+		return TodoVersionConflict{ID: e.ID, Expected: e.Expected, Actual: e.Actual}
+	default:
+		return TodoStorageError{Cause: err}
+	}
+}
+
+// Due dates have to fit in the range of time.Time.UnixNano, which is how
+// some repos store them
+var (
+	earliestDueAt = time.Unix(0, math.MinInt64)
+	latestDueAt   = time.Unix(0, math.MaxInt64)
+)
+
+// checkDueAt returns a TodoDueAtError if the given due date can't be stored
+func checkDueAt(dueAt *time.Time) TodoServiceError {
+	if dueAt != nil && (dueAt.Before(earliestDueAt) || dueAt.After(latestDueAt)) {
+		return TodoDueAtError{DueAt: *dueAt}
+	}
+	return nil
+}
+
+// maxTagLength is the longest a tag can be, in characters
+const maxTagLength = 64
+
+// checkTags returns a TodoTagError for the first of the given tags that is
+// empty, too long, or has control characters in it
+func checkTags(tags []string) TodoServiceError {
+	for _, tag := range tags {
+		if len(tag) == 0 || utf8.RuneCountInString(tag) > maxTagLength {
+			return TodoTagError{Tag: tag}
+		}
+		for _, r := range tag {
+			if unicode.IsControl(r) {
+				return TodoTagError{Tag: tag}
+			}
+		}
+	}
+	return nil
+}
+
+// normaliseRecurrence returns the given recurrence rule the way
+// domain.Recurrence writes it, or a TodoRecurrenceError if it can't be parsed
+func normaliseRecurrence(rule string) (string, TodoServiceError) {
+	if len(rule) == 0 {
+		return "", nil
+	}
+	if recurrence, err := domain.ParseRecurrence(rule); err == nil {
+		return recurrence.String(), nil
+	} else {
+		return "", TodoRecurrenceError{Recurrence: rule, Cause: err}
+	}
+}
+
+// maxSearchTerms is the most terms a search can have, since each of them
+// has to be looked up separately
+const maxSearchTerms = 32
+
 // <-- errors
 
 type TodoServiceError interface {
@@ -80,16 +1172,195 @@ type TodoDataError struct {
 	Task string
 }
 
+// TodoDueAtError is returned when a due date is too far in the past or
+// future to make any sense
+type TodoDueAtError struct {
+	DueAt time.Time
+}
+
+// TodoTagError is returned when a tag is empty, too long, or has
+// control characters in it
+type TodoTagError struct {
+	Tag string
+}
+
+// TodoPriorityError is returned when a Priority is not one of the
+// known ones
+type TodoPriorityError struct {
+	Priority domain.Priority
+}
+
+// TodoRecurrenceError is returned when a recurrence rule can't be parsed,
+// or uses parts of RFC 5545 that are not supported
+type TodoRecurrenceError struct {
+	Recurrence string
+	Cause      error
+}
+
+// TodoSearchError is returned when a search has no terms to look for,
+// or too many of them
+type TodoSearchError struct {
+	Text string
+}
+
+// TodoParentNotFound is returned when the parent given for a Todo does
+// not exist
+type TodoParentNotFound struct {
+	ParentID domain.TodoID
+}
+
+// TodoParentCycle is returned when the parent given for a Todo is the Todo
+// itself, or one of its descendants
+type TodoParentCycle struct {
+	ID       domain.TodoID
+	ParentID domain.TodoID
+}
+
+// TodoListNotFound is returned when the list given for a Todo does not
+// exist
+type TodoListNotFound struct {
+	ListID domain.TodoListID
+}
+
+// TodoDependencyNotFound is returned when a Todo is made to depend on one
+// that does not exist
+type TodoDependencyNotFound struct {
+	DependencyID domain.TodoID
+}
+
+// TodoDependencyCycle is returned when a Todo is made to depend on itself,
+// or on a Todo that depends on it
+type TodoDependencyCycle struct {
+	ID           domain.TodoID
+	DependencyID domain.TodoID
+}
+
+// TodoMoveError is returned when a Todo can't be moved where it was asked
+// to go
+type TodoMoveError struct {
+	ID     domain.TodoID
+	Reason string
+}
+
+// TodoMoveTargetNotFound is returned when a Todo is moved next to one that
+// does not exist
+type TodoMoveTargetNotFound struct {
+	TargetID domain.TodoID
+}
+
 type TodoNotFound struct {
 	ID domain.TodoID
 }
 
+// TrashedTodoNotFound is returned when restoring or purging a Todo that is
+// not in the trash
+type TrashedTodoNotFound struct {
+	ID domain.TodoID
+}
+
+// TodoRevisionNotFound is returned when reverting a Todo to a revision it
+// does not have
+type TodoRevisionNotFound struct {
+	ID  domain.TodoID
+	Rev uint
+}
+
+// TodoVersionConflict is returned when a Todo is not at the version
+// it was expected to be
+type TodoVersionConflict struct {
+	ID       domain.TodoID
+	Expected domain.TodoVersion
+	Actual   domain.TodoVersion
+}
+
+// TodoBatchError is returned when one of the operations in a batch fails,
+// so that none of them were made
+type TodoBatchError struct {
+	// Index is where the operation that failed is in the batch
+	Index int
+	Cause TodoServiceError
+}
+
+// TodoStorageError is returned when the underlying repo failed for
+// reasons that have nothing to do with the data given
+type TodoStorageError struct {
+	Cause error
+}
+
 func (err TodoDataError) Error() string {
 	return fmt.Sprintf("This task was empty: [%s]", err.Task)
 }
 
+func (err TodoDueAtError) Error() string {
+	return fmt.Sprintf("This due date is out of range: [%v]", err.DueAt)
+}
+
+func (err TodoTagError) Error() string {
+	return fmt.Sprintf("Tags must be between 1 and %d characters, without control characters: %q", maxTagLength, err.Tag)
+}
+
+func (err TodoPriorityError) Error() string {
+	return fmt.Sprintf("This priority does not exist: [%v]", err.Priority)
+}
+
+func (err TodoRecurrenceError) Error() string {
+	return fmt.Sprintf("This recurrence rule is invalid: %q (%v)", err.Recurrence, err.Cause)
+}
+
+func (err TodoSearchError) Error() string {
+	return fmt.Sprintf("Searches must have between 1 and %d words: %q", maxSearchTerms, err.Text)
+}
+
+func (err TodoParentNotFound) Error() string {
+	return fmt.Sprintf("This parent does not exist: [%v]", err.ParentID)
+}
+
+func (err TodoParentCycle) Error() string {
+	return fmt.Sprintf("[%v] can't be the parent of [%v], as it is the same Todo or one of its subtasks", err.ParentID, err.ID)
+}
+
+func (err TodoListNotFound) Error() string {
+	return fmt.Sprintf("This list does not exist: [%v]", err.ListID)
+}
+
+func (err TodoDependencyNotFound) Error() string {
+	return fmt.Sprintf("This dependency does not exist: [%v]", err.DependencyID)
+}
+
+func (err TodoDependencyCycle) Error() string {
+	return fmt.Sprintf("[%v] can't depend on [%v], as it is the same Todo or depends on it", err.ID, err.DependencyID)
+}
+
+func (err TodoMoveError) Error() string {
+	return fmt.Sprintf("[%v] can't be moved there, as %s", err.ID, err.Reason)
+}
+
+func (err TodoMoveTargetNotFound) Error() string {
+	return fmt.Sprintf("The todo to move next to does not exist: [%v]", err.TargetID)
+}
+
 func (err TodoNotFound) Error() string {
 	return fmt.Sprintf("This id does not exist: [%v]", err.ID)
 }
 
+func (err TrashedTodoNotFound) Error() string {
+	return fmt.Sprintf("This id is not in the trash: [%v]", err.ID)
+}
+
+func (err TodoRevisionNotFound) Error() string {
+	return fmt.Sprintf("This revision does not exist: [%v] of [%v]", err.Rev, err.ID)
+}
+
+func (err TodoVersionConflict) Error() string {
+	return fmt.Sprintf("This todo has changed: [%v] is at version [%v], not [%v]", err.ID, err.Actual, err.Expected)
+}
+
+func (err TodoBatchError) Error() string {
+	return fmt.Sprintf("Operation [%d] of the batch failed, so none were made: %v", err.Index, err.Cause)
+}
+
+func (err TodoStorageError) Error() string {
+	return fmt.Sprintf("Could not access storage: [%v]", err.Cause)
+}
+
 //     errors  -->
@@ -1,19 +1,26 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/events"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateValidData(t *testing.T) {
 	mockRepo := mockRepo{}
-	mockRepo.create = func(newTodo *domain.NewTodo) domain.Todo {
+	mockRepo.create = func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
 		return domain.Todo{
 			ID:   domain.TodoID(123),
 			Task: newTodo.Task,
-		}
+		}, nil
 	}
 	service := todoServiceImpl{Repo: &mockRepo}
 	newTodo := domain.NewTodo{Task: "do something"}
@@ -31,8 +38,27 @@ func TestCreateInvalidData(t *testing.T) {
 	assert.True(t, err != nil)
 }
 
+func TestCreateCompletedStampsTime(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.create = func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{
+			ID:          domain.TodoID(123),
+			Task:        newTodo.Task,
+			Completed:   newTodo.Completed,
+			CompletedAt: newTodo.CompletedAt,
+		}, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo, Clock: fixedClock}
+	created, err := service.Create(&domain.NewTodo{Task: "done already", Completed: true})
+	assert.Nil(t, err)
+	assert.Equal(t, fixedTime, *created.CompletedAt)
+}
+
 func TestUpdateValidData(t *testing.T) {
 	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Task: "old"}, nil
+	}
 	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
 		return *todo, nil
 	}
@@ -52,8 +78,37 @@ func TestUpdateInvalidData(t *testing.T) {
 	assert.True(t, err != nil)
 }
 
+func TestUpdateKeepsCompletionTime(t *testing.T) {
+	mockRepo := mockRepo{}
+	completedAt := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Task: "old", Completed: true, CompletedAt: &completedAt}, nil
+	}
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		return *todo, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo, Clock: fixedClock}
+	updated, err := service.Update(&domain.Todo{ID: domain.TodoID(123), Task: "new", Completed: true})
+	assert.Nil(t, err)
+	assert.Equal(t, &completedAt, updated.CompletedAt)
+}
+
+func TestUpdateGetNotFound(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{}, domain.TodoNotFound{ID: *id}
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	_, err := service.Update(&domain.Todo{ID: domain.TodoID(123), Task: "hello"})
+	assert.Equal(t, uint(0), mockRepo.updateCalled)
+	assert.Equal(t, TodoNotFound{ID: domain.TodoID(123)}, err)
+}
+
 func TestUpdateNotFound(t *testing.T) {
 	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Task: "old"}, nil
+	}
 	stored := false
 	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
 		stored = true
@@ -70,13 +125,93 @@ func TestUpdateNotFound(t *testing.T) {
 func TestList(t *testing.T) {
 	mockRepo := mockRepo{}
 	existing := domain.Todo{ID: domain.TodoID(123), Task: "hello"}
-	mockRepo.list = func() []domain.Todo {
-		return []domain.Todo{existing}
+	mockRepo.list = func(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+		return domain.TodoPage{Todos: []domain.Todo{existing}}, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	listed, err := service.List(&domain.TodoQuery{})
+	assert.Equal(t, uint(1), mockRepo.listCalled)
+	assert.ElementsMatch(t, []domain.Todo{existing}, listed.Todos)
+	assert.True(t, err == nil)
+}
+
+func TestListOverdueAsOfNow(t *testing.T) {
+	mockRepo := mockRepo{}
+	var listedWith *domain.TodoQuery
+	mockRepo.list = func(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+		listedWith = query
+		return domain.TodoPage{}, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo, Clock: fixedClock}
+	yes := true
+	_, err := service.List(&domain.TodoQuery{Overdue: &yes})
+	assert.Nil(t, err)
+	assert.Equal(t, fixedTime, listedWith.OverdueAsOf)
+}
+
+func TestListDueDateOutOfRange(t *testing.T) {
+	mockRepo := mockRepo{}
+	service := todoServiceImpl{Repo: &mockRepo}
+	longAgo := time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := service.List(&domain.TodoQuery{DueAfter: &longAgo})
+	assert.Equal(t, uint(0), mockRepo.listCalled)
+	assert.Equal(t, TodoDueAtError{DueAt: longAgo}, err)
+}
+
+func TestListStorageFailure(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.list = func(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+		return domain.TodoPage{}, domain.TodoRepoFailure{Cause: errors.New("disk on fire")}
 	}
 	service := todoServiceImpl{Repo: &mockRepo}
-	listed := service.List()
+	_, err := service.List(&domain.TodoQuery{})
 	assert.Equal(t, uint(1), mockRepo.listCalled)
-	assert.ElementsMatch(t, []domain.Todo{existing}, listed)
+	assert.IsType(t, TodoStorageError{}, err)
+}
+
+func TestCreateWithDueDate(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.create = func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: domain.TodoID(123), Task: newTodo.Task, DueAt: newTodo.DueAt}, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	dueAt := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	created, err := service.Create(&domain.NewTodo{Task: "do something", DueAt: &dueAt})
+	assert.Nil(t, err)
+	assert.Equal(t, &dueAt, created.DueAt)
+}
+
+func TestCreateDueDateOutOfRange(t *testing.T) {
+	mockRepo := mockRepo{}
+	service := todoServiceImpl{Repo: &mockRepo}
+	for _, dueAt := range []time.Time{{}, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)} {
+		d := dueAt
+		_, err := service.Create(&domain.NewTodo{Task: "do something", DueAt: &d})
+		assert.Equal(t, TodoDueAtError{DueAt: dueAt}, err)
+	}
+	assert.Equal(t, uint(0), mockRepo.createCalled)
+}
+
+func TestUpdateDueDateOutOfRange(t *testing.T) {
+	mockRepo := mockRepo{}
+	service := todoServiceImpl{Repo: &mockRepo}
+	dueAt := time.Time{}
+	_, err := service.Update(&domain.Todo{ID: domain.TodoID(123), Task: "do something", DueAt: &dueAt})
+	assert.Equal(t, TodoDueAtError{DueAt: dueAt}, err)
+	assert.Equal(t, uint(0), mockRepo.getCalled)
+	assert.Equal(t, uint(0), mockRepo.updateCalled)
+}
+
+func TestCreateStorageFailure(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.create = func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{}, domain.TodoRepoFailure{Cause: errors.New("disk on fire")}
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	newTodo := domain.NewTodo{Task: "do something"}
+	_, err := service.Create(&newTodo)
+	assert.Equal(t, uint(1), mockRepo.createCalled)
+	assert.IsType(t, TodoStorageError{}, err)
 }
 
 func TestGetOk(t *testing.T) {
@@ -107,44 +242,797 @@ func TestGetNotFound(t *testing.T) {
 
 func TestDeleteOk(t *testing.T) {
 	mockRepo := mockRepo{}
-	mockRepo.delete = func(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Version: 1, Task: "old"}, nil
+	}
+	mockRepo.list = func(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+		return domain.TodoPage{}, nil
+	}
+	mockRepo.delete = func(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
 		return true, nil
 	}
 	service := todoServiceImpl{Repo: &mockRepo}
 	id := domain.TodoID(123)
-	deleted, err := service.Delete(&id)
+	deleted, err := service.Delete(&id, 0, OrphanChildren)
 	assert.True(t, deleted)
 	assert.True(t, err == nil)
 }
 
 func TestDeleteNotFound(t *testing.T) {
 	mockRepo := mockRepo{}
-	mockRepo.delete = func(id *domain.TodoID) (bool, domain.TodoRepoError) {
-		return false, domain.TodoNotFound{ID: *id}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{}, domain.TodoNotFound{ID: *id}
 	}
 	service := todoServiceImpl{Repo: &mockRepo}
 	id := domain.TodoID(123)
-	deleted, err := service.Delete(&id)
+	deleted, err := service.Delete(&id, 0, OrphanChildren)
 	assert.False(t, deleted)
-	assert.True(t, err != nil)
+	assert.Equal(t, TodoNotFound{ID: id}, err)
+	assert.Equal(t, uint(0), mockRepo.deleteCalled)
+}
+
+func TestUpdateStaleVersion(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Version: 3, Task: "old"}, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	_, err := service.Update(&domain.Todo{ID: domain.TodoID(123), Version: 2, Task: "new"})
+	assert.Equal(t, uint(0), mockRepo.updateCalled)
+	assert.Equal(t, TodoVersionConflict{ID: domain.TodoID(123), Expected: 2, Actual: 3}, err)
+}
+
+func TestUpdateRetriesConcurrentChanges(t *testing.T) {
+	mockRepo := mockRepo{}
+	var version domain.TodoVersion = 1
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Version: version, Task: "old"}, nil
+	}
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		if mockRepo.updateCalled == 0 {
+			// someone else got there first
+			version++
+			return domain.Todo{}, domain.TodoVersionConflict{ID: todo.ID, Expected: todo.Version, Actual: version}
+		}
+		updated := *todo
+		updated.Version++
+		return updated, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	updated, err := service.Update(&domain.Todo{ID: domain.TodoID(123), Task: "new"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), mockRepo.updateCalled)
+	assert.Equal(t, domain.TodoVersion(3), updated.Version)
+}
+
+func TestDeleteStaleVersion(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Version: 2, Task: "old"}, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	id := domain.TodoID(123)
+	_, err := service.Delete(&id, 1, DeleteChildren)
+	assert.Equal(t, TodoVersionConflict{ID: id, Expected: 1, Actual: 2}, err)
+	assert.Equal(t, uint(0), mockRepo.deleteCalled)
+	assert.Equal(t, uint(0), mockRepo.listCalled)
+}
+
+func TestCompleteOpen(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Task: "hello"}, nil
+	}
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		return *todo, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo, Clock: fixedClock}
+	id := domain.TodoID(123)
+	completed, err := service.Complete(&id)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), mockRepo.updateCalled)
+	assert.True(t, completed.Completed)
+	assert.Equal(t, fixedTime, *completed.CompletedAt)
+}
+
+func TestCompleteAlreadyCompleted(t *testing.T) {
+	mockRepo := mockRepo{}
+	completedAt := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	existing := domain.Todo{ID: domain.TodoID(123), Task: "hello", Completed: true, CompletedAt: &completedAt}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return existing, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo, Clock: fixedClock}
+	completed, err := service.Complete(&existing.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), mockRepo.updateCalled)
+	assert.Equal(t, existing, completed)
+}
+
+func TestCompleteNotFound(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{}, domain.TodoNotFound{ID: *id}
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	id := domain.TodoID(123)
+	_, err := service.Complete(&id)
+	assert.Equal(t, TodoNotFound{ID: id}, err)
+}
+
+func TestReopenCompleted(t *testing.T) {
+	mockRepo := mockRepo{}
+	completedAt := time.Date(2019, 8, 20, 0, 0, 0, 0, time.UTC)
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Task: "hello", Completed: true, CompletedAt: &completedAt}, nil
+	}
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		return *todo, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo, Clock: fixedClock}
+	id := domain.TodoID(123)
+	reopened, err := service.Reopen(&id)
+	assert.Nil(t, err)
+	assert.False(t, reopened.Completed)
+	assert.Nil(t, reopened.CompletedAt)
 }
 
 // mocks
 
+var fixedTime = time.Date(2019, 8, 21, 9, 0, 0, 0, time.UTC)
+
+func TestCreateInvalidTags(t *testing.T) {
+	mockRepo := mockRepo{}
+	service := todoServiceImpl{Repo: &mockRepo}
+	for _, tag := range []string{"", strings.Repeat("x", 65), "new\nline"} {
+		_, err := service.Create(&domain.NewTodo{Task: "do something", Tags: []string{"ok", tag}})
+		assert.Equal(t, TodoTagError{Tag: tag}, err)
+	}
+	assert.Equal(t, uint(0), mockRepo.createCalled)
+}
+
+func TestCreateInvalidPriority(t *testing.T) {
+	mockRepo := mockRepo{}
+	service := todoServiceImpl{Repo: &mockRepo}
+	_, err := service.Create(&domain.NewTodo{Task: "do something", Priority: domain.Priority(42)})
+	assert.Equal(t, TodoPriorityError{Priority: domain.Priority(42)}, err)
+	assert.Equal(t, uint(0), mockRepo.createCalled)
+}
+
+func TestUpdatePriority(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Version: 1, Task: "hello"}, nil
+	}
+	var updatedWith *domain.Todo
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		updatedWith = todo
+		return *todo, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	_, err := service.Update(&domain.Todo{ID: domain.TodoID(123), Task: "hello", Priority: domain.HighPriority})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.HighPriority, updatedWith.Priority)
+}
+
+func TestAddTags(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Version: 1, Task: "hello", Tags: []string{"home", "work"}}, nil
+	}
+	var updatedWith *domain.Todo
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		updatedWith = todo
+		return *todo, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	id := domain.TodoID(123)
+	_, err := service.AddTags(&id, []string{"work", "errands"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"errands", "home", "work"}, updatedWith.Tags)
+	assert.Equal(t, domain.TodoVersion(1), updatedWith.Version)
+}
+
+func TestAddInvalidTags(t *testing.T) {
+	mockRepo := mockRepo{}
+	service := todoServiceImpl{Repo: &mockRepo}
+	id := domain.TodoID(123)
+	_, err := service.AddTags(&id, []string{""})
+	assert.Equal(t, TodoTagError{}, err)
+	assert.Equal(t, uint(0), mockRepo.getCalled)
+}
+
+func TestRemoveTags(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: *id, Version: 1, Task: "hello", Tags: []string{"errands", "home", "work"}}, nil
+	}
+	var updatedWith *domain.Todo
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		updatedWith = todo
+		return *todo, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	id := domain.TodoID(123)
+	_, err := service.RemoveTags(&id, []string{"work", "nope", "errands"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"home"}, updatedWith.Tags)
+}
+
+func TestListTags(t *testing.T) {
+	mockRepo := mockRepo{}
+	tagCounts := []domain.TagCount{{Tag: "home", Count: 2}}
+	mockRepo.listTags = func() ([]domain.TagCount, domain.TodoRepoError) {
+		return tagCounts, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	listed, err := service.ListTags()
+	assert.Nil(t, err)
+	assert.Equal(t, tagCounts, listed)
+}
+
+func TestSearch(t *testing.T) {
+	mockRepo := mockRepo{}
+	results := []domain.TodoSearchResult{{Todo: domain.Todo{ID: domain.TodoID(1), Task: "Buy milk"}, Score: 0.5}}
+	mockRepo.search = func(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+		return results, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	found, err := service.Search(&domain.TodoSearch{Text: "milk"})
+	assert.Nil(t, err)
+	assert.Equal(t, results, found)
+}
+
+func TestSearchWithoutTerms(t *testing.T) {
+	mockRepo := mockRepo{}
+	service := todoServiceImpl{Repo: &mockRepo}
+	for _, text := range []string{"", " ?! ", strings.Repeat("word ", maxSearchTerms+1)} {
+		_, err := service.Search(&domain.TodoSearch{Text: text})
+		assert.Equal(t, TodoSearchError{Text: text}, err)
+	}
+	assert.Equal(t, uint(0), mockRepo.searchCalled)
+}
+
+func TestSearchStorageFailure(t *testing.T) {
+	mockRepo := mockRepo{}
+	mockRepo.search = func(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+		return nil, domain.TodoRepoFailure{Cause: errors.New("disk on fire")}
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	_, err := service.Search(&domain.TodoSearch{Text: "milk"})
+	assert.IsType(t, TodoStorageError{}, err)
+}
+
+func TestCreateWithParent(t *testing.T) {
+	parentID := domain.TodoID(1)
+	mockRepo := mockRepoWith(domain.Todo{ID: parentID, Task: "parent"})
+	mockRepo.create = func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{ID: 2, Task: newTodo.Task, ParentID: newTodo.ParentID}, nil
+	}
+	service := todoServiceImpl{Repo: mockRepo}
+	created, err := service.Create(&domain.NewTodo{Task: "child", ParentID: &parentID})
+	assert.Nil(t, err)
+	assert.Equal(t, &parentID, created.ParentID)
+}
+
+func TestCreateWithMissingParent(t *testing.T) {
+	mockRepo := mockRepoWith()
+	service := todoServiceImpl{Repo: mockRepo}
+	parentID := domain.TodoID(1)
+	_, err := service.Create(&domain.NewTodo{Task: "child", ParentID: &parentID})
+	assert.Equal(t, TodoParentNotFound{ParentID: parentID}, err)
+	assert.Equal(t, uint(0), mockRepo.createCalled)
+}
+
+func TestUpdateParentCycle(t *testing.T) {
+	one, two, three := domain.TodoID(1), domain.TodoID(2), domain.TodoID(3)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Task: "grandparent"},
+		domain.Todo{ID: two, Task: "parent", ParentID: &one},
+		domain.Todo{ID: three, Task: "child", ParentID: &two},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	_, err := service.Update(&domain.Todo{ID: one, Task: "grandparent", ParentID: &three})
+	assert.Equal(t, TodoParentCycle{ID: one, ParentID: three}, err)
+	_, err = service.Update(&domain.Todo{ID: one, Task: "grandparent", ParentID: &one})
+	assert.Equal(t, TodoParentCycle{ID: one, ParentID: one}, err)
+	assert.Equal(t, uint(0), mockRepo.updateCalled)
+
+	moved, err := service.Update(&domain.Todo{ID: three, Task: "child", ParentID: &one})
+	assert.Nil(t, err)
+	assert.Equal(t, &one, moved.ParentID)
+}
+
+func TestListChildrenOfMissingTodo(t *testing.T) {
+	mockRepo := mockRepoWith()
+	service := todoServiceImpl{Repo: mockRepo}
+	parentID := domain.TodoID(1)
+	_, err := service.List(&domain.TodoQuery{ParentID: &parentID})
+	assert.Equal(t, TodoNotFound{ID: parentID}, err)
+}
+
+func TestSubtrees(t *testing.T) {
+	one, two := domain.TodoID(1), domain.TodoID(2)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Task: "grandparent"},
+		domain.Todo{ID: two, Task: "parent", ParentID: &one},
+		domain.Todo{ID: 3, Task: "child", ParentID: &two},
+		domain.Todo{ID: 4, Task: "other parent", ParentID: &one},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	grandparent, _ := mockRepo.Get(&one)
+	trees, err := service.Subtrees([]domain.Todo{grandparent})
+	assert.Nil(t, err)
+	expected := []domain.TodoTree{{
+		Todo: grandparent,
+		Children: []domain.TodoTree{
+			{Todo: mockRepo.todos[two], Children: []domain.TodoTree{{Todo: mockRepo.todos[3]}}},
+			{Todo: mockRepo.todos[4]},
+		},
+	}}
+	assert.Equal(t, expected, trees)
+}
+
+func TestDeleteOrphansChildren(t *testing.T) {
+	one, two := domain.TodoID(1), domain.TodoID(2)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Task: "grandparent"},
+		domain.Todo{ID: two, Task: "parent", ParentID: &one},
+		domain.Todo{ID: 3, Task: "child", ParentID: &two},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	deleted, err := service.Delete(&one, 0, OrphanChildren)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.NotContains(t, mockRepo.todos, one)
+	assert.Nil(t, mockRepo.todos[two].ParentID)
+	assert.Equal(t, &two, mockRepo.todos[3].ParentID)
+}
+
+func TestDeleteCascades(t *testing.T) {
+	one, two := domain.TodoID(1), domain.TodoID(2)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Task: "grandparent"},
+		domain.Todo{ID: two, Task: "parent", ParentID: &one},
+		domain.Todo{ID: 3, Task: "child", ParentID: &two},
+		domain.Todo{ID: 4, Task: "unrelated"},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	deleted, err := service.Delete(&one, 0, DeleteChildren)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []domain.TodoID{4}, mockRepo.ids())
+}
+
+func TestDeleteCascadesToSubtaskDependedOn(t *testing.T) {
+	one, two := domain.TodoID(1), domain.TodoID(2)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Version: 2, Task: "parent", DependsOn: []domain.TodoID{two}},
+		domain.Todo{ID: two, Version: 1, Task: "child", ParentID: &one},
+	)
+	// versions are checked and bumped, as real repos do
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		if err := domain.CheckVersion(todo.ID, todo.Version, mockRepo.todos[todo.ID].Version); err != nil {
+			return domain.Todo{}, err
+		}
+		todo.Version++
+		mockRepo.todos[todo.ID] = *todo
+		return *todo, nil
+	}
+	deleteTodo := mockRepo.delete
+	mockRepo.delete = func(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+		if err := domain.CheckVersion(*id, version, mockRepo.todos[*id].Version); err != nil {
+			return false, err
+		}
+		return deleteTodo(id, version)
+	}
+	publisher := &recordingPublisher{}
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock, Events: publisher}
+	deleted, err := service.Delete(&one, 2, DeleteChildren)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Empty(t, mockRepo.ids())
+	// the parent is deleted as it was once it no longer depended on its child
+	last := publisher.published[len(publisher.published)-1].Details().Todo
+	assert.Equal(t, one, last.ID)
+	assert.Empty(t, last.DependsOn)
+
+	// the version the parent was asked to be deleted at is still checked
+	mockRepo = mockRepoWith(domain.Todo{ID: one, Version: 2, Task: "parent"})
+	service = todoServiceImpl{Repo: mockRepo}
+	_, err = service.Delete(&one, 1, DeleteChildren)
+	assert.Equal(t, TodoVersionConflict{ID: one, Expected: 1, Actual: 2}, err)
+}
+
+func TestCreateWithMissingDependency(t *testing.T) {
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Task: "Buy paint"})
+	service := todoServiceImpl{Repo: mockRepo}
+	_, err := service.Create(&domain.NewTodo{Task: "Paint fence", DependsOn: []domain.TodoID{1, 2}})
+	assert.Equal(t, TodoDependencyNotFound{DependencyID: 2}, err)
+	assert.Equal(t, uint(0), mockRepo.createCalled)
+}
+
+func TestAddAndRemoveDependencies(t *testing.T) {
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: 1, Task: "Buy paint"},
+		domain.Todo{ID: 2, Task: "Buy brushes"},
+		domain.Todo{ID: 3, Task: "Paint fence", DependsOn: []domain.TodoID{2}},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	id := domain.TodoID(3)
+	added, err := service.AddDependencies(&id, []domain.TodoID{1, 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{1, 2}, added.DependsOn)
+	removed, err := service.RemoveDependencies(&id, []domain.TodoID{2, 4})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{1}, removed.DependsOn)
+}
+
+func TestAddDependenciesCycle(t *testing.T) {
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: 1, Task: "Buy paint"},
+		domain.Todo{ID: 2, Task: "Buy brushes", DependsOn: []domain.TodoID{1}},
+		domain.Todo{ID: 3, Task: "Paint fence", DependsOn: []domain.TodoID{2, 404}},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	one := domain.TodoID(1)
+	_, err := service.AddDependencies(&one, []domain.TodoID{3})
+	assert.Equal(t, TodoDependencyCycle{ID: 1, DependencyID: 3}, err)
+	_, err = service.AddDependencies(&one, []domain.TodoID{1})
+	assert.Equal(t, TodoDependencyCycle{ID: 1, DependencyID: 1}, err)
+	_, err = service.Update(&domain.Todo{ID: 2, Task: "Buy brushes", DependsOn: []domain.TodoID{3}})
+	assert.Equal(t, TodoDependencyCycle{ID: 2, DependencyID: 3}, err)
+	assert.Equal(t, uint(0), mockRepo.updateCalled)
+
+	// depending on the same Todo along different paths is fine
+	three := domain.TodoID(3)
+	added, err := service.AddDependencies(&three, []domain.TodoID{1})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{1, 2, 404}, added.DependsOn)
+}
+
+func TestReady(t *testing.T) {
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: 1, Task: "Buy paint", Completed: true},
+		domain.Todo{ID: 2, Task: "Buy brushes"},
+		domain.Todo{ID: 3, Task: "Paint fence", DependsOn: []domain.TodoID{1}},
+		domain.Todo{ID: 4, Task: "Clean brushes", DependsOn: []domain.TodoID{2}, Blocked: true},
+		domain.Todo{ID: 5, Task: "Pay bills", Priority: domain.UrgentPriority},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	ready, err := service.Ready()
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.Todo{mockRepo.todos[5], mockRepo.todos[2], mockRepo.todos[3]}, ready)
+}
+
+func TestDeleteDropsFromDependents(t *testing.T) {
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: 1, Task: "Buy paint"},
+		domain.Todo{ID: 2, Task: "Buy brushes"},
+		domain.Todo{ID: 3, Task: "Paint fence", DependsOn: []domain.TodoID{1, 2}},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	one := domain.TodoID(1)
+	deleted, err := service.Delete(&one, 0, OrphanChildren)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, []domain.TodoID{2}, mockRepo.todos[3].DependsOn)
+}
+
+func TestCreateOnList(t *testing.T) {
+	lists := mockListRepoWith(domain.TodoList{ID: 1, Name: "Chores"})
+	service := serviceWithLists(mockRepoWith(), lists)
+	one, two := domain.TodoListID(1), domain.TodoListID(2)
+	created, err := service.Create(&domain.NewTodo{Task: "Sweep", ListID: &one})
+	assert.Nil(t, err)
+	assert.Equal(t, &one, created.ListID)
+	_, err = service.Create(&domain.NewTodo{Task: "Mop", ListID: &two})
+	assert.Equal(t, TodoListNotFound{ListID: two}, err)
+}
+
+func TestUpdateMovesToList(t *testing.T) {
+	lists := mockListRepoWith(domain.TodoList{ID: 1, Name: "Chores"})
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Task: "Sweep"})
+	service := serviceWithLists(mockRepo, lists)
+	one, two := domain.TodoListID(1), domain.TodoListID(2)
+	updated, err := service.Update(&domain.Todo{ID: 1, Task: "Sweep", ListID: &one})
+	assert.Nil(t, err)
+	assert.Equal(t, &one, updated.ListID)
+	_, err = service.Update(&domain.Todo{ID: 1, Task: "Sweep", ListID: &two})
+	assert.Equal(t, TodoListNotFound{ListID: two}, err)
+	assert.Equal(t, &one, mockRepo.todos[1].ListID)
+}
+
+func TestListOnAbsentList(t *testing.T) {
+	mockRepo := mockRepoWith()
+	service := serviceWithLists(mockRepo, mockListRepoWith())
+	four := domain.TodoListID(4)
+	_, err := service.List(&domain.TodoQuery{ListID: &four})
+	assert.Equal(t, ListNotFound{ID: four}, err)
+	assert.Equal(t, uint(0), mockRepo.listCalled)
+}
+
+func TestCreateNormalisesRecurrence(t *testing.T) {
+	mockRepo := mockRepoWith()
+	service := todoServiceImpl{Repo: mockRepo}
+	created, err := service.Create(&domain.NewTodo{Task: "Water plants", Recurrence: "RRULE:freq=weekly;byday=su,sa,sa;interval=1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=SA,SU", created.Recurrence)
+}
+
+func TestCreateWithInvalidRecurrence(t *testing.T) {
+	for _, rule := range []string{
+		"WEEKLY",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=1;INTERVAL=2",
+		"FREQ=DAILY;COUNT=2;UNTIL=20191231",
+		"FREQ=DAILY;UNTIL=2019-12-31",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=1",
+	} {
+		mockRepo := mockRepoWith()
+		service := todoServiceImpl{Repo: mockRepo}
+		_, err := service.Create(&domain.NewTodo{Task: "Water plants", Recurrence: rule})
+		assert.IsType(t, TodoRecurrenceError{}, err, rule)
+		assert.Equal(t, uint(0), mockRepo.createCalled, rule)
+	}
+}
+
+func TestCompleteRecurring(t *testing.T) {
+	// a Tuesday
+	tuesday := time.Date(2019, 8, 20, 9, 0, 0, 0, time.UTC)
+	endOfJanuary := time.Date(2019, 1, 31, 9, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		rule     string
+		dueAt    time.Time
+		nextDue  time.Time
+		nextRule string
+	}{
+		{"FREQ=DAILY", tuesday, tuesday.AddDate(0, 0, 1), "FREQ=DAILY"},
+		{"FREQ=DAILY;INTERVAL=3", tuesday, tuesday.AddDate(0, 0, 3), "FREQ=DAILY;INTERVAL=3"},
+		{"FREQ=DAILY;BYDAY=MO,FR", tuesday, tuesday.AddDate(0, 0, 3), "FREQ=DAILY;BYDAY=MO,FR"},
+		{"FREQ=WEEKLY", tuesday, tuesday.AddDate(0, 0, 7), "FREQ=WEEKLY"},
+		{"FREQ=WEEKLY;BYDAY=TU,SA", tuesday, tuesday.AddDate(0, 0, 4), "FREQ=WEEKLY;BYDAY=TU,SA"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU", tuesday, tuesday.AddDate(0, 0, 13), "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU"},
+		{"FREQ=MONTHLY", tuesday, tuesday.AddDate(0, 1, 0), "FREQ=MONTHLY"},
+		{"FREQ=MONTHLY", endOfJanuary, endOfJanuary.AddDate(0, 2, 0), "FREQ=MONTHLY"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", tuesday, tuesday.AddDate(0, 0, 10), "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=MONTHLY;BYDAY=1MO", tuesday, tuesday.AddDate(0, 0, 13), "FREQ=MONTHLY;BYDAY=1MO"},
+		{"FREQ=MONTHLY;INTERVAL=2;COUNT=3", tuesday, tuesday.AddDate(0, 2, 0), "FREQ=MONTHLY;INTERVAL=2;COUNT=2"},
+		{"FREQ=WEEKLY;UNTIL=20190831T000000Z", tuesday, tuesday.AddDate(0, 0, 7), "FREQ=WEEKLY;UNTIL=20190831T000000Z"},
+	} {
+		mockRepo := mockRepoWith(domain.Todo{ID: 1, Task: "Water plants", DueAt: &c.dueAt, Tags: []string{"home"}, Recurrence: c.rule})
+		// completed on time, so that no occurrence gets skipped
+		dueAt := c.dueAt
+		service := todoServiceImpl{Repo: mockRepo, Clock: func() time.Time { return dueAt }}
+		id := domain.TodoID(1)
+		completed, err := service.Complete(&id)
+		assert.Nil(t, err, c.rule)
+		assert.True(t, completed.Completed, c.rule)
+		assert.Empty(t, completed.Recurrence, c.rule)
+		next := mockRepo.todos[2]
+		assert.Equal(t, "Water plants", next.Task, c.rule)
+		assert.Equal(t, []string{"home"}, next.Tags, c.rule)
+		assert.False(t, next.Completed, c.rule)
+		assert.Equal(t, &c.nextDue, next.DueAt, c.rule)
+		assert.Equal(t, c.nextRule, next.Recurrence, c.rule)
+	}
+}
+
+func TestCompleteLastOccurrence(t *testing.T) {
+	tuesday := time.Date(2019, 8, 20, 9, 0, 0, 0, time.UTC)
+	for _, rule := range []string{"FREQ=DAILY;COUNT=1", "FREQ=DAILY;UNTIL=20190820"} {
+		mockRepo := mockRepoWith(domain.Todo{ID: 1, Task: "Water plants", DueAt: &tuesday, Recurrence: rule})
+		service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock}
+		id := domain.TodoID(1)
+		completed, err := service.Complete(&id)
+		assert.Nil(t, err, rule)
+		assert.True(t, completed.Completed, rule)
+		assert.Equal(t, uint(0), mockRepo.createCalled, rule)
+	}
+}
+
+func TestCompleteRecurringWithoutDueDate(t *testing.T) {
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Task: "Water plants", Recurrence: "FREQ=DAILY"})
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock}
+	_, err := service.Update(&domain.Todo{ID: 1, Task: "Water plants", Completed: true, Recurrence: "FREQ=DAILY"})
+	assert.Nil(t, err)
+	assert.Equal(t, fixedTime.AddDate(0, 0, 1), *mockRepo.todos[2].DueAt)
+
+	// completing it again does not make it recur again
+	_, err = service.Update(&domain.Todo{ID: 1, Task: "Water plants", Completed: true, Recurrence: "FREQ=DAILY"})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{1, 2}, mockRepo.ids())
+}
+
+func TestCompleteOverdueRecurring(t *testing.T) {
+	monthAgo := fixedTime.AddDate(0, -1, 0)
+	for _, c := range []struct {
+		rule     string
+		nextDue  time.Time
+		nextRule string
+	}{
+		// due a month ago, so the 4 weekly occurrences since then are gone
+		{"FREQ=WEEKLY", monthAgo.AddDate(0, 0, 35), "FREQ=WEEKLY"},
+		{"FREQ=WEEKLY;COUNT=10", monthAgo.AddDate(0, 0, 35), "FREQ=WEEKLY;COUNT=5"},
+		{"FREQ=DAILY", fixedTime, "FREQ=DAILY"},
+	} {
+		mockRepo := mockRepoWith(domain.Todo{ID: 1, Task: "Water plants", DueAt: &monthAgo, Recurrence: c.rule})
+		service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock}
+		id := domain.TodoID(1)
+		_, err := service.Complete(&id)
+		assert.Nil(t, err, c.rule)
+		next := mockRepo.todos[2]
+		assert.Equal(t, &c.nextDue, next.DueAt, c.rule)
+		assert.Equal(t, c.nextRule, next.Recurrence, c.rule)
+		assert.False(t, next.IsOverdue(fixedTime), c.rule)
+	}
+
+	// the series runs out while catching up
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Task: "Water plants", DueAt: &monthAgo, Recurrence: "FREQ=WEEKLY;COUNT=3"})
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock}
+	id := domain.TodoID(1)
+	completed, err := service.Complete(&id)
+	assert.Nil(t, err)
+	assert.True(t, completed.Completed)
+	assert.Equal(t, uint(0), mockRepo.createCalled)
+}
+
+func fixedClock() time.Time {
+	return fixedTime
+}
+
+// storingMockRepo is a mockRepo that keeps Todos in a map, for tests that
+// need several of them to be consistent with each other
+type storingMockRepo struct {
+	mockRepo
+	todos map[domain.TodoID]domain.Todo
+	// trash holds the deleted Todos, all deleted at fixedTime
+	trash map[domain.TodoID]domain.TrashedTodo
+}
+
+func mockRepoWith(todos ...domain.Todo) *storingMockRepo {
+	r := &storingMockRepo{todos: make(map[domain.TodoID]domain.Todo), trash: make(map[domain.TodoID]domain.TrashedTodo)}
+	for _, todo := range todos {
+		r.todos[todo.ID] = todo
+	}
+	r.create = func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+		id := domain.TodoID(1)
+		if ids := r.ids(); len(ids) > 0 {
+			id = ids[len(ids)-1] + 1
+		}
+		r.todos[id] = domain.Todo{
+			ID: id, Version: 1, Task: newTodo.Task, Completed: newTodo.Completed, CompletedAt: newTodo.CompletedAt,
+			DueAt: newTodo.DueAt, Tags: newTodo.Tags, Priority: newTodo.Priority, ParentID: newTodo.ParentID,
+			DependsOn: newTodo.DependsOn, Recurrence: newTodo.Recurrence, ListID: newTodo.ListID, Position: newTodo.Position,
+		}
+		if len(newTodo.Position) == 0 {
+			last := ""
+			for _, todo := range r.todos {
+				if todo.Position > last {
+					last = todo.Position
+				}
+			}
+			todo := r.todos[id]
+			todo.Position, _ = domain.PositionBetween(last, "")
+			r.todos[id] = todo
+		}
+		return r.todos[id], nil
+	}
+	r.get = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		if todo, ok := r.todos[*id]; ok {
+			return todo, nil
+		}
+		return domain.Todo{}, domain.TodoNotFound{ID: *id}
+	}
+	r.list = func(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+		var listed []domain.Todo
+		for _, id := range r.ids() {
+			if todo := r.todos[id]; query.Matches(&todo) && query.IsAfter(&todo) {
+				listed = append(listed, todo)
+			}
+		}
+		sort.Slice(listed, func(i, j int) bool { return query.Compare(&listed[i], &listed[j]) < 0 })
+		if query.Limit > 0 && uint(len(listed)) > query.Limit+1 {
+			listed = listed[:query.Limit+1]
+		}
+		return domain.MkTodoPage(listed, query.Limit), nil
+	}
+	r.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		r.todos[todo.ID] = *todo
+		return *todo, nil
+	}
+	r.delete = func(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+		if todo, ok := r.todos[*id]; ok {
+			r.trash[*id] = domain.TrashedTodo{Todo: todo, DeletedAt: fixedTime}
+		}
+		delete(r.todos, *id)
+		return true, nil
+	}
+	r.restore = func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+		trashed, ok := r.trash[*id]
+		if !ok {
+			return domain.Todo{}, domain.TodoNotFound{ID: *id}
+		}
+		delete(r.trash, *id)
+		todo := trashed.Todo
+		todo.Version++
+		r.todos[*id] = todo
+		return todo, nil
+	}
+	r.purge = func(id *domain.TodoID) (bool, domain.TodoRepoError) {
+		if _, ok := r.trash[*id]; !ok {
+			return false, domain.TodoNotFound{ID: *id}
+		}
+		delete(r.trash, *id)
+		return true, nil
+	}
+	r.withinTx = func(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+		saved := make(map[domain.TodoID]domain.Todo)
+		for id, todo := range r.todos {
+			saved[id] = todo
+		}
+		savedTrash := make(map[domain.TodoID]domain.TrashedTodo)
+		for id, trashed := range r.trash {
+			savedTrash[id] = trashed
+		}
+		err := f(r, r.lists)
+		if err != nil {
+			r.todos = saved
+			r.trash = savedTrash
+		}
+		return err
+	}
+	return r
+}
+
+func (r *storingMockRepo) ids() []domain.TodoID {
+	var ids []domain.TodoID
+	for id := range r.todos {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 type mockRepo struct {
-	create       func(newTodo *domain.NewTodo) domain.Todo
-	createCalled uint
-	get          func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError)
-	getCalled    uint
-	list         func() []domain.Todo
-	listCalled   uint
-	delete       func(id *domain.TodoID) (bool, domain.TodoRepoError)
-	deleteCalled uint
-	update       func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError)
-	updateCalled uint
-}
-
-func (r *mockRepo) Create(newTodo *domain.NewTodo) domain.Todo {
+	create                   func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError)
+	createCalled             uint
+	get                      func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError)
+	getCalled                uint
+	list                     func(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError)
+	listCalled               uint
+	delete                   func(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError)
+	deleteCalled             uint
+	update                   func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError)
+	updateCalled             uint
+	listTags                 func() ([]domain.TagCount, domain.TodoRepoError)
+	listTagsCalled           uint
+	search                   func(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError)
+	searchCalled             uint
+	withinTx                 func(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error
+	withinTxCalled           uint
+	listTrash                func() ([]domain.TrashedTodo, domain.TodoRepoError)
+	listTrashCalled          uint
+	restore                  func(id *domain.TodoID) (domain.Todo, domain.TodoRepoError)
+	restoreCalled            uint
+	purge                    func(id *domain.TodoID) (bool, domain.TodoRepoError)
+	purgeCalled              uint
+	purgeDeletedBefore       func(before time.Time) (int, domain.TodoRepoError)
+	purgeDeletedBeforeCalled uint
+	history                  func(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError)
+	historyCalled            uint
+	asActor                  func(actor string) domain.TodoRepo
+	asActorCalled            uint
+	// lists is handed to functions run by WithinTx
+	lists domain.TodoListRepo
+}
+
+func (r *mockRepo) Create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
 	defer func() { r.createCalled++ }()
 	return r.create(newTodo)
 }
@@ -153,17 +1041,601 @@ func (r *mockRepo) Get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
 	defer func() { r.getCalled++ }()
 	return r.get(id)
 }
-func (r *mockRepo) List() []domain.Todo {
+func (r *mockRepo) List(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
 	defer func() { r.listCalled++ }()
-	return r.list()
+	return r.list(query)
 }
 
-func (r *mockRepo) Delete(id *domain.TodoID) (bool, domain.TodoRepoError) {
+func (r *mockRepo) Delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
 	defer func() { r.deleteCalled++ }()
-	return r.delete(id)
+	return r.delete(id, version)
 }
 
 func (r *mockRepo) Update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
 	defer func() { r.updateCalled++ }()
 	return r.update(todo)
 }
+
+func (r *mockRepo) ListTags() ([]domain.TagCount, domain.TodoRepoError) {
+	defer func() { r.listTagsCalled++ }()
+	return r.listTags()
+}
+
+func (r *mockRepo) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	defer func() { r.searchCalled++ }()
+	return r.search(search)
+}
+
+func (r *mockRepo) ListTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	defer func() { r.listTrashCalled++ }()
+	return r.listTrash()
+}
+
+func (r *mockRepo) Restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	defer func() { r.restoreCalled++ }()
+	return r.restore(id)
+}
+
+func (r *mockRepo) Purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	defer func() { r.purgeCalled++ }()
+	return r.purge(id)
+}
+
+func (r *mockRepo) PurgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	defer func() { r.purgeDeletedBeforeCalled++ }()
+	return r.purgeDeletedBefore(before)
+}
+
+func (r *mockRepo) History(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	defer func() { r.historyCalled++ }()
+	return r.history(id)
+}
+
+func (r *mockRepo) AsActor(actor string) domain.TodoRepo {
+	defer func() { r.asActorCalled++ }()
+	if r.asActor == nil {
+		// nobody keeps track of actors
+		return r
+	}
+	return r.asActor(actor)
+}
+
+func (r *mockRepo) WithinTx(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	defer func() { r.withinTxCalled++ }()
+	if r.withinTx == nil {
+		// no transaction to speak of
+		return f(r, r.lists)
+	}
+	return r.withinTx(f)
+}
+
+// positionOrder returns the ids of the Todos in the given repo in the order
+// they are arranged in by hand
+func positionOrder(t *testing.T, repo domain.TodoRepo) []domain.TodoID {
+	page, err := repo.List(&domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPosition}}})
+	assert.Nil(t, err)
+	var ids []domain.TodoID
+	for _, todo := range page.Todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func TestMove(t *testing.T) {
+	repo := mockRepoWith()
+	service := todoServiceImpl{Repo: repo}
+	for _, task := range []string{"One", "Two", "Three", "Four"} {
+		_, err := service.Create(&domain.NewTodo{Task: task})
+		assert.Nil(t, err)
+	}
+	one, two, three, four := domain.TodoID(1), domain.TodoID(2), domain.TodoID(3), domain.TodoID(4)
+
+	_, err := service.Move(&four, &one, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{4, 1, 2, 3}, positionOrder(t, repo))
+
+	_, err = service.Move(&four, nil, &two)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{1, 2, 4, 3}, positionOrder(t, repo))
+
+	moved, err := service.Move(&one, &three, &four)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{2, 4, 1, 3}, positionOrder(t, repo))
+	assert.Equal(t, "One", moved.Task)
+
+	_, err = service.Move(&two, nil, &three)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TodoID{4, 1, 3, 2}, positionOrder(t, repo))
+}
+
+func TestMoveOnlyChangesMovedTodo(t *testing.T) {
+	repo := mockRepoWith()
+	service := todoServiceImpl{Repo: repo}
+	for _, task := range []string{"One", "Two", "Three"} {
+		_, err := service.Create(&domain.NewTodo{Task: task})
+		assert.Nil(t, err)
+	}
+	one, two, three := domain.TodoID(1), domain.TodoID(2), domain.TodoID(3)
+	before, _ := repo.Get(&two)
+	_, err := service.Move(&three, &one, nil)
+	assert.Nil(t, err)
+	after, _ := repo.Get(&two)
+	assert.Equal(t, before, after)
+}
+
+func TestMoveInvalid(t *testing.T) {
+	service := todoServiceImpl{Repo: mockRepoWith(
+		domain.Todo{ID: 1, Task: "One", Position: "a0"},
+		domain.Todo{ID: 2, Task: "Two", Position: "a1"},
+		domain.Todo{ID: 3, Task: "Three", Position: "a2"},
+	)}
+	one, two, three, absent := domain.TodoID(1), domain.TodoID(2), domain.TodoID(3), domain.TodoID(123)
+
+	_, err := service.Move(&two, nil, nil)
+	assert.IsType(t, TodoMoveError{}, err)
+	_, err = service.Move(&two, &two, nil)
+	assert.IsType(t, TodoMoveError{}, err)
+	_, err = service.Move(&two, &one, &three)
+	assert.Equal(t, TodoMoveError{ID: two, Reason: "[3] does not come before [1]"}, err)
+	_, err = service.Move(&two, &absent, nil)
+	assert.Equal(t, TodoMoveTargetNotFound{TargetID: absent}, err)
+	_, err = service.Move(&absent, &one, nil)
+	assert.Equal(t, TodoNotFound{ID: absent}, err)
+}
+
+func TestBatch(t *testing.T) {
+	one, two := domain.TodoID(1), domain.TodoID(2)
+	repo := mockRepoWith(
+		domain.Todo{ID: one, Version: 1, Task: "Paint fence"},
+		domain.Todo{ID: two, Version: 1, Task: "Buy paint", ParentID: &one},
+	)
+	service := todoServiceImpl{Repo: repo, Clock: fixedClock}
+	results, err := service.Batch([]BatchOp{
+		{Create: &domain.NewTodo{Task: "Buy brushes"}},
+		{Update: &domain.Todo{ID: two, Task: "Buy white paint", ParentID: &one}},
+		{Delete: &BatchDelete{ID: one}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), repo.withinTxCalled)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, "Buy brushes", results[0].Task)
+		assert.Equal(t, "Buy white paint", results[1].Task)
+		assert.Equal(t, domain.Todo{}, results[2])
+	}
+	// deleting works as it does on its own, orphaning children
+	orphaned, _ := repo.Get(&two)
+	assert.Nil(t, orphaned.ParentID)
+	assert.Equal(t, []domain.TodoID{2, 3}, repo.ids())
+}
+
+func TestBatchFailure(t *testing.T) {
+	repo := mockRepoWith(domain.Todo{ID: 1, Version: 1, Task: "Paint fence"})
+	service := todoServiceImpl{Repo: repo, Clock: fixedClock}
+	_, err := service.Batch([]BatchOp{
+		{Create: &domain.NewTodo{Task: "Buy brushes"}},
+		{Delete: &BatchDelete{ID: 1}},
+		{Update: &domain.Todo{ID: 42, Task: "Buy paint"}},
+		{Create: &domain.NewTodo{Task: "Never gets this far"}},
+	})
+	assert.Equal(t, TodoBatchError{Index: 2, Cause: TodoNotFound{ID: 42}}, err)
+	// nothing was made
+	assert.Equal(t, []domain.TodoID{1}, repo.ids())
+}
+
+func TestBatchInvalid(t *testing.T) {
+	repo := mockRepoWith()
+	service := todoServiceImpl{Repo: repo, Clock: fixedClock}
+	_, err := service.Batch([]BatchOp{
+		{Create: &domain.NewTodo{Task: "Buy brushes"}},
+		{Create: &domain.NewTodo{Task: ""}},
+	})
+	assert.Equal(t, TodoBatchError{Index: 1, Cause: &TodoDataError{Task: ""}}, err)
+	assert.Empty(t, repo.ids())
+}
+
+func TestBatchStorageFailure(t *testing.T) {
+	repo := mockRepoWith()
+	failure := domain.TodoRepoFailure{Cause: errors.New("disk on fire")}
+	repo.withinTx = func(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+		if err := f(repo, nil); err != nil {
+			return err
+		}
+		return failure
+	}
+	service := todoServiceImpl{Repo: repo, Clock: fixedClock}
+	_, err := service.Batch([]BatchOp{{Create: &domain.NewTodo{Task: "Buy brushes"}}})
+	assert.Equal(t, TodoStorageError{Cause: failure}, err)
+}
+
+func TestCompleteRecurringIsAtomic(t *testing.T) {
+	tuesday := time.Date(2019, 8, 20, 9, 0, 0, 0, time.UTC)
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Version: 1, Task: "Water plants", DueAt: &tuesday, Recurrence: "FREQ=DAILY"})
+	failure := domain.TodoRepoFailure{Cause: errors.New("disk on fire")}
+	mockRepo.create = func(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+		return domain.Todo{}, failure
+	}
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock}
+	id := domain.TodoID(1)
+	_, err := service.Complete(&id)
+	assert.Equal(t, TodoStorageError{Cause: failure}, err)
+	assert.Equal(t, uint(1), mockRepo.withinTxCalled)
+	// it is not completed without its next occurrence
+	assert.Equal(t, domain.Todo{ID: 1, Version: 1, Task: "Water plants", DueAt: &tuesday, Recurrence: "FREQ=DAILY"}, mockRepo.todos[1])
+}
+
+func TestDeleteCascadeIsAtomic(t *testing.T) {
+	one, two := domain.TodoID(1), domain.TodoID(2)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Task: "grandparent"},
+		domain.Todo{ID: two, Task: "parent", ParentID: &one},
+		domain.Todo{ID: 3, Task: "child", ParentID: &two},
+		domain.Todo{ID: 4, Task: "unrelated", DependsOn: []domain.TodoID{3}},
+	)
+	deleteTodo := mockRepo.delete
+	failure := domain.TodoRepoFailure{ID: two, Cause: errors.New("disk on fire")}
+	mockRepo.delete = func(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+		if *id == two {
+			return false, failure
+		}
+		return deleteTodo(id, version)
+	}
+	service := todoServiceImpl{Repo: mockRepo}
+	_, err := service.Delete(&one, 0, DeleteChildren)
+	assert.Equal(t, TodoStorageError{Cause: failure}, err)
+	// the child deleted before the failure is back, and still depended on
+	assert.Equal(t, []domain.TodoID{1, 2, 3, 4}, mockRepo.ids())
+	assert.Equal(t, []domain.TodoID{3}, mockRepo.todos[4].DependsOn)
+}
+
+func TestWithinTx(t *testing.T) {
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Version: 1, Task: "Buy paint"})
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock}
+	one := domain.TodoID(1)
+	err := service.WithinTx(func(todos TodoService, lists domain.TodoListRepo) TodoServiceError {
+		if _, err := todos.Create(&domain.NewTodo{Task: "Paint fence", DependsOn: []domain.TodoID{1}}); err != nil {
+			return err
+		}
+		if _, err := todos.Complete(&one); err != nil {
+			return err
+		}
+		return TodoNotFound{ID: 3}
+	})
+	assert.Equal(t, TodoNotFound{ID: 3}, err)
+	// nested operations join the transaction rather than starting their own
+	assert.Equal(t, uint(1), mockRepo.withinTxCalled)
+	assert.Equal(t, map[domain.TodoID]domain.Todo{1: {ID: 1, Version: 1, Task: "Buy paint"}}, mockRepo.todos)
+
+	err = service.WithinTx(func(todos TodoService, lists domain.TodoListRepo) TodoServiceError {
+		_, err := todos.Complete(&one)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.True(t, mockRepo.todos[1].Completed)
+}
+
+func TestDeleteMovesToTrash(t *testing.T) {
+	one := domain.TodoID(1)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Task: "parent"},
+		domain.Todo{ID: 2, Task: "child", ParentID: &one},
+	)
+	service := todoServiceImpl{Repo: mockRepo}
+	_, err := service.Delete(&one, 0, DeleteChildren)
+	assert.Nil(t, err)
+	assert.Empty(t, mockRepo.ids())
+	// children go along with their parent
+	assert.Len(t, mockRepo.trash, 2)
+}
+
+func TestRestore(t *testing.T) {
+	one, two, list := domain.TodoID(1), domain.TodoID(2), domain.TodoListID(1)
+	mockRepo := mockRepoWith(domain.Todo{ID: one, Version: 1, Task: "Buy paint"})
+	mockRepo.trash[two] = domain.TrashedTodo{
+		Todo:      domain.Todo{ID: two, Version: 3, Task: "Paint fence", ListID: &list, DependsOn: []domain.TodoID{one}},
+		DeletedAt: fixedTime,
+	}
+	service := serviceWithLists(mockRepo, mockListRepoWith(domain.TodoList{ID: list, Name: "Chores"}))
+	restored, err := service.Restore(&two)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.Todo{ID: two, Version: 4, Task: "Paint fence", ListID: &list, DependsOn: []domain.TodoID{one}}, restored)
+	assert.Equal(t, uint(0), mockRepo.updateCalled)
+	assert.Empty(t, mockRepo.trash)
+}
+
+func TestRestoreDropsWhatIsGone(t *testing.T) {
+	one, two, three, list := domain.TodoID(1), domain.TodoID(2), domain.TodoID(3), domain.TodoListID(1)
+	mockRepo := mockRepoWith(domain.Todo{ID: one, Version: 1, Task: "Buy paint"})
+	mockRepo.trash[three] = domain.TrashedTodo{
+		Todo: domain.Todo{
+			ID: three, Version: 1, Task: "Paint fence", ParentID: &two, ListID: &list, DependsOn: []domain.TodoID{one, two},
+		},
+		DeletedAt: fixedTime,
+	}
+	service := serviceWithLists(mockRepo, mockListRepoWith())
+	restored, err := service.Restore(&three)
+	assert.Nil(t, err)
+	assert.Equal(t, domain.Todo{ID: three, Version: 2, Task: "Paint fence", DependsOn: []domain.TodoID{one}}, restored)
+	assert.Equal(t, restored, mockRepo.todos[three])
+}
+
+func TestRestoreNotInTrash(t *testing.T) {
+	one := domain.TodoID(1)
+	service := todoServiceImpl{Repo: mockRepoWith(domain.Todo{ID: one, Task: "Buy paint"})}
+	_, err := service.Restore(&one)
+	assert.Equal(t, TrashedTodoNotFound{ID: one}, err)
+}
+
+func TestPurge(t *testing.T) {
+	one := domain.TodoID(1)
+	mockRepo := mockRepoWith()
+	mockRepo.trash[one] = domain.TrashedTodo{Todo: domain.Todo{ID: one, Task: "Buy paint"}, DeletedAt: fixedTime}
+	service := todoServiceImpl{Repo: mockRepo}
+	purged, err := service.Purge(&one)
+	assert.Nil(t, err)
+	assert.True(t, purged)
+	assert.Empty(t, mockRepo.trash)
+	_, err = service.Purge(&one)
+	assert.Equal(t, TrashedTodoNotFound{ID: one}, err)
+}
+
+func TestTrashPurgedAfterRetention(t *testing.T) {
+	mockRepo := mockRepo{}
+	var purgedBefore time.Time
+	mockRepo.purgeDeletedBefore = func(before time.Time) (int, domain.TodoRepoError) {
+		purgedBefore = before
+		return 1, nil
+	}
+	mockRepo.listTrash = func() ([]domain.TrashedTodo, domain.TodoRepoError) {
+		return []domain.TrashedTodo{}, nil
+	}
+	service := todoServiceImpl{Repo: &mockRepo, Clock: fixedClock, TrashRetention: 24 * time.Hour}
+	_, err := service.ListTrash()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), mockRepo.purgeDeletedBeforeCalled)
+	assert.Equal(t, fixedTime.Add(-24*time.Hour), purgedBefore)
+
+	// without a retention, the trash is kept until purged by hand
+	service.TrashRetention = 0
+	_, err = service.ListTrash()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), mockRepo.purgeDeletedBeforeCalled)
+	assert.Equal(t, uint(2), mockRepo.listTrashCalled)
+}
+
+func TestHistory(t *testing.T) {
+	revisions := []domain.TodoRevision{
+		{Rev: 1, Change: domain.CreatedRevision, At: fixedTime, Actor: "alice", Todo: domain.Todo{ID: 1, Version: 1, Task: "Water plants"}},
+		{Rev: 2, Change: domain.DeletedRevision, At: fixedTime, Todo: domain.Todo{ID: 1, Version: 1, Task: "Water plants"}},
+	}
+	mockRepo := mockRepo{}
+	mockRepo.history = func(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+		if *id == 1 {
+			return revisions, nil
+		}
+		return nil, domain.TodoNotFound{ID: *id}
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	id := domain.TodoID(1)
+	retrieved, err := service.History(&id)
+	assert.Nil(t, err)
+	assert.Equal(t, revisions, retrieved)
+	id = 2
+	_, err = service.History(&id)
+	assert.Equal(t, TodoNotFound{ID: 2}, err)
+}
+
+// repoWithHistory returns a storingMockRepo holding the given Todos, whose
+// history is made of the given revisions
+func repoWithHistory(revisions []domain.TodoRevision, todos ...domain.Todo) *storingMockRepo {
+	repo := mockRepoWith(todos...)
+	repo.history = func(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+		var found []domain.TodoRevision
+		for _, revision := range revisions {
+			if revision.Todo.ID == *id {
+				found = append(found, revision)
+			}
+		}
+		return found, nil
+	}
+	return repo
+}
+
+func TestRevert(t *testing.T) {
+	dueAt := fixedTime.Add(time.Hour)
+	parentId := domain.TodoID(2)
+	was := domain.Todo{
+		ID: 1, Version: 1, Task: "Water plants", Completed: true, CompletedAt: &fixedTime, DueAt: &dueAt,
+		Tags: []string{"garden"}, Priority: domain.HighPriority, ParentID: &parentId, DependsOn: []domain.TodoID{3},
+		Position: "a",
+	}
+	is := domain.Todo{ID: 1, Version: 2, Task: "Water the plants", Position: "c"}
+	repo := repoWithHistory(
+		[]domain.TodoRevision{{Rev: 1, Change: domain.CreatedRevision, Todo: was}, {Rev: 2, Change: domain.UpdatedRevision, Todo: is}},
+		is, domain.Todo{ID: 2, Version: 1, Task: "Garden"}, domain.Todo{ID: 3, Version: 1, Task: "Buy a watering can"},
+	)
+	service := todoServiceImpl{Repo: repo}
+	id := domain.TodoID(1)
+	reverted, err := service.Revert(&id, 1)
+	assert.Nil(t, err)
+	expected := was
+	expected.Version = 2
+	expected.Position = "c"
+	assert.Equal(t, expected, reverted)
+	assert.Equal(t, expected, repo.todos[1])
+}
+
+func TestRevertRevisionNotFound(t *testing.T) {
+	is := domain.Todo{ID: 1, Version: 1, Task: "Water plants"}
+	repo := repoWithHistory([]domain.TodoRevision{{Rev: 1, Change: domain.CreatedRevision, Todo: is}}, is)
+	service := todoServiceImpl{Repo: repo}
+	id := domain.TodoID(1)
+	_, err := service.Revert(&id, 2)
+	assert.Equal(t, TodoRevisionNotFound{ID: 1, Rev: 2}, err)
+	assert.Equal(t, uint(0), repo.updateCalled)
+}
+
+func TestRevertChecksWhatIsGone(t *testing.T) {
+	parentId := domain.TodoID(2)
+	was := domain.Todo{ID: 1, Version: 1, Task: "Water plants", ParentID: &parentId}
+	is := domain.Todo{ID: 1, Version: 2, Task: "Water plants"}
+	repo := repoWithHistory(
+		[]domain.TodoRevision{{Rev: 1, Change: domain.CreatedRevision, Todo: was}, {Rev: 2, Change: domain.UpdatedRevision, Todo: is}},
+		is,
+	)
+	service := todoServiceImpl{Repo: repo}
+	id := domain.TodoID(1)
+	_, err := service.Revert(&id, 1)
+	assert.Equal(t, TodoParentNotFound{ParentID: 2}, err)
+	assert.Equal(t, is, repo.todos[1])
+}
+
+func TestAsActor(t *testing.T) {
+	actorRepo := mockRepoWith()
+	mockRepo := mockRepo{}
+	var actors []string
+	mockRepo.asActor = func(actor string) domain.TodoRepo {
+		actors = append(actors, actor)
+		return actorRepo
+	}
+	service := todoServiceImpl{Repo: &mockRepo}
+	_, err := service.AsActor("alice").Create(&domain.NewTodo{Task: "Water plants"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alice"}, actors)
+	assert.Equal(t, uint(1), actorRepo.createCalled)
+	assert.Equal(t, uint(0), mockRepo.createCalled)
+}
+
+// recordingPublisher keeps the events published to it
+type recordingPublisher struct {
+	published []events.Event
+}
+
+func (p *recordingPublisher) Publish(event events.Event) {
+	p.published = append(p.published, event)
+}
+
+func TestPublishesChanges(t *testing.T) {
+	mockRepo := mockRepoWith()
+	publisher := &recordingPublisher{}
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock, Events: publisher}
+	created, _ := service.AsActor("alice").Create(&domain.NewTodo{Task: "Water plants"})
+	toUpdate := created
+	toUpdate.Task = "Water the plants"
+	updated, _ := service.Update(&toUpdate)
+	_, _ = service.Delete(&created.ID, 0, OrphanChildren)
+	restored, _ := service.Restore(&created.ID)
+	assert.Equal(t, []events.Event{
+		events.TodoCreated{Change: events.Change{Todo: created, Actor: "alice", At: fixedTime}},
+		events.TodoUpdated{Change: events.Change{Todo: updated, Previous: &created, At: fixedTime}},
+		events.TodoDeleted{Change: events.Change{Todo: updated, At: fixedTime}},
+		events.TodoRestored{Change: events.Change{Todo: restored, At: fixedTime}},
+	}, publisher.published)
+}
+
+func TestPublishesDeletedDescendants(t *testing.T) {
+	one := domain.TodoID(1)
+	mockRepo := mockRepoWith(
+		domain.Todo{ID: one, Task: "parent"},
+		domain.Todo{ID: 2, Task: "child", ParentID: &one},
+	)
+	publisher := &recordingPublisher{}
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock, Events: publisher}
+	_, err := service.Delete(&one, 0, DeleteChildren)
+	assert.Nil(t, err)
+	var deleted []domain.TodoID
+	for _, event := range publisher.published {
+		assert.Equal(t, "todo.deleted", event.Name())
+		deleted = append(deleted, event.Details().Todo.ID)
+	}
+	assert.Equal(t, []domain.TodoID{2, 1}, deleted)
+}
+
+func TestPublishesOnlyOnceCommitted(t *testing.T) {
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Version: 1, Task: "Buy paint"})
+	publisher := &recordingPublisher{}
+	service := todoServiceImpl{Repo: mockRepo, Clock: fixedClock, Events: publisher}
+	err := service.WithinTx(func(todos TodoService, lists domain.TodoListRepo) TodoServiceError {
+		if _, err := todos.Create(&domain.NewTodo{Task: "Paint fence"}); err != nil {
+			return err
+		}
+		assert.Empty(t, publisher.published)
+		return TodoNotFound{ID: 3}
+	})
+	assert.Equal(t, TodoNotFound{ID: 3}, err)
+	assert.Empty(t, publisher.published)
+
+	_, err = service.Batch([]BatchOp{
+		{Create: &domain.NewTodo{Task: "Paint fence"}},
+		{Delete: &BatchDelete{ID: 1}},
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, publisher.published, 2) {
+		assert.Equal(t, "todo.created", publisher.published[0].Name())
+		assert.Equal(t, "todo.deleted", publisher.published[1].Name())
+	}
+}
+
+func TestPublishesNothingOnFailure(t *testing.T) {
+	mockRepo := mockRepoWith()
+	publisher := &recordingPublisher{}
+	service := todoServiceImpl{Repo: mockRepo, Events: publisher}
+	_, err := service.Create(&domain.NewTodo{})
+	assert.NotNil(t, err)
+	one := domain.TodoID(1)
+	_, err = service.Delete(&one, 0, OrphanChildren)
+	assert.NotNil(t, err)
+	assert.Empty(t, publisher.published)
+}
+
+// lockingPublisher keeps the events published to it from several goroutines
+type lockingPublisher struct {
+	mutex     sync.Mutex
+	published []events.Event
+}
+
+func (p *lockingPublisher) Publish(event events.Event) {
+	// give the changes made after every other one a chance to get in first
+	if event.Details().Todo.Version%2 == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.published = append(p.published, event)
+}
+
+func TestPublishesInCommitOrder(t *testing.T) {
+	mockRepo := mockRepoWith(domain.Todo{ID: 1, Version: 1, Task: "Water plants"})
+	// transactions are made one at a time, as real repos make them
+	var txMutex sync.Mutex
+	mockRepo.withinTx = func(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+		txMutex.Lock()
+		defer txMutex.Unlock()
+		return f(mockRepo, nil)
+	}
+	mockRepo.update = func(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+		todo.Version = mockRepo.todos[todo.ID].Version + 1
+		mockRepo.todos[todo.ID] = *todo
+		return *todo, nil
+	}
+	publisher := &lockingPublisher{}
+	service := MkTodoService(mockRepo, nil, 0, publisher)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := service.Update(&domain.Todo{ID: 1, Task: fmt.Sprintf("Water plants %d", i)})
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, publisher.published, 50)
+	var lastVersion domain.TodoVersion
+	for _, event := range publisher.published {
+		version := event.Details().Todo.Version
+		assert.True(t, version > lastVersion, "version %d published after %d", version, lastVersion)
+		lastVersion = version
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// TrashPurger regularly purges the Todos that have been in the trash for
+// longer than they are kept, so that they don't pile up when nobody looks
+// at the trash; TodoService only purges them on its way to it
+type TrashPurger struct {
+	repo      domain.TodoRepo
+	retention time.Duration
+	// clock tells the time; defaults to time.Now when nil
+	clock   func() time.Time
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// MkTrashPurger returns a TrashPurger for the trash of the given
+// domain.TodoRepo, where deleted Todos are kept for the given retention
+// (forever if zero, in which case it never purges anything)
+func MkTrashPurger(repo domain.TodoRepo, retention time.Duration) *TrashPurger {
+	return &TrashPurger{repo: repo, retention: retention, clock: time.Now}
+}
+
+// Start purges the trash every interval, in the background, until Stop is
+// called
+func (p *TrashPurger) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	p.startWith(ticker.C, ticker.Stop)
+}
+
+// startWith purges the trash on every tick, calling done once stopped
+func (p *TrashPurger) startWith(ticks <-chan time.Time, done func()) {
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	go func() {
+		defer close(p.stopped)
+		defer done()
+		for {
+			select {
+			case <-ticks:
+				if _, err := p.Purge(); err != nil {
+					log.Printf("failed to purge the trash: %v", err)
+				}
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops purging the trash, waiting for a purge that is under way to
+// finish. It does nothing if the TrashPurger was not started.
+func (p *TrashPurger) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+	p.stop = nil
+}
+
+// Purge purges the Todos that are due to be right away, returning how
+// many there were
+func (p *TrashPurger) Purge() (int, TodoServiceError) {
+	now := time.Now
+	if p.clock != nil {
+		now = p.clock
+	}
+	return purgeTrash(p.repo, now().UTC(), p.retention)
+}
+
+// purgeTrash purges the Todos that have been in the trash of the given repo
+// for longer than the given retention, as of now
+func purgeTrash(repo domain.TodoRepo, now time.Time, retention time.Duration) (int, TodoServiceError) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	purged, err := repo.PurgeDeletedBefore(now.Add(-retention))
+	if err != nil {
+		return 0, fromRepoError(err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashPurgerPurgesOnEveryTick(t *testing.T) {
+	mockRepo := mockRepo{}
+	purgedBefore := make(chan time.Time)
+	mockRepo.purgeDeletedBefore = func(before time.Time) (int, domain.TodoRepoError) {
+		purgedBefore <- before
+		return 1, nil
+	}
+	now := fixedTime
+	purger := MkTrashPurger(&mockRepo, 24*time.Hour)
+	purger.clock = func() time.Time { return now }
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	purger.startWith(ticks, func() { close(done) })
+
+	ticks <- time.Time{}
+	assert.Equal(t, fixedTime.Add(-24*time.Hour), <-purgedBefore)
+	now = now.Add(time.Hour)
+	ticks <- time.Time{}
+	assert.Equal(t, fixedTime.Add(-23*time.Hour), <-purgedBefore)
+
+	purger.Stop()
+	<-done
+	assert.Equal(t, uint(2), mockRepo.purgeDeletedBeforeCalled)
+	// stopping again is harmless
+	purger.Stop()
+}
+
+func TestTrashPurgerKeepsGoingAfterFailures(t *testing.T) {
+	mockRepo := mockRepo{}
+	purged := make(chan struct{})
+	mockRepo.purgeDeletedBefore = func(before time.Time) (int, domain.TodoRepoError) {
+		purged <- struct{}{}
+		return 0, domain.TodoRepoFailure{Cause: assert.AnError}
+	}
+	purger := MkTrashPurger(&mockRepo, time.Hour)
+	purger.clock = fixedClock
+	ticks := make(chan time.Time)
+	purger.startWith(ticks, func() {})
+	for i := 0; i < 2; i++ {
+		ticks <- time.Time{}
+		<-purged
+	}
+	purger.Stop()
+	assert.Equal(t, uint(2), mockRepo.purgeDeletedBeforeCalled)
+}
+
+func TestTrashPurgerWithoutRetention(t *testing.T) {
+	mockRepo := mockRepo{}
+	purger := MkTrashPurger(&mockRepo, 0)
+	purged, err := purger.Purge()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, purged)
+	assert.Equal(t, uint(0), mockRepo.purgeDeletedBeforeCalled)
+	// never started, so there is nothing to stop
+	purger.Stop()
+}
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// SortField is something Todos can be listed in order of
+type SortField int
+
+const (
+	SortByID SortField = iota
+	SortByPriority
+	// SortByDueAt puts Todos without a due date after the ones with one
+	SortByDueAt
+	// SortByPosition is the order Todos are arranged in by hand
+	SortByPosition
+)
+
+// SortKey orders Todos by a SortField, in ascending order unless
+// Descending
+type SortKey struct {
+	Field      SortField
+	Descending bool
+}
+
+// SortKeys returns the keys that Todos matching the query are listed in
+// order of: the query's Sort, with ascending ids breaking any ties
+func (q *TodoQuery) SortKeys() []SortKey {
+	keys := make([]SortKey, 0, len(q.Sort)+1)
+	for _, key := range q.Sort {
+		keys = append(keys, key)
+		if key.Field == SortByID {
+			// ids are unique, so there are no ties left to break
+			return keys
+		}
+	}
+	return append(keys, SortKey{Field: SortByID})
+}
+
+// SortedByID returns true if the query lists Todos in order of
+// ascending id, which is the default
+func (q *TodoQuery) SortedByID() bool {
+	return q.SortKeys()[0] == SortKey{Field: SortByID}
+}
+
+// Compare returns a negative number if the Todo a is listed before b,
+// a positive one if it is listed after, and zero if they have the same id
+func (q *TodoQuery) Compare(a *Todo, b *Todo) int {
+	for _, key := range q.SortKeys() {
+		c := compareField(key.Field, a, b)
+		if key.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// IsAfter returns true if the given Todo is listed after the query's
+// After cursor, or if there is no cursor
+func (q *TodoQuery) IsAfter(todo *Todo) bool {
+	if q.After == nil {
+		return true
+	}
+	return q.Compare(q.After.asTodo(), todo) < 0
+}
+
+func compareField(field SortField, a *Todo, b *Todo) int {
+	switch field {
+	case SortByPriority:
+		return compareInt64(int64(a.Priority), int64(b.Priority))
+	case SortByDueAt:
+		return compareInt64(DueAtSortValue(a.DueAt), DueAtSortValue(b.DueAt))
+	case SortByPosition:
+		return strings.Compare(a.Position, b.Position)
+	default:
+		return compareInt64(int64(a.ID), int64(b.ID))
+	}
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// DueAtSortValue is what Todos are sorted on when sorting by due date:
+// nanoseconds since the epoch, with no due date coming after everything
+func DueAtSortValue(dueAt *time.Time) int64 {
+	if dueAt == nil {
+		return math.MaxInt64
+	}
+	return dueAt.UnixNano()
+}
@@ -2,30 +2,352 @@ package domain
 
 import (
 	"fmt"
+	"sort"
+	"time"
 )
 
 // TodoID is the identifier for a Todo
 type TodoID uint64
 
+// TodoVersion is bumped every time a Todo is changed. The zero value is
+// never the version of a persisted Todo, and is used to mean "any version"
+// when asking a TodoRepo to change something.
+type TodoVersion uint64
+
+// Priority says how important a Todo is; the zero value is NormalPriority
+type Priority int
+
+const (
+	LowPriority    Priority = -1
+	NormalPriority Priority = 0
+	HighPriority   Priority = 1
+	UrgentPriority Priority = 2
+)
+
+var priorityNames = map[Priority]string{
+	LowPriority:    "low",
+	NormalPriority: "normal",
+	HighPriority:   "high",
+	UrgentPriority: "urgent",
+}
+
+// ParsePriority returns the Priority with the given name
+func ParsePriority(name string) (Priority, bool) {
+	for priority, priorityName := range priorityNames {
+		if priorityName == name {
+			return priority, true
+		}
+	}
+	return NormalPriority, false
+}
+
+// IsValid returns true if the Priority is one of the known ones
+func (p Priority) IsValid() bool {
+	_, ok := priorityNames[p]
+	return ok
+}
+
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
 // NewTodo is for persisting a new Todo
 type NewTodo struct {
-	Task string
+	Task        string
+	Completed   bool
+	CompletedAt *time.Time
+	DueAt       *time.Time
+	Tags        []string
+	Priority    Priority
+	ParentID    *TodoID
+	DependsOn   []TodoID
+	Recurrence  string
+	ListID      *TodoListID
+	// Position is where the Todo goes in the order Todos are arranged in by
+	// hand; TodoRepos put it after all the others when empty
+	Position string
 }
 
 // Todo is a persisted Todo
 type Todo struct {
-	ID   TodoID
-	Task string
+	ID        TodoID
+	Version   TodoVersion
+	Task      string
+	Completed bool
+	// CompletedAt is when the Todo was marked as completed; nil
+	// unless Completed
+	CompletedAt *time.Time
+	// DueAt is when the Todo should be completed by; nil if there
+	// is no rush
+	DueAt *time.Time
+	// Tags categorise the Todo; sorted, without duplicates, and nil
+	// rather than empty
+	Tags     []string
+	Priority Priority
+	// ParentID is the id of the Todo this is a subtask of; nil for
+	// top-level Todos
+	ParentID *TodoID
+	// DependsOn holds the ids of the Todos that have to be completed before
+	// this one can be started; sorted, without duplicates, and nil rather
+	// than empty
+	DependsOn []TodoID
+	// Recurrence is a rule, as parsed by ParseRecurrence, for when the
+	// Todo comes round again once completed; empty if it doesn't
+	Recurrence string
+	// ListID is the id of the TodoList the Todo is on; nil if it is not
+	// on any
+	ListID *TodoListID
+	// Position is where the Todo is in the order Todos are arranged in by
+	// hand, as made by PositionBetween
+	Position string
+	// Blocked is worked out by the TodoRepo when reading a Todo: it is true
+	// if any of the Todos it depends on is not completed. It is ignored
+	// when writing, and changes without the version changing.
+	Blocked bool
+}
+
+// TrashedTodo is a Todo that was deleted, and is kept in the trash until
+// it gets restored or purged
+type TrashedTodo struct {
+	// Todo is as it was when it was deleted
+	Todo      Todo
+	DeletedAt time.Time
+}
+
+// TodoTree is a Todo along with all of its descendants
+type TodoTree struct {
+	Todo     Todo
+	Children []TodoTree
+}
+
+// TodoQuery narrows down the Todos returned by TodoRepo.List. Nil
+// fields match everything.
+type TodoQuery struct {
+	Completed *bool
+	// DueBefore and DueAfter only match Todos due in [DueAfter, DueBefore),
+	// so adjacent ranges don't overlap; Todos without a due date never match
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	// Overdue matches Todos depending on whether they are not completed
+	// and due before OverdueAsOf
+	Overdue     *bool
+	OverdueAsOf time.Time
+	// Tags matches Todos with any of the given tags, or with all of them
+	// if AllTags is set
+	Tags    []string
+	AllTags bool
+	// ParentID matches the children of the Todo with the given id, and
+	// TopLevel matches Todos without a parent
+	ParentID *TodoID
+	TopLevel bool
+	// DependencyOf matches the Todos that depend on the Todo with the given id
+	DependencyOf *TodoID
+	// ListID matches the Todos on the TodoList with the given id
+	ListID *TodoListID
+	// Sort is the order to list Todos in; see SortKeys
+	Sort []SortKey
+	// Limit caps the number of Todos in a page; 0 means no limit
+	Limit uint
+	// After skips to the Todos that come after the given position
+	After *TodoCursor
+}
+
+// TodoCursor marks a position in a listing of Todos, by holding whatever
+// they can be sorted on from the Todo at that position
+type TodoCursor struct {
+	ID       TodoID
+	Priority Priority
+	DueAt    *time.Time
+	Position string
+}
+
+// TodoPage is a page of Todos returned by TodoRepo.List
+type TodoPage struct {
+	Todos []Todo
+	// Next points at the end of this page, so it can be used to get the
+	// one after it; nil if this is the last page
+	Next *TodoCursor
+}
+
+// MkTodoPage returns a TodoPage for the given Todos, which should be
+// listed in the order of the query they match and, if there is a limit,
+// hold up to one more than the limit so that we know whether there is a
+// page after this one
+func MkTodoPage(todos []Todo, limit uint) TodoPage {
+	if limit == 0 || uint(len(todos)) <= limit {
+		return TodoPage{Todos: todos}
+	}
+	todos = todos[:limit]
+	return TodoPage{Todos: todos, Next: CursorFor(&todos[limit-1])}
+}
+
+// CursorFor returns a TodoCursor pointing at the given Todo
+func CursorFor(todo *Todo) *TodoCursor {
+	return &TodoCursor{ID: todo.ID, Priority: todo.Priority, DueAt: todo.DueAt, Position: todo.Position}
+}
+
+func (c *TodoCursor) asTodo() *Todo {
+	return &Todo{ID: c.ID, Priority: c.Priority, DueAt: c.DueAt, Position: c.Position}
+}
+
+// Matches returns true if the given Todo satisfies the query's filters;
+// paging is not taken into account
+func (q *TodoQuery) Matches(todo *Todo) bool {
+	if q.Completed != nil && *q.Completed != todo.Completed {
+		return false
+	}
+	if q.DueBefore != nil && (todo.DueAt == nil || !todo.DueAt.Before(*q.DueBefore)) {
+		return false
+	}
+	if q.DueAfter != nil && (todo.DueAt == nil || todo.DueAt.Before(*q.DueAfter)) {
+		return false
+	}
+	if q.Overdue != nil && *q.Overdue != todo.IsOverdue(q.OverdueAsOf) {
+		return false
+	}
+	if len(q.Tags) > 0 && !q.matchesTags(todo) {
+		return false
+	}
+	if q.ParentID != nil && (todo.ParentID == nil || *todo.ParentID != *q.ParentID) {
+		return false
+	}
+	if q.TopLevel && todo.ParentID != nil {
+		return false
+	}
+	if q.DependencyOf != nil && !todo.DependsOnTodo(*q.DependencyOf) {
+		return false
+	}
+	if q.ListID != nil && (todo.ListID == nil || *todo.ListID != *q.ListID) {
+		return false
+	}
+	return true
+}
+
+func (q *TodoQuery) matchesTags(todo *Todo) bool {
+	for _, tag := range q.Tags {
+		hasTag := todo.HasTag(tag)
+		if hasTag && !q.AllTags {
+			return true
+		}
+		if !hasTag && q.AllTags {
+			return false
+		}
+	}
+	return q.AllTags
+}
+
+// HasTag returns true if the Todo is tagged with the given tag
+func (todo *Todo) HasTag(tag string) bool {
+	i := sort.SearchStrings(todo.Tags, tag)
+	return i < len(todo.Tags) && todo.Tags[i] == tag
+}
+
+// DependsOnTodo returns true if the Todo depends on the one with the given id
+func (todo *Todo) DependsOnTodo(id TodoID) bool {
+	i := sort.Search(len(todo.DependsOn), func(i int) bool { return todo.DependsOn[i] >= id })
+	return i < len(todo.DependsOn) && todo.DependsOn[i] == id
+}
+
+// NormaliseIDs sorts the given ids and removes duplicates, returning nil
+// if there are none
+func NormaliseIDs(ids []TodoID) []TodoID {
+	if len(ids) == 0 {
+		return nil
+	}
+	normalised := make([]TodoID, len(ids))
+	copy(normalised, ids)
+	sort.Slice(normalised, func(i, j int) bool { return normalised[i] < normalised[j] })
+	unique := normalised[:1]
+	for _, id := range normalised[1:] {
+		if id != unique[len(unique)-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// NormaliseTags sorts the given tags and removes duplicates, returning
+// nil if there are none
+func NormaliseTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	normalised := make([]string, len(tags))
+	copy(normalised, tags)
+	sort.Strings(normalised)
+	unique := normalised[:1]
+	for _, tag := range normalised[1:] {
+		if tag != unique[len(unique)-1] {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// TagCount is a tag along with how many Todos are tagged with it
+type TagCount struct {
+	Tag   string
+	Count uint
+}
+
+// IsOverdue returns true if the Todo is not completed and was due
+// before the given time
+func (todo *Todo) IsOverdue(asOf time.Time) bool {
+	return !todo.Completed && todo.DueAt != nil && todo.DueAt.Before(asOf)
 }
 
 // TodoRepo is an interface for managing the persistence lifecycle
-// of a Todo
+// of a Todo.
+//
+// Created Todos start at version 1. Update and Delete fail with
+// TodoVersionConflict if given a non-zero version that is not the
+// currently persisted one; Update returns the Todo with its new version.
+//
+// Create puts Todos without a Position after all the others, and Update
+// keeps the current Position of Todos given without one.
 type TodoRepo interface {
-	Create(newTodo *NewTodo) Todo
+	Create(newTodo *NewTodo) (Todo, TodoRepoError)
 	Get(id *TodoID) (Todo, TodoRepoError)
-	List() []Todo
-	Delete(id *TodoID) (bool, TodoRepoError)
+	List(query *TodoQuery) (TodoPage, TodoRepoError)
+	// Delete moves a Todo to the trash, as of now, after which it is as good
+	// as gone, only found by the methods below
+	Delete(id *TodoID, version TodoVersion) (bool, TodoRepoError)
 	Update(todo *Todo) (Todo, TodoRepoError)
+	// ListTags lists the tags in use, in order, with how many Todos use them
+	ListTags() ([]TagCount, TodoRepoError)
+	// Search finds the Todos whose tasks match the given search, ranked
+	// with RankSearch
+	Search(search *TodoSearch) ([]TodoSearchResult, TodoRepoError)
+	// ListTrash lists the Todos in the trash, most recently deleted first
+	ListTrash() ([]TrashedTodo, TodoRepoError)
+	// Restore takes a Todo out of the trash, as it was apart from being at
+	// the next version; TodoNotFound if it is not in the trash
+	Restore(id *TodoID) (Todo, TodoRepoError)
+	// Purge deletes a Todo in the trash for good; TodoNotFound if it is not
+	// in the trash
+	Purge(id *TodoID) (bool, TodoRepoError)
+	// PurgeDeletedBefore purges all of the Todos that were moved to the
+	// trash before the given time, returning how many there were
+	PurgeDeletedBefore(before time.Time) (int, TodoRepoError)
+	// History lists the revisions recorded for the Todo with the given id
+	// by Create, Update, Delete and Restore, oldest first; TodoNotFound if
+	// it has none and is neither stored nor in the trash
+	History(id *TodoID) ([]TodoRevision, TodoRepoError)
+	// AsActor returns a TodoRepo for the same Todos, which records the
+	// changes made through it, and the repos it hands to WithinTx, as made
+	// by the given actor
+	AsActor(actor string) TodoRepo
+	// WithinTx runs the given function against repos for the Todos and
+	// TodoLists kept alongside this repo's, which see the changes made
+	// through them straight away. The changes are made for good if the
+	// function returns nil, and undone if it returns an error, which
+	// WithinTx then returns; nobody else sees any of them until then.
+	// Calling WithinTx on the repos given to the function just runs
+	// another function in the same transaction.
+	WithinTx(f func(todos TodoRepo, lists TodoListRepo) error) error
 }
 
 // <-- Errors
@@ -43,11 +365,52 @@ type TodoNotFound struct {
 }
 
 func (e TodoNotFound) Error() string {
-	return fmt.Sprintf("Could not find [%v] in in-mem repo", e.ID)
+	return fmt.Sprintf("Could not find [%v] in repo", e.ID)
 }
 
 func (e TodoNotFound) Id() TodoID {
 	return e.ID
 }
 
+// TodoVersionConflict is returned when trying to change a Todo
+// using a version that is not the current one
+type TodoVersionConflict struct {
+	ID       TodoID
+	Expected TodoVersion
+	Actual   TodoVersion
+}
+
+func (e TodoVersionConflict) Error() string {
+	return fmt.Sprintf("Expected [%v] to be at version [%v] but it is at [%v]", e.ID, e.Expected, e.Actual)
+}
+
+func (e TodoVersionConflict) Id() TodoID {
+	return e.ID
+}
+
+// CheckVersion returns a TodoVersionConflict if expected is neither zero
+// nor the actual version of the Todo with the given id
+func CheckVersion(id TodoID, expected TodoVersion, actual TodoVersion) TodoRepoError {
+	if expected != 0 && expected != actual {
+		return TodoVersionConflict{ID: id, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// TodoRepoFailure is returned when the underlying storage of a repo
+// fails, e.g. because a database could not be reached. ID is zero
+// when the failure is not about a specific Todo.
+type TodoRepoFailure struct {
+	ID    TodoID
+	Cause error
+}
+
+func (e TodoRepoFailure) Error() string {
+	return fmt.Sprintf("Storage failure for [%v]: %v", e.ID, e.Cause)
+}
+
+func (e TodoRepoFailure) Id() TodoID {
+	return e.ID
+}
+
 //     Errors -->
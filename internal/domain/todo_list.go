@@ -0,0 +1,67 @@
+package domain
+
+import "fmt"
+
+// TodoListID is the identifier for a TodoList
+type TodoListID uint64
+
+// NewTodoList is for persisting a new TodoList
+type NewTodoList struct {
+	Name string
+}
+
+// TodoList is a persisted list of Todos, e.g. one per project. Todos say
+// which list they are on, if any, with Todo.ListID.
+type TodoList struct {
+	ID   TodoListID
+	Name string
+}
+
+type TodoListRepo interface {
+	Create(newList *NewTodoList) (TodoList, TodoListRepoError)
+	Get(id *TodoListID) (TodoList, TodoListRepoError)
+	// List lists all TodoLists, in order of id
+	List() ([]TodoList, TodoListRepoError)
+	Update(list *TodoList) (TodoList, TodoListRepoError)
+	// Delete deletes a TodoList, leaving the Todos on it alone
+	Delete(id *TodoListID) (bool, TodoListRepoError)
+}
+
+// <-- Errors
+
+// TodoListRepoError is an error interface for TodoListRepo
+type TodoListRepoError interface {
+	error
+	Id() TodoListID
+}
+
+// TodoListNotFound is returned when the repo cannot find a TodoList
+// by a given TodoListID
+type TodoListNotFound struct {
+	ID TodoListID
+}
+
+func (e TodoListNotFound) Error() string {
+	return fmt.Sprintf("Could not find list [%v] in repo", e.ID)
+}
+
+func (e TodoListNotFound) Id() TodoListID {
+	return e.ID
+}
+
+// TodoListRepoFailure is returned when the underlying storage of a repo
+// fails. ID is zero when the failure is not about a specific TodoList.
+type TodoListRepoFailure struct {
+	ID    TodoListID
+	Cause error
+}
+
+func (e TodoListRepoFailure) Error() string {
+	return fmt.Sprintf("Storage failure for list [%v]: %v", e.ID, e.Cause)
+}
+
+func (e TodoListRepoFailure) Id() TodoListID {
+	return e.ID
+}
+
+//     Errors -->
@@ -0,0 +1,63 @@
+package eventsourced
+
+import (
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// EventType says what happened in an Event
+type EventType string
+
+const (
+	// TodoCreated events hold the Todo that was created
+	TodoCreated EventType = "todo_created"
+	// TodoUpdated events hold the Todo as it was updated to
+	TodoUpdated EventType = "todo_updated"
+	// TodoDeleted events say which Todo was moved to the trash
+	TodoDeleted EventType = "todo_deleted"
+	// TodoRestored events say which Todo was taken back out of the trash
+	TodoRestored EventType = "todo_restored"
+	// TodoPurged events say which Todo was deleted from the trash for good
+	TodoPurged EventType = "todo_purged"
+	// TodoListCreated events hold the TodoList that was created
+	TodoListCreated EventType = "list_created"
+	// TodoListUpdated events hold the TodoList as it was renamed to
+	TodoListUpdated EventType = "list_updated"
+	// TodoListDeleted events say which TodoList was deleted
+	TodoListDeleted EventType = "list_deleted"
+)
+
+// Event is something that happened to Todos or TodoLists. Their state is
+// what folding all of the events, in order, makes of it.
+type Event struct {
+	// Seq numbers events in the order they happened, from 1 on
+	Seq  uint64    `json:"seq"`
+	Type EventType `json:"type"`
+	At   time.Time `json:"at"`
+	// Actor is who made the change, as given to domain.TodoRepo.AsActor
+	Actor string `json:"actor,omitempty"`
+	// Todo is set for TodoCreated and TodoUpdated
+	Todo *domain.Todo `json:"todo,omitempty"`
+	// TodoID is set for TodoDeleted, TodoRestored and TodoPurged
+	TodoID domain.TodoID `json:"todo_id,omitempty"`
+	// List is set for TodoListCreated and TodoListUpdated
+	List *domain.TodoList `json:"list,omitempty"`
+	// ListID is set for TodoListDeleted
+	ListID domain.TodoListID `json:"list_id,omitempty"`
+}
+
+// Snapshot is the state made by folding all of the events up to and
+// including the one numbered Seq, which happened At the given time, so
+// that it can be rebuilt without folding them all again
+type Snapshot struct {
+	Seq          uint64                `json:"seq"`
+	At           time.Time             `json:"at"`
+	LastID       domain.TodoID         `json:"last_id"`
+	LastListID   domain.TodoListID     `json:"last_list_id"`
+	LastPosition string                `json:"last_position"`
+	Todos        []domain.Todo         `json:"todos"`
+	Trash        []domain.TrashedTodo  `json:"trash"`
+	History      []domain.TodoRevision `json:"history"`
+	Lists        []domain.TodoList     `json:"lists"`
+}
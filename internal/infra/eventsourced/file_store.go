@@ -0,0 +1,195 @@
+package eventsourced
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	eventsFileName   = "events.log"
+	snapshotFileName = "events.snapshot"
+)
+
+// fileStore is an EventStore that keeps events in a log file, one line of
+// JSON per Append so that events appended together are read back together,
+// and the latest snapshot in a file of its own
+type fileStore struct {
+	mutex sync.Mutex
+	dir   string
+	log   *os.File
+	// size is where the last complete line of the log ends
+	size    int64
+	lastSeq uint64
+}
+
+// OpenFileStore opens (creating if needed) an EventStore keeping its files
+// in the given directory. Whatever is left of an Append that was cut short
+// by a crash is dropped.
+//
+// The returned store implements io.Closer.
+func OpenFileStore(dir string) (EventStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, eventsFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &fileStore{dir: dir, log: log}
+	if err := s.open(); err != nil {
+		_ = log.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// open finds where the log ends and readies it for appending, which
+// always happens there, whatever reading moves the file offset to
+func (s *fileStore) open() error {
+	if err := s.read(0, func(events []Event) {
+		if len(events) > 0 {
+			s.lastSeq = events[len(events)-1].Seq
+		}
+	}); err != nil {
+		return err
+	}
+	if snapshot, err := s.readSnapshot(); err != nil {
+		return err
+	} else if snapshot != nil && snapshot.Seq > s.lastSeq {
+		s.lastSeq = snapshot.Seq
+	}
+	// Drop whatever was left of a torn write so new events start on a
+	// fresh line
+	return s.log.Truncate(s.size)
+}
+
+// read goes through every complete line of the log, up to limit unless it
+// is zero, handing the events on each to f and keeping track of where the
+// last one ends
+func (s *fileStore) read(limit int64, f func(events []Event)) error {
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(s.log)
+	var offset int64
+	for limit == 0 || offset < limit {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// anything without a trailing newline is a torn write
+			break
+		} else if err != nil {
+			return err
+		}
+		var events []Event
+		if err := json.Unmarshal(line, &events); err != nil {
+			return err
+		}
+		f(events)
+		offset += int64(len(line))
+	}
+	s.size = offset
+	return nil
+}
+
+func (s *fileStore) Append(events []Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+	if err := checkSequence(s.lastSeq, events); err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	line := append(bytes, '\n')
+	if _, err := s.log.WriteAt(line, s.size); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	s.size += int64(len(line))
+	s.lastSeq = events[len(events)-1].Seq
+	return nil
+}
+
+func (s *fileStore) Events(after uint64) ([]Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	found := make([]Event, 0)
+	err := s.read(s.size, func(events []Event) {
+		for _, event := range events {
+			if event.Seq > after {
+				found = append(found, event)
+			}
+		}
+	})
+	return found, err
+}
+
+// SaveSnapshot replaces the snapshot file with the given snapshot, in one go
+func (s *fileStore) SaveSnapshot(snapshot *Snapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	bytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.dir, snapshotFileName)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(bytes); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, snapshotFileName)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Snapshot() (*Snapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.readSnapshot()
+}
+
+func (s *fileStore) readSnapshot() (*Snapshot, error) {
+	bytes, err := ioutil.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(bytes, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (s *fileStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.log.Close()
+}
@@ -0,0 +1,103 @@
+package eventsourced
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/repotest"
+	"github.com/stretchr/testify/assert"
+)
+
+func mkTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "todddo-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func openFileStore(t *testing.T, dir string) EventStore {
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func reopen(t *testing.T, repo domain.TodoRepo, dir string, snapshotEvery int) domain.TodoRepo {
+	if err := repo.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, _ := mkRepos(t, openFileStore(t, dir), snapshotEvery)
+	return reopened
+}
+
+func TestFileRepoSurvivesRestart(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, _ := mkRepos(t, openFileStore(t, dir), 2)
+	first, _ := repo.Create(&domain.NewTodo{Task: "first"})
+	second, _ := repo.Create(&domain.NewTodo{Task: "second"})
+	second.Task = "second, changed"
+	second, _ = repo.Update(&second)
+
+	repo = reopen(t, repo, dir, 2)
+	page, _ := repo.List(&domain.TodoQuery{})
+	assert.Equal(t, []domain.Todo{first, second}, page.Todos)
+	history, _ := repo.History(&second.ID)
+	assert.Len(t, history, 2)
+	_ = repo.(io.Closer).Close()
+}
+
+func TestFileStoreIgnoresTornWrite(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, _ := mkRepos(t, openFileStore(t, dir), 0)
+	kept, _ := repo.Create(&domain.NewTodo{Task: "clean up after yourself"})
+	_ = repo.(io.Closer).Close()
+
+	log, _ := os.OpenFile(filepath.Join(dir, eventsFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = log.WriteString(`[{"seq":2,"type":"todo_cr`)
+	_ = log.Close()
+
+	repo, _ = mkRepos(t, openFileStore(t, dir), 0)
+	created, _ := repo.Create(&domain.NewTodo{Task: "after the crash"})
+	repo = reopen(t, repo, dir, 0)
+	page, _ := repo.List(&domain.TodoQuery{})
+	assert.Equal(t, []domain.Todo{kept, created}, page.Todos)
+	_ = repo.(io.Closer).Close()
+}
+
+func TestFileStoreRejectsCorruptLog(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	_ = ioutil.WriteFile(filepath.Join(dir, eventsFileName), []byte("not json\n"), 0644)
+	_, err := OpenFileStore(dir)
+	assert.NotNil(t, err)
+}
+
+func TestFileRepoContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (domain.TodoRepo, func()) {
+		dir := mkTempDir(t)
+		repo, _ := mkRepos(t, openFileStore(t, dir), 5)
+		return repo, func() {
+			_ = repo.(io.Closer).Close()
+			_ = os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestFileListRepoContract(t *testing.T) {
+	repotest.RunLists(t, func(t *testing.T) (domain.TodoListRepo, func()) {
+		dir := mkTempDir(t)
+		repo, lists := mkRepos(t, openFileStore(t, dir), 2)
+		return lists, func() {
+			_ = repo.(io.Closer).Close()
+			_ = os.RemoveAll(dir)
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package eventsourced
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// projection is the state of Todos and TodoLists made by folding events.
+// It is not safe for concurrent use.
+//
+// The Todos in it are never changed in place, only replaced, so they can
+// be handed out and shared between copies.
+type projection struct {
+	// seq and at are those of the last event folded in
+	seq uint64
+	at  time.Time
+	// todos never have Blocked set; see load
+	todos map[domain.TodoID]domain.Todo
+	// ids holds the keys of todos in ascending order
+	ids   []domain.TodoID
+	trash map[domain.TodoID]domain.TrashedTodo
+	// revisions holds the history of every Todo there ever was, oldest
+	// revision first
+	revisions  map[domain.TodoID][]domain.TodoRevision
+	lists      map[domain.TodoListID]string
+	lastId     domain.TodoID
+	lastListId domain.TodoListID
+	// lastPosition is the largest Position handed out so far, which new
+	// Todos go after
+	lastPosition string
+}
+
+func mkProjection() *projection {
+	return &projection{
+		todos:     make(map[domain.TodoID]domain.Todo),
+		trash:     make(map[domain.TodoID]domain.TrashedTodo),
+		revisions: make(map[domain.TodoID][]domain.TodoRevision),
+		lists:     make(map[domain.TodoListID]string),
+	}
+}
+
+// apply folds the given event, which must be the next one, into the state
+func (p *projection) apply(event *Event) {
+	p.seq = event.Seq
+	p.at = event.At
+	switch event.Type {
+	case TodoCreated:
+		todo := *event.Todo
+		p.put(todo)
+		p.revise(event, domain.CreatedRevision, todo)
+	case TodoUpdated:
+		todo := *event.Todo
+		p.put(todo)
+		p.revise(event, domain.UpdatedRevision, todo)
+	case TodoDeleted:
+		todo, exists := p.todos[event.TodoID]
+		if !exists {
+			return
+		}
+		p.remove(todo.ID)
+		p.trash[todo.ID] = domain.TrashedTodo{Todo: todo, DeletedAt: event.At}
+		p.revise(event, domain.DeletedRevision, todo)
+	case TodoRestored:
+		trashed, exists := p.trash[event.TodoID]
+		if !exists {
+			return
+		}
+		todo := trashed.Todo
+		todo.Version++
+		delete(p.trash, todo.ID)
+		p.put(todo)
+		p.revise(event, domain.RestoredRevision, todo)
+	case TodoPurged:
+		delete(p.trash, event.TodoID)
+	case TodoListCreated, TodoListUpdated:
+		p.lists[event.List.ID] = event.List.Name
+		if event.List.ID > p.lastListId {
+			p.lastListId = event.List.ID
+		}
+	case TodoListDeleted:
+		delete(p.lists, event.ListID)
+	}
+}
+
+// put stores the given Todo in place of the one with the same id, if any
+func (p *projection) put(todo domain.Todo) {
+	todo.Blocked = false
+	if _, exists := p.todos[todo.ID]; !exists {
+		i := sort.Search(len(p.ids), func(i int) bool { return p.ids[i] >= todo.ID })
+		p.ids = append(p.ids, 0)
+		copy(p.ids[i+1:], p.ids[i:])
+		p.ids[i] = todo.ID
+	}
+	p.todos[todo.ID] = todo
+	if todo.ID > p.lastId {
+		p.lastId = todo.ID
+	}
+	if todo.Position > p.lastPosition {
+		p.lastPosition = todo.Position
+	}
+}
+
+// remove removes the Todo with the given id, if it is stored
+func (p *projection) remove(id domain.TodoID) {
+	delete(p.todos, id)
+	i := sort.Search(len(p.ids), func(i int) bool { return p.ids[i] >= id })
+	if i < len(p.ids) && p.ids[i] == id {
+		p.ids = append(p.ids[:i], p.ids[i+1:]...)
+	}
+}
+
+// revise records the change made to the given Todo by the given event as
+// its next revision
+func (p *projection) revise(event *Event, change domain.RevisionChange, todo domain.Todo) {
+	revisions := p.revisions[todo.ID]
+	p.revisions[todo.ID] = append(revisions, domain.TodoRevision{
+		Rev:    uint(len(revisions)) + 1,
+		Change: change,
+		At:     event.At,
+		Actor:  event.Actor,
+		Todo:   todo,
+	})
+}
+
+// load returns the Todo with the given id, which must be stored, working
+// out whether it is blocked
+func (p *projection) load(id domain.TodoID) domain.Todo {
+	todo := p.todos[id]
+	for _, dependency := range todo.DependsOn {
+		if stored, exists := p.todos[dependency]; exists && !stored.Completed {
+			todo.Blocked = true
+			break
+		}
+	}
+	return todo
+}
+
+// nextPosition returns a Position after all the ones handed out so far
+func (p *projection) nextPosition() string {
+	// lastPosition is always valid, so this can't fail
+	position, _ := domain.PositionBetween(p.lastPosition, "")
+	return position
+}
+
+// copy returns a copy of the state, which can be changed without changing
+// this one
+func (p *projection) copy() *projection {
+	copied := *p
+	copied.todos = make(map[domain.TodoID]domain.Todo, len(p.todos))
+	for id, todo := range p.todos {
+		copied.todos[id] = todo
+	}
+	copied.ids = append(make([]domain.TodoID, 0, len(p.ids)), p.ids...)
+	copied.trash = make(map[domain.TodoID]domain.TrashedTodo, len(p.trash))
+	for id, trashed := range p.trash {
+		copied.trash[id] = trashed
+	}
+	copied.revisions = make(map[domain.TodoID][]domain.TodoRevision, len(p.revisions))
+	for id, revisions := range p.revisions {
+		// capped, so that appending to either copy does not write over
+		// the revisions of the other
+		copied.revisions[id] = revisions[:len(revisions):len(revisions)]
+	}
+	copied.lists = make(map[domain.TodoListID]string, len(p.lists))
+	for id, name := range p.lists {
+		copied.lists[id] = name
+	}
+	return &copied
+}
+
+// snapshot returns a Snapshot of the state
+func (p *projection) snapshot() *Snapshot {
+	snapshot := &Snapshot{
+		Seq:          p.seq,
+		At:           p.at,
+		LastID:       p.lastId,
+		LastListID:   p.lastListId,
+		LastPosition: p.lastPosition,
+		Todos:        make([]domain.Todo, 0, len(p.ids)),
+		Trash:        make([]domain.TrashedTodo, 0, len(p.trash)),
+		History:      make([]domain.TodoRevision, 0, len(p.revisions)),
+		Lists:        p.listsInOrder(),
+	}
+	for _, id := range p.ids {
+		snapshot.Todos = append(snapshot.Todos, p.todos[id])
+	}
+	for _, id := range sortedIds(p.trash) {
+		snapshot.Trash = append(snapshot.Trash, p.trash[id])
+	}
+	revisedIds := make([]domain.TodoID, 0, len(p.revisions))
+	for id := range p.revisions {
+		revisedIds = append(revisedIds, id)
+	}
+	sort.Slice(revisedIds, func(i, j int) bool { return revisedIds[i] < revisedIds[j] })
+	for _, id := range revisedIds {
+		snapshot.History = append(snapshot.History, p.revisions[id]...)
+	}
+	return snapshot
+}
+
+// projectionOf returns the state the given Snapshot was taken of
+func projectionOf(snapshot *Snapshot) *projection {
+	p := mkProjection()
+	p.seq = snapshot.Seq
+	p.at = snapshot.At
+	for _, todo := range snapshot.Todos {
+		p.put(todo)
+	}
+	for _, trashed := range snapshot.Trash {
+		p.trash[trashed.Todo.ID] = trashed
+	}
+	for _, revision := range snapshot.History {
+		p.revisions[revision.Todo.ID] = append(p.revisions[revision.Todo.ID], revision)
+	}
+	for _, list := range snapshot.Lists {
+		p.lists[list.ID] = list.Name
+	}
+	p.lastId = snapshot.LastID
+	p.lastListId = snapshot.LastListID
+	p.lastPosition = snapshot.LastPosition
+	return p
+}
+
+// listsInOrder returns all the TodoLists in order of id
+func (p *projection) listsInOrder() []domain.TodoList {
+	lists := make([]domain.TodoList, 0, len(p.lists))
+	for id, name := range p.lists {
+		lists = append(lists, domain.TodoList{ID: id, Name: name})
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+	return lists
+}
+
+// sortedIds returns the ids of the given trashed Todos in ascending order
+func sortedIds(trash map[domain.TodoID]domain.TrashedTodo) []domain.TodoID {
+	ids := make([]domain.TodoID, 0, len(trash))
+	for id := range trash {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,104 @@
+package eventsourced
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EventStore keeps the events that happened to Todos and TodoLists, in
+// order and for good, along with the latest Snapshot of their state
+type EventStore interface {
+	// Append stores the given events, which must be numbered on from the
+	// last one stored, all together or not at all
+	Append(events []Event) error
+	// Events returns the events numbered after the given one, in order
+	Events(after uint64) ([]Event, error)
+	// SaveSnapshot stores the given snapshot in place of the latest one
+	SaveSnapshot(snapshot *Snapshot) error
+	// Snapshot returns the latest snapshot, or nil if there is none
+	Snapshot() (*Snapshot, error)
+}
+
+// EventGap is returned when appending events that do not follow on from
+// the last one stored
+type EventGap struct {
+	Last uint64
+	Next uint64
+}
+
+func (e EventGap) Error() string {
+	return fmt.Sprintf("Event [%v] does not follow on from event [%v]", e.Next, e.Last)
+}
+
+// checkSequence returns an EventGap unless the given events are numbered
+// one after the other, on from last
+func checkSequence(last uint64, events []Event) error {
+	for _, event := range events {
+		if event.Seq != last+1 {
+			return EventGap{Last: last, Next: event.Seq}
+		}
+		last = event.Seq
+	}
+	return nil
+}
+
+// memoryStore is an EventStore that keeps everything in memory
+type memoryStore struct {
+	mutex    sync.Mutex
+	events   []Event
+	snapshot *Snapshot
+}
+
+// MkMemoryStore returns an EventStore that keeps everything in memory, so
+// that it is lost on restart
+func MkMemoryStore() EventStore {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Append(events []Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := checkSequence(s.lastSeq(), events); err != nil {
+		return err
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *memoryStore) Events(after uint64) ([]Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	found := make([]Event, 0)
+	for _, event := range s.events {
+		if event.Seq > after {
+			found = append(found, event)
+		}
+	}
+	return found, nil
+}
+
+func (s *memoryStore) SaveSnapshot(snapshot *Snapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snapshot = snapshot
+	return nil
+}
+
+func (s *memoryStore) Snapshot() (*Snapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.snapshot, nil
+}
+
+// lastSeq is the number of the last event there is, even if only as part
+// of the snapshot. Must be called with the mutex held.
+func (s *memoryStore) lastSeq() uint64 {
+	var last uint64
+	if s.snapshot != nil {
+		last = s.snapshot.Seq
+	}
+	if len(s.events) > 0 && s.events[len(s.events)-1].Seq > last {
+		last = s.events[len(s.events)-1].Seq
+	}
+	return last
+}
@@ -0,0 +1,107 @@
+package eventsourced
+
+import (
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// listRepoImpl is the domain.TodoListRepo for the TodoLists kept in the
+// same events as the Todos of a repoImpl
+type listRepoImpl struct {
+	core *repoCore
+}
+
+func (l *listRepoImpl) writer() *writer {
+	return &writer{core: l.core}
+}
+
+func (l *listRepoImpl) Create(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+	return l.writer().createList(newList)
+}
+
+func (l *listRepoImpl) Get(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+	return l.writer().getList(id)
+}
+
+func (l *listRepoImpl) List() ([]domain.TodoList, domain.TodoListRepoError) {
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+	return l.core.state.listsInOrder(), nil
+}
+
+func (l *listRepoImpl) Update(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+	return l.writer().updateList(list)
+}
+
+func (l *listRepoImpl) Delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+	return l.writer().deleteList(id)
+}
+
+func (w *writer) createList(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	list := domain.TodoList{ID: w.state().lastListId + 1, Name: newList.Name}
+	if err := w.emit(Event{Type: TodoListCreated, List: &list}); err != nil {
+		return domain.TodoList{}, domain.TodoListRepoFailure{ID: list.ID, Cause: err}
+	}
+	return list, nil
+}
+
+func (w *writer) getList(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	if name, exists := w.state().lists[*id]; exists {
+		return domain.TodoList{ID: *id, Name: name}, nil
+	} else {
+		return domain.TodoList{}, domain.TodoListNotFound{ID: *id}
+	}
+}
+
+func (w *writer) updateList(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	if _, exists := w.state().lists[list.ID]; !exists {
+		return domain.TodoList{}, domain.TodoListNotFound{ID: list.ID}
+	}
+	updated := *list
+	if err := w.emit(Event{Type: TodoListUpdated, List: &updated}); err != nil {
+		return domain.TodoList{}, domain.TodoListRepoFailure{ID: list.ID, Cause: err}
+	}
+	return updated, nil
+}
+
+func (w *writer) deleteList(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	if _, exists := w.state().lists[*id]; !exists {
+		return false, domain.TodoListNotFound{ID: *id}
+	}
+	if err := w.emit(Event{Type: TodoListDeleted, ListID: *id}); err != nil {
+		return false, domain.TodoListRepoFailure{ID: *id, Cause: err}
+	}
+	return true, nil
+}
+
+// txListRepo is txRepo for TodoLists
+type txListRepo struct {
+	w *writer
+}
+
+func (t *txListRepo) Create(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	return t.w.createList(newList)
+}
+
+func (t *txListRepo) Get(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	return t.w.getList(id)
+}
+
+func (t *txListRepo) List() ([]domain.TodoList, domain.TodoListRepoError) {
+	return t.w.state().listsInOrder(), nil
+}
+
+func (t *txListRepo) Update(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	return t.w.updateList(list)
+}
+
+func (t *txListRepo) Delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	return t.w.deleteList(id)
+}
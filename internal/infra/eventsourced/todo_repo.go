@@ -0,0 +1,552 @@
+package eventsourced
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// repoCore is what the repos working on the same events share
+type repoCore struct {
+	mutex sync.Mutex
+	store EventStore
+	state *projection
+	// snapshotEvery is how many events get appended between snapshots;
+	// never if 0
+	snapshotEvery int
+	// number of events appended since the last snapshot
+	appended int
+}
+
+// repoImpl is a domain.TodoRepo whose Todos are a projection of the events
+// in its EventStore. Changes are made by appending events to the store,
+// then folding them into the projection.
+type repoImpl struct {
+	*repoCore
+	// actor is who the changes made through the repo are made by
+	actor string
+}
+
+// MkRepos returns a new TodoRepo and TodoListRepo whose state is rebuilt
+// from the events in the given store, starting from its latest snapshot,
+// and which append the events making their changes to it. A snapshot is
+// saved every snapshotEvery events (never if 0).
+//
+// The returned TodoRepo implements io.Closer, closing the store if it can.
+func MkRepos(store EventStore, snapshotEvery int) (domain.TodoRepo, domain.TodoListRepo, error) {
+	state, err := rebuild(store, time.Time{})
+	if err != nil {
+		return nil, nil, err
+	}
+	core := &repoCore{store: store, state: state, snapshotEvery: snapshotEvery}
+	return &repoImpl{repoCore: core}, &listRepoImpl{core: core}, nil
+}
+
+// Replay returns a TodoRepo and TodoListRepo whose state is what it was in
+// the given store as of the given time, for looking into how it came to be.
+// They keep whatever changes are made through them in memory, leaving the
+// store alone.
+func Replay(store EventStore, until time.Time) (domain.TodoRepo, domain.TodoListRepo, error) {
+	state, err := rebuild(store, until)
+	if err != nil {
+		return nil, nil, err
+	}
+	replayed := MkMemoryStore()
+	if err := replayed.SaveSnapshot(state.snapshot()); err != nil {
+		return nil, nil, err
+	}
+	core := &repoCore{store: replayed, state: state}
+	return &repoImpl{repoCore: core}, &listRepoImpl{core: core}, nil
+}
+
+// rebuild folds the events in the given store, up to the given time unless
+// it is zero, starting from the latest snapshot taken by then
+func rebuild(store EventStore, until time.Time) (*projection, error) {
+	state := mkProjection()
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil && (until.IsZero() || !snapshot.At.After(until)) {
+		state = projectionOf(snapshot)
+	}
+	events, err := store.Events(state.seq)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		if !until.IsZero() && events[i].At.After(until) {
+			break
+		}
+		if events[i].Seq != state.seq+1 {
+			return nil, EventGap{Last: state.seq, Next: events[i].Seq}
+		}
+		state.apply(&events[i])
+	}
+	return state, nil
+}
+
+func (r *repoImpl) writer() *writer {
+	return &writer{core: r.repoCore, actor: r.actor}
+}
+
+func (r *repoImpl) Create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().create(newTodo)
+}
+
+func (r *repoImpl) Get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().get(id)
+}
+
+func (r *repoImpl) List(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().list(query)
+}
+
+func (r *repoImpl) Delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().delete(id, version)
+}
+
+func (r *repoImpl) Update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().update(todo)
+}
+
+func (r *repoImpl) ListTags() ([]domain.TagCount, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().listTags()
+}
+
+func (r *repoImpl) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().search(search)
+}
+
+func (r *repoImpl) ListTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().listTrash()
+}
+
+func (r *repoImpl) Restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().restore(id)
+}
+
+func (r *repoImpl) Purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().purge(id)
+}
+
+func (r *repoImpl) PurgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().purgeDeletedBefore(before)
+}
+
+func (r *repoImpl) History(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.writer().history(id)
+}
+
+func (r *repoImpl) AsActor(actor string) domain.TodoRepo {
+	return &repoImpl{repoCore: r.repoCore, actor: actor}
+}
+
+// WithinTx runs the given function against a copy of the state, which the
+// events it makes get folded into as they are made. They are appended to
+// the store all together once it returns nil, and the copy takes the place
+// of the state; otherwise the copy and the events are simply dropped.
+func (r *repoImpl) WithinTx(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	t := &tx{state: r.state.copy()}
+	w := &writer{core: r.repoCore, tx: t, actor: r.actor}
+	if err := f(&txRepo{w: w}, &txListRepo{w: w}); err != nil {
+		return err
+	}
+	if len(t.events) == 0 {
+		return nil
+	}
+	if err := r.store.Append(t.events); err != nil {
+		return domain.TodoRepoFailure{Cause: err}
+	}
+	r.state = t.state
+	r.snapshotIfDue(len(t.events))
+	return nil
+}
+
+// Close closes the store, if it can be
+func (r *repoImpl) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if closer, ok := r.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// snapshotIfDue saves a snapshot if enough events have been appended since
+// the last one was. Must be called with the mutex held.
+func (c *repoCore) snapshotIfDue(appended int) {
+	c.appended += appended
+	if c.snapshotEvery <= 0 || c.appended < c.snapshotEvery {
+		return
+	}
+	// The events are already safe in the store, so a failed snapshot is
+	// not fatal; it will simply be retried after the next change.
+	if err := c.store.SaveSnapshot(c.state.snapshot()); err == nil {
+		c.appended = 0
+	}
+}
+
+// tx holds what a function run by WithinTx has done so far
+type tx struct {
+	// state is the copy of the state the function works on
+	state *projection
+	// events are those made so far, already folded into state
+	events []Event
+}
+
+// writer does the work of the TodoRepo and TodoListRepo methods, making
+// changes as its actor, either for good or within a transaction. Its
+// methods must be called with the mutex held.
+type writer struct {
+	core *repoCore
+	// nil unless running a function for WithinTx
+	tx    *tx
+	actor string
+}
+
+// state returns the state the writer works on
+func (w *writer) state() *projection {
+	if w.tx != nil {
+		return w.tx.state
+	}
+	return w.core.state
+}
+
+// emit makes the given events, as of now, and folds them into the state.
+// Outside of transactions, they are appended to the store first.
+func (w *writer) emit(events ...Event) error {
+	state := w.state()
+	at := time.Now().UTC()
+	for i := range events {
+		events[i].Seq = state.seq + uint64(i) + 1
+		events[i].At = at
+		events[i].Actor = w.actor
+	}
+	if w.tx != nil {
+		w.tx.events = append(w.tx.events, events...)
+	} else if err := w.core.store.Append(events); err != nil {
+		return err
+	}
+	for i := range events {
+		state.apply(&events[i])
+	}
+	if w.tx == nil {
+		w.core.snapshotIfDue(len(events))
+	}
+	return nil
+}
+
+func (w *writer) create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+	state := w.state()
+	todo := domain.Todo{
+		ID:          state.lastId + 1,
+		Version:     1,
+		Task:        newTodo.Task,
+		Completed:   newTodo.Completed,
+		CompletedAt: newTodo.CompletedAt,
+		DueAt:       newTodo.DueAt,
+		Tags:        domain.NormaliseTags(newTodo.Tags),
+		Priority:    newTodo.Priority,
+		ParentID:    newTodo.ParentID,
+		DependsOn:   domain.NormaliseIDs(newTodo.DependsOn),
+		Recurrence:  newTodo.Recurrence,
+		ListID:      newTodo.ListID,
+		Position:    newTodo.Position,
+	}
+	if len(todo.Position) == 0 {
+		todo.Position = state.nextPosition()
+	}
+	if err := w.emit(Event{Type: TodoCreated, Todo: &todo}); err != nil {
+		return domain.Todo{}, domain.TodoRepoFailure{ID: todo.ID, Cause: err}
+	}
+	return state.load(todo.ID), nil
+}
+
+func (w *writer) get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	state := w.state()
+	if _, exists := state.todos[*id]; exists {
+		return state.load(*id), nil
+	} else {
+		return domain.Todo{}, domain.TodoNotFound{ID: *id}
+	}
+}
+
+func (w *writer) list(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+	state := w.state()
+	if !query.SortedByID() {
+		return w.listSorted(query), nil
+	}
+	start := 0
+	if query.After != nil {
+		start = sort.Search(len(state.ids), func(i int) bool { return state.ids[i] > query.After.ID })
+	}
+	retrieved := make([]domain.Todo, 0)
+	for _, id := range state.ids[start:] {
+		if query.Limit > 0 && uint(len(retrieved)) > query.Limit {
+			break
+		}
+		todo := state.load(id)
+		if query.Matches(&todo) {
+			retrieved = append(retrieved, todo)
+		}
+	}
+	return domain.MkTodoPage(retrieved, query.Limit), nil
+}
+
+// listSorted lists Todos in any order, which means going through (and
+// sorting) all the ones that match
+func (w *writer) listSorted(query *domain.TodoQuery) domain.TodoPage {
+	state := w.state()
+	retrieved := make([]domain.Todo, 0)
+	for _, id := range state.ids {
+		todo := state.load(id)
+		if query.Matches(&todo) && query.IsAfter(&todo) {
+			retrieved = append(retrieved, todo)
+		}
+	}
+	sort.Slice(retrieved, func(i, j int) bool { return query.Compare(&retrieved[i], &retrieved[j]) < 0 })
+	if query.Limit > 0 && uint(len(retrieved)) > query.Limit+1 {
+		retrieved = retrieved[:query.Limit+1]
+	}
+	return domain.MkTodoPage(retrieved, query.Limit)
+}
+
+func (w *writer) delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+	existing, exists := w.state().todos[*id]
+	if !exists {
+		return false, domain.TodoNotFound{ID: *id}
+	}
+	if err := domain.CheckVersion(*id, version, existing.Version); err != nil {
+		return false, err
+	}
+	if err := w.emit(Event{Type: TodoDeleted, TodoID: *id}); err != nil {
+		return false, domain.TodoRepoFailure{ID: *id, Cause: err}
+	}
+	return true, nil
+}
+
+func (w *writer) update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+	state := w.state()
+	existing, exists := state.todos[todo.ID]
+	if !exists {
+		return domain.Todo{}, domain.TodoNotFound{ID: todo.ID}
+	}
+	if err := domain.CheckVersion(todo.ID, todo.Version, existing.Version); err != nil {
+		return domain.Todo{}, err
+	}
+	updated := *todo
+	updated.Version = existing.Version + 1
+	updated.Tags = domain.NormaliseTags(todo.Tags)
+	updated.DependsOn = domain.NormaliseIDs(todo.DependsOn)
+	updated.Blocked = false
+	if len(updated.Position) == 0 {
+		updated.Position = existing.Position
+	}
+	if err := w.emit(Event{Type: TodoUpdated, Todo: &updated}); err != nil {
+		return domain.Todo{}, domain.TodoRepoFailure{ID: todo.ID, Cause: err}
+	}
+	return state.load(todo.ID), nil
+}
+
+func (w *writer) listTags() ([]domain.TagCount, domain.TodoRepoError) {
+	counts := make(map[string]uint)
+	for _, todo := range w.state().todos {
+		for _, tag := range todo.Tags {
+			counts[tag]++
+		}
+	}
+	tagCounts := make([]domain.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tagCounts = append(tagCounts, domain.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tagCounts, func(i, j int) bool { return tagCounts[i].Tag < tagCounts[j].Tag })
+	return tagCounts, nil
+}
+
+// search goes through the tasks of all of the Todos, as nothing is indexed
+func (w *writer) search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	state := w.state()
+	tokens := domain.Tokenise(search.Text)
+	var postings []domain.Posting
+	for _, id := range state.ids {
+		terms := domain.Tokenise(state.todos[id].Task)
+		for term, occurrences := range domain.TermCounts(terms) {
+			for _, token := range tokens {
+				if lower, upper := domain.PrefixRange(token); term >= lower && term < upper {
+					postings = append(postings, domain.Posting{
+						ID:          id,
+						Term:        term,
+						Occurrences: occurrences,
+						Length:      uint(len(terms)),
+					})
+				}
+			}
+		}
+	}
+	hits := domain.RankSearch(tokens, postings, uint(len(state.ids)), search.Limit)
+	results := make([]domain.TodoSearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = domain.TodoSearchResult{Todo: state.load(hit.ID), Score: hit.Score}
+	}
+	return results, nil
+}
+
+func (w *writer) listTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	state := w.state()
+	trashed := make([]domain.TrashedTodo, 0, len(state.trash))
+	for _, task := range state.trash {
+		trashed = append(trashed, task)
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		if !trashed[i].DeletedAt.Equal(trashed[j].DeletedAt) {
+			return trashed[i].DeletedAt.After(trashed[j].DeletedAt)
+		}
+		return trashed[i].Todo.ID > trashed[j].Todo.ID
+	})
+	return trashed, nil
+}
+
+func (w *writer) restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	state := w.state()
+	if _, exists := state.trash[*id]; !exists {
+		return domain.Todo{}, domain.TodoNotFound{ID: *id}
+	}
+	if err := w.emit(Event{Type: TodoRestored, TodoID: *id}); err != nil {
+		return domain.Todo{}, domain.TodoRepoFailure{ID: *id, Cause: err}
+	}
+	return state.load(*id), nil
+}
+
+func (w *writer) purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	if _, exists := w.state().trash[*id]; !exists {
+		return false, domain.TodoNotFound{ID: *id}
+	}
+	if err := w.emit(Event{Type: TodoPurged, TodoID: *id}); err != nil {
+		return false, domain.TodoRepoFailure{ID: *id, Cause: err}
+	}
+	return true, nil
+}
+
+// purgeDeletedBefore purges the expired Todos all together, with an event
+// each
+func (w *writer) purgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	state := w.state()
+	var events []Event
+	for _, id := range sortedIds(state.trash) {
+		if state.trash[id].DeletedAt.Before(before) {
+			events = append(events, Event{Type: TodoPurged, TodoID: id})
+		}
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if err := w.emit(events...); err != nil {
+		return 0, domain.TodoRepoFailure{Cause: err}
+	}
+	return len(events), nil
+}
+
+func (w *writer) history(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	state := w.state()
+	revisions := state.revisions[*id]
+	if len(revisions) == 0 {
+		_, stored := state.todos[*id]
+		_, trashed := state.trash[*id]
+		if !stored && !trashed {
+			return nil, domain.TodoNotFound{ID: *id}
+		}
+	}
+	return append(make([]domain.TodoRevision, 0, len(revisions)), revisions...), nil
+}
+
+// txRepo is the TodoRepo handed to functions run by WithinTx, which works
+// on the copy of the state without taking the mutex, as it is already held
+type txRepo struct {
+	w *writer
+}
+
+func (t *txRepo) Create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+	return t.w.create(newTodo)
+}
+
+func (t *txRepo) Get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	return t.w.get(id)
+}
+
+func (t *txRepo) List(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+	return t.w.list(query)
+}
+
+func (t *txRepo) Delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+	return t.w.delete(id, version)
+}
+
+func (t *txRepo) Update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+	return t.w.update(todo)
+}
+
+func (t *txRepo) ListTags() ([]domain.TagCount, domain.TodoRepoError) {
+	return t.w.listTags()
+}
+
+func (t *txRepo) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	return t.w.search(search)
+}
+
+func (t *txRepo) ListTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	return t.w.listTrash()
+}
+
+func (t *txRepo) Restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	return t.w.restore(id)
+}
+
+func (t *txRepo) Purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	return t.w.purge(id)
+}
+
+func (t *txRepo) PurgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	return t.w.purgeDeletedBefore(before)
+}
+
+func (t *txRepo) History(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	return t.w.history(id)
+}
+
+func (t *txRepo) AsActor(actor string) domain.TodoRepo {
+	return &txRepo{w: &writer{core: t.w.core, tx: t.w.tx, actor: actor}}
+}
+
+func (t *txRepo) WithinTx(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	return f(t, &txListRepo{w: t.w})
+}
@@ -0,0 +1,151 @@
+package eventsourced
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/repotest"
+	"github.com/stretchr/testify/assert"
+)
+
+func mkRepos(t *testing.T, store EventStore, snapshotEvery int) (domain.TodoRepo, domain.TodoListRepo) {
+	repo, lists, err := MkRepos(store, snapshotEvery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, lists
+}
+
+func TestTodoRepoContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (domain.TodoRepo, func()) {
+		repo, _ := mkRepos(t, MkMemoryStore(), 3)
+		return repo, func() {}
+	})
+}
+
+func TestTodoListRepoContract(t *testing.T) {
+	repotest.RunLists(t, func(t *testing.T) (domain.TodoListRepo, func()) {
+		_, lists := mkRepos(t, MkMemoryStore(), 3)
+		return lists, func() {}
+	})
+}
+
+func TestRebuildsFromEvents(t *testing.T) {
+	store := MkMemoryStore()
+	repo, lists := mkRepos(t, store, 0)
+	list, _ := lists.Create(&domain.NewTodoList{Name: "chores"})
+	kept, _ := repo.AsActor("alice").Create(&domain.NewTodo{Task: "clean up", ListID: &list.ID})
+	kept.Task = "clean up after yourself"
+	kept, _ = repo.Update(&kept)
+	trashed, _ := repo.Create(&domain.NewTodo{Task: "forget me"})
+	_, _ = repo.Delete(&trashed.ID, 0)
+
+	rebuilt, rebuiltLists := mkRepos(t, store, 0)
+	page, _ := rebuilt.List(&domain.TodoQuery{})
+	assert.Equal(t, []domain.Todo{kept}, page.Todos)
+	trash, _ := rebuilt.ListTrash()
+	assert.Len(t, trash, 1)
+	assert.Equal(t, trashed.ID, trash[0].Todo.ID)
+	allLists, _ := rebuiltLists.List()
+	assert.Equal(t, []domain.TodoList{list}, allLists)
+	history, _ := rebuilt.History(&kept.ID)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "alice", history[0].Actor)
+	next, _ := rebuilt.Create(&domain.NewTodo{Task: "brand new"})
+	assert.Equal(t, trashed.ID+1, next.ID)
+}
+
+func TestSnapshots(t *testing.T) {
+	store := MkMemoryStore()
+	repo, _ := mkRepos(t, store, 2)
+	first, _ := repo.Create(&domain.NewTodo{Task: "first"})
+	snapshot, _ := store.Snapshot()
+	assert.Nil(t, snapshot)
+	second, _ := repo.Create(&domain.NewTodo{Task: "second"})
+	snapshot, _ = store.Snapshot()
+	if assert.NotNil(t, snapshot) {
+		assert.Equal(t, uint64(2), snapshot.Seq)
+		assert.Len(t, snapshot.Todos, 2)
+	}
+	third, _ := repo.Create(&domain.NewTodo{Task: "third"})
+
+	rebuilt, _ := mkRepos(t, store, 2)
+	page, _ := rebuilt.List(&domain.TodoQuery{})
+	assert.Equal(t, []domain.Todo{first, second, third}, page.Todos)
+}
+
+func TestTransactionsAppendTogether(t *testing.T) {
+	store := MkMemoryStore()
+	repo, _ := mkRepos(t, store, 0)
+	_ = repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		_, _ = todos.Create(&domain.NewTodo{Task: "first"})
+		_, _ = todos.Create(&domain.NewTodo{Task: "second"})
+		return nil
+	})
+	err := repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		_, _ = todos.Create(&domain.NewTodo{Task: "rolled back"})
+		return domain.TodoNotFound{ID: 9}
+	})
+	assert.Equal(t, domain.TodoNotFound{ID: 9}, err)
+	events, _ := store.Events(0)
+	assert.Len(t, events, 2)
+	page, _ := repo.List(&domain.TodoQuery{})
+	assert.Len(t, page.Todos, 2)
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	store := MkMemoryStore()
+	_ = store.Append([]Event{
+		{Seq: 1, Type: TodoCreated, At: at(0), Todo: &domain.Todo{ID: 1, Version: 1, Task: "first"}},
+		{Seq: 2, Type: TodoCreated, At: at(1), Todo: &domain.Todo{ID: 2, Version: 1, Task: "second"}},
+	})
+	_ = store.Append([]Event{
+		{Seq: 3, Type: TodoUpdated, At: at(2), Todo: &domain.Todo{ID: 1, Version: 2, Task: "first, changed"}},
+		{Seq: 4, Type: TodoDeleted, At: at(3), TodoID: 2},
+	})
+
+	replayed, _, err := Replay(store, at(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := replayed.List(&domain.TodoQuery{})
+	if assert.Len(t, page.Todos, 2) {
+		assert.Equal(t, "first, changed", page.Todos[0].Task)
+		assert.Equal(t, "second", page.Todos[1].Task)
+	}
+	// changes to what was replayed are kept away from the store
+	_, _ = replayed.Create(&domain.NewTodo{Task: "what if"})
+	events, _ := store.Events(0)
+	assert.Len(t, events, 4)
+
+	replayed, _, _ = Replay(store, at(0))
+	page, _ = replayed.List(&domain.TodoQuery{})
+	if assert.Len(t, page.Todos, 1) {
+		assert.Equal(t, "first", page.Todos[0].Task)
+	}
+}
+
+func TestReplayIgnoresLaterSnapshots(t *testing.T) {
+	store := MkMemoryStore()
+	repo, _ := mkRepos(t, store, 1)
+	_, _ = repo.Create(&domain.NewTodo{Task: "first"})
+	_, _ = repo.Create(&domain.NewTodo{Task: "second"})
+	events, _ := store.Events(0)
+
+	replayed, _, err := Replay(store, events[0].At)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := replayed.List(&domain.TodoQuery{})
+	assert.True(t, len(page.Todos) >= 1)
+	assert.Equal(t, "first", page.Todos[0].Task)
+}
+
+func TestAppendRejectsGaps(t *testing.T) {
+	store := MkMemoryStore()
+	err := store.Append([]Event{{Seq: 2, Type: TodoDeleted, TodoID: 1}})
+	assert.Equal(t, EventGap{Last: 0, Next: 2}, err)
+}
@@ -0,0 +1,333 @@
+package inmem
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+const (
+	logFileName      = "todos.log"
+	snapshotFileName = "todos.snapshot"
+)
+
+// journal is an append-only, write-ahead log of the changes made to a
+// repoImpl, which gets compacted into a snapshot every so often so that
+// it does not grow forever.
+//
+// Entries are JSON, one per line. Replaying them is idempotent, so
+// crashing between writing a snapshot and truncating the log is harmless.
+type journal struct {
+	dir string
+	log journalFile
+	// size is where the last complete entry of the log ends
+	size int64
+	// torn is set when a failed append could not be dropped from the log
+	// yet, which has to happen before anything else is appended
+	torn         bool
+	compactEvery int
+	// number of entries appended since the last compaction
+	appended int
+}
+
+// journalFile is the part of *os.File a journal writes its log with
+type journalFile interface {
+	io.WriterAt
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+type journalOp string
+
+const (
+	putOp        journalOp = "put"
+	deleteOp     journalOp = "delete"
+	putListOp    journalOp = "put_list"
+	deleteListOp journalOp = "delete_list"
+	// batchOp holds the entries of a transaction, so that they get
+	// replayed all together or not at all
+	batchOp journalOp = "batch"
+	// revisionOp holds a revision in a snapshot; changes are journaled
+	// along with the revisions they make, as put ops
+	revisionOp journalOp = "revision"
+	// dropRevisionOp undoes the revision made by a put op within a
+	// transaction that gets rolled back, so it is never journaled
+	dropRevisionOp journalOp = "drop_revision"
+)
+
+type journalEntry struct {
+	Op          journalOp          `json:"op"`
+	ID          domain.TodoID      `json:"id"`
+	Version     domain.TodoVersion `json:"version,omitempty"`
+	Task        string             `json:"task,omitempty"`
+	Completed   bool               `json:"completed,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	DueAt       *time.Time         `json:"due_at,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Priority    domain.Priority    `json:"priority,omitempty"`
+	ParentID    *domain.TodoID     `json:"parent_id,omitempty"`
+	DependsOn   []domain.TodoID    `json:"depends_on,omitempty"`
+	Recurrence  string             `json:"recurrence,omitempty"`
+	Position    string             `json:"position,omitempty"`
+	// DeletedAt is set when putting a Todo in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Rev is set when the change is recorded in the history of the Todo,
+	// as Change, made At the given time by Actor
+	Rev    uint                  `json:"rev,omitempty"`
+	Change domain.RevisionChange `json:"change,omitempty"`
+	At     *time.Time            `json:"at,omitempty"`
+	Actor  string                `json:"actor,omitempty"`
+	// ListID is the list a Todo is on, or the list itself for list ops
+	ListID *domain.TodoListID `json:"list_id,omitempty"`
+	// Name is only used by list ops
+	Name string `json:"name,omitempty"`
+	// Entries is only used by batch ops
+	Entries []journalEntry `json:"entries,omitempty"`
+}
+
+// putEntry returns an entry recording the given Todo as it is
+func putEntry(todo *domain.Todo) *journalEntry {
+	return &journalEntry{
+		Op:          putOp,
+		ID:          todo.ID,
+		Version:     todo.Version,
+		Task:        todo.Task,
+		Completed:   todo.Completed,
+		CompletedAt: todo.CompletedAt,
+		DueAt:       todo.DueAt,
+		Tags:        todo.Tags,
+		Priority:    todo.Priority,
+		ParentID:    todo.ParentID,
+		DependsOn:   todo.DependsOn,
+		Recurrence:  todo.Recurrence,
+		ListID:      todo.ListID,
+		Position:    todo.Position,
+	}
+}
+
+// trashEntry returns an entry putting the given Todo in the trash as it is,
+// deleted at the given time
+func trashEntry(todo *domain.Todo, deletedAt time.Time) *journalEntry {
+	entry := putEntry(todo)
+	entry.DeletedAt = &deletedAt
+	return entry
+}
+
+// revisionEntry returns an entry recording the given revision, for snapshots
+func revisionEntry(revision *domain.TodoRevision) *journalEntry {
+	entry := putEntry(&revision.Todo)
+	entry.Op = revisionOp
+	entry.Rev = revision.Rev
+	entry.Change = revision.Change
+	at := revision.At
+	entry.At = &at
+	entry.Actor = revision.Actor
+	return entry
+}
+
+// asPersisted returns the Todo a put entry is about, at the given Position
+func (entry *journalEntry) asPersisted(position string) persistedTask {
+	return persistedTask{
+		version:     entry.Version,
+		task:        entry.Task,
+		completed:   entry.Completed,
+		completedAt: entry.CompletedAt,
+		dueAt:       entry.DueAt,
+		tags:        entry.Tags,
+		priority:    entry.Priority,
+		parentID:    entry.ParentID,
+		dependsOn:   entry.DependsOn,
+		recurrence:  entry.Recurrence,
+		listID:      entry.ListID,
+		position:    position,
+	}
+}
+
+// putListEntry returns an entry recording the given TodoList as it is
+func putListEntry(list *domain.TodoList) *journalEntry {
+	id := list.ID
+	return &journalEntry{Op: putListOp, ListID: &id, Name: list.Name}
+}
+
+type snapshot struct {
+	LastID     domain.TodoID     `json:"last_id"`
+	Todos      []journalEntry    `json:"todos"`
+	LastListID domain.TodoListID `json:"last_list_id,omitempty"`
+	Lists      []journalEntry    `json:"lists,omitempty"`
+	Trash      []journalEntry    `json:"trash,omitempty"`
+	History    []journalEntry    `json:"history,omitempty"`
+}
+
+// openJournal opens (creating if needed) the journal in the given directory,
+// replays it into the given repoImpl, then readies it for appending
+func openJournal(dir string, compactEvery int, r *repoImpl) (*journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := readSnapshot(filepath.Join(dir, snapshotFileName), r); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	replayed, goodUntil, err := replay(log, r)
+	if err != nil {
+		_ = log.Close()
+		return nil, err
+	}
+	// Drop whatever was left of a torn write so new entries start on a
+	// fresh line
+	if err := log.Truncate(goodUntil); err != nil {
+		_ = log.Close()
+		return nil, err
+	}
+	return &journal{
+		dir:          dir,
+		log:          log,
+		size:         goodUntil,
+		compactEvery: compactEvery,
+		appended:     replayed,
+	}, nil
+}
+
+func readSnapshot(path string, r *repoImpl) error {
+	bytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(bytes, &snap); err != nil {
+		return err
+	}
+	for _, entry := range snap.Todos {
+		r.apply(&entry)
+	}
+	for _, entry := range snap.Lists {
+		r.apply(&entry)
+	}
+	for _, entry := range snap.Trash {
+		r.apply(&entry)
+	}
+	for _, entry := range snap.History {
+		r.apply(&entry)
+	}
+	if snap.LastID > r.lastId {
+		r.lastId = snap.LastID
+	}
+	if snap.LastListID > r.lastListId {
+		r.lastListId = snap.LastListID
+	}
+	return nil
+}
+
+// replay applies every complete entry in the log to the repo, returning how
+// many there were and the offset just after the last one
+func replay(log *os.File, r *repoImpl) (int, int64, error) {
+	reader := bufio.NewReader(log)
+	var replayed int
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// anything without a trailing newline is a torn write
+			return replayed, offset, nil
+		} else if err != nil {
+			return replayed, offset, err
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return replayed, offset, err
+		}
+		r.apply(&entry)
+		replayed++
+		offset += int64(len(line))
+	}
+}
+
+// append durably writes the given entry to the log, right after the last
+// complete one. If that fails, whatever part of the entry made it to the
+// log is dropped, so that it is neither replayed nor in the way of the
+// entries appended after it.
+func (j *journal) append(entry *journalEntry) error {
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if j.torn {
+		if err := j.log.Truncate(j.size); err != nil {
+			return err
+		}
+		j.torn = false
+	}
+	line := append(bytes, '\n')
+	if err := j.writeLine(line); err != nil {
+		j.torn = j.log.Truncate(j.size) != nil
+		return err
+	}
+	j.size += int64(len(line))
+	j.appended++
+	return nil
+}
+
+func (j *journal) writeLine(line []byte) error {
+	if _, err := j.log.WriteAt(line, j.size); err != nil {
+		return err
+	}
+	return j.log.Sync()
+}
+
+// shouldCompact is true once enough entries have been appended since the
+// last compaction
+func (j *journal) shouldCompact() bool {
+	return j.compactEvery > 0 && j.appended >= j.compactEvery
+}
+
+// compact writes the given state out as the new snapshot and empties the log
+func (j *journal) compact(snap *snapshot) error {
+	bytes, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(j.dir, snapshotFileName)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(bytes); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(j.dir, snapshotFileName)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := j.log.Truncate(0); err != nil {
+		return err
+	}
+	j.size = 0
+	j.appended = 0
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.log.Close()
+}
@@ -0,0 +1,348 @@
+package inmem
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/repotest"
+	"github.com/stretchr/testify/assert"
+)
+
+func mkTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "todddo-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func reopen(t *testing.T, repo domain.TodoRepo, dir string, compactEvery int) domain.TodoRepo {
+	if err := repo.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := MkFileRepo(dir, compactEvery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reopened
+}
+
+func TestFileRepoSurvivesRestart(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, err := MkFileRepo(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _ := repo.Create(&domain.NewTodo{Task: "clean up after yourself"})
+	kept.Task = "do the dishes"
+	kept, _ = repo.Update(&kept)
+	deleted, _ := repo.Create(&domain.NewTodo{Task: "forget me"})
+	_, _ = repo.Delete(&deleted.ID, 0)
+
+	repo = reopen(t, repo, dir, 0)
+	page, _ := repo.List(&domain.TodoQuery{})
+	listed := page.Todos
+	assert.Equal(t, []domain.Todo{kept}, listed)
+	// the search index gets rebuilt along the way
+	results, _ := repo.Search(&domain.TodoSearch{Text: "dishes"})
+	assert.Len(t, results, 1)
+	results, _ = repo.Search(&domain.TodoSearch{Text: "forget"})
+	assert.Empty(t, results)
+	next, _ := repo.Create(&domain.NewTodo{Task: "brand new"})
+	assert.Equal(t, deleted.ID+1, next.ID)
+}
+
+func TestFileRepoCompacts(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, err := MkFileRepo(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := repo.Create(&domain.NewTodo{Task: "one"})
+	second, _ := repo.Create(&domain.NewTodo{Task: "two"})
+	_, _ = repo.Delete(&second.ID, 0)
+
+	logInfo, _ := os.Stat(filepath.Join(dir, logFileName))
+	assert.Equal(t, int64(0), logInfo.Size())
+	_, err = os.Stat(filepath.Join(dir, snapshotFileName))
+	assert.Nil(t, err)
+
+	third, _ := repo.Create(&domain.NewTodo{Task: "three"})
+	repo = reopen(t, repo, dir, 3)
+	page, _ := repo.List(&domain.TodoQuery{})
+	listed := page.Todos
+	assert.Equal(t, []domain.Todo{first, third}, listed)
+	// the id of the deleted todo is remembered through the snapshot
+	assert.Equal(t, second.ID+1, third.ID)
+}
+
+func TestFileRepoIgnoresTornWrite(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, err := MkFileRepo(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _ := repo.Create(&domain.NewTodo{Task: "clean up after yourself"})
+	_ = repo.(io.Closer).Close()
+
+	log, _ := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = log.WriteString(`{"op":"put","id":2,"ta`)
+	_ = log.Close()
+
+	repo, err = MkFileRepo(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, _ := repo.Create(&domain.NewTodo{Task: "after the crash"})
+	repo = reopen(t, repo, dir, 0)
+	page, _ := repo.List(&domain.TodoQuery{})
+	listed := page.Todos
+	assert.Equal(t, []domain.Todo{kept, created}, listed)
+}
+
+// faultyLog makes the writes to a journal's log fail the way a full disk
+// would
+type faultyLog struct {
+	journalFile
+	// short makes writes stop halfway through
+	short bool
+	// unsynced makes writes go through but fail to sync
+	unsynced bool
+}
+
+var errNoSpace = errors.New("no space left on device")
+
+func (l *faultyLog) WriteAt(p []byte, off int64) (int, error) {
+	if l.short {
+		n, _ := l.journalFile.WriteAt(p[:len(p)/2], off)
+		return n, errNoSpace
+	}
+	return l.journalFile.WriteAt(p, off)
+}
+
+func (l *faultyLog) Sync() error {
+	if l.unsynced {
+		return errNoSpace
+	}
+	return l.journalFile.Sync()
+}
+
+func TestFileRepoDropsFailedWrites(t *testing.T) {
+	for name, faulty := range map[string]faultyLog{
+		"short write": {short: true},
+		"failed sync": {unsynced: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := mkTempDir(t)
+			defer os.RemoveAll(dir)
+			repo, err := MkFileRepo(dir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept, _ := repo.Create(&domain.NewTodo{Task: "clean up after yourself"})
+
+			journal := repo.(*repoImpl).journal
+			faulty.journalFile = journal.log
+			journal.log = &faulty
+			_, err = repo.Create(&domain.NewTodo{Task: "lost to a full disk"})
+			assert.NotNil(t, err)
+			journal.log = faulty.journalFile
+
+			created, err := repo.Create(&domain.NewTodo{Task: "after freeing some space"})
+			assert.Nil(t, err)
+			repo = reopen(t, repo, dir, 0)
+			page, _ := repo.List(&domain.TodoQuery{})
+			assert.Equal(t, []domain.Todo{kept, created}, page.Todos)
+		})
+	}
+}
+
+func TestFileRepoPositionsTodosFromBeforePositions(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	_ = ioutil.WriteFile(filepath.Join(dir, logFileName), []byte(
+		`{"op":"put","id":1,"version":1,"task":"first"}`+"\n"+
+			`{"op":"put","id":2,"version":1,"task":"second"}`+"\n"+
+			`{"op":"put","id":1,"version":2,"task":"first, changed"}`+"\n",
+	), 0644)
+	repo, err := MkFileRepo(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, _ := repo.Create(&domain.NewTodo{Task: "third"})
+	page, _ := repo.List(&domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPosition}}})
+	var ids []domain.TodoID
+	for _, todo := range page.Todos {
+		ids = append(ids, todo.ID)
+	}
+	assert.Equal(t, []domain.TodoID{1, 2, created.ID}, ids)
+}
+
+func TestFileRepoRejectsCorruptLog(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	_ = ioutil.WriteFile(filepath.Join(dir, logFileName), []byte("not json\n"), 0644)
+	_, err := MkFileRepo(dir, 0)
+	assert.NotNil(t, err)
+}
+
+func TestFileRepoContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (domain.TodoRepo, func()) {
+		dir := mkTempDir(t)
+		repo, err := MkFileRepo(dir, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo, func() {
+			_ = repo.(io.Closer).Close()
+			_ = os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestFileListRepoContract(t *testing.T) {
+	repotest.RunLists(t, func(t *testing.T) (domain.TodoListRepo, func()) {
+		dir := mkTempDir(t)
+		repo, lists, err := MkFileRepos(dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lists, func() {
+			_ = repo.(io.Closer).Close()
+			_ = os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestFileRepoKeepsLists(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, lists, err := MkFileRepos(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _ := lists.Create(&domain.NewTodoList{Name: "Chores"})
+	deleted, _ := lists.Create(&domain.NewTodoList{Name: "Errands"})
+	_, _ = lists.Delete(&deleted.ID)
+	// enough to compact, so that lists have to come back from the snapshot
+	onList, _ := repo.Create(&domain.NewTodo{Task: "Sweep", ListID: &kept.ID})
+	renamed := kept
+	renamed.Name = "House"
+	_, _ = lists.Update(&renamed)
+
+	if err := repo.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	repo, lists, err = MkFileRepos(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, _ := lists.List()
+	assert.Equal(t, []domain.TodoList{renamed}, listed)
+	retrieved, _ := repo.Get(&onList.ID)
+	assert.Equal(t, onList, retrieved)
+	next, _ := lists.Create(&domain.NewTodoList{Name: "Garden"})
+	assert.Equal(t, deleted.ID+1, next.ID)
+}
+
+func TestFileRepoJournalsTransactions(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, err := MkFileRepo(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var committed []domain.Todo
+	err = repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		for _, task := range []string{"one", "two"} {
+			created, err := todos.Create(&domain.NewTodo{Task: task})
+			if err != nil {
+				return err
+			}
+			committed = append(committed, created)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	_ = repo.WithinTx(func(todos domain.TodoRepo, lists domain.TodoListRepo) error {
+		_, _ = todos.Create(&domain.NewTodo{Task: "rolled back"})
+		return errors.New("changed my mind")
+	})
+
+	// a transaction takes up a single line, so it can't be torn in two
+	bytes, _ := ioutil.ReadFile(filepath.Join(dir, logFileName))
+	assert.Equal(t, 1, strings.Count(string(bytes), "\n"))
+	repo = reopen(t, repo, dir, 0)
+	page, _ := repo.List(&domain.TodoQuery{})
+	assert.Equal(t, committed, page.Todos)
+}
+
+func TestFileRepoKeepsTrash(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, err := MkFileRepo(dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := repo.Create(&domain.NewTodo{Task: "Water the plants"})
+	trashed, _ := repo.Create(&domain.NewTodo{Task: "Feed the cat"})
+	_, _ = repo.Delete(&trashed.ID, 0)
+	_, _ = repo.Delete(&restored.ID, 0)
+	// enough to compact, so that the trash has to come back from the snapshot
+	restored, _ = repo.Restore(&restored.ID)
+	before, _ := repo.ListTrash()
+
+	repo = reopen(t, repo, dir, 4)
+	after, _ := repo.ListTrash()
+	assert.Equal(t, before, after)
+	page, _ := repo.List(&domain.TodoQuery{})
+	assert.Equal(t, []domain.Todo{restored}, page.Todos)
+	_, _ = repo.Purge(&trashed.ID)
+
+	repo = reopen(t, repo, dir, 4)
+	after, _ = repo.ListTrash()
+	assert.Empty(t, after)
+	next, _ := repo.Create(&domain.NewTodo{Task: "Walk the dog"})
+	assert.Equal(t, trashed.ID+1, next.ID)
+}
+
+func TestFileRepoKeepsHistory(t *testing.T) {
+	dir := mkTempDir(t)
+	defer os.RemoveAll(dir)
+	repo, err := MkFileRepo(dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, _ := repo.AsActor("alice").Create(&domain.NewTodo{Task: "Water the plants"})
+	changed := created
+	changed.Task = "Water the plants twice"
+	_, _ = repo.Update(&changed)
+	purged, _ := repo.Create(&domain.NewTodo{Task: "Feed the cat"})
+	_, _ = repo.Delete(&purged.ID, 0)
+	// enough to compact, so that the history has to come back from the snapshot
+	_, _ = repo.Purge(&purged.ID)
+	before, _ := repo.History(&created.ID)
+	purgedBefore, _ := repo.History(&purged.ID)
+
+	repo = reopen(t, repo, dir, 4)
+	after, _ := repo.History(&created.ID)
+	assert.Equal(t, before, after)
+	purgedAfter, _ := repo.History(&purged.ID)
+	assert.Equal(t, purgedBefore, purgedAfter)
+	_, _ = repo.AsActor("bob").Delete(&created.ID, 0)
+	before, _ = repo.History(&created.ID)
+	assert.Len(t, before, 3)
+
+	repo = reopen(t, repo, dir, 4)
+	after, _ = repo.History(&created.ID)
+	assert.Equal(t, before, after)
+}
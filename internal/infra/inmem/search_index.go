@@ -0,0 +1,99 @@
+package inmem
+
+import (
+	"sort"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// searchIndex is an inverted index from the terms in the tasks of Todos to
+// the Todos they occur in. It is not safe for concurrent use.
+type searchIndex struct {
+	// postings holds, for each term, how many times it occurs in each Todo
+	postings map[string]map[domain.TodoID]uint
+	// terms holds the keys of postings in order, so that the ones starting
+	// with a prefix can be found without going through all of them
+	terms []string
+	// termCounts holds, for each Todo, how many times each term occurs in
+	// its task, so that it can be taken out of postings again
+	termCounts map[domain.TodoID]map[string]uint
+	// lengths holds how many terms each Todo's task has
+	lengths map[domain.TodoID]uint
+}
+
+func mkSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings:   make(map[string]map[domain.TodoID]uint),
+		termCounts: make(map[domain.TodoID]map[string]uint),
+		lengths:    make(map[domain.TodoID]uint),
+	}
+}
+
+// put indexes the given task as that of the Todo with the given id,
+// replacing whatever it was indexed with before
+func (i *searchIndex) put(id domain.TodoID, task string) {
+	i.remove(id)
+	terms := domain.Tokenise(task)
+	counts := domain.TermCounts(terms)
+	for term, count := range counts {
+		ids, exists := i.postings[term]
+		if !exists {
+			ids = make(map[domain.TodoID]uint)
+			i.postings[term] = ids
+			i.insertTerm(term)
+		}
+		ids[id] = count
+	}
+	i.termCounts[id] = counts
+	i.lengths[id] = uint(len(terms))
+}
+
+// remove takes the Todo with the given id out of the index, if it is in it
+func (i *searchIndex) remove(id domain.TodoID) {
+	for term := range i.termCounts[id] {
+		ids := i.postings[term]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(i.postings, term)
+			i.removeTerm(term)
+		}
+	}
+	delete(i.termCounts, id)
+	delete(i.lengths, id)
+}
+
+// search ranks the indexed Todos against the given search
+func (i *searchIndex) search(search *domain.TodoSearch) []domain.SearchHit {
+	tokens := domain.Tokenise(search.Text)
+	var postings []domain.Posting
+	for _, token := range tokens {
+		lower, upper := domain.PrefixRange(token)
+		start := sort.SearchStrings(i.terms, lower)
+		end := sort.SearchStrings(i.terms, upper)
+		for _, term := range i.terms[start:end] {
+			for id, occurrences := range i.postings[term] {
+				postings = append(postings, domain.Posting{
+					ID:          id,
+					Term:        term,
+					Occurrences: occurrences,
+					Length:      i.lengths[id],
+				})
+			}
+		}
+	}
+	return domain.RankSearch(tokens, postings, uint(len(i.lengths)), search.Limit)
+}
+
+func (i *searchIndex) insertTerm(term string) {
+	at := sort.SearchStrings(i.terms, term)
+	i.terms = append(i.terms, "")
+	copy(i.terms[at+1:], i.terms[at:])
+	i.terms[at] = term
+}
+
+func (i *searchIndex) removeTerm(term string) {
+	at := sort.SearchStrings(i.terms, term)
+	if at < len(i.terms) && i.terms[at] == term {
+		i.terms = append(i.terms[:at], i.terms[at+1:]...)
+	}
+}
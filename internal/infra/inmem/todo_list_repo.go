@@ -0,0 +1,101 @@
+package inmem
+
+import (
+	"sort"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// listRepoImpl keeps TodoLists in the repoImpl that holds their Todos,
+// under the same mutex and journal
+type listRepoImpl struct {
+	r *repoImpl
+}
+
+func (l *listRepoImpl) Create(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	l.r.mutex.Lock()
+	defer l.r.mutex.Unlock()
+	return l.create(newList)
+}
+
+// create does the work of Create for listRepoImpl and txListRepo alike.
+// Like the rest of the lower-case versions of the TodoListRepo methods, it
+// must be called with the mutex held.
+func (l *listRepoImpl) create(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	list := domain.TodoList{ID: l.r.lastListId + 1, Name: newList.Name}
+	if err := l.commit(putListEntry(&list)); err != nil {
+		return domain.TodoList{}, err
+	}
+	return list, nil
+}
+
+func (l *listRepoImpl) Get(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	l.r.mutex.Lock()
+	defer l.r.mutex.Unlock()
+	return l.get(id)
+}
+
+func (l *listRepoImpl) get(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	if name, exists := l.r.lists[*id]; exists {
+		return domain.TodoList{ID: *id, Name: name}, nil
+	} else {
+		return domain.TodoList{}, domain.TodoListNotFound{ID: *id}
+	}
+}
+
+func (l *listRepoImpl) List() ([]domain.TodoList, domain.TodoListRepoError) {
+	l.r.mutex.Lock()
+	defer l.r.mutex.Unlock()
+	return l.r.listsInOrder(), nil
+}
+
+func (l *listRepoImpl) Update(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	l.r.mutex.Lock()
+	defer l.r.mutex.Unlock()
+	return l.update(list)
+}
+
+func (l *listRepoImpl) update(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	if _, exists := l.r.lists[list.ID]; !exists {
+		return domain.TodoList{}, domain.TodoListNotFound{ID: list.ID}
+	}
+	if err := l.commit(putListEntry(list)); err != nil {
+		return domain.TodoList{}, err
+	}
+	return *list, nil
+}
+
+func (l *listRepoImpl) Delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	l.r.mutex.Lock()
+	defer l.r.mutex.Unlock()
+	return l.delete(id)
+}
+
+func (l *listRepoImpl) delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	if _, exists := l.r.lists[*id]; !exists {
+		return false, domain.TodoListNotFound{ID: *id}
+	}
+	if err := l.commit(&journalEntry{Op: deleteListOp, ListID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// commit is repoImpl.commit for entries about TodoLists
+func (l *listRepoImpl) commit(entry *journalEntry) domain.TodoListRepoError {
+	if err := l.r.write(entry); err != nil {
+		return domain.TodoListRepoFailure{ID: *entry.ListID, Cause: err}
+	}
+	return nil
+}
+
+// listsInOrder returns all the TodoLists in order of id. Must be called
+// with the mutex held.
+func (r *repoImpl) listsInOrder() []domain.TodoList {
+	lists := make([]domain.TodoList, 0, len(r.lists))
+	for id, name := range r.lists {
+		lists = append(lists, domain.TodoList{ID: id, Name: name})
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+	return lists
+}
@@ -3,6 +3,7 @@ package inmem
 import (
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
 )
@@ -11,64 +12,238 @@ type repoImpl struct {
 	mutex  sync.Mutex
 	lastId domain.TodoID
 	stored map[domain.TodoID]persistedTask
+	// trash holds the Todos that were deleted, which are in neither stored
+	// nor ids
+	trash map[domain.TodoID]trashedTask
+	// revisions holds the history of every Todo there ever was, oldest
+	// revision first
+	revisions map[domain.TodoID][]domain.TodoRevision
+	// ids holds the keys of stored in ascending order, so that listing
+	// does not need to go through (and sort) everything
+	ids []domain.TodoID
+	// index is kept in step with stored for Search
+	index *searchIndex
+	// lastPosition is the largest Position handed out so far, which new
+	// Todos go after
+	lastPosition string
+	// TodoLists are kept alongside Todos, so they get journaled together;
+	// see listRepoImpl
+	lastListId domain.TodoListID
+	lists      map[domain.TodoListID]string
+	// nil unless the repo was made with MkFileRepo
+	journal *journal
+	// nil unless a function is being run by WithinTx
+	tx *tx
 }
 
 type persistedTask struct {
-	task string
+	version     domain.TodoVersion
+	task        string
+	completed   bool
+	completedAt *time.Time
+	dueAt       *time.Time
+	tags        []string
+	priority    domain.Priority
+	parentID    *domain.TodoID
+	dependsOn   []domain.TodoID
+	recurrence  string
+	listID      *domain.TodoListID
+	position    string
+}
+
+type trashedTask struct {
+	persistedTask
+	deletedAt time.Time
+}
+
+func (p *persistedTask) asTodo(id domain.TodoID) domain.Todo {
+	return domain.Todo{
+		ID:          id,
+		Version:     p.version,
+		Task:        p.task,
+		Completed:   p.completed,
+		CompletedAt: p.completedAt,
+		DueAt:       p.dueAt,
+		Tags:        p.tags,
+		Priority:    p.priority,
+		ParentID:    p.parentID,
+		DependsOn:   p.dependsOn,
+		Recurrence:  p.recurrence,
+		ListID:      p.listID,
+		Position:    p.position,
+	}
+}
+
+// load returns the Todo with the given id, which must be stored, working
+// out whether it is blocked. Must be called with the mutex held.
+func (r *repoImpl) load(id domain.TodoID) domain.Todo {
+	persisted := r.stored[id]
+	todo := persisted.asTodo(id)
+	for _, dependency := range todo.DependsOn {
+		if stored, exists := r.stored[dependency]; exists && !stored.completed {
+			todo.Blocked = true
+			break
+		}
+	}
+	return todo
 }
 
 // MkRepo returns a new TodoRepo based on an in-mem implementation
 func MkRepo() domain.TodoRepo {
+	return mkRepoImpl()
+}
+
+// MkFileRepo returns a new TodoRepo based on an in-mem implementation that
+// also appends every change to a log file in the given directory. The log
+// is replayed on startup, so nothing is lost across restarts, and gets
+// compacted into a snapshot file after compactEvery changes (never if 0).
+//
+// The returned repo implements io.Closer.
+func MkFileRepo(dir string, compactEvery int) (domain.TodoRepo, error) {
+	r := mkRepoImpl()
+	journal, err := openJournal(dir, compactEvery, r)
+	if err != nil {
+		return nil, err
+	}
+	r.journal = journal
+	return r, nil
+}
+
+// MkRepos returns a new TodoRepo and TodoListRepo based on an in-mem
+// implementation, keeping Todos and TodoLists side by side
+func MkRepos() (domain.TodoRepo, domain.TodoListRepo) {
+	r := mkRepoImpl()
+	return r, &listRepoImpl{r: r}
+}
+
+// MkFileRepos is MkRepos for MkFileRepo, with Todos and TodoLists sharing
+// the same log. Closing the TodoRepo closes both.
+func MkFileRepos(dir string, compactEvery int) (domain.TodoRepo, domain.TodoListRepo, error) {
+	repo, err := MkFileRepo(dir, compactEvery)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, &listRepoImpl{r: repo.(*repoImpl)}, nil
+}
+
+func mkRepoImpl() *repoImpl {
 	return &repoImpl{
-		stored: make(map[domain.TodoID]persistedTask),
+		stored:    make(map[domain.TodoID]persistedTask),
+		trash:     make(map[domain.TodoID]trashedTask),
+		revisions: make(map[domain.TodoID][]domain.TodoRevision),
+		index:     mkSearchIndex(),
+		lists:     make(map[domain.TodoListID]string),
 	}
 }
 
-func (r *repoImpl) Create(newTodo *domain.NewTodo) domain.Todo {
+func (r *repoImpl) Create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	id := r.lastId + 1
-	r.lastId = id
-	persisted := persistedTask{newTodo.Task}
-	r.stored[id] = persisted
-	return domain.Todo{
-		ID:   id,
-		Task: newTodo.Task,
+	return r.create(newTodo, "")
+}
+
+// create does the work of Create for repoImpl, actorRepo and txRepo alike,
+// recording the change as made by the given actor. Like the rest of the
+// lower-case versions of the TodoRepo methods, it must be called with the
+// mutex held.
+func (r *repoImpl) create(newTodo *domain.NewTodo, actor string) (domain.Todo, domain.TodoRepoError) {
+	todo := domain.Todo{
+		ID:          r.lastId + 1,
+		Version:     1,
+		Task:        newTodo.Task,
+		Completed:   newTodo.Completed,
+		CompletedAt: newTodo.CompletedAt,
+		DueAt:       newTodo.DueAt,
+		Tags:        domain.NormaliseTags(newTodo.Tags),
+		Priority:    newTodo.Priority,
+		ParentID:    newTodo.ParentID,
+		DependsOn:   domain.NormaliseIDs(newTodo.DependsOn),
+		Recurrence:  newTodo.Recurrence,
+		ListID:      newTodo.ListID,
+		Position:    newTodo.Position,
+	}
+	if len(todo.Position) == 0 {
+		todo.Position = r.nextPosition()
 	}
+	if err := r.commit(r.revise(putEntry(&todo), domain.CreatedRevision, actor)); err != nil {
+		return domain.Todo{}, err
+	}
+	return r.load(todo.ID), nil
 }
 
 func (r *repoImpl) Get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	if retrieved, exists := r.stored[*id]; exists {
-		return domain.Todo{
-			ID:   *id,
-			Task: retrieved.task,
-		}, nil
+	return r.get(id)
+}
+
+func (r *repoImpl) get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	if _, exists := r.stored[*id]; exists {
+		return r.load(*id), nil
 	} else {
 		return domain.Todo{}, domain.TodoNotFound{ID: *id}
 	}
-
 }
-func (r *repoImpl) List() []domain.Todo {
+
+func (r *repoImpl) List(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	retrieved := make([]domain.Todo, 0, len(r.stored))
-	for id, v := range r.stored {
-		retrieved = append(retrieved, domain.Todo{
-			ID:   id,
-			Task: v.task,
-		})
+	return r.list(query)
+}
+
+func (r *repoImpl) list(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+	if !query.SortedByID() {
+		return r.listSorted(query), nil
+	}
+	start := 0
+	if query.After != nil {
+		start = sort.Search(len(r.ids), func(i int) bool { return r.ids[i] > query.After.ID })
+	}
+	retrieved := make([]domain.Todo, 0)
+	for _, id := range r.ids[start:] {
+		if query.Limit > 0 && uint(len(retrieved)) > query.Limit {
+			break
+		}
+		todo := r.load(id)
+		if query.Matches(&todo) {
+			retrieved = append(retrieved, todo)
+		}
 	}
-	sort.SliceStable(retrieved, func(i, j int) bool { return retrieved[i].ID < retrieved[j].ID })
-	return retrieved
+	return domain.MkTodoPage(retrieved, query.Limit), nil
 }
 
-func (r *repoImpl) Delete(id *domain.TodoID) (bool, domain.TodoRepoError) {
+// listSorted lists Todos in any order, which means going through (and
+// sorting) all the ones that match. Must be called with the mutex held.
+func (r *repoImpl) listSorted(query *domain.TodoQuery) domain.TodoPage {
+	retrieved := make([]domain.Todo, 0)
+	for _, id := range r.ids {
+		todo := r.load(id)
+		if query.Matches(&todo) && query.IsAfter(&todo) {
+			retrieved = append(retrieved, todo)
+		}
+	}
+	sort.Slice(retrieved, func(i, j int) bool { return query.Compare(&retrieved[i], &retrieved[j]) < 0 })
+	if query.Limit > 0 && uint(len(retrieved)) > query.Limit+1 {
+		retrieved = retrieved[:query.Limit+1]
+	}
+	return domain.MkTodoPage(retrieved, query.Limit)
+}
+
+func (r *repoImpl) Delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	if _, exists := r.stored[*id]; exists {
-		delete(r.stored, *id)
+	return r.delete(id, version, "")
+}
+
+func (r *repoImpl) delete(id *domain.TodoID, version domain.TodoVersion, actor string) (bool, domain.TodoRepoError) {
+	if existing, exists := r.stored[*id]; exists {
+		if err := domain.CheckVersion(*id, version, existing.version); err != nil {
+			return false, err
+		}
+		todo := existing.asTodo(*id)
+		if err := r.commit(r.revise(trashEntry(&todo, time.Now().UTC()), domain.DeletedRevision, actor)); err != nil {
+			return false, err
+		}
 		return true, nil
 	} else {
 		return false, domain.TodoNotFound{ID: *id}
@@ -78,10 +253,402 @@ func (r *repoImpl) Delete(id *domain.TodoID) (bool, domain.TodoRepoError) {
 func (r *repoImpl) Update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	if _, exists := r.stored[todo.ID]; exists {
-		r.stored[todo.ID] = persistedTask{task: todo.Task}
-		return *todo, nil
+	return r.update(todo, "")
+}
+
+func (r *repoImpl) update(todo *domain.Todo, actor string) (domain.Todo, domain.TodoRepoError) {
+	if existing, exists := r.stored[todo.ID]; exists {
+		if err := domain.CheckVersion(todo.ID, todo.Version, existing.version); err != nil {
+			return domain.Todo{}, err
+		}
+		updated := *todo
+		updated.Version = existing.version + 1
+		updated.Tags = domain.NormaliseTags(todo.Tags)
+		updated.DependsOn = domain.NormaliseIDs(todo.DependsOn)
+		if len(updated.Position) == 0 {
+			updated.Position = existing.position
+		}
+		if err := r.commit(r.revise(putEntry(&updated), domain.UpdatedRevision, actor)); err != nil {
+			return domain.Todo{}, err
+		}
+		return r.load(updated.ID), nil
 	} else {
 		return domain.Todo{}, domain.TodoNotFound{ID: todo.ID}
 	}
 }
+
+func (r *repoImpl) ListTags() ([]domain.TagCount, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.listTags()
+}
+
+func (r *repoImpl) listTags() ([]domain.TagCount, domain.TodoRepoError) {
+	counts := make(map[string]uint)
+	for _, persisted := range r.stored {
+		for _, tag := range persisted.tags {
+			counts[tag]++
+		}
+	}
+	tagCounts := make([]domain.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tagCounts = append(tagCounts, domain.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tagCounts, func(i, j int) bool { return tagCounts[i].Tag < tagCounts[j].Tag })
+	return tagCounts, nil
+}
+
+func (r *repoImpl) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.search(search)
+}
+
+func (r *repoImpl) search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	hits := r.index.search(search)
+	results := make([]domain.TodoSearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = domain.TodoSearchResult{Todo: r.load(hit.ID), Score: hit.Score}
+	}
+	return results, nil
+}
+
+func (r *repoImpl) ListTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.listTrash()
+}
+
+func (r *repoImpl) listTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	trashed := make([]domain.TrashedTodo, 0, len(r.trash))
+	for id, task := range r.trash {
+		trashed = append(trashed, domain.TrashedTodo{Todo: task.asTodo(id), DeletedAt: task.deletedAt})
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		if !trashed[i].DeletedAt.Equal(trashed[j].DeletedAt) {
+			return trashed[i].DeletedAt.After(trashed[j].DeletedAt)
+		}
+		return trashed[i].Todo.ID > trashed[j].Todo.ID
+	})
+	return trashed, nil
+}
+
+func (r *repoImpl) Restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.restore(id, "")
+}
+
+func (r *repoImpl) restore(id *domain.TodoID, actor string) (domain.Todo, domain.TodoRepoError) {
+	trashed, exists := r.trash[*id]
+	if !exists {
+		return domain.Todo{}, domain.TodoNotFound{ID: *id}
+	}
+	todo := trashed.asTodo(*id)
+	todo.Version++
+	if err := r.commit(r.revise(putEntry(&todo), domain.RestoredRevision, actor)); err != nil {
+		return domain.Todo{}, err
+	}
+	return r.load(*id), nil
+}
+
+func (r *repoImpl) Purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.purge(id)
+}
+
+func (r *repoImpl) purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	if _, exists := r.trash[*id]; !exists {
+		return false, domain.TodoNotFound{ID: *id}
+	}
+	if err := r.commit(&journalEntry{Op: deleteOp, ID: *id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *repoImpl) PurgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.purgeDeletedBefore(before)
+}
+
+func (r *repoImpl) purgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	var expired []domain.TodoID
+	for id, trashed := range r.trash {
+		if trashed.deletedAt.Before(before) {
+			expired = append(expired, id)
+		}
+	}
+	for i, id := range expired {
+		if err := r.commit(&journalEntry{Op: deleteOp, ID: id}); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+func (r *repoImpl) History(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.history(id)
+}
+
+func (r *repoImpl) history(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	revisions := r.revisions[*id]
+	if len(revisions) == 0 {
+		_, stored := r.stored[*id]
+		_, trashed := r.trash[*id]
+		if !stored && !trashed {
+			return nil, domain.TodoNotFound{ID: *id}
+		}
+	}
+	return append(make([]domain.TodoRevision, 0, len(revisions)), revisions...), nil
+}
+
+func (r *repoImpl) AsActor(actor string) domain.TodoRepo {
+	return &actorRepo{repoImpl: r, actor: actor}
+}
+
+// actorRepo is a repoImpl that records the changes made through it as made
+// by its actor
+type actorRepo struct {
+	*repoImpl
+	actor string
+}
+
+func (a *actorRepo) Create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.create(newTodo, a.actor)
+}
+
+func (a *actorRepo) Delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.delete(id, version, a.actor)
+}
+
+func (a *actorRepo) Update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.update(todo, a.actor)
+}
+
+func (a *actorRepo) Restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.restore(id, a.actor)
+}
+
+func (a *actorRepo) WithinTx(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	return a.withinTx(a.actor, f)
+}
+
+// revise returns the given put entry, recorded as the next revision of the
+// Todo it is about, made as of now by the given actor
+func (r *repoImpl) revise(entry *journalEntry, change domain.RevisionChange, actor string) *journalEntry {
+	at := time.Now().UTC()
+	entry.Rev = uint(len(r.revisions[entry.ID])) + 1
+	entry.Change = change
+	entry.At = &at
+	entry.Actor = actor
+	return entry
+}
+
+// addRevision adds the revision recorded by the given entry, about the
+// given Todo, to its history, unless it is there already
+func (r *repoImpl) addRevision(entry *journalEntry, todo domain.Todo) {
+	revisions := r.revisions[entry.ID]
+	if entry.Rev != uint(len(revisions))+1 {
+		return
+	}
+	r.revisions[entry.ID] = append(revisions, domain.TodoRevision{
+		Rev:    entry.Rev,
+		Change: entry.Change,
+		At:     *entry.At,
+		Actor:  entry.Actor,
+		Todo:   todo,
+	})
+}
+
+// Close releases the files held by a repo made with MkFileRepo
+func (r *repoImpl) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.journal == nil {
+		return nil
+	}
+	return r.journal.close()
+}
+
+// commit makes the change described by the given entry durable if there is
+// a journal, then applies it. Must be called with the mutex held.
+func (r *repoImpl) commit(entry *journalEntry) domain.TodoRepoError {
+	if err := r.write(entry); err != nil {
+		return domain.TodoRepoFailure{ID: entry.ID, Cause: err}
+	}
+	return nil
+}
+
+// write does the work of commit, for entries about Todos and TodoLists
+// alike. Within a transaction, the change is only applied for now, and gets
+// journaled along with the rest of the transaction's when it is committed.
+func (r *repoImpl) write(entry *journalEntry) error {
+	if r.tx != nil {
+		r.tx.record(r, entry)
+		r.apply(entry)
+		return nil
+	}
+	if r.journal == nil {
+		r.apply(entry)
+		return nil
+	}
+	if err := r.journal.append(entry); err != nil {
+		return err
+	}
+	r.apply(entry)
+	r.compactIfDue()
+	return nil
+}
+
+// compactIfDue compacts the journal if enough changes have been made since
+// it last was
+func (r *repoImpl) compactIfDue() {
+	if r.journal.shouldCompact() {
+		// The change is already safe in the log, so a failed compaction
+		// is not fatal; it will simply be retried on the next change.
+		_ = r.journal.compact(r.snapshot())
+	}
+}
+
+// apply changes the in-mem state according to the given entry
+func (r *repoImpl) apply(entry *journalEntry) {
+	switch entry.Op {
+	case putOp:
+		existing, exists := r.stored[entry.ID]
+		position := entry.Position
+		if len(position) == 0 {
+			// journaled before Todos had positions
+			position = existing.position
+			if !exists {
+				position = r.nextPosition()
+			}
+		}
+		if position > r.lastPosition {
+			r.lastPosition = position
+		}
+		persisted := entry.asPersisted(position)
+		if entry.ID > r.lastId {
+			r.lastId = entry.ID
+		}
+		if entry.Rev > 0 {
+			r.addRevision(entry, persisted.asTodo(entry.ID))
+		}
+		if entry.DeletedAt != nil {
+			r.remove(entry.ID)
+			r.trash[entry.ID] = trashedTask{persistedTask: persisted, deletedAt: *entry.DeletedAt}
+			return
+		}
+		delete(r.trash, entry.ID)
+		if !exists {
+			r.insertId(entry.ID)
+		}
+		r.stored[entry.ID] = persisted
+		r.index.put(entry.ID, entry.Task)
+	case deleteOp:
+		r.remove(entry.ID)
+		delete(r.trash, entry.ID)
+	case revisionOp:
+		persisted := entry.asPersisted(entry.Position)
+		r.addRevision(entry, persisted.asTodo(entry.ID))
+	case dropRevisionOp:
+		if revisions := r.revisions[entry.ID]; uint(len(revisions)) >= entry.Rev {
+			r.revisions[entry.ID] = revisions[:entry.Rev-1]
+		}
+		if len(r.revisions[entry.ID]) == 0 {
+			delete(r.revisions, entry.ID)
+		}
+	case putListOp:
+		r.lists[*entry.ListID] = entry.Name
+		if *entry.ListID > r.lastListId {
+			r.lastListId = *entry.ListID
+		}
+	case deleteListOp:
+		delete(r.lists, *entry.ListID)
+	case batchOp:
+		for i := range entry.Entries {
+			r.apply(&entry.Entries[i])
+		}
+	}
+}
+
+// nextPosition returns a Position after all the ones handed out so far.
+// Must be called with the mutex held.
+func (r *repoImpl) nextPosition() string {
+	// lastPosition is always valid, so this can't fail
+	position, _ := domain.PositionBetween(r.lastPosition, "")
+	return position
+}
+
+// insertId adds the given id to ids, keeping them sorted; new ids are
+// always the largest, so this is usually just an append
+func (r *repoImpl) insertId(id domain.TodoID) {
+	i := sort.Search(len(r.ids), func(i int) bool { return r.ids[i] >= id })
+	r.ids = append(r.ids, 0)
+	copy(r.ids[i+1:], r.ids[i:])
+	r.ids[i] = id
+}
+
+// remove removes the Todo with the given id, if any, from stored, along
+// with its id and what it is indexed under
+func (r *repoImpl) remove(id domain.TodoID) {
+	if _, exists := r.stored[id]; exists {
+		r.removeId(id)
+	}
+	delete(r.stored, id)
+	r.index.remove(id)
+}
+
+func (r *repoImpl) removeId(id domain.TodoID) {
+	i := sort.Search(len(r.ids), func(i int) bool { return r.ids[i] >= id })
+	if i < len(r.ids) && r.ids[i] == id {
+		r.ids = append(r.ids[:i], r.ids[i+1:]...)
+	}
+}
+
+func (r *repoImpl) snapshot() *snapshot {
+	todos := make([]journalEntry, 0, len(r.ids))
+	for _, id := range r.ids {
+		persisted := r.stored[id]
+		todo := persisted.asTodo(id)
+		todos = append(todos, *putEntry(&todo))
+	}
+	lists := make([]journalEntry, 0, len(r.lists))
+	for _, list := range r.listsInOrder() {
+		lists = append(lists, *putListEntry(&list))
+	}
+	trash := make([]journalEntry, 0, len(r.trash))
+	for id, trashed := range r.trash {
+		todo := trashed.asTodo(id)
+		trash = append(trash, *trashEntry(&todo, trashed.deletedAt))
+	}
+	history := make([]journalEntry, 0, len(r.revisions))
+	for _, id := range r.revisedIds() {
+		for _, revision := range r.revisions[id] {
+			history = append(history, *revisionEntry(&revision))
+		}
+	}
+	return &snapshot{LastID: r.lastId, Todos: todos, LastListID: r.lastListId, Lists: lists, Trash: trash, History: history}
+}
+
+// revisedIds returns the ids of the Todos that have a history, in order
+func (r *repoImpl) revisedIds() []domain.TodoID {
+	ids := make([]domain.TodoID, 0, len(r.revisions))
+	for id := range r.revisions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
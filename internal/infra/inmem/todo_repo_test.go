@@ -7,20 +7,21 @@ import (
 	"github.com/icrowley/fake"
 
 	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/repotest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreate(t *testing.T) {
 	repo := MkRepo()
 	newTodo := domain.NewTodo{Task: "clean up after yourself"}
-	created := repo.Create(&newTodo)
+	created, _ := repo.Create(&newTodo)
 	assert.Equal(t, newTodo.Task, created.Task)
 }
 
 func TestGetPresent(t *testing.T) {
 	repo := MkRepo()
 	newTodo := domain.NewTodo{Task: "clean up after yourself"}
-	created := repo.Create(&newTodo)
+	created, _ := repo.Create(&newTodo)
 	retrieved, _ := repo.Get(&created.ID)
 	assert.Equal(t, newTodo.Task, retrieved.Task)
 }
@@ -38,9 +39,11 @@ func TestList(t *testing.T) {
 	var createds []domain.Todo
 	for i := 0; i < toMake; i++ {
 		newTodo := domain.NewTodo{Task: fake.Sentence()}
-		createds = append(createds, repo.Create(&newTodo))
+		created, _ := repo.Create(&newTodo)
+		createds = append(createds, created)
 	}
-	listed := repo.List()
+	page, _ := repo.List(&domain.TodoQuery{})
+	listed := page.Todos
 	assert.Equal(t, toMake, len(listed))
 	for _, created := range createds {
 		var foundInList *domain.Todo
@@ -61,8 +64,8 @@ func TestList(t *testing.T) {
 func TestDeletePresent(t *testing.T) {
 	repo := MkRepo()
 	newTodo := domain.NewTodo{Task: "clean up after yourself"}
-	created := repo.Create(&newTodo)
-	deleted, _ := repo.Delete(&created.ID)
+	created, _ := repo.Create(&newTodo)
+	deleted, _ := repo.Delete(&created.ID, 0)
 	assert.True(t, deleted)
 
 	_, err := repo.Get(&created.ID)
@@ -72,7 +75,7 @@ func TestDeletePresent(t *testing.T) {
 func TestDeleteAbsent(t *testing.T) {
 	repo := MkRepo()
 	id := domain.TodoID(99999999)
-	deleted, err := repo.Delete(&id)
+	deleted, err := repo.Delete(&id, 0)
 	assert.False(t, deleted)
 	assert.True(t, err != nil)
 }
@@ -80,7 +83,7 @@ func TestDeleteAbsent(t *testing.T) {
 func TestUpdatePresent(t *testing.T) {
 	repo := MkRepo()
 	newTodo := domain.NewTodo{Task: "clean up after yourself"}
-	created := repo.Create(&newTodo)
+	created, _ := repo.Create(&newTodo)
 	created.Task = "do the dishes"
 	_, err := repo.Update(&created)
 	assert.True(t, err == nil)
@@ -97,3 +100,16 @@ func TestUpdateAbsent(t *testing.T) {
 	_, err := repo.Update(&update)
 	assert.True(t, err != nil)
 }
+
+func TestTodoRepoContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (domain.TodoRepo, func()) {
+		return MkRepo(), func() {}
+	})
+}
+
+func TestTodoListRepoContract(t *testing.T) {
+	repotest.RunLists(t, func(t *testing.T) (domain.TodoListRepo, func()) {
+		_, lists := MkRepos()
+		return lists, func() {}
+	})
+}
@@ -0,0 +1,179 @@
+package inmem
+
+import (
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// tx keeps track of the changes made by a function run by WithinTx, which
+// get applied as they are made, so that it can read them back, and undone
+// if it fails. The mutex is held all along, so nobody else sees them until
+// the transaction is committed.
+type tx struct {
+	// entries are the changes made so far, in order
+	entries []journalEntry
+	// undo holds the entries that undo each of entries
+	undo []journalEntry
+	// what the counters were before the transaction
+	lastId       domain.TodoID
+	lastPosition string
+	lastListId   domain.TodoListID
+}
+
+// record remembers the given entry, along with how to undo it, before it
+// gets applied to the given repoImpl
+func (t *tx) record(r *repoImpl, entry *journalEntry) {
+	t.entries = append(t.entries, *entry)
+	switch entry.Op {
+	case putOp, deleteOp:
+		if existing, exists := r.stored[entry.ID]; exists {
+			todo := existing.asTodo(entry.ID)
+			t.undo = append(t.undo, *putEntry(&todo))
+		} else if trashed, exists := r.trash[entry.ID]; exists {
+			todo := trashed.asTodo(entry.ID)
+			t.undo = append(t.undo, *trashEntry(&todo, trashed.deletedAt))
+		} else {
+			t.undo = append(t.undo, journalEntry{Op: deleteOp, ID: entry.ID})
+		}
+		if entry.Rev > 0 {
+			t.undo = append(t.undo, journalEntry{Op: dropRevisionOp, ID: entry.ID, Rev: entry.Rev})
+		}
+	case putListOp, deleteListOp:
+		if name, exists := r.lists[*entry.ListID]; exists {
+			t.undo = append(t.undo, *putListEntry(&domain.TodoList{ID: *entry.ListID, Name: name}))
+		} else {
+			t.undo = append(t.undo, journalEntry{Op: deleteListOp, ListID: entry.ListID})
+		}
+	}
+}
+
+func (r *repoImpl) WithinTx(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	return r.withinTx("", f)
+}
+
+// withinTx does the work of WithinTx for repoImpl and actorRepo, handing
+// the function repos whose changes are made by the given actor
+func (r *repoImpl) withinTx(actor string, f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tx = &tx{lastId: r.lastId, lastPosition: r.lastPosition, lastListId: r.lastListId}
+	committed := false
+	defer func() {
+		// also undoes the changes if f panics
+		if !committed {
+			r.rollback()
+		}
+		r.tx = nil
+	}()
+	if err := f(&txRepo{r: r, actor: actor}, &txListRepo{l: &listRepoImpl{r: r}}); err != nil {
+		return err
+	}
+	if r.journal != nil && len(r.tx.entries) > 0 {
+		if err := r.journal.append(&journalEntry{Op: batchOp, Entries: r.tx.entries}); err != nil {
+			return domain.TodoRepoFailure{Cause: err}
+		}
+		r.compactIfDue()
+	}
+	committed = true
+	return nil
+}
+
+// rollback undoes the changes made by the current transaction. Must be
+// called with the mutex held.
+func (r *repoImpl) rollback() {
+	for i := len(r.tx.undo) - 1; i >= 0; i-- {
+		r.apply(&r.tx.undo[i])
+	}
+	r.lastId = r.tx.lastId
+	r.lastPosition = r.tx.lastPosition
+	r.lastListId = r.tx.lastListId
+}
+
+// txRepo is the TodoRepo handed to functions run by WithinTx, which works
+// on its repoImpl without taking the mutex, as it is already held
+type txRepo struct {
+	r     *repoImpl
+	actor string
+}
+
+func (t *txRepo) Create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+	return t.r.create(newTodo, t.actor)
+}
+
+func (t *txRepo) Get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	return t.r.get(id)
+}
+
+func (t *txRepo) List(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+	return t.r.list(query)
+}
+
+func (t *txRepo) Delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+	return t.r.delete(id, version, t.actor)
+}
+
+func (t *txRepo) Update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+	return t.r.update(todo, t.actor)
+}
+
+func (t *txRepo) ListTags() ([]domain.TagCount, domain.TodoRepoError) {
+	return t.r.listTags()
+}
+
+func (t *txRepo) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	return t.r.search(search)
+}
+
+func (t *txRepo) ListTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	return t.r.listTrash()
+}
+
+func (t *txRepo) Restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	return t.r.restore(id, t.actor)
+}
+
+func (t *txRepo) Purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	return t.r.purge(id)
+}
+
+func (t *txRepo) PurgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	return t.r.purgeDeletedBefore(before)
+}
+
+func (t *txRepo) History(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	return t.r.history(id)
+}
+
+func (t *txRepo) AsActor(actor string) domain.TodoRepo {
+	return &txRepo{r: t.r, actor: actor}
+}
+
+func (t *txRepo) WithinTx(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	return f(t, &txListRepo{l: &listRepoImpl{r: t.r}})
+}
+
+// txListRepo is txRepo for TodoLists
+type txListRepo struct {
+	l *listRepoImpl
+}
+
+func (t *txListRepo) Create(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	return t.l.create(newList)
+}
+
+func (t *txListRepo) Get(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	return t.l.get(id)
+}
+
+func (t *txListRepo) List() ([]domain.TodoList, domain.TodoListRepoError) {
+	return t.l.r.listsInOrder(), nil
+}
+
+func (t *txListRepo) Update(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	return t.l.update(list)
+}
+
+func (t *txListRepo) Delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	return t.l.delete(id)
+}
@@ -0,0 +1,203 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+// migration brings the schema one step closer to being up to date
+type migration func(tx *sql.Tx) error
+
+// statement returns a migration that runs the given SQL statement
+func statement(query string) migration {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// migrations holds the steps needed to bring the schema up to date,
+// in order. The number of migrations already applied is tracked in SQLite's
+// user_version pragma, so existing entries must never be edited or
+// reordered: append new ones instead.
+var migrations = []migration{
+	// AUTOINCREMENT keeps ids from being reused after deletes, which
+	// matches the other TodoRepo implementations
+	statement(`CREATE TABLE todos (
+		id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		task TEXT NOT NULL
+	)`),
+	statement(`ALTER TABLE todos ADD COLUMN completed INTEGER NOT NULL DEFAULT 0`),
+	statement(`ALTER TABLE todos ADD COLUMN completed_at INTEGER`),
+	statement(`ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1`),
+	statement(`ALTER TABLE todos ADD COLUMN due_at INTEGER`),
+	statement(`CREATE INDEX todos_due_at ON todos (due_at)`),
+	statement(`CREATE TABLE todo_tags (
+		todo_id INTEGER NOT NULL REFERENCES todos (id),
+		tag     TEXT NOT NULL,
+		PRIMARY KEY (todo_id, tag)
+	)`),
+	statement(`CREATE INDEX todo_tags_tag ON todo_tags (tag)`),
+	statement(`ALTER TABLE todos ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`),
+	statement(`CREATE INDEX todos_priority ON todos (priority)`),
+	statement(`CREATE TABLE todo_terms (
+		term        TEXT NOT NULL,
+		todo_id     INTEGER NOT NULL REFERENCES todos (id),
+		occurrences INTEGER NOT NULL,
+		PRIMARY KEY (term, todo_id)
+	)`),
+	statement(`CREATE INDEX todo_terms_todo_id ON todo_terms (todo_id)`),
+	statement(`ALTER TABLE todos ADD COLUMN term_count INTEGER NOT NULL DEFAULT 0`),
+	indexExistingTasks,
+	statement(`ALTER TABLE todos ADD COLUMN parent_id INTEGER REFERENCES todos (id)`),
+	statement(`CREATE INDEX todos_parent_id ON todos (parent_id)`),
+	statement(`CREATE TABLE todo_dependencies (
+		todo_id       INTEGER NOT NULL REFERENCES todos (id),
+		depends_on_id INTEGER NOT NULL REFERENCES todos (id),
+		PRIMARY KEY (todo_id, depends_on_id)
+	)`),
+	statement(`CREATE INDEX todo_dependencies_depends_on_id ON todo_dependencies (depends_on_id)`),
+	statement(`ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT ''`),
+	statement(`CREATE TABLE todo_lists (
+		id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL
+	)`),
+	statement(`ALTER TABLE todos ADD COLUMN list_id INTEGER REFERENCES todo_lists (id)`),
+	statement(`CREATE INDEX todos_list_id ON todos (list_id)`),
+	statement(`ALTER TABLE todos ADD COLUMN position TEXT NOT NULL DEFAULT ''`),
+	positionExistingTodos,
+	statement(`CREATE INDEX todos_position ON todos (position)`),
+	// Trashed Todos are kept apart so that nothing else has to look out
+	// for them; tags and dependencies are joined as in todoColumns
+	statement(`CREATE TABLE trashed_todos (
+		id           INTEGER PRIMARY KEY,
+		deleted_at   INTEGER NOT NULL,
+		version      INTEGER NOT NULL,
+		task         TEXT NOT NULL,
+		completed    INTEGER NOT NULL,
+		completed_at INTEGER,
+		due_at       INTEGER,
+		priority     INTEGER NOT NULL,
+		parent_id    INTEGER,
+		recurrence   TEXT NOT NULL,
+		list_id      INTEGER,
+		position     TEXT NOT NULL,
+		tags         TEXT,
+		depends_on   TEXT
+	)`),
+	statement(`CREATE INDEX trashed_todos_deleted_at ON trashed_todos (deleted_at)`),
+	statement(`CREATE TABLE todo_revisions (
+		todo_id      INTEGER NOT NULL,
+		rev          INTEGER NOT NULL,
+		change       TEXT NOT NULL,
+		at           INTEGER NOT NULL,
+		actor        TEXT NOT NULL,
+		version      INTEGER NOT NULL,
+		task         TEXT NOT NULL,
+		completed    INTEGER NOT NULL,
+		completed_at INTEGER,
+		due_at       INTEGER,
+		priority     INTEGER NOT NULL,
+		parent_id    INTEGER,
+		recurrence   TEXT NOT NULL,
+		list_id      INTEGER,
+		position     TEXT NOT NULL,
+		tags         TEXT,
+		depends_on   TEXT,
+		PRIMARY KEY (todo_id, rev)
+	)`),
+}
+
+// migrate applies any migrations the given database has not seen yet
+func migrate(db *sql.DB) error {
+	return migrateUpTo(db, len(migrations))
+}
+
+// migrateUpTo applies the migrations the given database has not seen yet,
+// stopping before the one at the given index
+func migrateUpTo(db *sql.DB, end int) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < end; i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[i](tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration [%d] failed: %v", i, err)
+		}
+		// PRAGMA does not support bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexExistingTasks fills todo_terms in for the Todos created before
+// there was a search index
+func indexExistingTasks(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, task FROM todos")
+	if err != nil {
+		return err
+	}
+	tasks := make(map[domain.TodoID]string)
+	for rows.Next() {
+		var id domain.TodoID
+		var task string
+		if err := rows.Scan(&id, &task); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		tasks[id] = task
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for id, task := range tasks {
+		if err := indexTask(tx, id, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// positionExistingTodos gives the Todos created before there were positions
+// one each, in order of id
+func positionExistingTodos(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id FROM todos ORDER BY id")
+	if err != nil {
+		return err
+	}
+	var ids []domain.TodoID
+	for rows.Next() {
+		var id domain.TodoID
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	position := ""
+	for _, id := range ids {
+		if position, err = domain.PositionBetween(position, ""); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE todos SET position = ? WHERE id = ?", position, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+)
+
+type listRepoImpl struct {
+	db *sql.DB
+	// tx is only set on the repos handed out by WithinTx; see repoImpl
+	tx *sql.Tx
+}
+
+// MkListRepo returns a new TodoListRepo based on the given SQLite database,
+// making sure the schema it needs exists first; see MkRepo
+func MkListRepo(db *sql.DB) (domain.TodoListRepo, error) {
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	return &listRepoImpl{db: db}, nil
+}
+
+func (l *listRepoImpl) Create(newList *domain.NewTodoList) (domain.TodoList, domain.TodoListRepoError) {
+	result, err := l.conn().Exec("INSERT INTO todo_lists (name) VALUES (?)", newList.Name)
+	if err != nil {
+		return domain.TodoList{}, domain.TodoListRepoFailure{Cause: err}
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return domain.TodoList{}, domain.TodoListRepoFailure{Cause: err}
+	}
+	return domain.TodoList{ID: domain.TodoListID(id), Name: newList.Name}, nil
+}
+
+func (l *listRepoImpl) Get(id *domain.TodoListID) (domain.TodoList, domain.TodoListRepoError) {
+	list := domain.TodoList{ID: *id}
+	switch err := l.conn().QueryRow("SELECT name FROM todo_lists WHERE id = ?", *id).Scan(&list.Name); err {
+	case nil:
+		return list, nil
+	case sql.ErrNoRows:
+		return domain.TodoList{}, domain.TodoListNotFound{ID: *id}
+	default:
+		return domain.TodoList{}, domain.TodoListRepoFailure{ID: *id, Cause: err}
+	}
+}
+
+func (l *listRepoImpl) List() ([]domain.TodoList, domain.TodoListRepoError) {
+	rows, err := l.conn().Query("SELECT id, name FROM todo_lists ORDER BY id")
+	if err != nil {
+		return nil, domain.TodoListRepoFailure{Cause: err}
+	}
+	defer rows.Close()
+	lists := make([]domain.TodoList, 0)
+	for rows.Next() {
+		var list domain.TodoList
+		if err := rows.Scan(&list.ID, &list.Name); err != nil {
+			return nil, domain.TodoListRepoFailure{Cause: err}
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.TodoListRepoFailure{Cause: err}
+	}
+	return lists, nil
+}
+
+func (l *listRepoImpl) Update(list *domain.TodoList) (domain.TodoList, domain.TodoListRepoError) {
+	result, err := l.conn().Exec("UPDATE todo_lists SET name = ? WHERE id = ?", list.Name, list.ID)
+	if err != nil {
+		return domain.TodoList{}, domain.TodoListRepoFailure{ID: list.ID, Cause: err}
+	}
+	if err := checkListAffected(result, list.ID); err != nil {
+		return domain.TodoList{}, err
+	}
+	return *list, nil
+}
+
+func (l *listRepoImpl) Delete(id *domain.TodoListID) (bool, domain.TodoListRepoError) {
+	result, err := l.conn().Exec("DELETE FROM todo_lists WHERE id = ?", *id)
+	if err != nil {
+		return false, domain.TodoListRepoFailure{ID: *id, Cause: err}
+	}
+	if err := checkListAffected(result, *id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// conn is repoImpl.conn for TodoLists
+func (l *listRepoImpl) conn() querier {
+	if l.tx != nil {
+		return l.tx
+	}
+	return l.db
+}
+
+// checkListAffected returns TodoListNotFound if the given result did not
+// affect any rows
+func checkListAffected(result sql.Result, id domain.TodoListID) domain.TodoListRepoError {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.TodoListRepoFailure{ID: id, Cause: err}
+	}
+	if affected == 0 {
+		return domain.TodoListNotFound{ID: id}
+	}
+	return nil
+}
@@ -0,0 +1,842 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+
+	// Registers the "sqlite3" driver for database/sql
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DriverName is the database/sql driver name to use when opening a
+// database for MkRepo
+const DriverName = "sqlite3"
+
+// todoColumns are the columns read into a domain.Todo by scanTodo, in order.
+// Tags and dependencies come from their own tables, each joined into a
+// single column, and whether the Todo is blocked is worked out on the fly.
+const todoColumns = "id, version, task, completed, completed_at, due_at, priority, parent_id, recurrence, list_id, position, " +
+	"(SELECT group_concat(tag, char(31)) FROM todo_tags WHERE todo_id = todos.id), " +
+	"(SELECT group_concat(depends_on_id) FROM todo_dependencies WHERE todo_id = todos.id), " +
+	"EXISTS (SELECT 1 FROM todo_dependencies JOIN todos AS dependencies ON dependencies.id = depends_on_id " +
+	"WHERE todo_id = todos.id AND dependencies.completed = 0)"
+
+// tagSeparator is what group_concat joins tags with in todoColumns
+const tagSeparator = "\x1f"
+
+// copiedColumns are the columns of todos that get copied as they are into
+// trashed_todos and todo_revisions, after the id, with tags and dependencies
+// joined as in todoColumns
+const copiedColumns = "version, task, completed, completed_at, due_at, priority, parent_id, recurrence, list_id, position, " +
+	"(SELECT group_concat(tag, char(31)) FROM todo_tags WHERE todo_id = todos.id), " +
+	"(SELECT group_concat(depends_on_id) FROM todo_dependencies WHERE todo_id = todos.id)"
+
+// copyColumns are what copiedColumns get copied into
+const copyColumns = "version, task, completed, completed_at, due_at, priority, parent_id, recurrence, list_id, position, " +
+	"tags, depends_on"
+
+// trashedColumns are todoColumns for trashed_todos, where nothing is
+// blocked, followed by deleted_at
+const trashedColumns = "id, " + copyColumns + ", 0, deleted_at"
+
+// revisionColumns are todoColumns for todo_revisions, where nothing is
+// blocked either, followed by the rest of the revision
+const revisionColumns = "todo_id, " + copyColumns + ", 0, rev, change, at, actor"
+
+type repoImpl struct {
+	db *sql.DB
+	// tx is only set on the repos handed out by WithinTx, and used
+	// instead of db
+	tx *sql.Tx
+	// actor is who changes made through the repo are recorded as made by
+	actor string
+}
+
+// Open opens (creating it if needed) the SQLite database at the given path
+// and returns a TodoRepo backed by it. Use ":memory:" for a throwaway database.
+func Open(path string) (domain.TodoRepo, error) {
+	db, err := sql.Open(DriverName, path)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := MkRepo(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// OpenRepos is Open, also returning a TodoListRepo backed by the same database
+func OpenRepos(path string) (domain.TodoRepo, domain.TodoListRepo, error) {
+	repo, err := Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, &listRepoImpl{db: repo.(*repoImpl).db}, nil
+}
+
+// MkRepo returns a new TodoRepo based on the given SQLite database,
+// making sure the schema it needs exists first.
+//
+// SQLite only allows a single writer at a time, so the pool is limited
+// to one connection; this also keeps ":memory:" databases from being
+// silently split across connections.
+func MkRepo(db *sql.DB) (domain.TodoRepo, error) {
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	return &repoImpl{db: db}, nil
+}
+
+func (r *repoImpl) Create(newTodo *domain.NewTodo) (domain.Todo, domain.TodoRepoError) {
+	var created domain.Todo
+	err := r.inTx(0, func(tx *sql.Tx) domain.TodoRepoError {
+		position := newTodo.Position
+		if len(position) == 0 {
+			var err error
+			if position, err = nextPosition(tx); err != nil {
+				return domain.TodoRepoFailure{Cause: err}
+			}
+		}
+		result, err := tx.Exec(
+			"INSERT INTO todos (task, completed, completed_at, due_at, priority, parent_id, recurrence, list_id, position) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			newTodo.Task, newTodo.Completed, toNanos(newTodo.CompletedAt), toNanos(newTodo.DueAt), newTodo.Priority,
+			toNullableID(newTodo.ParentID), newTodo.Recurrence, toNullableListID(newTodo.ListID), position,
+		)
+		if err != nil {
+			return domain.TodoRepoFailure{Cause: err}
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return domain.TodoRepoFailure{Cause: err}
+		}
+		todoID := domain.TodoID(id)
+		if err := insertTags(tx, todoID, domain.NormaliseTags(newTodo.Tags)); err != nil {
+			return err
+		}
+		if err := insertDependencies(tx, todoID, domain.NormaliseIDs(newTodo.DependsOn)); err != nil {
+			return err
+		}
+		if err := indexTask(tx, todoID, newTodo.Task); err != nil {
+			return err
+		}
+		if err := recordRevision(tx, todoID, domain.CreatedRevision, r.actor); err != nil {
+			return err
+		}
+		var getErr domain.TodoRepoError
+		created, getErr = getTodo(tx, todoID)
+		return getErr
+	})
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	return created, nil
+}
+
+func (r *repoImpl) Get(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	return getTodo(r.conn(), *id)
+}
+
+// nextPosition returns a Position after those of all the Todos there are
+func nextPosition(tx *sql.Tx) (string, error) {
+	var last string
+	if err := tx.QueryRow("SELECT coalesce(max(position), '') FROM todos").Scan(&last); err != nil {
+		return "", err
+	}
+	return domain.PositionBetween(last, "")
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// conn returns what to run statements against: the transaction the repo
+// was handed out for by WithinTx, if any, and the database otherwise
+func (r *repoImpl) conn() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// getTodo reads the Todo with the given id
+func getTodo(q querier, id domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	row := q.QueryRow("SELECT "+todoColumns+" FROM todos WHERE id = ?", id)
+	switch todo, err := scanTodo(row); err {
+	case nil:
+		return todo, nil
+	case sql.ErrNoRows:
+		return domain.Todo{}, domain.TodoNotFound{ID: id}
+	default:
+		return domain.Todo{}, domain.TodoRepoFailure{ID: id, Cause: err}
+	}
+}
+
+func (r *repoImpl) List(query *domain.TodoQuery) (domain.TodoPage, domain.TodoRepoError) {
+	where, args := whereClause(query)
+	limit := ""
+	if query.Limit > 0 {
+		// one more than asked for, to find out if there is a next page
+		limit = " LIMIT ?"
+		args = append(args, query.Limit+1)
+	}
+	rows, err := r.conn().Query("SELECT "+todoColumns+" FROM todos"+where+orderByClause(query)+limit, args...)
+	if err != nil {
+		return domain.TodoPage{}, domain.TodoRepoFailure{Cause: err}
+	}
+	defer rows.Close()
+	retrieved := make([]domain.Todo, 0)
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return domain.TodoPage{}, domain.TodoRepoFailure{Cause: err}
+		}
+		retrieved = append(retrieved, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return domain.TodoPage{}, domain.TodoRepoFailure{Cause: err}
+	}
+	return domain.MkTodoPage(retrieved, query.Limit), nil
+}
+
+func (r *repoImpl) Delete(id *domain.TodoID, version domain.TodoVersion) (bool, domain.TodoRepoError) {
+	err := r.inTx(*id, func(tx *sql.Tx) domain.TodoRepoError {
+		if err := checkVersion(tx, *id, version); err != nil {
+			return err
+		}
+		if err := recordRevision(tx, *id, domain.DeletedRevision, r.actor); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO trashed_todos (id, deleted_at, "+copyColumns+") "+
+				"SELECT id, ?, "+copiedColumns+" FROM todos WHERE id = ?",
+			time.Now().UnixNano(), *id,
+		); err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		if _, err := tx.Exec("DELETE FROM todo_tags WHERE todo_id = ?", *id); err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		if _, err := tx.Exec("DELETE FROM todo_terms WHERE todo_id = ?", *id); err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		if _, err := tx.Exec("DELETE FROM todo_dependencies WHERE todo_id = ?", *id); err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		if _, err := tx.Exec("DELETE FROM todos WHERE id = ?", *id); err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		return nil
+	})
+	return err == nil, err
+}
+
+func (r *repoImpl) Update(todo *domain.Todo) (domain.Todo, domain.TodoRepoError) {
+	var updated domain.Todo
+	err := r.inTx(todo.ID, func(tx *sql.Tx) domain.TodoRepoError {
+		if err := checkVersion(tx, todo.ID, todo.Version); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE todos SET version = version + 1, task = ?, completed = ?, completed_at = ?, due_at = ?, priority = ?, parent_id = ?, "+
+				"recurrence = ?, list_id = ?, position = coalesce(nullif(?, ''), position) WHERE id = ?",
+			todo.Task, todo.Completed, toNanos(todo.CompletedAt), toNanos(todo.DueAt), todo.Priority,
+			toNullableID(todo.ParentID), todo.Recurrence, toNullableListID(todo.ListID), todo.Position, todo.ID,
+		); err != nil {
+			return domain.TodoRepoFailure{ID: todo.ID, Cause: err}
+		}
+		if _, err := tx.Exec("DELETE FROM todo_tags WHERE todo_id = ?", todo.ID); err != nil {
+			return domain.TodoRepoFailure{ID: todo.ID, Cause: err}
+		}
+		if err := insertTags(tx, todo.ID, domain.NormaliseTags(todo.Tags)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM todo_dependencies WHERE todo_id = ?", todo.ID); err != nil {
+			return domain.TodoRepoFailure{ID: todo.ID, Cause: err}
+		}
+		if err := insertDependencies(tx, todo.ID, domain.NormaliseIDs(todo.DependsOn)); err != nil {
+			return err
+		}
+		if err := indexTask(tx, todo.ID, todo.Task); err != nil {
+			return err
+		}
+		if err := recordRevision(tx, todo.ID, domain.UpdatedRevision, r.actor); err != nil {
+			return err
+		}
+		var err domain.TodoRepoError
+		updated, err = getTodo(tx, todo.ID)
+		return err
+	})
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	return updated, nil
+}
+
+func (r *repoImpl) ListTags() ([]domain.TagCount, domain.TodoRepoError) {
+	rows, err := r.conn().Query("SELECT tag, count(*) FROM todo_tags GROUP BY tag ORDER BY tag")
+	if err != nil {
+		return nil, domain.TodoRepoFailure{Cause: err}
+	}
+	defer rows.Close()
+	tagCounts := make([]domain.TagCount, 0)
+	for rows.Next() {
+		var tagCount domain.TagCount
+		if err := rows.Scan(&tagCount.Tag, &tagCount.Count); err != nil {
+			return nil, domain.TodoRepoFailure{Cause: err}
+		}
+		tagCounts = append(tagCounts, tagCount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.TodoRepoFailure{Cause: err}
+	}
+	return tagCounts, nil
+}
+
+func (r *repoImpl) Search(search *domain.TodoSearch) ([]domain.TodoSearchResult, domain.TodoRepoError) {
+	results := make([]domain.TodoSearchResult, 0)
+	err := r.inTx(0, func(tx *sql.Tx) domain.TodoRepoError {
+		var todos uint
+		if err := tx.QueryRow("SELECT count(*) FROM todos").Scan(&todos); err != nil {
+			return domain.TodoRepoFailure{Cause: err}
+		}
+		tokens := domain.Tokenise(search.Text)
+		var postings []domain.Posting
+		for _, token := range tokens {
+			found, err := postingsWithPrefix(tx, token)
+			if err != nil {
+				return domain.TodoRepoFailure{Cause: err}
+			}
+			postings = append(postings, found...)
+		}
+		for _, hit := range domain.RankSearch(tokens, postings, todos, search.Limit) {
+			todo, err := getTodo(tx, hit.ID)
+			if err != nil {
+				return err
+			}
+			results = append(results, domain.TodoSearchResult{Todo: todo, Score: hit.Score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *repoImpl) ListTrash() ([]domain.TrashedTodo, domain.TodoRepoError) {
+	rows, err := r.conn().Query("SELECT " + trashedColumns + " FROM trashed_todos ORDER BY deleted_at DESC, id DESC")
+	if err != nil {
+		return nil, domain.TodoRepoFailure{Cause: err}
+	}
+	defer rows.Close()
+	trashed := make([]domain.TrashedTodo, 0)
+	for rows.Next() {
+		todo, err := scanTrashedTodo(rows)
+		if err != nil {
+			return nil, domain.TodoRepoFailure{Cause: err}
+		}
+		trashed = append(trashed, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.TodoRepoFailure{Cause: err}
+	}
+	return trashed, nil
+}
+
+func (r *repoImpl) Restore(id *domain.TodoID) (domain.Todo, domain.TodoRepoError) {
+	var restored domain.Todo
+	err := r.inTx(*id, func(tx *sql.Tx) domain.TodoRepoError {
+		row := tx.QueryRow("SELECT "+trashedColumns+" FROM trashed_todos WHERE id = ?", *id)
+		trashed, err := scanTrashedTodo(row)
+		if err == sql.ErrNoRows {
+			return domain.TodoNotFound{ID: *id}
+		} else if err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		todo := trashed.Todo
+		if _, err := tx.Exec(
+			"INSERT INTO todos (id, version, task, completed, completed_at, due_at, priority, parent_id, recurrence, list_id, position) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			todo.ID, todo.Version+1, todo.Task, todo.Completed, toNanos(todo.CompletedAt), toNanos(todo.DueAt), todo.Priority,
+			toNullableID(todo.ParentID), todo.Recurrence, toNullableListID(todo.ListID), todo.Position,
+		); err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		if err := insertTags(tx, todo.ID, todo.Tags); err != nil {
+			return err
+		}
+		if err := insertDependencies(tx, todo.ID, todo.DependsOn); err != nil {
+			return err
+		}
+		if err := indexTask(tx, todo.ID, todo.Task); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM trashed_todos WHERE id = ?", *id); err != nil {
+			return domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		if err := recordRevision(tx, *id, domain.RestoredRevision, r.actor); err != nil {
+			return err
+		}
+		var getErr domain.TodoRepoError
+		restored, getErr = getTodo(tx, *id)
+		return getErr
+	})
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	return restored, nil
+}
+
+func (r *repoImpl) Purge(id *domain.TodoID) (bool, domain.TodoRepoError) {
+	result, err := r.conn().Exec("DELETE FROM trashed_todos WHERE id = ?", *id)
+	if err != nil {
+		return false, domain.TodoRepoFailure{ID: *id, Cause: err}
+	}
+	if purged, err := result.RowsAffected(); err != nil {
+		return false, domain.TodoRepoFailure{ID: *id, Cause: err}
+	} else if purged == 0 {
+		return false, domain.TodoNotFound{ID: *id}
+	}
+	return true, nil
+}
+
+func (r *repoImpl) PurgeDeletedBefore(before time.Time) (int, domain.TodoRepoError) {
+	result, err := r.conn().Exec("DELETE FROM trashed_todos WHERE deleted_at < ?", before.UnixNano())
+	if err != nil {
+		return 0, domain.TodoRepoFailure{Cause: err}
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, domain.TodoRepoFailure{Cause: err}
+	}
+	return int(purged), nil
+}
+
+func (r *repoImpl) History(id *domain.TodoID) ([]domain.TodoRevision, domain.TodoRepoError) {
+	rows, err := r.conn().Query("SELECT "+revisionColumns+" FROM todo_revisions WHERE todo_id = ? ORDER BY rev", *id)
+	if err != nil {
+		return nil, domain.TodoRepoFailure{ID: *id, Cause: err}
+	}
+	defer rows.Close()
+	revisions := make([]domain.TodoRevision, 0)
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, domain.TodoRepoFailure{ID: *id, Cause: err}
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.TodoRepoFailure{ID: *id, Cause: err}
+	}
+	if len(revisions) == 0 {
+		// Todos from before there was any history have none
+		var exists bool
+		if err := r.conn().QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM todos WHERE id = ?) OR EXISTS (SELECT 1 FROM trashed_todos WHERE id = ?)", *id, *id,
+		).Scan(&exists); err != nil {
+			return nil, domain.TodoRepoFailure{ID: *id, Cause: err}
+		} else if !exists {
+			return nil, domain.TodoNotFound{ID: *id}
+		}
+	}
+	return revisions, nil
+}
+
+func (r *repoImpl) AsActor(actor string) domain.TodoRepo {
+	return &repoImpl{db: r.db, tx: r.tx, actor: actor}
+}
+
+// recordRevision records the Todo with the given id as it is now as its
+// next revision, made by the given actor
+func recordRevision(tx *sql.Tx, id domain.TodoID, change domain.RevisionChange, actor string) domain.TodoRepoError {
+	if _, err := tx.Exec(
+		"INSERT INTO todo_revisions (todo_id, rev, change, at, actor, "+copyColumns+") "+
+			"SELECT id, (SELECT coalesce(max(rev), 0) + 1 FROM todo_revisions WHERE todo_id = todos.id), ?, ?, ?, "+
+			copiedColumns+" FROM todos WHERE id = ?",
+		change, time.Now().UnixNano(), actor, id,
+	); err != nil {
+		return domain.TodoRepoFailure{ID: id, Cause: err}
+	}
+	return nil
+}
+
+// postingsWithPrefix returns the postings of every term starting with the
+// given prefix
+func postingsWithPrefix(tx *sql.Tx, prefix string) ([]domain.Posting, error) {
+	lower, upper := domain.PrefixRange(prefix)
+	rows, err := tx.Query(
+		"SELECT todo_id, term, occurrences, todos.term_count FROM todo_terms JOIN todos ON todos.id = todo_id "+
+			"WHERE term >= ? AND term < ?",
+		lower, upper,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var postings []domain.Posting
+	for rows.Next() {
+		var posting domain.Posting
+		if err := rows.Scan(&posting.ID, &posting.Term, &posting.Occurrences, &posting.Length); err != nil {
+			return nil, err
+		}
+		postings = append(postings, posting)
+	}
+	return postings, rows.Err()
+}
+
+// indexTask replaces the terms that the Todo with the given id is indexed
+// under for searches with those of the given task
+func indexTask(tx *sql.Tx, id domain.TodoID, task string) domain.TodoRepoError {
+	if _, err := tx.Exec("DELETE FROM todo_terms WHERE todo_id = ?", id); err != nil {
+		return domain.TodoRepoFailure{ID: id, Cause: err}
+	}
+	terms := domain.Tokenise(task)
+	for term, occurrences := range domain.TermCounts(terms) {
+		if _, err := tx.Exec(
+			"INSERT INTO todo_terms (term, todo_id, occurrences) VALUES (?, ?, ?)", term, id, occurrences,
+		); err != nil {
+			return domain.TodoRepoFailure{ID: id, Cause: err}
+		}
+	}
+	if _, err := tx.Exec("UPDATE todos SET term_count = ? WHERE id = ?", len(terms), id); err != nil {
+		return domain.TodoRepoFailure{ID: id, Cause: err}
+	}
+	return nil
+}
+
+// insertTags tags the Todo with the given id with the given tags
+func insertTags(tx *sql.Tx, id domain.TodoID, tags []string) domain.TodoRepoError {
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT INTO todo_tags (todo_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return domain.TodoRepoFailure{ID: id, Cause: err}
+		}
+	}
+	return nil
+}
+
+// insertDependencies makes the Todo with the given id depend on the Todos
+// with the given ids
+func insertDependencies(tx *sql.Tx, id domain.TodoID, dependsOn []domain.TodoID) domain.TodoRepoError {
+	for _, dependency := range dependsOn {
+		if _, err := tx.Exec(
+			"INSERT INTO todo_dependencies (todo_id, depends_on_id) VALUES (?, ?)", id, dependency,
+		); err != nil {
+			return domain.TodoRepoFailure{ID: id, Cause: err}
+		}
+	}
+	return nil
+}
+
+// WithinTx runs the given function against repos in an SQLite transaction.
+// Statements run against the database directly in the meantime wait for it
+// to finish, as there is only the one connection, so the function must only
+// use the repos it is given.
+func (r *repoImpl) WithinTx(f func(todos domain.TodoRepo, lists domain.TodoListRepo) error) error {
+	if r.tx != nil {
+		return f(r, &listRepoImpl{db: r.db, tx: r.tx})
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return domain.TodoRepoFailure{Cause: err}
+	}
+	committed := false
+	defer func() {
+		// also rolls back if f panics
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+	if err := f(&repoImpl{db: r.db, tx: tx, actor: r.actor}, &listRepoImpl{db: r.db, tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.TodoRepoFailure{Cause: err}
+	}
+	committed = true
+	return nil
+}
+
+// inTx runs the given function in a transaction, committing it if the
+// function succeeds and rolling it back otherwise. Within WithinTx, it
+// runs it in the transaction that is already going on instead.
+func (r *repoImpl) inTx(id domain.TodoID, f func(tx *sql.Tx) domain.TodoRepoError) domain.TodoRepoError {
+	if r.tx != nil {
+		return f(r.tx)
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return domain.TodoRepoFailure{ID: id, Cause: err}
+	}
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.TodoRepoFailure{ID: id, Cause: err}
+	}
+	return nil
+}
+
+// checkVersion makes sure the Todo with the given id exists, and is at the
+// given version unless that is zero
+func checkVersion(tx *sql.Tx, id domain.TodoID, expected domain.TodoVersion) domain.TodoRepoError {
+	var actual domain.TodoVersion
+	switch err := tx.QueryRow("SELECT version FROM todos WHERE id = ?", id).Scan(&actual); err {
+	case nil:
+		return domain.CheckVersion(id, expected, actual)
+	case sql.ErrNoRows:
+		return domain.TodoNotFound{ID: id}
+	default:
+		return domain.TodoRepoFailure{ID: id, Cause: err}
+	}
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTodo reads the todoColumns of the current row into a domain.Todo
+func scanTodo(s scanner) (domain.Todo, error) {
+	var todo domain.Todo
+	var completedAt, dueAt, parentID, listID sql.NullInt64
+	var tags, dependsOn sql.NullString
+	if err := s.Scan(
+		&todo.ID, &todo.Version, &todo.Task, &todo.Completed, &completedAt, &dueAt, &todo.Priority, &parentID, &todo.Recurrence,
+		&listID, &todo.Position, &tags, &dependsOn, &todo.Blocked,
+	); err != nil {
+		return domain.Todo{}, err
+	}
+	if parentID.Valid {
+		id := domain.TodoID(parentID.Int64)
+		todo.ParentID = &id
+	}
+	if listID.Valid {
+		id := domain.TodoListID(listID.Int64)
+		todo.ListID = &id
+	}
+	todo.CompletedAt = fromNanos(completedAt)
+	todo.DueAt = fromNanos(dueAt)
+	if tags.Valid {
+		// group_concat does not promise any order
+		todo.Tags = domain.NormaliseTags(strings.Split(tags.String, tagSeparator))
+	}
+	if dependsOn.Valid {
+		var ids []domain.TodoID
+		for _, id := range strings.Split(dependsOn.String, ",") {
+			parsed, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				return domain.Todo{}, err
+			}
+			ids = append(ids, domain.TodoID(parsed))
+		}
+		todo.DependsOn = domain.NormaliseIDs(ids)
+	}
+	return todo, nil
+}
+
+// scanTrashedTodo reads the trashedColumns of the current row into a
+// domain.TrashedTodo
+func scanTrashedTodo(s scanner) (domain.TrashedTodo, error) {
+	var deletedAt int64
+	todo, err := scanTodo(&extraColumns{s: s, dest: []interface{}{&deletedAt}})
+	if err != nil {
+		return domain.TrashedTodo{}, err
+	}
+	return domain.TrashedTodo{Todo: todo, DeletedAt: time.Unix(0, deletedAt).UTC()}, nil
+}
+
+// scanRevision reads the revisionColumns of the current row into a
+// domain.TodoRevision
+func scanRevision(s scanner) (domain.TodoRevision, error) {
+	var revision domain.TodoRevision
+	var at int64
+	todo, err := scanTodo(&extraColumns{s: s, dest: []interface{}{&revision.Rev, &revision.Change, &at, &revision.Actor}})
+	if err != nil {
+		return domain.TodoRevision{}, err
+	}
+	revision.Todo = todo
+	revision.At = time.Unix(0, at).UTC()
+	return revision, nil
+}
+
+// extraColumns is a scanner that also scans the columns that come after
+// the ones it is asked to into dest
+type extraColumns struct {
+	s    scanner
+	dest []interface{}
+}
+
+func (e *extraColumns) Scan(dest ...interface{}) error {
+	return e.s.Scan(append(dest, e.dest...)...)
+}
+
+// whereClause turns the given query into a WHERE clause (empty if the
+// query matches everything) along with the arguments it needs
+func whereClause(query *domain.TodoQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if query.Completed != nil {
+		conditions = append(conditions, "completed = ?")
+		args = append(args, *query.Completed)
+	}
+	if query.DueBefore != nil {
+		conditions = append(conditions, "due_at < ?")
+		args = append(args, query.DueBefore.UnixNano())
+	}
+	if query.DueAfter != nil {
+		conditions = append(conditions, "due_at >= ?")
+		args = append(args, query.DueAfter.UnixNano())
+	}
+	if query.Overdue != nil {
+		if *query.Overdue {
+			conditions = append(conditions, "(completed = 0 AND due_at < ?)")
+		} else {
+			conditions = append(conditions, "(completed = 1 OR due_at IS NULL OR due_at >= ?)")
+		}
+		args = append(args, query.OverdueAsOf.UnixNano())
+	}
+	if tags := domain.NormaliseTags(query.Tags); len(tags) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+		tagged := "SELECT count(*) FROM todo_tags WHERE todo_id = todos.id AND tag IN (" + placeholders + ")"
+		if query.AllTags {
+			conditions = append(conditions, "("+tagged+") = ?")
+		} else {
+			conditions = append(conditions, "("+tagged+") > 0")
+		}
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+		if query.AllTags {
+			args = append(args, len(tags))
+		}
+	}
+	if query.ParentID != nil {
+		conditions = append(conditions, "parent_id = ?")
+		args = append(args, *query.ParentID)
+	}
+	if query.TopLevel {
+		conditions = append(conditions, "parent_id IS NULL")
+	}
+	if query.DependencyOf != nil {
+		conditions = append(conditions, "id IN (SELECT todo_id FROM todo_dependencies WHERE depends_on_id = ?)")
+		args = append(args, *query.DependencyOf)
+	}
+	if query.ListID != nil {
+		conditions = append(conditions, "list_id = ?")
+		args = append(args, *query.ListID)
+	}
+	if query.After != nil {
+		after, afterArgs := afterCondition(query)
+		conditions = append(conditions, after)
+		args = append(args, afterArgs...)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// sortExpressions are what Todos get sorted on for each domain.SortField,
+// matching domain.TodoQuery.Compare
+var sortExpressions = map[domain.SortField]string{
+	domain.SortByID:       "id",
+	domain.SortByPriority: "priority",
+	domain.SortByDueAt:    "coalesce(due_at, 9223372036854775807)",
+	domain.SortByPosition: "position",
+}
+
+// sortValue returns the value of the sort expression for the given field
+// at the given cursor
+func sortValue(field domain.SortField, cursor *domain.TodoCursor) interface{} {
+	switch field {
+	case domain.SortByPriority:
+		return cursor.Priority
+	case domain.SortByDueAt:
+		return domain.DueAtSortValue(cursor.DueAt)
+	case domain.SortByPosition:
+		return cursor.Position
+	default:
+		return cursor.ID
+	}
+}
+
+func orderByClause(query *domain.TodoQuery) string {
+	var terms []string
+	for _, key := range query.SortKeys() {
+		term := sortExpressions[key.Field]
+		if key.Descending {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// afterCondition matches the Todos that are sorted after the query's
+// cursor: those past it on the first sort key, or tied on the first key
+// and past it on the second, and so on
+func afterCondition(query *domain.TodoQuery) (string, []interface{}) {
+	keys := query.SortKeys()
+	var alternatives []string
+	var args []interface{}
+	for i, key := range keys {
+		var conjuncts []string
+		for _, tied := range keys[:i] {
+			conjuncts = append(conjuncts, sortExpressions[tied.Field]+" = ?")
+			args = append(args, sortValue(tied.Field, query.After))
+		}
+		if key.Descending {
+			conjuncts = append(conjuncts, sortExpressions[key.Field]+" < ?")
+		} else {
+			conjuncts = append(conjuncts, sortExpressions[key.Field]+" > ?")
+		}
+		args = append(args, sortValue(key.Field, query.After))
+		alternatives = append(alternatives, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func toNullableID(id *domain.TodoID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+func toNullableListID(id *domain.TodoListID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+// Times are stored as UTC nanoseconds since the epoch, which keeps them
+// exact and cheap to compare in SQL
+
+func toNanos(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+func fromNanos(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(0, n.Int64).UTC()
+	return &t
+}
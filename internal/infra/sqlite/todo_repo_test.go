@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lloydmeta/todddo-openapi/internal/domain"
+	"github.com/lloydmeta/todddo-openapi/internal/domain/repotest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTodoRepoContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (domain.TodoRepo, func()) {
+		repo, err := Open(":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo, func() { _ = repo.(*repoImpl).db.Close() }
+	})
+}
+
+func TestTodoListRepoContract(t *testing.T) {
+	repotest.RunLists(t, func(t *testing.T) (domain.TodoListRepo, func()) {
+		repo, lists, err := OpenRepos(":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lists, func() { _ = repo.(*repoImpl).db.Close() }
+	})
+}
+
+func TestSurvivesReopening(t *testing.T) {
+	dir, err := ioutil.TempDir("", "todddo-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "todos.db")
+
+	first, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTodo := domain.NewTodo{Task: "remember me"}
+	created, _ := first.Create(&newTodo)
+	deleted, _ := first.Create(&domain.NewTodo{Task: "forget me"})
+	_, _ = first.Delete(&deleted.ID, 0)
+	_ = first.(*repoImpl).db.Close()
+
+	second, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved, err := second.Get(&created.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, created, retrieved)
+	// ids of deleted todos are not handed out again
+	next, _ := second.Create(&domain.NewTodo{Task: "brand new"})
+	assert.True(t, next.ID > deleted.ID)
+}
+
+func TestPositionsTodosFromBeforePositions(t *testing.T) {
+	db, err := sql.Open(DriverName, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	// the schema as it was just before positions were added
+	if err := migrateUpTo(db, 22); err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range []string{"first", "second"} {
+		if _, err := db.Exec("INSERT INTO todos (task) VALUES (?)", task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := MkRepo(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, _ := repo.Create(&domain.NewTodo{Task: "third"})
+	page, _ := repo.List(&domain.TodoQuery{Sort: []domain.SortKey{{Field: domain.SortByPosition}}})
+	var ids []domain.TodoID
+	for _, todo := range page.Todos {
+		assert.NotEmpty(t, todo.Position)
+		ids = append(ids, todo.ID)
+	}
+	assert.Equal(t, []domain.TodoID{1, 2, created.ID}, ids)
+}
+
+func TestIndexesTodosFromBeforeSearch(t *testing.T) {
+	db, err := sql.Open(DriverName, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	// the schema as it was just before todo_terms was added
+	if err := migrateUpTo(db, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO todos (task) VALUES ('Water the plants')"); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := MkRepo(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := repo.Search(&domain.TodoSearch{Text: "plant"})
+	assert.Nil(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Water the plants", results[0].Todo.Task)
+	}
+}
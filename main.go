@@ -1,6 +1,14 @@
 package main
 
 import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/lloydmeta/todddo-openapi/app"
@@ -22,18 +30,67 @@ import (
 // @host localhost:8080
 // @BasePath /
 func main() {
+	config, err := app.ConfigFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	components, err := app.MkDefaultComponents(config)
+	if err != nil {
+		panic(err)
+	}
 	g := gin.Default()
-	components := app.MkDefaultComponents()
-	todoRoutesHandler := routing.TodosRoutesHandler{Controller: components.Controllers.TodoController}
+	todoRoutesHandler := routing.TodosRoutesHandler{
+		Controller: components.Controllers.TodoController,
+		Events:     components.Controllers.TodoEventController,
+	}
 
 	todoRoutesHandler.RegisterRoutes(g)
+	listsRoutesHandler := routing.ListsRoutesHandler{Controller: components.Controllers.TodoListController}
+	listsRoutesHandler.RegisterRoutes(g)
+	socketRoutesHandler := routing.SocketRoutesHandler{
+		Controller: components.Controllers.TodoController,
+		Events:     components.Controllers.TodoEventController,
+	}
+	socketRoutesHandler.RegisterRoutes(g)
+	// the one handler for requests that match no route, which also serves
+	// the routes gin can't
+	g.NoRoute(routing.NoRoute(todoRoutesHandler.CustomRoutes()...))
 
 	g.Use(gzip.Gzip(gzip.BestSpeed))
 
 	// use ginSwagger middleware to serve the API docs
 	g.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	if err := g.Run(); err != nil {
-		panic(err)
+	server := &http.Server{Addr: address(), Handler: g}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	// event streams never go idle, so they get cut off once the time is up
+	if err := server.Shutdown(ctx); err != nil {
+		_ = server.Close()
+	}
+	if err := components.Close(); err != nil {
+		log.Printf("failed to close the components: %v", err)
+	}
+}
+
+// shutdownTimeout is how long requests under way get to finish once asked
+// to stop
+const shutdownTimeout = 10 * time.Second
+
+// address is where to listen, on the port in the PORT environment variable
+// like gin.Engine.Run does, or on 8080
+func address() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
 	}
+	return ":8080"
 }